@@ -12,11 +12,20 @@ import (
 
 	"github.com/joho/godotenv"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 var Pool *pgxpool.Pool
 
+// DBTX is satisfied by both the pool and a pgx transaction, so helpers
+// can run on their own or inside a caller's transaction
+type DBTX interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
 func InitDB(){
 	// Load environment variables from .env file
 	err := godotenv.Load()
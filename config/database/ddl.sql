@@ -1,10 +1,38 @@
 -- Drop tables in reverse order to avoid foreign key constraint issues
+DROP TABLE IF EXISTS Loyalty_Redemption_Lot;
+DROP TABLE IF EXISTS Audit_Event;
+DROP TABLE IF EXISTS Report_Schedule;
+DROP TABLE IF EXISTS Bundle_Component;
+DROP TABLE IF EXISTS Equipment_Loan;
+DROP TABLE IF EXISTS Stock_Reservation;
+DROP TABLE IF EXISTS Admin_Notification;
+DROP TABLE IF EXISTS Inventory_Movement;
+DROP TABLE IF EXISTS Wallet_Transfer;
+DROP TABLE IF EXISTS Time_Balance_Usage;
+DROP TABLE IF EXISTS Time_Balance;
+DROP TABLE IF EXISTS Membership_Usage;
+DROP TABLE IF EXISTS Membership_Allowance;
+DROP TABLE IF EXISTS Membership;
+DROP TABLE IF EXISTS Membership_Plan_Hours;
+DROP TABLE IF EXISTS Membership_Plan;
+DROP TABLE IF EXISTS Loyalty_Ledger;
+DROP TABLE IF EXISTS Loyalty_Reward;
+DROP TABLE IF EXISTS Loyalty_Earn_Rate;
+DROP TABLE IF EXISTS Voucher_Redemption;
+DROP TABLE IF EXISTS Transaction_Tax;
+DROP TABLE IF EXISTS Tax_Rule;
+DROP TABLE IF EXISTS Receipt;
+DROP SEQUENCE IF EXISTS invoice_number_seq;
+DROP TABLE IF EXISTS Payment_Reconciliation;
 DROP TABLE IF EXISTS Report;
 DROP TABLE IF EXISTS Rental_Services;
 DROP TABLE IF EXISTS Service;
 DROP TABLE IF EXISTS Log;
 DROP TABLE IF EXISTS Transaction;
+DROP TABLE IF EXISTS Voucher;
 DROP TABLE IF EXISTS Rental_History;
+DROP TABLE IF EXISTS Bundle;
+DROP TABLE IF EXISTS Shift;
 DROP TABLE IF EXISTS Admin;
 DROP TABLE IF EXISTS Computer;
 DROP TABLE IF EXISTS Customer;
@@ -72,12 +100,28 @@ CREATE TABLE Transaction (
     FOREIGN KEY (customer_id) REFERENCES Customer(id)
 );
 
--- 6. Log Table
-CREATE TABLE Log (
-    id SERIAL PRIMARY KEY,
-    description varchar(250) 
+-- 6. Audit_Event Table (who changed what, written in the same transaction as the change;
+-- replaces the free-text Log table)
+CREATE TABLE Audit_Event (
+    id BIGSERIAL PRIMARY KEY,
+    occurred_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    actor_type VARCHAR(20) NOT NULL CHECK (actor_type IN ('admin', 'customer', 'system')),
+    actor_id INTEGER,
+    action VARCHAR(100) NOT NULL,
+    entity_type VARCHAR(50) NOT NULL,
+    entity_id VARCHAR(100),
+    before_data JSONB,
+    after_data JSONB,
+    description TEXT NOT NULL DEFAULT '',
+    ip_address VARCHAR(45),
+    request_id VARCHAR(100)
 );
 
+CREATE INDEX idx_audit_event_occurred ON Audit_Event (occurred_at);
+CREATE INDEX idx_audit_event_actor ON Audit_Event (actor_type, actor_id, occurred_at);
+CREATE INDEX idx_audit_event_entity ON Audit_Event (entity_type, entity_id, occurred_at);
+CREATE INDEX idx_audit_event_action ON Audit_Event (action, occurred_at);
+
 -- 7. Service Table
 CREATE TABLE Service (
     id SERIAL PRIMARY KEY,
@@ -85,6 +129,7 @@ CREATE TABLE Service (
     price DOUBLE PRECISION NOT NULL,
     description VARCHAR(250),
     quantity INTEGER NOT NULL DEFAULT 0,
+    category VARCHAR(100) NOT NULL DEFAULT 'service',
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -94,9 +139,11 @@ CREATE TABLE Rental_Services (
     rental_history_id INTEGER,
     service_id INTEGER NOT NULL,
     quantity INTEGER DEFAULT 1,
+    transaction_id INTEGER,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (rental_history_id) REFERENCES Rental_History(id) ON DELETE CASCADE,
-    FOREIGN KEY (service_id) REFERENCES Service(id) ON DELETE CASCADE
+    FOREIGN KEY (service_id) REFERENCES Service(id) ON DELETE CASCADE,
+    FOREIGN KEY (transaction_id) REFERENCES Transaction(id)
 );
 
 -- 9. Report Table
@@ -110,13 +157,478 @@ CREATE TABLE Report (
     total_revenue DOUBLE PRECISION DEFAULT 0,
     total_rentals INTEGER DEFAULT 0,
     top_services VARCHAR(250),
+    total_tax DOUBLE PRECISION DEFAULT 0,
+    total_discount DOUBLE PRECISION DEFAULT 0,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (admin_id) REFERENCES Admin(id)
 );
 
+-- Saved reports keep their top services as JSON and their breakdowns in details, so
+-- they can be read back without recomputing them
+ALTER TABLE report ALTER COLUMN top_services TYPE TEXT;
+ALTER TABLE report ADD COLUMN details JSONB;
+CREATE INDEX idx_report_period ON report (report_type, start_date, end_date);
+
 -- to insert metadata column into transaction
 ALTER TABLE transaction ADD COLUMN metadata JSONB DEFAULT '{}'::JSONB;
 
+-- 10. Payment_Reconciliation Table (local vs gateway status per reconciled order)
+CREATE TABLE Payment_Reconciliation (
+    id SERIAL PRIMARY KEY,
+    order_id VARCHAR(100) NOT NULL,
+    local_status VARCHAR(100) NOT NULL,
+    gateway_status VARCHAR(100) NOT NULL,
+    action VARCHAR(100) NOT NULL,
+    checked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (order_id) REFERENCES Transaction(order_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_transaction_pending ON Transaction (status, transaction_date);
+
+-- 11. Shift Table (cash counter shifts per admin)
+CREATE TABLE Shift (
+    id SERIAL PRIMARY KEY,
+    admin_id INTEGER NOT NULL,
+    opened_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    closed_at TIMESTAMP,
+    opening_float DOUBLE PRECISION NOT NULL DEFAULT 0,
+    expected_cash DOUBLE PRECISION,
+    counted_cash DOUBLE PRECISION,
+    notes VARCHAR(250),
+    status VARCHAR(100) NOT NULL DEFAULT 'Open',
+    FOREIGN KEY (admin_id) REFERENCES Admin(id)
+);
+
+-- only one open shift per admin
+CREATE UNIQUE INDEX idx_shift_open_admin ON Shift (admin_id) WHERE status = 'Open';
+
+-- cash payments are recorded against the admin's open shift
+ALTER TABLE transaction ADD COLUMN shift_id INTEGER REFERENCES Shift(id);
+
+-- admin who took the order at the counter, whatever the payment method
+ALTER TABLE transaction ADD COLUMN admin_id INTEGER REFERENCES Admin(id);
+
+-- 12. Receipt Table (running invoice numbers for settled rentals and service purchases)
+CREATE SEQUENCE invoice_number_seq START 1;
+
+CREATE TABLE Receipt (
+    id SERIAL PRIMARY KEY,
+    invoice_number VARCHAR(20) UNIQUE NOT NULL,
+    customer_id INTEGER NOT NULL,
+    transaction_id INTEGER UNIQUE NOT NULL,
+    rental_history_id INTEGER,
+    order_id VARCHAR(100),
+    payment_method VARCHAR(100) NOT NULL,
+    total DOUBLE PRECISION NOT NULL,
+    reprint_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (customer_id) REFERENCES Customer(id),
+    FOREIGN KEY (transaction_id) REFERENCES Transaction(id),
+    FOREIGN KEY (rental_history_id) REFERENCES Rental_History(id)
+);
+
+-- 13. Tax_Rule Table (tax and service charge rates, optionally per category)
+CREATE TABLE Tax_Rule (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    rate DOUBLE PRECISION NOT NULL,
+    inclusive BOOLEAN NOT NULL DEFAULT FALSE,
+    category VARCHAR(100),
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 14. Transaction_Tax Table (tax charged per transaction, kept when rules change)
+CREATE TABLE Transaction_Tax (
+    id SERIAL PRIMARY KEY,
+    transaction_id INTEGER NOT NULL,
+    tax_rule_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    rate DOUBLE PRECISION NOT NULL,
+    inclusive BOOLEAN NOT NULL,
+    taxable_amount DOUBLE PRECISION NOT NULL,
+    tax_amount DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (transaction_id) REFERENCES Transaction(id) ON DELETE CASCADE,
+    FOREIGN KEY (tax_rule_id) REFERENCES Tax_Rule(id)
+);
+
+-- total tax contained in the transaction amount
+ALTER TABLE transaction ADD COLUMN tax_amount DOUBLE PRECISION DEFAULT 0;
+
+-- 15. Voucher Table (promo codes with percentage or fixed discounts)
+CREATE TABLE Voucher (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(50) UNIQUE NOT NULL,
+    discount_type VARCHAR(20) NOT NULL CHECK (discount_type IN ('percentage', 'fixed')),
+    discount_value DOUBLE PRECISION NOT NULL,
+    max_discount DOUBLE PRECISION,
+    min_spend DOUBLE PRECISION NOT NULL DEFAULT 0,
+    valid_from TIMESTAMP NOT NULL,
+    valid_until TIMESTAMP NOT NULL,
+    usage_limit INTEGER,
+    per_customer_limit INTEGER,
+    used_count INTEGER NOT NULL DEFAULT 0,
+    computer_types TEXT[],
+    service_ids INTEGER[],
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 16. Voucher_Redemption Table (one row per discounted transaction, without one while the order is being placed)
+CREATE TABLE Voucher_Redemption (
+    id SERIAL PRIMARY KEY,
+    voucher_id INTEGER NOT NULL,
+    customer_id INTEGER NOT NULL,
+    transaction_id INTEGER UNIQUE,
+    discount_amount DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (voucher_id) REFERENCES Voucher(id),
+    FOREIGN KEY (customer_id) REFERENCES Customer(id),
+    FOREIGN KEY (transaction_id) REFERENCES Transaction(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_voucher_redemption_customer ON Voucher_Redemption (voucher_id, customer_id);
+
+-- discount given on the transaction amount, before tax
+ALTER TABLE transaction ADD COLUMN discount_amount DOUBLE PRECISION DEFAULT 0;
+ALTER TABLE transaction ADD COLUMN voucher_id INTEGER REFERENCES Voucher(id);
+
+-- 17. Loyalty_Earn_Rate Table (rupiah spent per point, by transaction type)
+CREATE TABLE Loyalty_Earn_Rate (
+    transaction_type VARCHAR(100) PRIMARY KEY,
+    rupiah_per_point DOUBLE PRECISION NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+-- 18. Loyalty_Reward Table (free hours or services points can be redeemed for)
+CREATE TABLE Loyalty_Reward (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    reward_type VARCHAR(20) NOT NULL CHECK (reward_type IN ('free_hours', 'free_service')),
+    points_cost INTEGER NOT NULL,
+    hours INTEGER NOT NULL DEFAULT 0,
+    service_id INTEGER,
+    quantity INTEGER NOT NULL DEFAULT 0,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    FOREIGN KEY (service_id) REFERENCES Service(id)
+);
+
+-- 19. Loyalty_Ledger Table (points earned, redeemed, refunded and expired)
+CREATE TABLE Loyalty_Ledger (
+    id SERIAL PRIMARY KEY,
+    customer_id INTEGER NOT NULL,
+    transaction_id INTEGER,
+    entry_type VARCHAR(20) NOT NULL CHECK (entry_type IN ('earn', 'redeem', 'refund', 'expire')),
+    points INTEGER NOT NULL,
+    remaining INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP,
+    description VARCHAR(250),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (customer_id) REFERENCES Customer(id),
+    FOREIGN KEY (transaction_id) REFERENCES Transaction(id)
+);
+
+-- a transaction earns points once
+CREATE UNIQUE INDEX idx_loyalty_ledger_earn ON Loyalty_Ledger (transaction_id) WHERE entry_type = 'earn';
+CREATE INDEX idx_loyalty_ledger_customer ON Loyalty_Ledger (customer_id, expires_at);
+
+-- discount paid for with loyalty points, before tax
+ALTER TABLE transaction ADD COLUMN loyalty_discount DOUBLE PRECISION DEFAULT 0;
+
+-- 20. Membership_Plan Table (passes sold for a price and duration)
+CREATE TABLE Membership_Plan (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    price DOUBLE PRECISION NOT NULL,
+    duration_days INTEGER NOT NULL,
+    discount_percent DOUBLE PRECISION NOT NULL DEFAULT 0,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 21. Membership_Plan_Hours Table (included hours per computer type, NULL for unlimited)
+CREATE TABLE Membership_Plan_Hours (
+    plan_id INTEGER NOT NULL,
+    computer_type VARCHAR(100) NOT NULL,
+    included_hours INTEGER,
+    PRIMARY KEY (plan_id, computer_type),
+    FOREIGN KEY (plan_id) REFERENCES Membership_Plan(id) ON DELETE CASCADE
+);
+
+-- 22. Membership Table (a customer's paid plan period)
+CREATE TABLE Membership (
+    id SERIAL PRIMARY KEY,
+    customer_id INTEGER NOT NULL,
+    plan_id INTEGER NOT NULL,
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'Active',
+    auto_renew BOOLEAN NOT NULL DEFAULT FALSE,
+    transaction_id INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (customer_id) REFERENCES Customer(id),
+    FOREIGN KEY (plan_id) REFERENCES Membership_Plan(id),
+    FOREIGN KEY (transaction_id) REFERENCES Transaction(id)
+);
+
+-- only one active membership per customer
+CREATE UNIQUE INDEX idx_membership_active_customer ON Membership (customer_id) WHERE status = 'Active';
+
+-- 23. Membership_Allowance Table (included hours left in a membership period)
+CREATE TABLE Membership_Allowance (
+    membership_id INTEGER NOT NULL,
+    computer_type VARCHAR(100) NOT NULL,
+    remaining_hours INTEGER,
+    PRIMARY KEY (membership_id, computer_type),
+    FOREIGN KEY (membership_id) REFERENCES Membership(id) ON DELETE CASCADE
+);
+
+-- 24. Membership_Usage Table (hours and discount a membership gave a transaction)
+CREATE TABLE Membership_Usage (
+    id SERIAL PRIMARY KEY,
+    membership_id INTEGER NOT NULL,
+    transaction_id INTEGER UNIQUE,
+    computer_type VARCHAR(100),
+    hours INTEGER NOT NULL DEFAULT 0,
+    discount_amount DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (membership_id) REFERENCES Membership(id),
+    FOREIGN KEY (transaction_id) REFERENCES Transaction(id) ON DELETE CASCADE
+);
+
+-- included hours and member discount given on the transaction, before tax
+ALTER TABLE transaction ADD COLUMN membership_discount DOUBLE PRECISION DEFAULT 0;
+
+-- time packages are services that credit prepaid minutes, optionally for one computer type
+ALTER TABLE service ADD COLUMN product_type VARCHAR(20) NOT NULL DEFAULT 'item' CHECK (product_type IN ('item', 'time_package', 'loan'));
+ALTER TABLE service ADD COLUMN package_minutes INTEGER CHECK (package_minutes > 0);
+ALTER TABLE service ADD COLUMN package_computer_type VARCHAR(100);
+ALTER TABLE service ADD COLUMN package_validity_days INTEGER CHECK (package_validity_days > 0);
+
+-- 25. Time_Balance Table (prepaid minutes credited by one time package purchase)
+CREATE TABLE Time_Balance (
+    id SERIAL PRIMARY KEY,
+    customer_id INTEGER NOT NULL,
+    service_id INTEGER NOT NULL,
+    transaction_id INTEGER NOT NULL,
+    computer_type VARCHAR(100),
+    minutes_total INTEGER NOT NULL,
+    minutes_remaining INTEGER NOT NULL CHECK (minutes_remaining >= 0),
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (transaction_id, service_id),
+    FOREIGN KEY (customer_id) REFERENCES Customer(id) ON DELETE CASCADE,
+    FOREIGN KEY (service_id) REFERENCES Service(id),
+    FOREIGN KEY (transaction_id) REFERENCES Transaction(id)
+);
+
+CREATE INDEX idx_time_balance_customer ON Time_Balance (customer_id);
+
+-- 26. Time_Balance_Usage Table (prepaid minutes drawn by a rental)
+CREATE TABLE Time_Balance_Usage (
+    id SERIAL PRIMARY KEY,
+    time_balance_id INTEGER NOT NULL,
+    transaction_id INTEGER,
+    minutes INTEGER NOT NULL CHECK (minutes > 0),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (time_balance_id) REFERENCES Time_Balance(id) ON DELETE CASCADE,
+    FOREIGN KEY (transaction_id) REFERENCES Transaction(id) ON DELETE CASCADE
+);
+
+-- value of the computer time paid from prepaid minutes, before tax
+ALTER TABLE transaction ADD COLUMN time_credit DOUBLE PRECISION DEFAULT 0;
+
+-- 27. Wallet_Transfer Table (wallet balance sent between customers)
+CREATE TABLE Wallet_Transfer (
+    id SERIAL PRIMARY KEY,
+    sender_id INTEGER NOT NULL,
+    recipient_id INTEGER NOT NULL,
+    amount DOUBLE PRECISION NOT NULL CHECK (amount > 0),
+    note VARCHAR(250),
+    status VARCHAR(20) NOT NULL CHECK (status IN ('Pending', 'Completed')),
+    expires_at TIMESTAMP,
+    out_transaction_id INTEGER,
+    in_transaction_id INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP,
+    CHECK (sender_id <> recipient_id),
+    FOREIGN KEY (sender_id) REFERENCES Customer(id),
+    FOREIGN KEY (recipient_id) REFERENCES Customer(id),
+    FOREIGN KEY (out_transaction_id) REFERENCES Transaction(id),
+    FOREIGN KEY (in_transaction_id) REFERENCES Transaction(id)
+);
+
+CREATE INDEX idx_wallet_transfer_sender ON Wallet_Transfer (sender_id, completed_at);
+
+-- deactivated services stay in the catalog but are no longer sold
+ALTER TABLE service ADD COLUMN is_active BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- stock level at which admins are notified to reorder, none if NULL
+ALTER TABLE service ADD COLUMN reorder_threshold INTEGER CHECK (reorder_threshold >= 0);
+
+-- stock can never go negative, sales decrement it conditionally
+ALTER TABLE service ADD CONSTRAINT service_quantity_non_negative CHECK (quantity >= 0);
+
+-- 28. Inventory_Movement Table (ledger of every change to service stock)
+CREATE TABLE Inventory_Movement (
+    id SERIAL PRIMARY KEY,
+    service_id INTEGER NOT NULL,
+    movement_type VARCHAR(20) NOT NULL CHECK (movement_type IN ('sale', 'restock', 'return', 'adjustment', 'reservation', 'loan')),
+    quantity INTEGER NOT NULL,
+    quantity_after INTEGER NOT NULL CHECK (quantity_after >= 0),
+    reason VARCHAR(250),
+    transaction_id INTEGER,
+    admin_id INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (service_id) REFERENCES Service(id) ON DELETE CASCADE,
+    FOREIGN KEY (transaction_id) REFERENCES Transaction(id),
+    FOREIGN KEY (admin_id) REFERENCES Admin(id)
+);
+
+CREATE INDEX idx_inventory_movement_service ON Inventory_Movement (service_id, created_at);
+CREATE INDEX idx_inventory_movement_transaction ON Inventory_Movement (transaction_id);
+
+-- 29. Admin_Notification Table (alerts for admins such as low stock)
+CREATE TABLE Admin_Notification (
+    id SERIAL PRIMARY KEY,
+    notification_type VARCHAR(50) NOT NULL,
+    service_id INTEGER,
+    message VARCHAR(500) NOT NULL,
+    read_by INTEGER,
+    read_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (service_id) REFERENCES Service(id) ON DELETE CASCADE,
+    FOREIGN KEY (read_by) REFERENCES Admin(id)
+);
+
+CREATE INDEX idx_admin_notification_unread ON Admin_Notification (created_at) WHERE read_at IS NULL;
+
+-- 30. Stock_Reservation Table (stock held for unpaid GoPay orders until they settle or expire)
+CREATE TABLE Stock_Reservation (
+    id SERIAL PRIMARY KEY,
+    service_id INTEGER NOT NULL,
+    transaction_id INTEGER,
+    movement_id INTEGER,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    status VARCHAR(20) NOT NULL CHECK (status IN ('Held', 'Converted', 'Released')),
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    closed_at TIMESTAMP,
+    FOREIGN KEY (service_id) REFERENCES Service(id) ON DELETE CASCADE,
+    FOREIGN KEY (transaction_id) REFERENCES Transaction(id) ON DELETE CASCADE,
+    FOREIGN KEY (movement_id) REFERENCES Inventory_Movement(id)
+);
+
+CREATE INDEX idx_stock_reservation_held ON Stock_Reservation (expires_at) WHERE status = 'Held';
+CREATE INDEX idx_stock_reservation_transaction ON Stock_Reservation (transaction_id);
+
+-- charge per item when loaned equipment is lost, NULL for other products
+ALTER TABLE service ADD COLUMN loss_charge DOUBLE PRECISION CHECK (loss_charge >= 0);
+
+-- 31. Equipment_Loan Table (equipment lent out with a rental until it is returned)
+CREATE TABLE Equipment_Loan (
+    id SERIAL PRIMARY KEY,
+    rental_services_id INTEGER NOT NULL UNIQUE,
+    service_id INTEGER NOT NULL,
+    rental_history_id INTEGER NOT NULL,
+    customer_id INTEGER NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    status VARCHAR(20) NOT NULL CHECK (status IN ('Out', 'Overdue', 'Returned', 'Damaged', 'Lost')),
+    checked_out_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    due_at TIMESTAMP NOT NULL,
+    returned_at TIMESTAMP,
+    charge_amount DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK (charge_amount >= 0),
+    charged_amount DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK (charged_amount >= 0 AND charged_amount <= charge_amount),
+    charge_transaction_id INTEGER,
+    returned_by INTEGER,
+    notes VARCHAR(250),
+    FOREIGN KEY (rental_services_id) REFERENCES Rental_Services(id) ON DELETE CASCADE,
+    FOREIGN KEY (service_id) REFERENCES Service(id) ON DELETE CASCADE,
+    FOREIGN KEY (rental_history_id) REFERENCES Rental_History(id) ON DELETE CASCADE,
+    FOREIGN KEY (customer_id) REFERENCES Customer(id),
+    FOREIGN KEY (charge_transaction_id) REFERENCES Transaction(id),
+    FOREIGN KEY (returned_by) REFERENCES Admin(id)
+);
+
+CREATE INDEX idx_equipment_loan_open ON Equipment_Loan (due_at) WHERE status IN ('Out', 'Overdue');
+CREATE INDEX idx_equipment_loan_rental ON Equipment_Loan (rental_history_id);
+
+-- 32. Bundle Table (combos of computer time and services at a bundle price)
+CREATE TABLE Bundle (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    description VARCHAR(250),
+    price DOUBLE PRECISION NOT NULL CHECK (price > 0),
+    computer_type VARCHAR(50),
+    hours INTEGER NOT NULL DEFAULT 0 CHECK (hours >= 0),
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 33. Bundle_Component Table (services included in a bundle)
+CREATE TABLE Bundle_Component (
+    bundle_id INTEGER NOT NULL,
+    service_id INTEGER NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (bundle_id, service_id),
+    FOREIGN KEY (bundle_id) REFERENCES Bundle(id) ON DELETE CASCADE,
+    FOREIGN KEY (service_id) REFERENCES Service(id) ON DELETE CASCADE
+);
+
+-- the bundle a rental was sold with, its computer time allocation is part of total_cost
+ALTER TABLE rental_history ADD COLUMN bundle_id INTEGER REFERENCES Bundle(id);
+
+-- services sold in a bundle carry the share of the bundle price allocated to them
+ALTER TABLE rental_services ADD COLUMN bundle_id INTEGER REFERENCES Bundle(id);
+ALTER TABLE rental_services ADD COLUMN bundle_amount DOUBLE PRECISION CHECK (bundle_amount >= 0);
+
+-- prices charged at the time of sale, so later price changes do not rewrite history:
+-- the unit price, the membership, voucher and loyalty discount on the line, and the
+-- line total after discount and before tax
+ALTER TABLE rental_services ADD COLUMN unit_price DOUBLE PRECISION;
+ALTER TABLE rental_services ADD COLUMN discount DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE rental_services ADD COLUMN line_total DOUBLE PRECISION;
+
+-- the same for the computer time of a rental, unit_price is the hourly rate
+ALTER TABLE rental_history ADD COLUMN unit_price DOUBLE PRECISION;
+ALTER TABLE rental_history ADD COLUMN discount DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE rental_history ADD COLUMN line_total DOUBLE PRECISION;
+
+-- 34. Report_Schedule Table (reports generated and delivered automatically)
+-- run times are instants, worked out in the schedule's timezone
+CREATE TABLE Report_Schedule (
+    id SERIAL PRIMARY KEY,
+    report_type VARCHAR(100) NOT NULL,
+    frequency VARCHAR(20) NOT NULL CHECK (frequency IN ('daily', 'weekly', 'monthly')),
+    timezone VARCHAR(64) NOT NULL DEFAULT 'Asia/Jakarta',
+    run_hour INTEGER NOT NULL DEFAULT 7 CHECK (run_hour BETWEEN 0 AND 23),
+    format VARCHAR(10) NOT NULL DEFAULT 'xlsx' CHECK (format IN ('csv', 'xlsx')),
+    recipients TEXT[] NOT NULL DEFAULT '{}',
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMPTZ NOT NULL,
+    last_run_at TIMESTAMPTZ,
+    last_report_id INTEGER,
+    last_error VARCHAR(500),
+    created_by INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (last_report_id) REFERENCES Report(id) ON DELETE SET NULL,
+    FOREIGN KEY (created_by) REFERENCES Admin(id)
+);
+
+CREATE INDEX idx_report_schedule_due ON Report_Schedule (next_run_at) WHERE is_active;
+
+-- 35. Loyalty_Redemption_Lot Table (points a redemption drew from each earned lot, so a
+-- refund gives them back to the same lots with their original expiry)
+CREATE TABLE Loyalty_Redemption_Lot (
+    redeem_entry_id INTEGER NOT NULL,
+    lot_id INTEGER NOT NULL,
+    points INTEGER NOT NULL,
+    PRIMARY KEY (redeem_entry_id, lot_id),
+    FOREIGN KEY (redeem_entry_id) REFERENCES Loyalty_Ledger(id) ON DELETE CASCADE,
+    FOREIGN KEY (lot_id) REFERENCES Loyalty_Ledger(id)
+);
+
 -- Insert customer data
 INSERT INTO Customer (name, username, email, password, wallet)
 VALUES 
@@ -155,19 +667,83 @@ VALUES
 ('admin2', 'hashed_admin_password_2', 'Operator');
 
 -- Insert service table
-INSERT INTO Service (name, price, description, quantity)
+INSERT INTO Service (name, price, description, quantity, category)
+VALUES 
+('Printing', 2500, 'Black and white printing per page', 100, 'service'),
+('Snacks', 5000, 'Pack of chips or cookies', 50, 'food'),
+('Drinks', 3000, 'Cold or hot beverages', 75, 'food'),
+('Photocopying', 1500, 'Black and white photocopy per page', 200, 'service'),
+('Laminating', 5000, 'Laminating service per page', 30, 'service'),
+('Binding', 10000, 'Binding service for documents', 20, 'service'),
+('Internet Access', 2000, 'Access to high-speed internet per hour', 150, 'service'),
+('USB Drive', 50000, '16GB USB Drive for sale', 10, 'goods'),
+('Headphones', 15000, 'Headphones for rental', 20, 'equipment'),
+('Keyboard', 10000, 'Keyboard for rental', 15, 'equipment'),
+('Mouse', 5000, 'Mouse for rental', 25, 'equipment');
+
+-- Insert time packages
+INSERT INTO Service (name, price, description, quantity, category, product_type, package_minutes, package_computer_type, package_validity_days)
+VALUES
+('Office 5 Hours', 40000, '5 Office hours for the price of 4', 1000, 'computer_time', 'time_package', 300, 'Office', 90),
+('Any PC 5 Hours', 100000, '5 hours on any computer', 1000, 'computer_time', 'time_package', 300, NULL, 60),
+('Gaming 10 Hours', 160000, '10 Gaming hours for the price of 8', 1000, 'computer_time', 'time_package', 600, 'Gaming', 90);
+
+-- Reorder thresholds for consumables and goods
+UPDATE Service SET reorder_threshold = 10 WHERE name IN ('Snacks', 'Drinks');
+UPDATE Service SET reorder_threshold = 3 WHERE name = 'USB Drive';
+
+-- Equipment is lent for a rental and returned, lost items are charged
+UPDATE Service SET product_type = 'loan', loss_charge = 250000 WHERE name = 'Headphones';
+UPDATE Service SET product_type = 'loan', loss_charge = 200000 WHERE name = 'Keyboard';
+UPDATE Service SET product_type = 'loan', loss_charge = 100000 WHERE name = 'Mouse';
+
+-- Insert bundles
+INSERT INTO Bundle (name, description, price, computer_type, hours)
+VALUES
+('Gaming 3h + Drinks + Snacks', '3 Gaming hours with 2 drinks and a snack', 60000, 'Gaming', 3),
+('Office 2h + Printing', '2 Office hours with 20 printed pages', 35000, 'Office', 2);
+
+INSERT INTO Bundle_Component (bundle_id, service_id, quantity)
+VALUES
+(1, 3, 2),
+(1, 2, 1),
+(2, 1, 20);
+
+-- Insert tax rules: PPN on everything, service charge on food and drinks
+INSERT INTO Tax_Rule (name, rate, inclusive, category)
+VALUES 
+('PPN', 11, FALSE, NULL),
+('Service Charge', 5, FALSE, 'food');
+
+-- Insert vouchers
+INSERT INTO Voucher (code, discount_type, discount_value, max_discount, min_spend, valid_from, valid_until, usage_limit, per_customer_limit, computer_types, service_ids)
 VALUES 
-('Printing', 2500, 'Black and white printing per page', 100),
-('Snacks', 5000, 'Pack of chips or cookies', 50),
-('Drinks', 3000, 'Cold or hot beverages', 75),
-('Photocopying', 1500, 'Black and white photocopy per page', 200),
-('Laminating', 5000, 'Laminating service per page', 30),
-('Binding', 10000, 'Binding service for documents', 20),
-('Internet Access', 2000, 'Access to high-speed internet per hour', 150),
-('USB Drive', 50000, '16GB USB Drive for sale', 10),
-('Headphones', 15000, 'Headphones for rental', 20),
-('Keyboard', 10000, 'Keyboard for rental', 15),
-('Mouse', 5000, 'Mouse for rental', 25);
+('WELCOME10', 'percentage', 10, 20000, 0, '2024-12-01 00:00:00', '2030-12-31 23:59:59', NULL, 1, NULL, NULL),
+('GAMING5K', 'fixed', 5000, NULL, 40000, '2024-12-01 00:00:00', '2030-12-31 23:59:59', 100, 3, ARRAY['Gaming'], NULL);
+
+-- Insert loyalty earn rates and rewards
+INSERT INTO Loyalty_Earn_Rate (transaction_type, rupiah_per_point)
+VALUES 
+('Rental Payment', 1000),
+('Service Payment', 2000);
+
+INSERT INTO Loyalty_Reward (name, reward_type, points_cost, hours, service_id, quantity)
+VALUES 
+('1 Free Hour', 'free_hours', 100, 1, NULL, 0),
+('3 Free Hours', 'free_hours', 250, 3, NULL, 0),
+('Free Drink', 'free_service', 40, 0, 3, 1),
+('Free Snack', 'free_service', 60, 0, 2, 1);
+
+-- Insert membership plans
+INSERT INTO Membership_Plan (name, price, duration_days, discount_percent)
+VALUES 
+('Unlimited Office Monthly', 300000, 30, 0),
+('Gaming 20 Hours', 350000, 30, 10);
+
+INSERT INTO Membership_Plan_Hours (plan_id, computer_type, included_hours)
+VALUES 
+(1, 'Office', NULL),
+(2, 'Gaming', 20);
 
 -- Insert rental_history table
 INSERT INTO Rental_History (customer_id, computer_id, admin_id, rental_start_time, rental_end_time, total_cost)
@@ -194,4 +770,20 @@ VALUES
 INSERT INTO Report (admin_id, report_type, start_date, end_date, total_transactions, total_revenue, total_rentals, top_services)
 VALUES 
 (1, 'Daily Revenue Report', '2024-12-18 00:00:00', '2024-12-18 23:59:59', 3, 340000, 2, 'Printing, Snacks'),
-(2, 'Service Usage Report', '2024-12-18 00:00:00', '2024-12-18 23:59:59', 0, 0, 0, 'Snacks, Drinks');
\ No newline at end of file
+(2, 'Service Usage Report', '2024-12-18 00:00:00', '2024-12-18 23:59:59', 0, 0, 0, 'Snacks, Drinks');
+
+-- Backfill the prices of rows recorded before they were stored, at the list prices
+-- they were sold at as far as is known, then require them on new rows
+UPDATE rental_services rs
+SET unit_price = COALESCE(rs.bundle_amount / NULLIF(rs.quantity, 0), s.price),
+    line_total = COALESCE(rs.bundle_amount, rs.quantity * s.price)
+FROM service s
+WHERE s.id = rs.service_id AND rs.line_total IS NULL;
+
+UPDATE rental_history rh
+SET unit_price = c.hourly_rate, line_total = rh.total_cost
+FROM computer c
+WHERE c.id = rh.computer_id AND rh.line_total IS NULL;
+
+ALTER TABLE rental_services ALTER COLUMN unit_price SET NOT NULL, ALTER COLUMN line_total SET NOT NULL;
+ALTER TABLE rental_history ALTER COLUMN unit_price SET NOT NULL, ALTER COLUMN line_total SET NOT NULL;
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// Actor types of audit events
+const (
+	ActorAdmin    = "admin"
+	ActorCustomer = "customer"
+	ActorSystem   = "system" // background jobs and payment gateway callbacks
+)
+
+// Event is a change recorded in the audit log: who did what to which entity, with the
+// entity as it was before and after where there is one
+type Event struct {
+	ID          int64       `json:"id"`
+	OccurredAt  time.Time   `json:"occurred_at"`
+	ActorType   string      `json:"actor_type"`
+	ActorID     *int        `json:"actor_id"`
+	Action      string      `json:"action"` // entity.verb, such as service.update
+	EntityType  string      `json:"entity_type"`
+	EntityID    *string     `json:"entity_id"`
+	Before      interface{} `json:"before"`
+	After       interface{} `json:"after"`
+	Description string      `json:"description"`
+	IP          *string     `json:"ip"`
+	RequestID   *string     `json:"request_id"`
+}
+
+// FromRequest starts an event for a change made by a request, with the admin or customer
+// of its token as the actor, and the client IP and request ID
+func FromRequest(c echo.Context, action, entityType string, entityID interface{}) Event {
+	e := New(ActorSystem, nil, action, entityType, entityID)
+	if user, ok := c.Get("user").(*jwt.Token); ok {
+		if claims, ok := user.Claims.(jwt.MapClaims); ok {
+			if id, ok := claims["admin_id"].(float64); ok {
+				e.ActorType, e.ActorID = ActorAdmin, intPtr(int(id))
+			} else if id, ok := claims["customer_id"].(float64); ok {
+				e.ActorType, e.ActorID = ActorCustomer, intPtr(int(id))
+			}
+		}
+	}
+	if ip := c.RealIP(); ip != "" {
+		e.IP = &ip
+	}
+	requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+	if requestID == "" {
+		requestID = c.Request().Header.Get(echo.HeaderXRequestID)
+	}
+	if requestID != "" {
+		e.RequestID = &requestID
+	}
+	return e
+}
+
+// New starts an event for a change made outside a request, such as by a background job
+func New(actorType string, actorID *int, action, entityType string, entityID interface{}) Event {
+	e := Event{ActorType: actorType, ActorID: actorID, Action: action, EntityType: entityType}
+	if entityID != nil {
+		id := fmt.Sprint(entityID)
+		e.EntityID = &id
+	}
+	return e
+}
+
+// As sets the actor of an event, for requests made before the actor has a token, such as
+// registering or logging in
+func (e Event) As(actorType string, actorID int) Event {
+	e.ActorType, e.ActorID = actorType, intPtr(actorID)
+	return e
+}
+
+// Describe sets the readable sentence of an event
+func (e Event) Describe(format string, args ...interface{}) Event {
+	e.Description = fmt.Sprintf(format, args...)
+	return e
+}
+
+// Change sets the entity as it was before and after the change; either may be nil
+func (e Event) Change(before, after interface{}) Event {
+	e.Before, e.After = before, after
+	return e
+}
+
+// Record writes an event. Pass the transaction making the change, so the event is only
+// kept if the change is.
+func Record(ctx context.Context, db config.DBTX, e Event) error {
+	before, err := marshalState(e.Before)
+	if err != nil {
+		return err
+	}
+	after, err := marshalState(e.After)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO audit_event (actor_type, actor_id, action, entity_type, entity_id, before_data, after_data,
+		                         description, ip_address, request_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	_, err = db.Exec(ctx, query, e.ActorType, e.ActorID, e.Action, e.EntityType, e.EntityID, before, after,
+		e.Description, e.IP, e.RequestID)
+	if err != nil {
+		return fmt.Errorf("failed to record %s audit event: %w", e.Action, err)
+	}
+	return nil
+}
+
+// marshalState encodes the before or after state of an event, NULL if there is none
+func marshalState(state interface{}) ([]byte, error) {
+	if state == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit state: %w", err)
+	}
+	return encoded, nil
+}
+
+func intPtr(value int) *int {
+	return &value
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// SearchAuditEvents godoc
+// @Summary Search the audit log
+// @Description Allows super-admins to search audit events by actor, action, entity, request and time, newest first
+// @Tags Audit
+// @Produce json
+// @Param actor_type query string false "admin, customer or system"
+// @Param actor_id query int false "Admin or customer ID"
+// @Param action query string false "Action, such as service.update; a trailing * matches a prefix, such as service.*"
+// @Param entity_type query string false "Entity type, such as service"
+// @Param entity_id query string false "Entity ID"
+// @Param request_id query string false "Request ID"
+// @Param q query string false "Text in the description"
+// @Param start_date query string false "From date (YYYY-MM-DD)"
+// @Param end_date query string false "To date (YYYY-MM-DD), inclusive"
+// @Param limit query int false "Number of events (default 50, up to 500)"
+// @Param offset query int false "Events to skip"
+// @Success 200 {object} map[string]interface{} "Audit events retrieved successfully"
+// @Failure 400 {object} map[string]string "Invalid filter"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/audit-events [get]
+func SearchAuditEvents(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	adminRole, _ := claims["role"].(string)
+	if adminRole != "super-admin" {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can view the audit log."})
+	}
+
+	actorType := c.QueryParam("actor_type")
+	switch actorType {
+	case "", ActorAdmin, ActorCustomer, ActorSystem:
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "actor_type must be admin, customer or system"})
+	}
+
+	var actorID *int
+	if value := c.QueryParam("actor_id"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid actor_id"})
+		}
+		actorID = &parsed
+	}
+
+	var startDate, endDate *time.Time
+	for param, target := range map[string]**time.Time{"start_date": &startDate, "end_date": &endDate} {
+		if value := c.QueryParam(param); value != "" {
+			parsed, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid " + param + ", use YYYY-MM-DD"})
+			}
+			*target = &parsed
+		}
+	}
+
+	limit, offset := 50, 0
+	if value := c.QueryParam("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > 500 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "limit must be between 1 and 500"})
+		}
+		limit = parsed
+	}
+	if value := c.QueryParam("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid offset"})
+		}
+		offset = parsed
+	}
+
+	query := `
+		SELECT id, occurred_at, actor_type, actor_id, action, entity_type, entity_id, before_data, after_data,
+		       description, ip_address, request_id, COUNT(*) OVER ()
+		FROM audit_event
+		WHERE ($1 = '' OR actor_type = $1)
+		  AND ($2::INT IS NULL OR actor_id = $2)
+		  AND ($3 = '' OR action = $3 OR (RIGHT($3, 1) = '*' AND action LIKE RTRIM($3, '*') || '%'))
+		  AND ($4 = '' OR entity_type = $4)
+		  AND ($5 = '' OR entity_id = $5)
+		  AND ($6 = '' OR request_id = $6)
+		  AND ($7 = '' OR description ILIKE '%' || $7 || '%')
+		  AND ($8::DATE IS NULL OR occurred_at >= $8::DATE)
+		  AND ($9::DATE IS NULL OR occurred_at < $9::DATE + 1)
+		ORDER BY occurred_at DESC, id DESC
+		LIMIT $10 OFFSET $11`
+	rows, err := config.Pool.Query(context.Background(), query, actorType, actorID, c.QueryParam("action"),
+		c.QueryParam("entity_type"), c.QueryParam("entity_id"), c.QueryParam("request_id"), c.QueryParam("q"),
+		startDate, endDate, limit, offset)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch audit events"})
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	total := 0
+	for rows.Next() {
+		var e Event
+		var before, after []byte
+		if err := rows.Scan(&e.ID, &e.OccurredAt, &e.ActorType, &e.ActorID, &e.Action, &e.EntityType, &e.EntityID,
+			&before, &after, &e.Description, &e.IP, &e.RequestID, &total); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to parse audit events"})
+		}
+		if before != nil {
+			e.Before = json.RawMessage(before)
+		}
+		if after != nil {
+			e.After = json.RawMessage(after)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to parse audit events"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Audit events retrieved successfully",
+		"data":    events,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	config "w4/p2/milestones/config/database"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchAuditEvents(t *testing.T) {
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	event := New(ActorAdmin, intPtr(1), "service.update", "service", 7).
+		Change(map[string]float64{"price": 5000}, map[string]float64{"price": 6000}).
+		Describe("Super-admin (ID: 1) updated service 7")
+	assert.NoError(t, Record(ctx, tx, event))
+	assert.NoError(t, Record(ctx, tx, New(ActorSystem, nil, "membership.expired", "membership", 3).Describe("Membership 3 of Customer 2 expired")))
+	assert.NoError(t, tx.Commit(ctx))
+	defer config.Pool.Exec(ctx, `DELETE FROM audit_event WHERE entity_type IN ('service', 'membership') AND entity_id IN ('7', '3')`)
+
+	e := echo.New()
+	search := func(query string, claims jwt.MapClaims) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/admin/audit-events?"+query, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("user", jwt.NewWithClaims(jwt.SigningMethodHS256, claims))
+		assert.NoError(t, SearchAuditEvents(c))
+		return rec
+	}
+	superAdmin := jwt.MapClaims{"admin_id": float64(1), "role": "super-admin"}
+
+	rec := search("action=service.*&entity_id=7", superAdmin)
+	if assert.Equal(t, http.StatusOK, rec.Code) {
+		var response struct {
+			Data  []Event `json:"data"`
+			Total int     `json:"total"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		if assert.Equal(t, 1, response.Total) {
+			found := response.Data[0]
+			assert.Equal(t, "service.update", found.Action)
+			assert.Equal(t, ActorAdmin, found.ActorType)
+			assert.Equal(t, 1, *found.ActorID)
+			assert.Equal(t, map[string]interface{}{"price": float64(6000)}, found.After)
+		}
+	}
+
+	rec = search("actor_type=system&q=expired", superAdmin)
+	if assert.Equal(t, http.StatusOK, rec.Code) {
+		var response struct {
+			Data []Event `json:"data"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		if assert.NotEmpty(t, response.Data) {
+			assert.Nil(t, response.Data[0].ActorID)
+			assert.Nil(t, response.Data[0].Before)
+		}
+	}
+
+	rec = search("actor_type=robot", superAdmin)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// Only super-admins can read the audit log
+	rec = search("", jwt.MapClaims{"admin_id": float64(2), "role": "admin"})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestFromRequest(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPut, "/admin/services/7", nil)
+	req.Header.Set(echo.HeaderXRealIP, "10.0.0.5")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Response().Header().Set(echo.HeaderXRequestID, "req-123")
+	c.Set("user", jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"admin_id": float64(4),
+		"role":     "admin",
+	}))
+
+	event := FromRequest(c, "service.update", "service", 7)
+	assert.Equal(t, ActorAdmin, event.ActorType)
+	assert.Equal(t, 4, *event.ActorID)
+	assert.Equal(t, "7", *event.EntityID)
+	assert.Equal(t, "10.0.0.5", *event.IP)
+	assert.Equal(t, "req-123", *event.RequestID)
+
+	// Customers act as themselves
+	c.Set("user", jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"customer_id": float64(9)}))
+	event = FromRequest(c, "wallet_transfer.complete", "wallet_transfer", 1)
+	assert.Equal(t, ActorCustomer, event.ActorType)
+	assert.Equal(t, 9, *event.ActorID)
+
+	// Requests without a token are made by the system until an actor is set
+	c = e.NewContext(httptest.NewRequest(http.MethodPost, "/customer/login", nil), httptest.NewRecorder())
+	event = FromRequest(c, "customer.login", "customer", 9)
+	assert.Equal(t, ActorSystem, event.ActorType)
+	assert.Nil(t, event.ActorID)
+	assert.Nil(t, event.RequestID)
+	event = event.As(ActorCustomer, 9)
+	assert.Equal(t, ActorCustomer, event.ActorType)
+	assert.Equal(t, 9, *event.ActorID)
+}
+
+func TestMarshalState(t *testing.T) {
+	state, err := marshalState(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, state)
+
+	state, err = marshalState(map[string]interface{}{"is_active": false})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"is_active": false}`, string(state))
+
+	_, err = marshalState(map[string]interface{}{"bad": make(chan int)})
+	assert.Error(t, err)
+}
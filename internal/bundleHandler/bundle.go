@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+)
+
+// Bundle is a combo of computer time and services sold at its own price
+type Bundle struct {
+	ID           int               `json:"id"`
+	Name         string            `json:"name"`
+	Description  string            `json:"description"`
+	Price        float64           `json:"price"`
+	ComputerType *string           `json:"computer_type"` // any computer if NULL
+	Hours        int               `json:"hours"`         // computer time included
+	IsActive     bool              `json:"is_active"`
+	Components   []BundleComponent `json:"components"`
+	CreatedAt    time.Time         `json:"created_at"`
+}
+
+// BundleComponent is a service included in a bundle
+type BundleComponent struct {
+	ServiceID   int     `json:"service_id"`
+	ServiceName string  `json:"service_name"`
+	Category    string  `json:"category"`
+	Quantity    int     `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"` // current list price, used to allocate the bundle price
+	InStock     int     `json:"in_stock"`
+}
+
+// BundleError explains why a bundle cannot be sold with a rental
+type BundleError struct {
+	Message string
+}
+
+func (e *BundleError) Error() string {
+	return e.Message
+}
+
+const bundleColumns = `id, name, COALESCE(description, ''), price, computer_type, hours, is_active, created_at`
+
+// LoadBundle fetches a bundle with its components, or pgx.ErrNoRows
+func LoadBundle(ctx context.Context, db config.DBTX, bundleID int) (Bundle, error) {
+	var b Bundle
+	err := db.QueryRow(ctx, `SELECT `+bundleColumns+` FROM bundle WHERE id = $1`, bundleID).Scan(
+		&b.ID, &b.Name, &b.Description, &b.Price, &b.ComputerType, &b.Hours, &b.IsActive, &b.CreatedAt,
+	)
+	if err != nil {
+		return b, err
+	}
+	b.Components, err = loadComponents(ctx, db, bundleID)
+	return b, err
+}
+
+func loadComponents(ctx context.Context, db config.DBTX, bundleID int) ([]BundleComponent, error) {
+	query := `
+		SELECT bc.service_id, s.name, s.category, bc.quantity, s.price, s.quantity
+		FROM bundle_component bc
+		JOIN service s ON s.id = bc.service_id
+		WHERE bc.bundle_id = $1
+		ORDER BY bc.service_id`
+	rows, err := db.Query(ctx, query, bundleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch bundle components: %w", err)
+	}
+	defer rows.Close()
+
+	components := []BundleComponent{}
+	for rows.Next() {
+		var component BundleComponent
+		if err := rows.Scan(&component.ServiceID, &component.ServiceName, &component.Category, &component.Quantity, &component.UnitPrice, &component.InStock); err != nil {
+			return nil, fmt.Errorf("failed to parse bundle component: %w", err)
+		}
+		components = append(components, component)
+	}
+	return components, rows.Err()
+}
+
+// AllocatePrice splits a bundle price across its parts in proportion to their list
+// values, in whole rupiah, so the allocations always add up to the price. The last
+// part takes the rounding remainder. Parts with no list value share the price equally.
+func AllocatePrice(price float64, listValues []float64) []float64 {
+	allocations := make([]float64, len(listValues))
+	if len(listValues) == 0 {
+		return allocations
+	}
+
+	var total float64
+	for _, value := range listValues {
+		total += value
+	}
+
+	var allocated float64
+	for i, value := range listValues[:len(listValues)-1] {
+		share := 1 / float64(len(listValues))
+		if total > 0 {
+			share = value / total
+		}
+		allocations[i] = math.Round(price * share)
+		allocated += allocations[i]
+	}
+	allocations[len(allocations)-1] = price - allocated
+	return allocations
+}
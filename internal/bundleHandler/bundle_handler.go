@@ -0,0 +1,374 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	config "w4/p2/milestones/config/database"
+	audit_handler "w4/p2/milestones/internal/auditHandler"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// BundleRequest defines the payload to create or edit a bundle
+type BundleRequest struct {
+	Name         string  `json:"name" validate:"required"`
+	Description  string  `json:"description"`
+	Price        float64 `json:"price" validate:"required"`
+	ComputerType *string `json:"computer_type"` // any computer if omitted
+	Hours        int     `json:"hours"`         // computer time included
+	Components   []struct {
+		ServiceID int `json:"service_id"`
+		Quantity  int `json:"quantity"`
+	} `json:"components"`
+	IsActive *bool `json:"is_active"`
+}
+
+// normalize trims the fields and checks the ones that need no database
+func (req *BundleRequest) normalize() string {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.ComputerType != nil && strings.TrimSpace(*req.ComputerType) == "" {
+		req.ComputerType = nil
+	}
+
+	seen := map[int]bool{}
+	for _, component := range req.Components {
+		if component.Quantity <= 0 {
+			return "Component quantity must be greater than zero"
+		}
+		if seen[component.ServiceID] {
+			return "Each service can only be listed once"
+		}
+		seen[component.ServiceID] = true
+	}
+
+	switch {
+	case req.Name == "":
+		return "Bundle name is required"
+	case req.Price <= 0:
+		return "Price must be greater than zero"
+	case len(req.Description) > 250:
+		return "Description cannot exceed 250 characters"
+	case req.Hours < 0:
+		return "Hours cannot be negative"
+	case req.Hours == 0 && req.ComputerType != nil:
+		return "A computer type needs hours of computer time"
+	case req.Hours == 0 && len(req.Components) < 2:
+		return "A bundle needs computer time or at least two services"
+	}
+	return ""
+}
+
+// requireAdmin returns the admin ID and role if the JWT belongs to an admin or super-admin
+func requireAdmin(c echo.Context) (int, string, bool) {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	adminRole, _ := claims["role"].(string)
+	adminID, _ := claims["admin_id"].(float64)
+	return int(adminID), adminRole, adminRole == "admin" || adminRole == "super-admin"
+}
+
+// saveComponents replaces a bundle's components. Only stocked items can be bundled,
+// not time packages or loaned equipment.
+func saveComponents(ctx context.Context, tx pgx.Tx, bundleID int, req BundleRequest) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM bundle_component WHERE bundle_id = $1`, bundleID); err != nil {
+		return fmt.Errorf("failed to clear bundle components: %w", err)
+	}
+
+	for _, component := range req.Components {
+		var productType string
+		var isActive bool
+		err := tx.QueryRow(ctx, `SELECT product_type, is_active FROM service WHERE id = $1`, component.ServiceID).Scan(&productType, &isActive)
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && !isActive) {
+			return &BundleError{Message: fmt.Sprintf("Invalid service ID %d", component.ServiceID)}
+		} else if err != nil {
+			return fmt.Errorf("failed to fetch service %d: %w", component.ServiceID, err)
+		}
+		if productType != "item" {
+			return &BundleError{Message: fmt.Sprintf("Service ID %d cannot be bundled, only items can", component.ServiceID)}
+		}
+
+		query := `INSERT INTO bundle_component (bundle_id, service_id, quantity) VALUES ($1, $2, $3)`
+		if _, err := tx.Exec(ctx, query, bundleID, component.ServiceID, component.Quantity); err != nil {
+			return fmt.Errorf("failed to save bundle component: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadBundles fetches the bundles with their components, active ones only if asked
+func loadBundles(ctx context.Context, activeOnly bool) ([]Bundle, error) {
+	query := `SELECT ` + bundleColumns + ` FROM bundle WHERE ($1 = FALSE OR is_active = TRUE) ORDER BY price, id`
+	rows, err := config.Pool.Query(ctx, query, activeOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch bundles: %w", err)
+	}
+
+	bundles := []Bundle{}
+	for rows.Next() {
+		var b Bundle
+		if err := rows.Scan(&b.ID, &b.Name, &b.Description, &b.Price, &b.ComputerType, &b.Hours, &b.IsActive, &b.CreatedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to parse bundle: %w", err)
+		}
+		bundles = append(bundles, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range bundles {
+		bundles[i].Components, err = loadComponents(ctx, config.Pool, bundles[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bundles, nil
+}
+
+// GetBundleMenu godoc
+// @Summary Get the bundle menu
+// @Description List the combos on sale with their computer time, services and bundle price
+// @Tags Bundles
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Bundles retrieved successfully"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /customer/bundles [get]
+func GetBundleMenu(c echo.Context) error {
+	bundles, err := loadBundles(context.Background(), true)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch bundles"})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Bundles retrieved successfully",
+		"data":    bundles,
+	})
+}
+
+// GetBundles godoc
+// @Summary List bundles
+// @Description Retrieve every bundle with its components, including deactivated ones
+// @Tags Bundles
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Bundles retrieved successfully"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/bundles [get]
+func GetBundles(c echo.Context) error {
+	if _, _, ok := requireAdmin(c); !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only selected admins can perform this action."})
+	}
+
+	bundles, err := loadBundles(context.Background(), false)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch bundles"})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Bundles retrieved successfully",
+		"data":    bundles,
+	})
+}
+
+// CreateBundle godoc
+// @Summary Create a bundle
+// @Description Add a combo of computer time and services sold at a bundle price. Its price is allocated across the parts by list value when sold.
+// @Tags Bundles
+// @Accept json
+// @Produce json
+// @Param request body BundleRequest true "Bundle"
+// @Success 200 {object} Bundle "Created bundle"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/bundles [post]
+func CreateBundle(c echo.Context) error {
+	adminID, role, ok := requireAdmin(c)
+	if !ok || role != "super-admin" {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can manage bundles."})
+	}
+
+	var req BundleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+	if msg := req.normalize(); msg != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": msg})
+	}
+
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	var bundleID int
+	query := `
+		INSERT INTO bundle (name, description, price, computer_type, hours, is_active)
+		VALUES ($1, $2, $3, $4, $5, COALESCE($6, TRUE)) RETURNING id`
+	err = tx.QueryRow(ctx, query, req.Name, req.Description, req.Price, req.ComputerType, req.Hours, req.IsActive).Scan(&bundleID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create bundle"})
+	}
+
+	return saveBundle(c, tx, adminID, bundleID, req, nil)
+}
+
+// UpdateBundle godoc
+// @Summary Edit a bundle
+// @Description Change a bundle's name, price, computer time, components or active state. Past sales keep the revenue allocated when they were sold.
+// @Tags Bundles
+// @Accept json
+// @Produce json
+// @Param id path int true "Bundle ID"
+// @Param request body BundleRequest true "Bundle"
+// @Success 200 {object} Bundle "Updated bundle"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Bundle not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/bundles/{id} [put]
+func UpdateBundle(c echo.Context) error {
+	adminID, role, ok := requireAdmin(c)
+	if !ok || role != "super-admin" {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can manage bundles."})
+	}
+
+	bundleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid bundle ID"})
+	}
+
+	var req BundleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+	if msg := req.normalize(); msg != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": msg})
+	}
+
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT id FROM bundle WHERE id = $1 FOR UPDATE`, bundleID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch bundle"})
+	}
+	before, err := LoadBundle(ctx, tx, bundleID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Bundle not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch bundle"})
+	}
+
+	query := `
+		UPDATE bundle
+		SET name = $1, description = $2, price = $3, computer_type = $4, hours = $5, is_active = COALESCE($6, is_active)
+		WHERE id = $7`
+	if _, err := tx.Exec(ctx, query, req.Name, req.Description, req.Price, req.ComputerType, req.Hours, req.IsActive, bundleID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update bundle"})
+	}
+
+	return saveBundle(c, tx, adminID, bundleID, req, &before)
+}
+
+// saveBundle stores the components of a created or edited bundle, logs it and commits.
+// before is the bundle as it was before an edit, nil for a new one.
+func saveBundle(c echo.Context, tx pgx.Tx, adminID int, bundleID int, req BundleRequest, before *Bundle) error {
+	ctx := context.Background()
+	err := saveComponents(ctx, tx, bundleID, req)
+	var bundleErr *BundleError
+	if errors.As(err, &bundleErr) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": bundleErr.Message})
+	} else if err != nil {
+		fmt.Println("Bundle error:", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to save bundle components"})
+	}
+
+	bundle, err := LoadBundle(ctx, tx, bundleID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch bundle"})
+	}
+
+	event := audit_handler.FromRequest(c, "bundle.create", "bundle", bundleID).Change(nil, bundle).
+		Describe("Super-admin (ID: %d) created bundle %d (%s) at %.0f", adminID, bundleID, req.Name, req.Price)
+	if before != nil {
+		event = audit_handler.FromRequest(c, "bundle.update", "bundle", bundleID).Change(*before, bundle).
+			Describe("Super-admin (ID: %d) updated bundle %d (%s) at %.0f", adminID, bundleID, req.Name, req.Price)
+	}
+	if err := audit_handler.Record(ctx, tx, event); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log admin action"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to save bundle"})
+	}
+	return c.JSON(http.StatusOK, bundle)
+}
+
+// DeactivateBundle godoc
+// @Summary Deactivate a bundle
+// @Description Take a bundle off sale. It stays in past sales and can be reactivated by editing it.
+// @Tags Bundles
+// @Produce json
+// @Param id path int true "Bundle ID"
+// @Success 200 {object} map[string]string "Bundle deactivated"
+// @Failure 400 {object} map[string]string "Invalid bundle ID"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Bundle not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/bundles/{id} [delete]
+func DeactivateBundle(c echo.Context) error {
+	adminID, role, ok := requireAdmin(c)
+	if !ok || role != "super-admin" {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can manage bundles."})
+	}
+
+	bundleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid bundle ID"})
+	}
+
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	var wasActive bool
+	err = tx.QueryRow(ctx, `SELECT is_active FROM bundle WHERE id = $1 FOR UPDATE`, bundleID).Scan(&wasActive)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Bundle not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch bundle"})
+	}
+	if _, err := tx.Exec(ctx, `UPDATE bundle SET is_active = FALSE WHERE id = $1`, bundleID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to deactivate bundle"})
+	}
+
+	event := audit_handler.FromRequest(c, "bundle.deactivate", "bundle", bundleID).
+		Change(map[string]bool{"is_active": wasActive}, map[string]bool{"is_active": false}).
+		Describe("Super-admin (ID: %d) deactivated bundle %d", adminID, bundleID)
+	if err := audit_handler.Record(ctx, tx, event); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log admin action"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to deactivate bundle"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Bundle deactivated successfully"})
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetBundleMenu(t *testing.T) {
+	// Setup Echo, the menu needs no login
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/customer/bundles", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := GetBundleMenu(c)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response struct {
+			Message string   `json:"message"`
+			Data    []Bundle `json:"data"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.Equal(t, "Bundles retrieved successfully", response.Message)
+		for _, bundle := range response.Data {
+			assert.True(t, bundle.IsActive)
+		}
+	}
+}
+
+func TestAllocatePrice(t *testing.T) {
+	// 3 Gaming hours at 20000, 2 drinks at 3000 and a snack at 5000 sold for 60000
+	allocations := AllocatePrice(60000, []float64{60000, 6000, 5000})
+	assert.Equal(t, []float64{50704, 5070, 4226}, allocations)
+
+	var total float64
+	for _, amount := range allocations {
+		total += amount
+	}
+	assert.Equal(t, 60000.0, total)
+
+	// Parts without a list value share the price equally
+	assert.Equal(t, []float64{5000, 5000}, AllocatePrice(10000, []float64{0, 0}))
+	assert.Empty(t, AllocatePrice(10000, nil))
+}
@@ -0,0 +1,244 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+	inventory_handler "w4/p2/milestones/internal/inventoryHandler"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// dashboardTTL is how long a dashboard is served from memory before it is read again
+const dashboardTTL = 5 * time.Second
+
+// endingSoonWindow is how far ahead sessions are listed as ending soon
+const endingSoonWindow = 15 * time.Minute
+
+// recentEventCount is how many audit events the dashboard shows
+const recentEventCount = 10
+
+// Dashboard is the current state of the floor
+type Dashboard struct {
+	GeneratedAt     time.Time                        `json:"generated_at"`
+	Cached          bool                             `json:"cached"`
+	Computers       []ComputerStatus                 `json:"computers"`
+	ActiveSessions  int                              `json:"active_sessions"`
+	EndingSoon      []Session                        `json:"ending_soon"`
+	Today           TodayRevenue                     `json:"today"`
+	PendingPayments PendingPayments                  `json:"pending_payments"`
+	LowStock        []inventory_handler.LowStockItem `json:"low_stock"`
+	RecentEvents    []RecentEvent                    `json:"recent_events"`
+}
+
+// ComputerStatus is how many computers of a type are in use. A computer is in use while a
+// rental covers the current time.
+type ComputerStatus struct {
+	Type  string `json:"type"`
+	Total int    `json:"total"`
+	InUse int    `json:"in_use"`
+	Free  int    `json:"free"`
+}
+
+// Session is a rental running now
+type Session struct {
+	RentalID         int       `json:"rental_id"`
+	ComputerID       int       `json:"computer_id"`
+	ComputerName     string    `json:"computer_name"`
+	ComputerType     string    `json:"computer_type"`
+	CustomerID       int       `json:"customer_id"`
+	CustomerUsername string    `json:"customer_username"`
+	RentalEnd        time.Time `json:"rental_end"`
+	MinutesLeft      int       `json:"minutes_left"`
+}
+
+// TodayRevenue is the settled revenue since midnight, transfers excluded
+type TodayRevenue struct {
+	Revenue      float64 `json:"revenue"`
+	Transactions int     `json:"transactions"`
+}
+
+// PendingPayments are gateway orders still waiting for payment
+type PendingPayments struct {
+	Count  int        `json:"count"`
+	Amount float64    `json:"amount"`
+	Oldest *time.Time `json:"oldest"`
+}
+
+// RecentEvent is an entry of the audit log, without the before and after state
+type RecentEvent struct {
+	ID          int64     `json:"id"`
+	OccurredAt  time.Time `json:"occurred_at"`
+	ActorType   string    `json:"actor_type"`
+	ActorID     *int      `json:"actor_id"`
+	Action      string    `json:"action"`
+	EntityType  string    `json:"entity_type"`
+	EntityID    *string   `json:"entity_id"`
+	Description string    `json:"description"`
+}
+
+// dashboardCache keeps the last dashboard. Its lock is held while a dashboard is built,
+// so pollers arriving together wait for one read instead of each querying.
+var dashboardCache = struct {
+	sync.Mutex
+	dashboard *Dashboard
+}{}
+
+// cachedDashboard returns the cached dashboard while it is younger than dashboardTTL,
+// and otherwise builds and caches a new one
+func cachedDashboard(ctx context.Context, now time.Time, build func(context.Context, time.Time) (Dashboard, error)) (Dashboard, error) {
+	dashboardCache.Lock()
+	defer dashboardCache.Unlock()
+
+	if d := dashboardCache.dashboard; d != nil && now.Sub(d.GeneratedAt) < dashboardTTL {
+		cached := *d
+		cached.Cached = true
+		return cached, nil
+	}
+
+	d, err := build(ctx, now)
+	if err != nil {
+		return d, err
+	}
+	dashboardCache.dashboard = &d
+	return d, nil
+}
+
+// BuildDashboard reads the current state of the floor from the database
+func BuildDashboard(ctx context.Context, now time.Time) (Dashboard, error) {
+	d := Dashboard{GeneratedAt: now}
+
+	// Computers of each type, and those with a rental running now
+	computerQuery := `
+		SELECT c.type, COUNT(*)::INT,
+		       COUNT(*) FILTER (WHERE EXISTS (
+		           SELECT 1 FROM rental_history rh
+		           WHERE rh.computer_id = c.id AND rh.rental_start_time <= LOCALTIMESTAMP AND rh.rental_end_time > LOCALTIMESTAMP
+		       ))::INT
+		FROM computer c
+		GROUP BY c.type
+		ORDER BY c.type`
+	rows, err := config.Pool.Query(ctx, computerQuery)
+	if err != nil {
+		return d, fmt.Errorf("failed to fetch computers: %w", err)
+	}
+	d.Computers = []ComputerStatus{}
+	for rows.Next() {
+		var s ComputerStatus
+		if err := rows.Scan(&s.Type, &s.Total, &s.InUse); err != nil {
+			rows.Close()
+			return d, fmt.Errorf("failed to parse computers: %w", err)
+		}
+		s.Free = s.Total - s.InUse
+		d.ActiveSessions += s.InUse
+		d.Computers = append(d.Computers, s)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return d, fmt.Errorf("failed to read computers: %w", err)
+	}
+	rows.Close()
+
+	// Sessions ending within the window, soonest first
+	sessionQuery := `
+		SELECT rh.id, rh.computer_id, c.name, c.type, rh.customer_id, cu.username, rh.rental_end_time,
+		       CEIL(EXTRACT(EPOCH FROM rh.rental_end_time - LOCALTIMESTAMP) / 60)::INT
+		FROM rental_history rh
+		JOIN computer c ON c.id = rh.computer_id
+		JOIN customer cu ON cu.id = rh.customer_id
+		WHERE rh.rental_start_time <= LOCALTIMESTAMP AND rh.rental_end_time > LOCALTIMESTAMP
+		  AND rh.rental_end_time <= LOCALTIMESTAMP + $1 * INTERVAL '1 second'
+		ORDER BY rh.rental_end_time, rh.id`
+	rows, err = config.Pool.Query(ctx, sessionQuery, endingSoonWindow.Seconds())
+	if err != nil {
+		return d, fmt.Errorf("failed to fetch sessions ending soon: %w", err)
+	}
+	d.EndingSoon = []Session{}
+	for rows.Next() {
+		var s Session
+		if err := rows.Scan(&s.RentalID, &s.ComputerID, &s.ComputerName, &s.ComputerType, &s.CustomerID, &s.CustomerUsername,
+			&s.RentalEnd, &s.MinutesLeft); err != nil {
+			rows.Close()
+			return d, fmt.Errorf("failed to parse sessions ending soon: %w", err)
+		}
+		d.EndingSoon = append(d.EndingSoon, s)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return d, fmt.Errorf("failed to read sessions ending soon: %w", err)
+	}
+	rows.Close()
+
+	// Revenue so far today and pending gateway orders
+	moneyQuery := `
+		SELECT COALESCE(SUM(amount) FILTER (WHERE status ILIKE 'Settlement' AND transaction_date >= date_trunc('day', LOCALTIMESTAMP)
+		                                      AND transaction_type NOT IN ('Transfer Out', 'Transfer In')), 0)::DOUBLE PRECISION,
+		       COUNT(*) FILTER (WHERE status ILIKE 'Settlement' AND transaction_date >= date_trunc('day', LOCALTIMESTAMP)
+		                          AND transaction_type NOT IN ('Transfer Out', 'Transfer In'))::INT,
+		       COUNT(*) FILTER (WHERE status ILIKE 'pending' AND order_id IS NOT NULL)::INT,
+		       COALESCE(SUM(amount) FILTER (WHERE status ILIKE 'pending' AND order_id IS NOT NULL), 0)::DOUBLE PRECISION,
+		       MIN(transaction_date) FILTER (WHERE status ILIKE 'pending' AND order_id IS NOT NULL)
+		FROM transaction
+		WHERE transaction_date >= date_trunc('day', LOCALTIMESTAMP) OR status ILIKE 'pending'`
+	err = config.Pool.QueryRow(ctx, moneyQuery).Scan(&d.Today.Revenue, &d.Today.Transactions,
+		&d.PendingPayments.Count, &d.PendingPayments.Amount, &d.PendingPayments.Oldest)
+	if err != nil {
+		return d, fmt.Errorf("failed to fetch revenue: %w", err)
+	}
+
+	d.LowStock, err = inventory_handler.LowStock(ctx, config.Pool)
+	if err != nil {
+		return d, err
+	}
+
+	eventQuery := `
+		SELECT id, occurred_at, actor_type, actor_id, action, entity_type, entity_id, description
+		FROM audit_event
+		ORDER BY occurred_at DESC, id DESC
+		LIMIT $1`
+	rows, err = config.Pool.Query(ctx, eventQuery, recentEventCount)
+	if err != nil {
+		return d, fmt.Errorf("failed to fetch audit events: %w", err)
+	}
+	defer rows.Close()
+	d.RecentEvents = []RecentEvent{}
+	for rows.Next() {
+		var e RecentEvent
+		if err := rows.Scan(&e.ID, &e.OccurredAt, &e.ActorType, &e.ActorID, &e.Action, &e.EntityType, &e.EntityID, &e.Description); err != nil {
+			return d, fmt.Errorf("failed to parse audit events: %w", err)
+		}
+		d.RecentEvents = append(d.RecentEvents, e)
+	}
+	return d, rows.Err()
+}
+
+// GetDashboard godoc
+// @Summary Live admin dashboard
+// @Description Computers in use and free by type, sessions ending in the next 15 minutes, revenue so far today, pending gateway payments, low-stock services and recent audit events. Served from a cache refreshed every 5 seconds, so it can be polled.
+// @Tags Dashboard
+// @Produce json
+// @Success 200 {object} Dashboard
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/dashboard [get]
+func GetDashboard(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	adminRole, _ := claims["role"].(string)
+	if adminRole != "admin" && adminRole != "super-admin" {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only selected admins can perform this action."})
+	}
+
+	d, err := cachedDashboard(context.Background(), time.Now(), BuildDashboard)
+	if err != nil {
+		fmt.Println("Dashboard error:", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch dashboard"})
+	}
+	return c.JSON(http.StatusOK, d)
+}
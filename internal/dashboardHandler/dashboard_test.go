@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDashboard(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/admin/dashboard", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user", jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"admin_id": float64(1),
+		"role":     "admin",
+	}))
+
+	if assert.NoError(t, GetDashboard(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var d Dashboard
+		json.Unmarshal(rec.Body.Bytes(), &d)
+		assert.NotEmpty(t, d.Computers)
+		for _, s := range d.Computers {
+			assert.Equal(t, s.Total, s.InUse+s.Free)
+		}
+	}
+
+	// Customers cannot see the dashboard
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.Set("user", jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"customer_id": float64(1),
+	}))
+	if assert.NoError(t, GetDashboard(c)) {
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	}
+}
+
+func TestCachedDashboard(t *testing.T) {
+	dashboardCache.dashboard = nil
+	builds := 0
+	build := func(ctx context.Context, now time.Time) (Dashboard, error) {
+		builds++
+		return Dashboard{GeneratedAt: now, ActiveSessions: builds}, nil
+	}
+	start := time.Date(2024, 12, 18, 10, 0, 0, 0, time.UTC)
+
+	d, err := cachedDashboard(context.Background(), start, build)
+	assert.NoError(t, err)
+	assert.False(t, d.Cached)
+
+	// Polls within the TTL are served from memory
+	d, _ = cachedDashboard(context.Background(), start.Add(dashboardTTL-time.Millisecond), build)
+	assert.True(t, d.Cached)
+	assert.Equal(t, 1, d.ActiveSessions)
+	assert.Equal(t, 1, builds)
+
+	d, _ = cachedDashboard(context.Background(), start.Add(dashboardTTL), build)
+	assert.False(t, d.Cached)
+	assert.Equal(t, 2, d.ActiveSessions)
+
+	// A failed read leaves the cached dashboard in place
+	_, err = cachedDashboard(context.Background(), start.Add(3*dashboardTTL), func(context.Context, time.Time) (Dashboard, error) {
+		return Dashboard{}, errors.New("database down")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 2, dashboardCache.dashboard.ActiveSessions)
+	dashboardCache.dashboard = nil
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// csvFlushEvery is how many rows are buffered before they are sent to the client
+const csvFlushEvery = 100
+
+type csvWriter struct {
+	dst     io.Writer
+	start   func() // sends the download headers, if any
+	flush   func()
+	columns []Column
+	headers []string
+	lang    string
+	out     *csv.Writer
+	rows    int
+}
+
+// begin starts the file and writes the header row
+func (w *csvWriter) begin() error {
+	if w.out != nil {
+		return nil
+	}
+	w.start()
+	w.out = csv.NewWriter(w.dst)
+	// Spreadsheet programs read the file as UTF-8 when it starts with a byte order mark
+	if _, err := w.dst.Write([]byte("\xEF\xBB\xBF")); err != nil {
+		return err
+	}
+	return w.out.Write(w.headers)
+}
+
+func (w *csvWriter) WriteRow(values ...interface{}) error {
+	if err := w.begin(); err != nil {
+		return err
+	}
+
+	record := make([]string, len(w.columns))
+	for i := range record {
+		if i < len(values) {
+			record[i] = formatText(values[i], w.columns[i].Kind, w.lang)
+		}
+	}
+	if err := w.out.Write(record); err != nil {
+		return err
+	}
+
+	w.rows++
+	if w.rows%csvFlushEvery == 0 {
+		w.out.Flush()
+		w.flush()
+		return w.out.Error()
+	}
+	return nil
+}
+
+func (w *csvWriter) Close() error {
+	if err := w.begin(); err != nil {
+		return err
+	}
+	w.out.Flush()
+	w.flush()
+	return w.out.Error()
+}
@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Export formats
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// Content types of the export formats
+const (
+	MIMECSV  = "text/csv"
+	MIMEXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// Header languages
+const (
+	LangEnglish    = "en"
+	LangIndonesian = "id"
+)
+
+// Column kinds decide how a value is formatted
+const (
+	KindText     = "text"
+	KindNumber   = "number"
+	KindCurrency = "currency" // rupiah
+	KindDate     = "date"     // date and time
+)
+
+// Column is a column of an export with its header in each language
+type Column struct {
+	English    string
+	Indonesian string
+	Kind       string
+}
+
+// Header is the column header in a language, English by default
+func (col Column) Header(lang string) string {
+	if lang == LangIndonesian && col.Indonesian != "" {
+		return col.Indonesian
+	}
+	return col.English
+}
+
+// Writer streams the rows of an export to the response or a file
+type Writer interface {
+	// WriteRow writes one row, with a value per column. Nil values are left empty.
+	WriteRow(values ...interface{}) error
+	// Close finishes the file and flushes what is left of it
+	Close() error
+}
+
+// RequestedFormat is the export format asked for through the format query parameter,
+// or else the Accept header. Responses are JSON unless a spreadsheet was asked for.
+func RequestedFormat(c echo.Context) (string, error) {
+	if format := strings.ToLower(strings.TrimSpace(c.QueryParam("format"))); format != "" {
+		switch format {
+		case FormatJSON, FormatCSV, FormatXLSX:
+			return format, nil
+		}
+		return "", fmt.Errorf("format must be json, csv or xlsx")
+	}
+
+	for _, accepted := range strings.Split(c.Request().Header.Get(echo.HeaderAccept), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case MIMECSV:
+			return FormatCSV, nil
+		case MIMEXLSX:
+			return FormatXLSX, nil
+		}
+	}
+	return FormatJSON, nil
+}
+
+// RequestedLang is the language for column headers, from the lang query parameter or
+// else the Accept-Language header
+func RequestedLang(c echo.Context) string {
+	lang := c.QueryParam("lang")
+	if lang == "" {
+		lang = c.Request().Header.Get("Accept-Language")
+	}
+	if strings.HasPrefix(strings.ToLower(strings.TrimSpace(lang)), LangIndonesian) {
+		return LangIndonesian
+	}
+	return LangEnglish
+}
+
+// NewWriter starts an export download named after filename, without extension, and
+// writes its header row. Nothing is sent before the first row is written or the writer
+// is closed, so errors found before then can still be returned as JSON.
+func NewWriter(c echo.Context, format, filename string, columns []Column) (Writer, error) {
+	res := c.Response()
+	start := func() {
+		res.Header().Set(echo.HeaderContentType, ContentType(format))
+		res.Header().Set(echo.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": filename + "." + format}))
+		res.WriteHeader(http.StatusOK)
+	}
+	return newWriter(res, format, columns, RequestedLang(c), start, res.Flush)
+}
+
+// NewFileWriter writes an export to dst, such as a file or an attachment buffer
+func NewFileWriter(dst io.Writer, format string, columns []Column, lang string) (Writer, error) {
+	return newWriter(dst, format, columns, lang, func() {}, func() {})
+}
+
+func newWriter(dst io.Writer, format string, columns []Column, lang string, start, flush func()) (Writer, error) {
+	headers := make([]string, len(columns))
+	for i, col := range columns {
+		headers[i] = col.Header(lang)
+	}
+
+	switch format {
+	case FormatCSV:
+		return &csvWriter{dst: dst, start: start, flush: flush, columns: columns, headers: headers, lang: lang}, nil
+	case FormatXLSX:
+		return &xlsxWriter{dst: dst, start: start, flush: flush, columns: columns, headers: headers}, nil
+	}
+	return nil, fmt.Errorf("unsupported export format %q", format)
+}
+
+// ContentType is the content type of an export format
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return MIMECSV + "; charset=utf-8"
+	case FormatXLSX:
+		return MIMEXLSX
+	}
+	return echo.MIMEApplicationJSON
+}
+
+// FormatCurrency formats a rupiah amount in whole rupiah, with the thousands separator
+// of the language: Rp 1.250.000 in Indonesian and Rp 1,250,000 in English
+func FormatCurrency(amount float64, lang string) string {
+	separator := ","
+	if lang == LangIndonesian {
+		separator = "."
+	}
+
+	digits := strconv.FormatFloat(math.Abs(math.Round(amount)), 'f', 0, 64)
+	var grouped strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped.WriteString(separator)
+		}
+		grouped.WriteRune(digit)
+	}
+
+	if math.Round(amount) < 0 {
+		return "-Rp " + grouped.String()
+	}
+	return "Rp " + grouped.String()
+}
+
+// dateLayout is how dates are written in CSV exports
+const dateLayout = "2006-01-02 15:04:05"
+
+// formatText writes a value as text for a column, dereferencing pointers
+func formatText(value interface{}, kind string, lang string) string {
+	value = deref(value)
+	if value == nil {
+		return ""
+	}
+
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(dateLayout)
+	case string:
+		return v
+	}
+
+	if number, ok := toFloat(value); ok {
+		if kind == KindCurrency {
+			return FormatCurrency(number, lang)
+		}
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// deref returns what a pointer points to, or nil for a nil pointer
+func deref(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *string:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *int:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *float64:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return *v
+	}
+	return value
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
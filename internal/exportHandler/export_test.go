@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var testColumns = []Column{
+	{English: "Name", Indonesian: "Nama", Kind: KindText},
+	{English: "Date", Indonesian: "Tanggal", Kind: KindDate},
+	{English: "Amount", Indonesian: "Jumlah", Kind: KindCurrency},
+}
+
+func TestFormatCurrency(t *testing.T) {
+	assert.Equal(t, "Rp 1.250.000", FormatCurrency(1250000, LangIndonesian))
+	assert.Equal(t, "Rp 1,250,000", FormatCurrency(1250000, LangEnglish))
+	assert.Equal(t, "Rp 999", FormatCurrency(999.4, LangEnglish))
+	assert.Equal(t, "-Rp 15.000", FormatCurrency(-15000, LangIndonesian))
+	assert.Equal(t, "Rp 0", FormatCurrency(0, LangIndonesian))
+}
+
+func TestRequestedFormat(t *testing.T) {
+	e := echo.New()
+	cases := []struct {
+		url, accept, want string
+	}{
+		{"/export", "", FormatJSON},
+		{"/export?format=CSV", "", FormatCSV},
+		{"/export", "text/csv", FormatCSV},
+		{"/export", "application/json, " + MIMEXLSX + ";q=0.9", FormatXLSX},
+		{"/export?format=json", "text/csv", FormatJSON},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+		req.Header.Set(echo.HeaderAccept, tc.accept)
+		format, err := RequestedFormat(e.NewContext(req, httptest.NewRecorder()))
+		if assert.NoError(t, err, tc.url) {
+			assert.Equal(t, tc.want, format, tc.url)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/export?format=pdf", nil)
+	_, err := RequestedFormat(e.NewContext(req, httptest.NewRecorder()))
+	assert.Error(t, err)
+}
+
+func TestCSVWriter(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/export?lang=id", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	w, err := NewWriter(c, FormatCSV, "test", testColumns)
+	if assert.NoError(t, err) {
+		var missing *string
+		assert.NoError(t, w.WriteRow("Printing, color", time.Date(2024, 12, 18, 10, 30, 0, 0, time.UTC), 15000))
+		assert.NoError(t, w.WriteRow(missing, nil, 2500.0))
+		assert.NoError(t, w.Close())
+	}
+
+	assert.Equal(t, `attachment; filename=test.csv`, rec.Header().Get(echo.HeaderContentDisposition))
+	assert.Equal(t, "\xEF\xBB\xBFNama,Tanggal,Jumlah\n\"Printing, color\",2024-12-18 10:30:00,Rp 15.000\n,,Rp 2.500\n", rec.Body.String())
+}
+
+func TestXLSXWriter(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/export", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	w, err := NewWriter(c, FormatXLSX, "test", testColumns)
+	if assert.NoError(t, err) {
+		assert.NoError(t, w.WriteRow("Snacks & <Drinks>", time.Date(2024, 12, 18, 12, 0, 0, 0, time.UTC), 15000.5))
+		assert.NoError(t, w.Close())
+	}
+	assert.Equal(t, MIMEXLSX, rec.Header().Get(echo.HeaderContentType))
+
+	archive, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	var sheet string
+	for _, f := range archive.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			r, _ := f.Open()
+			content, _ := io.ReadAll(r)
+			sheet = string(content)
+		}
+	}
+	assert.True(t, strings.Contains(sheet, `<c r="A1" s="3" t="inlineStr"><is><t xml:space="preserve">Name</t></is></c>`), sheet)
+	assert.True(t, strings.Contains(sheet, `Snacks &amp; &lt;Drinks&gt;`), sheet)
+	assert.True(t, strings.Contains(sheet, `<c r="B2" s="2"><v>45644.5</v></c>`), sheet)
+	assert.True(t, strings.Contains(sheet, `<c r="C2" s="1"><v>15000.5</v></c>`), sheet)
+}
+
+func TestColumnName(t *testing.T) {
+	assert.Equal(t, "A", ColumnName(0))
+	assert.Equal(t, "Z", ColumnName(25))
+	assert.Equal(t, "AA", ColumnName(26))
+	assert.Equal(t, "AZ", ColumnName(51))
+	assert.Equal(t, "BA", ColumnName(52))
+}
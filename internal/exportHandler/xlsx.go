@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Cell styles defined in xlsxStyles
+const (
+	styleDefault  = 0
+	styleCurrency = 1
+	styleDate     = 2
+	styleHeader   = 3
+)
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+const xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Export" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`
+
+// xlsxStyles keeps amounts numeric so they can be summed, shown in rupiah
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="2"><numFmt numFmtId="164" formatCode="&quot;Rp &quot;#,##0"/><numFmt numFmtId="165" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts>
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="4">
+<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>
+<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="165" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>
+</cellXfs>
+</styleSheet>`
+
+// xlsxWriter writes a single-sheet workbook. The fixed parts go first so the sheet,
+// written last, can be streamed into the zip row by row.
+type xlsxWriter struct {
+	dst     io.Writer
+	start   func() // sends the download headers, if any
+	flush   func()
+	columns []Column
+	headers []string
+	archive *zip.Writer
+	sheet   *bufio.Writer
+	row     int
+}
+
+// begin writes the fixed parts of the workbook and the header row
+func (w *xlsxWriter) begin() error {
+	if w.archive != nil {
+		return nil
+	}
+	w.start()
+	w.archive = zip.NewWriter(w.dst)
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, part := range parts {
+		f, err := w.archive.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return err
+		}
+	}
+
+	f, err := w.archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	w.sheet = bufio.NewWriter(f)
+	w.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	header := make([]interface{}, len(w.headers))
+	for i, h := range w.headers {
+		header[i] = h
+	}
+	return w.writeRow(header, true)
+}
+
+func (w *xlsxWriter) WriteRow(values ...interface{}) error {
+	if err := w.begin(); err != nil {
+		return err
+	}
+	return w.writeRow(values, false)
+}
+
+func (w *xlsxWriter) writeRow(values []interface{}, header bool) error {
+	w.row++
+	rowNum := strconv.Itoa(w.row)
+	w.sheet.WriteString(`<row r="` + rowNum + `">`)
+	for i := range w.columns {
+		if i >= len(values) {
+			break
+		}
+		value := deref(values[i])
+		if value == nil {
+			continue
+		}
+		ref := ColumnName(i) + rowNum
+
+		if header {
+			w.writeText(ref, styleHeader, value)
+			continue
+		}
+		if t, ok := value.(time.Time); ok {
+			w.sheet.WriteString(`<c r="` + ref + `" s="` + strconv.Itoa(styleDate) + `"><v>` +
+				strconv.FormatFloat(excelSerial(t), 'f', -1, 64) + `</v></c>`)
+			continue
+		}
+		if number, ok := toFloat(value); ok {
+			style := styleDefault
+			if w.columns[i].Kind == KindCurrency {
+				style = styleCurrency
+			}
+			w.sheet.WriteString(`<c r="` + ref + `" s="` + strconv.Itoa(style) + `"><v>` +
+				strconv.FormatFloat(number, 'f', -1, 64) + `</v></c>`)
+			continue
+		}
+		w.writeText(ref, styleDefault, value)
+	}
+	_, err := w.sheet.WriteString("</row>")
+	return err
+}
+
+// writeText writes an inline string cell, so no shared string table has to be kept
+func (w *xlsxWriter) writeText(ref string, style int, value interface{}) {
+	w.sheet.WriteString(`<c r="` + ref + `" s="` + strconv.Itoa(style) + `" t="inlineStr"><is><t xml:space="preserve">`)
+	xml.EscapeText(w.sheet, []byte(formatText(value, KindText, LangEnglish)))
+	w.sheet.WriteString(`</t></is></c>`)
+}
+
+func (w *xlsxWriter) Close() error {
+	if err := w.begin(); err != nil {
+		return err
+	}
+	w.sheet.WriteString(`</sheetData></worksheet>`)
+	if err := w.sheet.Flush(); err != nil {
+		return err
+	}
+	if err := w.archive.Close(); err != nil {
+		return err
+	}
+	w.flush()
+	return nil
+}
+
+// ColumnName is the spreadsheet name of a zero-based column index: A, B, ..., Z, AA, ...
+func ColumnName(index int) string {
+	name := ""
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = string(rune('A'+(index-1)%26)) + name
+	}
+	return name
+}
+
+// excelEpoch is day zero of spreadsheet date serials
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// excelSerial is the spreadsheet serial of a time as shown on the wall clock
+func excelSerial(t time.Time) float64 {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	return wall.Sub(excelEpoch).Hours() / 24
+}
@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+	notification_handler "w4/p2/milestones/internal/notificationHandler"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Movement types of the inventory ledger
+const (
+	MovementSale        = "sale"
+	MovementRestock     = "restock"
+	MovementReturn      = "return"
+	MovementAdjustment  = "adjustment"
+	MovementReservation = "reservation"
+	MovementLoan        = "loan"
+)
+
+// ProductLoan is the service product type of equipment lent out for a rental and returned
+const ProductLoan = "loan"
+
+// Movement is one change to a service's stock
+type Movement struct {
+	ID            int       `json:"id"`
+	ServiceID     int       `json:"service_id"`
+	ServiceName   string    `json:"service_name,omitempty"`
+	MovementType  string    `json:"movement_type"`
+	Quantity      int       `json:"quantity"` // signed change in stock
+	QuantityAfter int       `json:"quantity_after"`
+	Reason        string    `json:"reason"`
+	TransactionID *int      `json:"transaction_id"`
+	AdminID       *int      `json:"admin_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// StockLine is a quantity of one service
+type StockLine struct {
+	ServiceID int
+	Quantity  int
+}
+
+// StockError explains why stock cannot be taken
+type StockError struct {
+	Message string
+}
+
+func (e *StockError) Error() string {
+	return e.Message
+}
+
+// MoveStock changes a service's stock by quantity in one conditional update, so
+// concurrent sales cannot take it below zero, and records the movement. A service
+// falling to its reorder threshold notifies the admins. Sales of loanable equipment
+// are recorded as loans. Sales and reservations must take stock, a negative quantity.
+// Running out of stock is a *StockError.
+func MoveStock(ctx context.Context, db config.DBTX, serviceID int, movementType string, quantity int, reason string, transactionID *int, adminID *int) (Movement, error) {
+	if (movementType == MovementSale || movementType == MovementReservation) && quantity >= 0 {
+		return Movement{}, fmt.Errorf("invalid %s quantity %d for service %d", movementType, quantity, serviceID)
+	}
+	return moveStock(ctx, db, serviceID, movementType, quantity, reason, transactionID, adminID, true)
+}
+
+// moveStock is MoveStock with the low-stock notification optional, for movements that
+// only restate stock already taken or give back reserved stock
+func moveStock(ctx context.Context, db config.DBTX, serviceID int, movementType string, quantity int, reason string, transactionID *int, adminID *int, notify bool) (Movement, error) {
+	movement := Movement{ServiceID: serviceID, MovementType: movementType, Quantity: quantity, Reason: reason, TransactionID: transactionID, AdminID: adminID}
+
+	var threshold *int
+	var productType string
+	updateQuery := `
+		UPDATE service SET quantity = quantity + $1
+		WHERE id = $2 AND quantity + $1 >= 0
+		RETURNING name, quantity, reorder_threshold, product_type`
+	err := db.QueryRow(ctx, updateQuery, quantity, serviceID).Scan(&movement.ServiceName, &movement.QuantityAfter, &threshold, &productType)
+	if errors.Is(err, pgx.ErrNoRows) {
+		var available int
+		if err := db.QueryRow(ctx, `SELECT quantity FROM service WHERE id = $1`, serviceID).Scan(&available); err != nil {
+			return movement, &StockError{Message: fmt.Sprintf("Invalid service ID %d", serviceID)}
+		}
+		return movement, &StockError{Message: fmt.Sprintf("Insufficient stock for Service ID %d. Available: %d, Requested: %d", serviceID, available, -quantity)}
+	} else if err != nil {
+		return movement, fmt.Errorf("failed to update stock of service %d: %w", serviceID, err)
+	}
+	if movementType == MovementSale && productType == ProductLoan {
+		movement.MovementType = MovementLoan
+	}
+
+	insertQuery := `
+		INSERT INTO inventory_movement (service_id, movement_type, quantity, quantity_after, reason, transaction_id, admin_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at`
+	err = db.QueryRow(ctx, insertQuery, serviceID, movement.MovementType, quantity, movement.QuantityAfter, reason, transactionID, adminID).Scan(&movement.ID, &movement.CreatedAt)
+	if err != nil {
+		return movement, fmt.Errorf("failed to record inventory movement: %w", err)
+	}
+
+	if notify && threshold != nil && CrossedThreshold(movement.QuantityAfter-quantity, movement.QuantityAfter, *threshold) {
+		message := fmt.Sprintf("%s is low on stock: %d left (reorder at %d)", movement.ServiceName, movement.QuantityAfter, *threshold)
+		if err := notification_handler.NotifyAdmins(ctx, db, notification_handler.TypeLowStock, &serviceID, message); err != nil {
+			return movement, err
+		}
+	}
+	return movement, nil
+}
+
+// CrossedThreshold reports whether stock just fell to or below the reorder threshold,
+// so each drop is notified once rather than on every sale below it
+func CrossedThreshold(before int, after int, threshold int) bool {
+	return before > threshold && after <= threshold
+}
+
+// TakeStock sells the lines of an order before payment in one transaction, so either
+// all of them are taken or none. It returns the movements to link to the transaction,
+// or a *StockError if a line is out of stock.
+func TakeStock(ctx context.Context, lines []StockLine, reason string) ([]int, error) {
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var movementIDs []int
+	for _, line := range lines {
+		movement, err := MoveStock(ctx, tx, line.ServiceID, MovementSale, -line.Quantity, reason, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		movementIDs = append(movementIDs, movement.ID)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit stock: %w", err)
+	}
+	return movementIDs, nil
+}
+
+// LinkMovements attaches movements taken before payment to their transaction
+func LinkMovements(ctx context.Context, db config.DBTX, movementIDs []int, transactionID int) error {
+	query := `UPDATE inventory_movement SET transaction_id = $1 WHERE id = ANY($2)`
+	if _, err := db.Exec(ctx, query, transactionID, movementIDs); err != nil {
+		return fmt.Errorf("failed to link inventory movements: %w", err)
+	}
+	return nil
+}
+
+// ReturnStock puts back the stock of movements whose order was not completed,
+// recording a return for each
+func ReturnStock(ctx context.Context, db config.DBTX, movementIDs []int, reason string) error {
+	rows, err := db.Query(ctx, `SELECT service_id, quantity, transaction_id FROM inventory_movement WHERE id = ANY($1)`, movementIDs)
+	if err != nil {
+		return fmt.Errorf("failed to fetch inventory movements: %w", err)
+	}
+	var returns []Movement
+	for rows.Next() {
+		var m Movement
+		if err := rows.Scan(&m.ServiceID, &m.Quantity, &m.TransactionID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to parse inventory movement: %w", err)
+		}
+		returns = append(returns, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, m := range returns {
+		if _, err := MoveStock(ctx, db, m.ServiceID, MovementReturn, -m.Quantity, reason, m.TransactionID, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LowStock lists the active services at or below their reorder threshold, emptiest first
+func LowStock(ctx context.Context, db config.DBTX) ([]LowStockItem, error) {
+	query := `
+		SELECT id, name, quantity, reorder_threshold
+		FROM service
+		WHERE is_active = TRUE AND reorder_threshold IS NOT NULL AND quantity <= reorder_threshold
+		ORDER BY quantity, name`
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch low-stock services: %w", err)
+	}
+	defer rows.Close()
+
+	items := []LowStockItem{}
+	for rows.Next() {
+		var item LowStockItem
+		if err := rows.Scan(&item.ServiceID, &item.Name, &item.Quantity, &item.ReorderThreshold); err != nil {
+			return nil, fmt.Errorf("failed to parse low-stock services: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// LowStockItem is a service at or below its reorder threshold
+type LowStockItem struct {
+	ServiceID        int    `json:"service_id"`
+	Name             string `json:"name"`
+	Quantity         int    `json:"quantity"`
+	ReorderThreshold int    `json:"reorder_threshold"`
+}
+
+// requireAdmin reports whether the JWT belongs to an admin or super-admin
+func requireAdmin(c echo.Context) bool {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	adminRole, _ := claims["role"].(string)
+	return adminRole == "admin" || adminRole == "super-admin"
+}
+
+// GetInventoryMovements godoc
+// @Summary List inventory movements
+// @Description Retrieve the inventory ledger of sales, restocks, returns, adjustments, reservations and equipment loans, newest first
+// @Tags Inventory
+// @Produce json
+// @Param service_id query int false "Only movements of this service"
+// @Param type query string false "Movement type (sale, restock, return, adjustment, reservation, loan)"
+// @Param start_date query string false "From date (YYYY-MM-DD)"
+// @Param end_date query string false "To date (YYYY-MM-DD), inclusive"
+// @Param limit query int false "Number of movements (default 100)"
+// @Param offset query int false "Movements to skip"
+// @Success 200 {object} map[string]interface{} "Inventory movements retrieved successfully"
+// @Failure 400 {object} map[string]string "Invalid filter"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/inventory/movements [get]
+func GetInventoryMovements(c echo.Context) error {
+	if !requireAdmin(c) {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only selected admins can perform this action."})
+	}
+
+	var serviceID int
+	if value := c.QueryParam("service_id"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid service_id"})
+		}
+		serviceID = parsed
+	}
+
+	var startDate, endDate *time.Time
+	for param, target := range map[string]**time.Time{"start_date": &startDate, "end_date": &endDate} {
+		if value := c.QueryParam(param); value != "" {
+			parsed, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid " + param + ", use YYYY-MM-DD"})
+			}
+			*target = &parsed
+		}
+	}
+
+	limit, offset := 100, 0
+	if value := c.QueryParam("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > 500 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "limit must be between 1 and 500"})
+		}
+		limit = parsed
+	}
+	if value := c.QueryParam("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid offset"})
+		}
+		offset = parsed
+	}
+
+	query := `
+		SELECT m.id, m.service_id, s.name, m.movement_type, m.quantity, m.quantity_after, COALESCE(m.reason, ''),
+		       m.transaction_id, m.admin_id, m.created_at
+		FROM inventory_movement m
+		JOIN service s ON s.id = m.service_id
+		WHERE ($1 = 0 OR m.service_id = $1)
+		  AND ($2 = '' OR m.movement_type = $2)
+		  AND ($3::DATE IS NULL OR m.created_at >= $3::DATE)
+		  AND ($4::DATE IS NULL OR m.created_at < $4::DATE + 1)
+		ORDER BY m.created_at DESC, m.id DESC
+		LIMIT $5 OFFSET $6`
+	rows, err := config.Pool.Query(context.Background(), query, serviceID, c.QueryParam("type"), startDate, endDate, limit, offset)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch inventory movements"})
+	}
+	defer rows.Close()
+
+	movements := []Movement{}
+	for rows.Next() {
+		var m Movement
+		if err := rows.Scan(&m.ID, &m.ServiceID, &m.ServiceName, &m.MovementType, &m.Quantity, &m.QuantityAfter, &m.Reason,
+			&m.TransactionID, &m.AdminID, &m.CreatedAt); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to parse inventory movements"})
+		}
+		movements = append(movements, m)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Inventory movements retrieved successfully",
+		"data":    movements,
+	})
+}
+
+// GetLowStock godoc
+// @Summary List low-stock services
+// @Description Retrieve the active services at or below their reorder threshold, emptiest first
+// @Tags Inventory
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Low-stock services retrieved successfully"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/inventory/low-stock [get]
+func GetLowStock(c echo.Context) error {
+	if !requireAdmin(c) {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only selected admins can perform this action."})
+	}
+
+	items, err := LowStock(context.Background(), config.Pool)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch low-stock services"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Low-stock services retrieved successfully",
+		"data":    items,
+	})
+}
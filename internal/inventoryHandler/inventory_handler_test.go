@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetInventoryMovements(t *testing.T) {
+	// Setup Echo
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/admin/inventory/movements?type=sale&limit=10", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	// Manually set the JWT claims
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"admin_id": float64(1),
+		"role":     "admin",
+	})
+	c.Set("user", token)
+
+	err := GetInventoryMovements(c)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.Equal(t, "Inventory movements retrieved successfully", response["message"])
+	}
+}
+
+func TestCrossedThreshold(t *testing.T) {
+	// Falling to the threshold notifies
+	assert.True(t, CrossedThreshold(11, 10, 10))
+	assert.True(t, CrossedThreshold(12, 3, 10))
+
+	// Staying above, or selling further below, does not
+	assert.False(t, CrossedThreshold(15, 11, 10))
+	assert.False(t, CrossedThreshold(9, 8, 10))
+
+	// Restocking does not
+	assert.False(t, CrossedThreshold(5, 20, 10))
+}
+
+func TestMoveStockDirection(t *testing.T) {
+	// Sales and reservations that would add stock are refused before touching the database
+	_, err := MoveStock(context.Background(), nil, 1, MovementSale, 2, "Sale", nil, nil)
+	assert.Error(t, err)
+
+	_, err = MoveStock(context.Background(), nil, 1, MovementReservation, 0, "Reserved", nil, nil)
+	assert.Error(t, err)
+}
+
+func TestExpireReservations(t *testing.T) {
+	// Nothing held past its TTL is left after a run
+	_, err := ExpireReservations(context.Background())
+	assert.NoError(t, err)
+
+	released, err := ExpireReservations(context.Background())
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, released)
+	}
+}
@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+)
+
+// ReservationTTL is how long stock stays reserved for an unpaid order, longer than
+// the GoPay payment window so late notifications still find their stock
+const ReservationTTL = 30 * time.Minute
+
+// Reservation statuses
+const (
+	ReservationHeld      = "Held"
+	ReservationConverted = "Converted"
+	ReservationReleased  = "Released"
+)
+
+// ReserveStock takes the stock of an order awaiting payment in one transaction, so
+// either all lines are reserved or none. The stock is unavailable to other orders
+// until the reservation is converted to a sale, released, or expires. It returns the
+// reservations to link to the transaction, or a *StockError if a line is out of stock.
+func ReserveStock(ctx context.Context, lines []StockLine, ttl time.Duration) ([]int, error) {
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var reservationIDs []int
+	expiresAt := time.Now().Add(ttl)
+	for _, line := range lines {
+		movement, err := MoveStock(ctx, tx, line.ServiceID, MovementReservation, -line.Quantity, "Reserved for pending order", nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var reservationID int
+		query := `
+			INSERT INTO stock_reservation (service_id, quantity, status, movement_id, expires_at)
+			VALUES ($1, $2, $3, $4, $5) RETURNING id`
+		if err := tx.QueryRow(ctx, query, line.ServiceID, line.Quantity, ReservationHeld, movement.ID, expiresAt).Scan(&reservationID); err != nil {
+			return nil, fmt.Errorf("failed to record stock reservation: %w", err)
+		}
+		reservationIDs = append(reservationIDs, reservationID)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit stock reservation: %w", err)
+	}
+	return reservationIDs, nil
+}
+
+// LinkReservations attaches reservations, and the movements that took their stock, to
+// the pending transaction they hold stock for
+func LinkReservations(ctx context.Context, db config.DBTX, reservationIDs []int, transactionID int) error {
+	query := `
+		WITH linked AS (
+			UPDATE stock_reservation SET transaction_id = $1 WHERE id = ANY($2) RETURNING movement_id
+		)
+		UPDATE inventory_movement SET transaction_id = $1 WHERE id IN (SELECT movement_id FROM linked)`
+	if _, err := db.Exec(ctx, query, transactionID, reservationIDs); err != nil {
+		return fmt.Errorf("failed to link stock reservations: %w", err)
+	}
+	return nil
+}
+
+// heldReservation is a reservation still holding stock
+type heldReservation struct {
+	id            int
+	serviceID     int
+	quantity      int
+	transactionID *int
+}
+
+// lockHeld locks the held reservations selected by the where clause, skipping rows
+// another caller is already settling or releasing
+func lockHeld(ctx context.Context, db config.DBTX, where string, args ...interface{}) ([]heldReservation, error) {
+	query := `
+		SELECT id, service_id, quantity, transaction_id
+		FROM stock_reservation
+		WHERE status = 'Held' AND ` + where + `
+		ORDER BY id
+		FOR UPDATE SKIP LOCKED`
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch stock reservations: %w", err)
+	}
+	defer rows.Close()
+
+	var held []heldReservation
+	for rows.Next() {
+		var r heldReservation
+		if err := rows.Scan(&r.id, &r.serviceID, &r.quantity, &r.transactionID); err != nil {
+			return nil, fmt.Errorf("failed to parse stock reservation: %w", err)
+		}
+		held = append(held, r)
+	}
+	return held, rows.Err()
+}
+
+// release puts the stock of held reservations back on sale
+func release(ctx context.Context, db config.DBTX, held []heldReservation, reason string) error {
+	for _, r := range held {
+		if _, err := moveStock(ctx, db, r.serviceID, MovementReservation, r.quantity, reason, r.transactionID, nil, true); err != nil {
+			return err
+		}
+		query := `UPDATE stock_reservation SET status = $1, closed_at = NOW() WHERE id = $2`
+		if _, err := db.Exec(ctx, query, ReservationReleased, r.id); err != nil {
+			return fmt.Errorf("failed to release stock reservation %d: %w", r.id, err)
+		}
+	}
+	return nil
+}
+
+// ReleaseReservations gives back the stock of reservations whose order was not created
+func ReleaseReservations(ctx context.Context, db config.DBTX, reservationIDs []int, reason string) error {
+	held, err := lockHeld(ctx, db, "id = ANY($1)", reservationIDs)
+	if err != nil {
+		return err
+	}
+	return release(ctx, db, held, reason)
+}
+
+// ReleaseTransactionReservations gives back the stock held for a transaction that will never settle
+func ReleaseTransactionReservations(ctx context.Context, db config.DBTX, transactionID int, reason string) error {
+	held, err := lockHeld(ctx, db, "transaction_id = $1", transactionID)
+	if err != nil {
+		return err
+	}
+	return release(ctx, db, held, reason)
+}
+
+// ConvertReservations turns the stock held for a settled transaction into sales and
+// returns the quantity converted per service. Lines whose reservation already expired
+// are not included and must be sold from stock.
+func ConvertReservations(ctx context.Context, db config.DBTX, transactionID int) (map[int]int, error) {
+	held, err := lockHeld(ctx, db, "transaction_id = $1", transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	converted := map[int]int{}
+	for _, r := range held {
+		// Restate the reserved stock as sold; the stock level does not change
+		reason := fmt.Sprintf("Reservation %d paid", r.id)
+		if _, err := moveStock(ctx, db, r.serviceID, MovementReservation, r.quantity, reason, &transactionID, nil, false); err != nil {
+			return nil, err
+		}
+		if _, err := moveStock(ctx, db, r.serviceID, MovementSale, -r.quantity, reason, &transactionID, nil, false); err != nil {
+			return nil, err
+		}
+
+		query := `UPDATE stock_reservation SET status = $1, closed_at = NOW() WHERE id = $2`
+		if _, err := db.Exec(ctx, query, ReservationConverted, r.id); err != nil {
+			return nil, fmt.Errorf("failed to convert stock reservation %d: %w", r.id, err)
+		}
+		converted[r.serviceID] += r.quantity
+	}
+	return converted, nil
+}
+
+// ExpireReservations releases the reservations past their TTL and returns how many
+func ExpireReservations(ctx context.Context) (int, error) {
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	held, err := lockHeld(ctx, tx, "expires_at <= NOW()")
+	if err != nil {
+		return 0, err
+	}
+	if err := release(ctx, tx, held, "Reservation expired"); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit expired reservations: %w", err)
+	}
+	return len(held), nil
+}
+
+// StartReservationExpiry periodically releases stock held for orders that were never paid
+func StartReservationExpiry(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			released, err := ExpireReservations(context.Background())
+			if err != nil {
+				fmt.Printf("Stock reservation expiry error: %v\n", err)
+				continue
+			}
+			if released > 0 {
+				fmt.Printf("Released %d expired stock reservations\n", released)
+			}
+		}
+	}()
+}
@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+	inventory_handler "w4/p2/milestones/internal/inventoryHandler"
+	notification_handler "w4/p2/milestones/internal/notificationHandler"
+)
+
+// Loan statuses
+const (
+	StatusOut      = "Out"
+	StatusOverdue  = "Overdue" // still out after the rental ended
+	StatusReturned = "Returned"
+	StatusDamaged  = "Damaged"
+	StatusLost     = "Lost"
+)
+
+// Return conditions
+const (
+	ConditionGood    = "good"
+	ConditionDamaged = "damaged"
+	ConditionLost    = "lost"
+)
+
+// Loan is equipment lent to a customer for a rental session
+type Loan struct {
+	ID              int        `json:"id"`
+	ServiceID       int        `json:"service_id"`
+	ServiceName     string     `json:"service_name"`
+	RentalHistoryID int        `json:"rental_history_id"`
+	CustomerID      int        `json:"customer_id"`
+	Quantity        int        `json:"quantity"`
+	Status          string     `json:"status"`
+	DueAt           time.Time  `json:"due_at"`
+	CheckedOutAt    time.Time  `json:"checked_out_at"`
+	ReturnedAt      *time.Time `json:"returned_at"`
+	ChargeAmount    float64    `json:"charge_amount"`  // damage or loss charge
+	ChargedAmount   float64    `json:"charged_amount"` // part of the charge taken from the wallet
+	Outstanding     float64    `json:"outstanding"`    // part of the charge the wallet could not cover
+	Notes           *string    `json:"notes"`
+}
+
+// LoanError explains why a loan cannot be returned
+type LoanError struct {
+	Message string
+}
+
+func (e *LoanError) Error() string {
+	return e.Message
+}
+
+// CheckOutLoans opens a loan for each loanable line recorded on a rental, due back when
+// the rental ends. Checking out a rental twice has no effect.
+func CheckOutLoans(ctx context.Context, db config.DBTX, rentalHistoryID int) (int, error) {
+	query := `
+		INSERT INTO equipment_loan (rental_services_id, service_id, rental_history_id, customer_id, quantity, status, due_at)
+		SELECT rs.id, rs.service_id, rh.id, rh.customer_id, rs.quantity, $2, rh.rental_end_time
+		FROM rental_services rs
+		JOIN service s ON s.id = rs.service_id
+		JOIN rental_history rh ON rh.id = rs.rental_history_id
+		WHERE rs.rental_history_id = $1 AND s.product_type = $3
+		ON CONFLICT (rental_services_id) DO NOTHING`
+	tag, err := db.Exec(ctx, query, rentalHistoryID, StatusOut, inventory_handler.ProductLoan)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check out equipment for rental %d: %w", rentalHistoryID, err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// ReturnCharge is the charge for returning a loan in a condition: nothing for good
+// equipment, the given amount for damage, and the loss charge per item, unless
+// overridden, for lost equipment
+func ReturnCharge(condition string, quantity int, lossCharge float64, override *float64) (float64, error) {
+	switch condition {
+	case ConditionGood:
+		return 0, nil
+	case ConditionDamaged:
+		if override == nil || *override <= 0 {
+			return 0, &LoanError{Message: "Damaged returns need a charge greater than zero"}
+		}
+		return *override, nil
+	case ConditionLost:
+		if override != nil {
+			if *override < 0 {
+				return 0, &LoanError{Message: "Charge cannot be negative"}
+			}
+			return *override, nil
+		}
+		return math.Round(lossCharge * float64(quantity)), nil
+	}
+	return 0, &LoanError{Message: "Condition must be good, damaged or lost"}
+}
+
+// FlagOverdueLoans marks loans still out after their rental ended and notifies the
+// admins once per loan
+func FlagOverdueLoans(ctx context.Context) (int, error) {
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE equipment_loan l
+		SET status = $1
+		FROM service s, customer cu
+		WHERE s.id = l.service_id AND cu.id = l.customer_id AND l.status = $2 AND l.due_at <= NOW()
+		RETURNING l.id, l.service_id, s.name, l.quantity, cu.name, l.rental_history_id`
+	rows, err := tx.Query(ctx, query, StatusOverdue, StatusOut)
+	if err != nil {
+		return 0, fmt.Errorf("failed to flag overdue loans: %w", err)
+	}
+
+	type flagged struct {
+		loanID, serviceID, quantity, rentalID int
+		serviceName, customerName             string
+	}
+	var loans []flagged
+	for rows.Next() {
+		var f flagged
+		if err := rows.Scan(&f.loanID, &f.serviceID, &f.serviceName, &f.quantity, &f.customerName, &f.rentalID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to parse overdue loan: %w", err)
+		}
+		loans = append(loans, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, f := range loans {
+		message := fmt.Sprintf("%s has not returned %d x %s after rental %d ended (loan %d)", f.customerName, f.quantity, f.serviceName, f.rentalID, f.loanID)
+		if err := notification_handler.NotifyAdmins(ctx, tx, notification_handler.TypeUnreturnedEquipment, &f.serviceID, message); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit overdue loans: %w", err)
+	}
+	return len(loans), nil
+}
+
+// StartLoanMonitor periodically flags equipment not returned by the end of its rental
+func StartLoanMonitor(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			flagged, err := FlagOverdueLoans(context.Background())
+			if err != nil {
+				fmt.Printf("Equipment loan monitor error: %v\n", err)
+				continue
+			}
+			if flagged > 0 {
+				fmt.Printf("Flagged %d unreturned equipment loans\n", flagged)
+			}
+		}
+	}()
+}
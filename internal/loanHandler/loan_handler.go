@@ -0,0 +1,244 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	config "w4/p2/milestones/config/database"
+	audit_handler "w4/p2/milestones/internal/auditHandler"
+	inventory_handler "w4/p2/milestones/internal/inventoryHandler"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// ReturnRequest records the condition loaned equipment came back in
+type ReturnRequest struct {
+	Condition string   `json:"condition"` // good, damaged or lost
+	Charge    *float64 `json:"charge"`    // required for damage, defaults to the loss charge when lost
+	Notes     string   `json:"notes"`
+}
+
+// ReturnResult is a closed loan with what was charged for it
+type ReturnResult struct {
+	Loan
+	ChargeTransactionID *int `json:"charge_transaction_id"`
+}
+
+// requireAdmin returns the admin ID from the JWT and whether it belongs to an admin or super-admin
+func requireAdmin(c echo.Context) (int, bool) {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	adminRole, _ := claims["role"].(string)
+	adminID, _ := claims["admin_id"].(float64)
+	return int(adminID), adminRole == "admin" || adminRole == "super-admin"
+}
+
+const loanColumns = `l.id, l.service_id, s.name, l.rental_history_id, l.customer_id, l.quantity, l.status,
+	l.due_at, l.checked_out_at, l.returned_at, l.charge_amount, l.charged_amount, l.notes`
+
+func scanLoan(row pgx.Row, l *Loan) error {
+	err := row.Scan(&l.ID, &l.ServiceID, &l.ServiceName, &l.RentalHistoryID, &l.CustomerID, &l.Quantity, &l.Status,
+		&l.DueAt, &l.CheckedOutAt, &l.ReturnedAt, &l.ChargeAmount, &l.ChargedAmount, &l.Notes)
+	l.Outstanding = l.ChargeAmount - l.ChargedAmount
+	return err
+}
+
+// GetLoans godoc
+// @Summary List equipment loans
+// @Description Retrieve equipment lent out with rentals, open loans first. Overdue loans were not returned by the end of their rental.
+// @Tags Loans
+// @Produce json
+// @Param status query string false "Loan status (Out, Overdue, Returned, Damaged, Lost)"
+// @Param customer_id query int false "Only loans of this customer"
+// @Success 200 {object} map[string]interface{} "Equipment loans retrieved successfully"
+// @Failure 400 {object} map[string]string "Invalid filter"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/loans [get]
+func GetLoans(c echo.Context) error {
+	if _, ok := requireAdmin(c); !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only selected admins can perform this action."})
+	}
+
+	var customerID int
+	if value := c.QueryParam("customer_id"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid customer_id"})
+		}
+		customerID = parsed
+	}
+
+	query := `
+		SELECT ` + loanColumns + `
+		FROM equipment_loan l
+		JOIN service s ON s.id = l.service_id
+		WHERE ($1 = '' OR l.status = $1) AND ($2 = 0 OR l.customer_id = $2)
+		ORDER BY l.returned_at IS NOT NULL, l.due_at, l.id`
+	rows, err := config.Pool.Query(context.Background(), query, c.QueryParam("status"), customerID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch equipment loans"})
+	}
+	defer rows.Close()
+
+	loans := []Loan{}
+	for rows.Next() {
+		var l Loan
+		if err := scanLoan(rows, &l); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to parse equipment loans"})
+		}
+		loans = append(loans, l)
+	}
+	if err := rows.Err(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to parse equipment loans"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Equipment loans retrieved successfully",
+		"data":    loans,
+	})
+}
+
+// ReturnLoan godoc
+// @Summary Return loaned equipment
+// @Description Close an equipment loan. Equipment returned in good condition goes back into stock. Damaged or lost equipment is charged to the customer's wallet; any part the wallet cannot cover stays outstanding on the loan.
+// @Tags Loans
+// @Accept json
+// @Produce json
+// @Param id path int true "Loan ID"
+// @Param request body ReturnRequest true "Return condition"
+// @Success 200 {object} ReturnResult
+// @Failure 400 {object} map[string]string "Invalid request or loan already returned"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Loan not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/loans/{id}/return [post]
+func ReturnLoan(c echo.Context) error {
+	adminID, ok := requireAdmin(c)
+	if !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only selected admins can perform this action."})
+	}
+
+	loanID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid loan ID"})
+	}
+
+	var req ReturnRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+	req.Condition = strings.ToLower(strings.TrimSpace(req.Condition))
+	req.Notes = strings.TrimSpace(req.Notes)
+
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	var result ReturnResult
+	var lossCharge *float64
+	query := `
+		SELECT ` + loanColumns + `, s.loss_charge
+		FROM equipment_loan l
+		JOIN service s ON s.id = l.service_id
+		WHERE l.id = $1
+		FOR UPDATE OF l`
+	err = tx.QueryRow(ctx, query, loanID).Scan(&result.ID, &result.ServiceID, &result.ServiceName, &result.RentalHistoryID,
+		&result.CustomerID, &result.Quantity, &result.Status, &result.DueAt, &result.CheckedOutAt, &result.ReturnedAt,
+		&result.ChargeAmount, &result.ChargedAmount, &result.Notes, &lossCharge)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Loan not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch loan"})
+	}
+	if result.Status != StatusOut && result.Status != StatusOverdue {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Loan has already been returned"})
+	}
+	before := result.Loan
+
+	var perItem float64
+	if lossCharge != nil {
+		perItem = *lossCharge
+	}
+	charge, err := ReturnCharge(req.Condition, result.Quantity, perItem, req.Charge)
+	var loanErr *LoanError
+	if errors.As(err, &loanErr) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": loanErr.Message})
+	}
+
+	reason := fmt.Sprintf("Loan %d returned %s", loanID, req.Condition)
+	switch req.Condition {
+	case ConditionGood:
+		result.Status = StatusReturned
+		if _, err := inventory_handler.MoveStock(ctx, tx, result.ServiceID, inventory_handler.MovementReturn, result.Quantity, reason, nil, &adminID); err != nil {
+			fmt.Println("Loan return stock error:", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to restock equipment"})
+		}
+	case ConditionDamaged:
+		result.Status = StatusDamaged
+	case ConditionLost:
+		result.Status = StatusLost
+	}
+
+	// Take what the wallet can cover; the rest stays outstanding on the loan
+	result.ChargeAmount = charge
+	if charge > 0 {
+		var wallet float64
+		if err := tx.QueryRow(ctx, `SELECT wallet FROM customer WHERE id = $1 FOR UPDATE`, result.CustomerID).Scan(&wallet); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch customer wallet"})
+		}
+		result.ChargedAmount = math.Min(charge, math.Max(wallet, 0))
+	}
+	if result.ChargedAmount > 0 {
+		if _, err := tx.Exec(ctx, `UPDATE customer SET wallet = wallet - $1 WHERE id = $2`, result.ChargedAmount, result.CustomerID); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to charge customer wallet"})
+		}
+		var transactionID int
+		chargeQuery := `
+			INSERT INTO transaction (customer_id, transaction_type, amount, transaction_method, status, transaction_date, metadata, admin_id)
+			VALUES ($1, 'Equipment Charge', $2, 'Wallet', 'settlement', NOW(), $3, $4) RETURNING id`
+		metadata := map[string]interface{}{"loan_id": loanID, "condition": req.Condition}
+		if err := tx.QueryRow(ctx, chargeQuery, result.CustomerID, result.ChargedAmount, metadata, adminID).Scan(&transactionID); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to record equipment charge"})
+		}
+		result.ChargeTransactionID = &transactionID
+	}
+	result.Outstanding = result.ChargeAmount - result.ChargedAmount
+
+	var notes *string
+	if req.Notes != "" {
+		notes = &req.Notes
+	}
+	updateQuery := `
+		UPDATE equipment_loan
+		SET status = $1, returned_at = NOW(), charge_amount = $2, charged_amount = $3, charge_transaction_id = $4, returned_by = $5, notes = $6
+		WHERE id = $7 RETURNING returned_at`
+	err = tx.QueryRow(ctx, updateQuery, result.Status, result.ChargeAmount, result.ChargedAmount, result.ChargeTransactionID, adminID, notes, loanID).Scan(&result.ReturnedAt)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to close loan"})
+	}
+	result.Notes = notes
+
+	event := audit_handler.FromRequest(c, "loan.return", "equipment_loan", loanID).Change(before, result).
+		Describe("Admin (ID: %d) closed loan %d of %d x %s as %s, charged %.0f of %.0f", adminID, loanID, result.Quantity, result.ServiceName, result.Status, result.ChargedAmount, result.ChargeAmount)
+	if err := audit_handler.Record(ctx, tx, event); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log admin action"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to save loan return"})
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetLoans(t *testing.T) {
+	// Setup Echo
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/admin/loans?status=Overdue", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	// Manually set the JWT claims
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"admin_id": float64(1),
+		"role":     "admin",
+	})
+	c.Set("user", token)
+
+	err := GetLoans(c)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.Equal(t, "Equipment loans retrieved successfully", response["message"])
+	}
+}
+
+func TestReturnLoanNotFound(t *testing.T) {
+	// Setup Echo
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/admin/loans/999999/return", strings.NewReader(`{"condition": "good"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("999999")
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"admin_id": float64(1),
+		"role":     "admin",
+	})
+	c.Set("user", token)
+
+	err := ReturnLoan(c)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestReturnCharge(t *testing.T) {
+	damage := 50000.0
+
+	charge, err := ReturnCharge(ConditionGood, 2, 100000, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, charge)
+
+	// Lost equipment defaults to the loss charge per item
+	charge, err = ReturnCharge(ConditionLost, 2, 100000, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 200000.0, charge)
+
+	charge, err = ReturnCharge(ConditionDamaged, 1, 100000, &damage)
+	assert.NoError(t, err)
+	assert.Equal(t, 50000.0, charge)
+
+	// Damage has no default charge
+	_, err = ReturnCharge(ConditionDamaged, 1, 100000, nil)
+	assert.Error(t, err)
+
+	_, err = ReturnCharge("broken", 1, 100000, nil)
+	assert.Error(t, err)
+}
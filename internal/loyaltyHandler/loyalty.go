@@ -0,0 +1,365 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Points expire this long after they are earned
+const pointsValidity = 365 * 24 * time.Hour
+
+// Ledger entry types. Earn entries hold points that can be spent; redeem and expire
+// entries take them away, and refund entries give redeemed points back to the lots they
+// were drawn from.
+const (
+	EntryEarn   = "earn"
+	EntryRedeem = "redeem"
+	EntryRefund = "refund"
+	EntryExpire = "expire"
+)
+
+// Reward types
+const (
+	RewardFreeHours   = "free_hours"
+	RewardFreeService = "free_service"
+)
+
+// LedgerEntry is one movement of a customer's points
+type LedgerEntry struct {
+	ID            int        `json:"id"`
+	TransactionID *int       `json:"transaction_id"`
+	EntryType     string     `json:"entry_type"`
+	Points        int        `json:"points"`    // positive when earned or refunded, negative when spent or expired
+	Remaining     int        `json:"remaining"` // unspent points of an earn entry
+	ExpiresAt     *time.Time `json:"expires_at"`
+	Description   string     `json:"description"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// Reward is something points can be redeemed for
+type Reward struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	RewardType string `json:"reward_type"` // free_hours or free_service
+	PointsCost int    `json:"points_cost"`
+	Hours      int    `json:"hours"`      // computer hours for free_hours
+	ServiceID  *int   `json:"service_id"` // service for free_service
+	Quantity   int    `json:"quantity"`   // service units for free_service
+	IsActive   bool   `json:"is_active"`
+}
+
+// RewardLine is one priced line of a rental or purchase
+type RewardLine struct {
+	ComputerTime bool
+	ServiceID    int
+	Quantity     float64
+	UnitPrice    float64
+	Amount       float64 // still payable after other discounts
+}
+
+// AppliedReward is the discount a reward gives on an order
+type AppliedReward struct {
+	RewardID      int       `json:"reward_id"`
+	Name          string    `json:"name"`
+	PointsCost    int       `json:"points_cost"`
+	Discount      float64   `json:"discount"`
+	LineDiscounts []float64 `json:"-"`
+}
+
+// LoyaltyError explains why points cannot be redeemed
+type LoyaltyError struct {
+	Message string
+}
+
+func (e *LoyaltyError) Error() string {
+	return e.Message
+}
+
+// PointsBalance returns the unexpired points a customer can spend
+func PointsBalance(ctx context.Context, db config.DBTX, customerID int) (int, error) {
+	var balance int
+	query := `
+		SELECT COALESCE(SUM(remaining), 0)
+		FROM loyalty_ledger
+		WHERE customer_id = $1 AND remaining > 0 AND expires_at > NOW()`
+	if err := db.QueryRow(ctx, query, customerID).Scan(&balance); err != nil {
+		return 0, fmt.Errorf("failed to fetch points balance: %w", err)
+	}
+	return balance, nil
+}
+
+// ComputeRewardDiscount works out what a reward takes off the lines: the hourly price
+// of free hours on the computer time, or the unit price of free services.
+func ComputeRewardDiscount(reward Reward, lines []RewardLine) (AppliedReward, error) {
+	applied := AppliedReward{RewardID: reward.ID, Name: reward.Name, PointsCost: reward.PointsCost, LineDiscounts: make([]float64, len(lines))}
+
+	for i, line := range lines {
+		var units float64
+		switch {
+		case reward.RewardType == RewardFreeHours && line.ComputerTime:
+			units = float64(reward.Hours)
+		case reward.RewardType == RewardFreeService && reward.ServiceID != nil && line.ServiceID == *reward.ServiceID:
+			units = float64(reward.Quantity)
+		default:
+			continue
+		}
+		discount := math.Min(math.Min(units, line.Quantity)*line.UnitPrice, line.Amount)
+		applied.LineDiscounts[i] = math.Round(discount)
+		applied.Discount += applied.LineDiscounts[i]
+	}
+
+	if applied.Discount == 0 {
+		if reward.RewardType == RewardFreeHours {
+			return applied, &LoyaltyError{Message: fmt.Sprintf("Reward %s can only be redeemed on a rental", reward.Name)}
+		}
+		return applied, &LoyaltyError{Message: fmt.Sprintf("Reward %s requires its service in the order", reward.Name)}
+	}
+	return applied, nil
+}
+
+// ApplyReward checks that a customer has the points for a reward and computes its
+// discount on the lines. Unusable rewards return a *LoyaltyError.
+func ApplyReward(ctx context.Context, db config.DBTX, rewardID int, customerID int, lines []RewardLine) (AppliedReward, error) {
+	var reward Reward
+	query := `
+		SELECT id, name, reward_type, points_cost, hours, service_id, quantity, is_active
+		FROM loyalty_reward
+		WHERE id = $1`
+	err := db.QueryRow(ctx, query, rewardID).Scan(
+		&reward.ID, &reward.Name, &reward.RewardType, &reward.PointsCost, &reward.Hours, &reward.ServiceID, &reward.Quantity, &reward.IsActive,
+	)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !reward.IsActive) {
+		return AppliedReward{}, &LoyaltyError{Message: "Loyalty reward not found"}
+	} else if err != nil {
+		return AppliedReward{}, fmt.Errorf("failed to fetch loyalty reward: %w", err)
+	}
+
+	balance, err := PointsBalance(ctx, db, customerID)
+	if err != nil {
+		return AppliedReward{}, err
+	}
+	if balance < reward.PointsCost {
+		return AppliedReward{}, &LoyaltyError{Message: fmt.Sprintf("Insufficient points. Balance: %d, Required: %d", balance, reward.PointsCost)}
+	}
+
+	return ComputeRewardDiscount(reward, lines)
+}
+
+// RedeemPoints spends the points of a reward before the customer pays, oldest expiry
+// first, and returns the ledger entry to link to the transaction. It fails with a
+// *LoyaltyError if the points were spent since the quote.
+func RedeemPoints(ctx context.Context, applied AppliedReward, customerID int) (int, error) {
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialize redemptions per customer
+	lockQuery := `SELECT id FROM customer WHERE id = $1 FOR UPDATE`
+	if _, err := tx.Exec(ctx, lockQuery, customerID); err != nil {
+		return 0, fmt.Errorf("failed to lock customer: %w", err)
+	}
+
+	query := `
+		SELECT id, remaining
+		FROM loyalty_ledger
+		WHERE customer_id = $1 AND remaining > 0 AND expires_at > NOW()
+		ORDER BY expires_at, id`
+	rows, err := tx.Query(ctx, query, customerID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch points: %w", err)
+	}
+	type lot struct{ id, remaining int }
+	var lots []lot
+	for rows.Next() {
+		var l lot
+		if err := rows.Scan(&l.id, &l.remaining); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to parse points: %w", err)
+		}
+		lots = append(lots, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var entryID int
+	insertQuery := `
+		INSERT INTO loyalty_ledger (customer_id, entry_type, points, remaining, description)
+		VALUES ($1, $2, $3, 0, $4)
+		RETURNING id`
+	err = tx.QueryRow(ctx, insertQuery, customerID, EntryRedeem, -applied.PointsCost, "Redeemed for "+applied.Name).Scan(&entryID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record redemption: %w", err)
+	}
+
+	// Take the points from the lots that expire first, noting each lot drawn from
+	needed := applied.PointsCost
+	for _, l := range lots {
+		if needed == 0 {
+			break
+		}
+		take := min(needed, l.remaining)
+		updateQuery := `UPDATE loyalty_ledger SET remaining = remaining - $1 WHERE id = $2`
+		if _, err := tx.Exec(ctx, updateQuery, take, l.id); err != nil {
+			return 0, fmt.Errorf("failed to spend points: %w", err)
+		}
+		drawQuery := `INSERT INTO loyalty_redemption_lot (redeem_entry_id, lot_id, points) VALUES ($1, $2, $3)`
+		if _, err := tx.Exec(ctx, drawQuery, entryID, l.id, take); err != nil {
+			return 0, fmt.Errorf("failed to record points spent: %w", err)
+		}
+		needed -= take
+	}
+	if needed > 0 {
+		return 0, &LoyaltyError{Message: fmt.Sprintf("Insufficient points for %s", applied.Name)}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit redemption: %w", err)
+	}
+	return entryID, nil
+}
+
+// LinkRedemption attaches a redemption to the transaction it discounted
+func LinkRedemption(ctx context.Context, db config.DBTX, entryID int, applied AppliedReward, transactionID int) error {
+	query := `UPDATE loyalty_ledger SET transaction_id = $1 WHERE id = $2`
+	if _, err := db.Exec(ctx, query, transactionID, entryID); err != nil {
+		return fmt.Errorf("failed to link redemption: %w", err)
+	}
+
+	updateQuery := `UPDATE transaction SET loyalty_discount = $1 WHERE id = $2`
+	if _, err := db.Exec(ctx, updateQuery, applied.Discount, transactionID); err != nil {
+		return fmt.Errorf("failed to save loyalty discount for transaction %d: %w", transactionID, err)
+	}
+	return nil
+}
+
+// refundEntry gives back the points of a redemption to the lots they were drawn from,
+// which keep their expiry, and records the refund. Each draw is given back once, and
+// points returned to a lot that has expired since are taken by the next ExpirePoints run.
+func refundEntry(ctx context.Context, db config.DBTX, entryID int, description string) error {
+	restoreQuery := `
+		WITH draws AS (
+			DELETE FROM loyalty_redemption_lot WHERE redeem_entry_id = $1
+			RETURNING lot_id, points
+		)
+		UPDATE loyalty_ledger l SET remaining = l.remaining + d.points
+		FROM draws d
+		WHERE l.id = d.lot_id`
+	tag, err := db.Exec(ctx, restoreQuery, entryID)
+	if err != nil {
+		return fmt.Errorf("failed to restore refunded points: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO loyalty_ledger (customer_id, transaction_id, entry_type, points, remaining, description)
+		SELECT customer_id, transaction_id, $2, -points, 0, $3
+		FROM loyalty_ledger
+		WHERE id = $1 AND entry_type = $4`
+	if _, err := db.Exec(ctx, query, entryID, EntryRefund, description, EntryRedeem); err != nil {
+		return fmt.Errorf("failed to refund points: %w", err)
+	}
+	return nil
+}
+
+// CancelRedemption refunds points spent by RedeemPoints when the order is not placed
+func CancelRedemption(ctx context.Context, db config.DBTX, entryID int) error {
+	return refundEntry(ctx, db, entryID, "Refunded, order not placed")
+}
+
+// RefundTransactionPoints refunds the points spent on a transaction that will never settle
+func RefundTransactionPoints(ctx context.Context, db config.DBTX, transactionID int) error {
+	var entryID int
+	query := `
+		SELECT id FROM loyalty_ledger
+		WHERE transaction_id = $1 AND entry_type = $2
+		  AND NOT EXISTS (SELECT 1 FROM loyalty_ledger WHERE transaction_id = $1 AND entry_type = $3)`
+	err := db.QueryRow(ctx, query, transactionID, EntryRedeem, EntryRefund).Scan(&entryID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to fetch redemption of transaction %d: %w", transactionID, err)
+	}
+	return refundEntry(ctx, db, entryID, "Refunded, payment not completed")
+}
+
+// EarnPoints credits the points of a settled rental or service payment at the active
+// earn rate for its type, on the amount paid excluding tax. Crediting the same
+// transaction twice has no effect.
+func EarnPoints(ctx context.Context, db config.DBTX, transactionID int) (int, error) {
+	var points int
+	query := `
+		INSERT INTO loyalty_ledger (customer_id, transaction_id, entry_type, points, remaining, expires_at, description)
+		SELECT t.customer_id, t.id, $2, p.points, p.points, NOW() + $3 * INTERVAL '1 second', 'Earned on ' || t.transaction_type
+		FROM transaction t
+		JOIN loyalty_earn_rate r ON r.transaction_type = t.transaction_type AND r.is_active = TRUE
+		CROSS JOIN LATERAL (
+			SELECT FLOOR((t.amount - COALESCE(t.tax_amount, 0)) / r.rupiah_per_point)::INTEGER AS points
+		) p
+		WHERE t.id = $1 AND p.points > 0
+		ON CONFLICT DO NOTHING
+		RETURNING points`
+	err := db.QueryRow(ctx, query, transactionID, EntryEarn, pointsValidity.Seconds()).Scan(&points)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to earn points for transaction %d: %w", transactionID, err)
+	}
+	return points, nil
+}
+
+// ExpirePoints zeroes the unspent points of expired lots and records one expire entry
+// per customer. It returns the number of customers whose points expired.
+func ExpirePoints(ctx context.Context) (int, error) {
+	query := `
+		WITH expired AS (
+			SELECT id, customer_id, remaining
+			FROM loyalty_ledger
+			WHERE remaining > 0 AND expires_at <= NOW()
+			FOR UPDATE
+		), cleared AS (
+			UPDATE loyalty_ledger l SET remaining = 0
+			FROM expired e
+			WHERE l.id = e.id
+		)
+		INSERT INTO loyalty_ledger (customer_id, entry_type, points, remaining, description)
+		SELECT customer_id, $1, -SUM(remaining), 0, 'Points expired'
+		FROM expired
+		GROUP BY customer_id`
+	tag, err := config.Pool.Exec(ctx, query, EntryExpire)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire points: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// StartPointsExpiry expires loyalty points every interval
+func StartPointsExpiry(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			customers, err := ExpirePoints(context.Background())
+			if err != nil {
+				fmt.Printf("Loyalty points expiry error: %v\n", err)
+				continue
+			}
+			if customers > 0 {
+				fmt.Printf("Loyalty points expired for %d customers\n", customers)
+			}
+		}
+	}()
+}
@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	config "w4/p2/milestones/config/database"
+	audit_handler "w4/p2/milestones/internal/auditHandler"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// EarnRate is how much a customer spends per point on one transaction type
+type EarnRate struct {
+	TransactionType string  `json:"transaction_type"`
+	RupiahPerPoint  float64 `json:"rupiah_per_point"`
+	IsActive        bool    `json:"is_active"`
+}
+
+// EarnRateRequest defines the payload to change an earn rate
+type EarnRateRequest struct {
+	TransactionType string  `json:"transaction_type" validate:"required"` // Rental Payment or Service Payment
+	RupiahPerPoint  float64 `json:"rupiah_per_point" validate:"required"`
+	IsActive        *bool   `json:"is_active"`
+}
+
+// LoyaltySummary is a customer's points balance, rewards and history
+type LoyaltySummary struct {
+	Balance        int           `json:"balance"`
+	ExpiringPoints int           `json:"expiring_points"` // points expiring within 30 days
+	Rewards        []Reward      `json:"rewards"`
+	History        []LedgerEntry `json:"history"`
+}
+
+// GetLoyalty godoc
+// @Summary Get loyalty points
+// @Description Retrieve the customer's points balance, points expiring within 30 days, the rewards points can be redeemed for, and the points history
+// @Tags Loyalty
+// @Produce json
+// @Param limit query int false "Number of history entries (default 50)"
+// @Param offset query int false "History entries to skip"
+// @Success 200 {object} LoyaltySummary "Loyalty points"
+// @Failure 400 {object} map[string]string "Invalid pagination"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /customer/loyalty [get]
+func GetLoyalty(c echo.Context) error {
+	// Extract customer ID from JWT claims
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	customerID := int(claims["customer_id"].(float64))
+
+	limit, offset := 50, 0
+	if value := c.QueryParam("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > 200 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "limit must be between 1 and 200"})
+		}
+		limit = parsed
+	}
+	if value := c.QueryParam("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid offset"})
+		}
+		offset = parsed
+	}
+
+	ctx := context.Background()
+	summary := LoyaltySummary{Rewards: []Reward{}, History: []LedgerEntry{}}
+
+	balanceQuery := `
+		SELECT COALESCE(SUM(remaining), 0),
+		       COALESCE(SUM(remaining) FILTER (WHERE expires_at <= NOW() + INTERVAL '30 days'), 0)
+		FROM loyalty_ledger
+		WHERE customer_id = $1 AND remaining > 0 AND expires_at > NOW()`
+	err := config.Pool.QueryRow(ctx, balanceQuery, customerID).Scan(&summary.Balance, &summary.ExpiringPoints)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch points balance"})
+	}
+
+	rewardsQuery := `
+		SELECT id, name, reward_type, points_cost, hours, service_id, quantity, is_active
+		FROM loyalty_reward
+		WHERE is_active = TRUE
+		ORDER BY points_cost`
+	rows, err := config.Pool.Query(ctx, rewardsQuery)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch rewards"})
+	}
+	for rows.Next() {
+		var reward Reward
+		if err := rows.Scan(&reward.ID, &reward.Name, &reward.RewardType, &reward.PointsCost, &reward.Hours, &reward.ServiceID, &reward.Quantity, &reward.IsActive); err != nil {
+			rows.Close()
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to process rewards"})
+		}
+		summary.Rewards = append(summary.Rewards, reward)
+	}
+	rows.Close()
+
+	historyQuery := `
+		SELECT id, transaction_id, entry_type, points, remaining, expires_at, description, created_at
+		FROM loyalty_ledger
+		WHERE customer_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3`
+	rows, err = config.Pool.Query(ctx, historyQuery, customerID, limit, offset)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch points history"})
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry LedgerEntry
+		if err := rows.Scan(&entry.ID, &entry.TransactionID, &entry.EntryType, &entry.Points, &entry.Remaining, &entry.ExpiresAt, &entry.Description, &entry.CreatedAt); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to process points history"})
+		}
+		summary.History = append(summary.History, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to process points history"})
+	}
+
+	return c.JSON(http.StatusOK, summary)
+}
+
+// GetEarnRates godoc
+// @Summary List loyalty earn rates
+// @Description Retrieve how much customers spend per point on rentals and service purchases
+// @Tags Loyalty
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Earn rates retrieved successfully"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/loyalty/earn-rates [get]
+func GetEarnRates(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	adminRole, _ := claims["role"].(string)
+	if adminRole != "super-admin" {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can manage loyalty earn rates."})
+	}
+
+	query := `SELECT transaction_type, rupiah_per_point, is_active FROM loyalty_earn_rate ORDER BY transaction_type`
+	rows, err := config.Pool.Query(context.Background(), query)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch earn rates"})
+	}
+	defer rows.Close()
+
+	rates := []EarnRate{}
+	for rows.Next() {
+		var rate EarnRate
+		if err := rows.Scan(&rate.TransactionType, &rate.RupiahPerPoint, &rate.IsActive); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to process earn rates"})
+		}
+		rates = append(rates, rate)
+	}
+
+	if err := rows.Err(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to process earn rates"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Earn rates retrieved successfully",
+		"data":    rates,
+	})
+}
+
+// UpdateEarnRate godoc
+// @Summary Update a loyalty earn rate
+// @Description Change how much customers spend per point on a transaction type. Points already earned are kept.
+// @Tags Loyalty
+// @Accept json
+// @Produce json
+// @Param request body EarnRateRequest true "Earn rate"
+// @Success 200 {object} EarnRate "Updated earn rate"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Transaction type not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/loyalty/earn-rates [put]
+func UpdateEarnRate(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	adminRole, _ := claims["role"].(string)
+	adminID, _ := claims["admin_id"].(float64)
+	if adminRole != "super-admin" {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can manage loyalty earn rates."})
+	}
+
+	var req EarnRateRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+	if req.RupiahPerPoint <= 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "rupiah_per_point must be greater than zero"})
+	}
+
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	var before EarnRate
+	err = tx.QueryRow(ctx, `SELECT transaction_type, rupiah_per_point, is_active FROM loyalty_earn_rate WHERE transaction_type = $1 FOR UPDATE`,
+		req.TransactionType).Scan(&before.TransactionType, &before.RupiahPerPoint, &before.IsActive)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Transaction type not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch earn rate"})
+	}
+
+	var rate EarnRate
+	query := `
+		UPDATE loyalty_earn_rate
+		SET rupiah_per_point = $1, is_active = COALESCE($2, is_active)
+		WHERE transaction_type = $3
+		RETURNING transaction_type, rupiah_per_point, is_active`
+	err = tx.QueryRow(ctx, query, req.RupiahPerPoint, req.IsActive, req.TransactionType).Scan(
+		&rate.TransactionType, &rate.RupiahPerPoint, &rate.IsActive,
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update earn rate"})
+	}
+
+	// Log the admin action
+	event := audit_handler.FromRequest(c, "loyalty_earn_rate.update", "loyalty_earn_rate", rate.TransactionType).Change(before, rate).
+		Describe("Super-admin (ID: %d) set the %s earn rate to 1 point per Rp %.0f (active: %t)",
+			int(adminID), rate.TransactionType, rate.RupiahPerPoint, rate.IsActive)
+	if err := audit_handler.Record(ctx, tx, event); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log admin action"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update earn rate"})
+	}
+
+	return c.JSON(http.StatusOK, rate)
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetLoyalty(t *testing.T) {
+	// Setup Echo
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/customer/loyalty", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	// Manually set the JWT claims
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"customer_id": float64(1), // Customer ID from the ddl.sql setup
+	})
+	c.Set("user", token)
+
+	err := GetLoyalty(c)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var summary LoyaltySummary
+		json.Unmarshal(rec.Body.Bytes(), &summary)
+		assert.GreaterOrEqual(t, summary.Balance, 0)
+		assert.NotEmpty(t, summary.Rewards, "Rewards from the ddl.sql setup should be listed")
+	}
+}
+
+func TestComputeRewardDiscount(t *testing.T) {
+	drinkID := 3
+	lines := []RewardLine{
+		{ComputerTime: true, Quantity: 2, UnitPrice: 20000, Amount: 40000},
+		{ServiceID: 3, Quantity: 2, UnitPrice: 3000, Amount: 6000},
+	}
+
+	// Free hours discount the computer time at its hourly rate
+	reward := Reward{ID: 1, Name: "3 Free Hours", RewardType: RewardFreeHours, PointsCost: 250, Hours: 3}
+	applied, err := ComputeRewardDiscount(reward, lines)
+	if assert.NoError(t, err) {
+		assert.Equal(t, float64(40000), applied.Discount, "Only the rented hours can be free")
+		assert.Equal(t, []float64{40000, 0}, applied.LineDiscounts)
+	}
+
+	// Free services discount units of that service
+	reward = Reward{ID: 3, Name: "Free Drink", RewardType: RewardFreeService, PointsCost: 40, ServiceID: &drinkID, Quantity: 1}
+	applied, err = ComputeRewardDiscount(reward, lines)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []float64{0, 3000}, applied.LineDiscounts)
+	}
+
+	// Free hours cannot be redeemed without a rental
+	reward = Reward{ID: 1, Name: "1 Free Hour", RewardType: RewardFreeHours, PointsCost: 100, Hours: 1}
+	_, err = ComputeRewardDiscount(reward, lines[1:])
+	var loyaltyErr *LoyaltyError
+	assert.ErrorAs(t, err, &loyaltyErr)
+}
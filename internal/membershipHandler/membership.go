@@ -0,0 +1,331 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+	audit_handler "w4/p2/milestones/internal/auditHandler"
+	tax_handler "w4/p2/milestones/internal/taxHandler"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CategoryMembership is the tax category of membership plan purchases
+const CategoryMembership = "membership"
+
+// Membership statuses
+const (
+	StatusActive   = "Active"
+	StatusExpired  = "Expired"
+	StatusReplaced = "Replaced"
+)
+
+// PlanHours is the computer time a plan includes for one computer type
+type PlanHours struct {
+	ComputerType  string `json:"computer_type"`
+	IncludedHours *int   `json:"included_hours"` // nil for unlimited
+}
+
+// MembershipPlan structure
+type MembershipPlan struct {
+	ID              int         `json:"id"`
+	Name            string      `json:"name"`
+	Price           float64     `json:"price"`
+	DurationDays    int         `json:"duration_days"`
+	DiscountPercent float64     `json:"discount_percent"` // off everything else bought while the membership is active
+	Hours           []PlanHours `json:"hours"`
+	IsActive        bool        `json:"is_active"`
+	CreatedAt       time.Time   `json:"created_at"`
+}
+
+// Allowance is the computer time left on a membership for one computer type
+type Allowance struct {
+	ComputerType   string `json:"computer_type"`
+	RemainingHours *int   `json:"remaining_hours"` // nil for unlimited
+}
+
+// Membership structure
+type Membership struct {
+	ID              int         `json:"id"`
+	CustomerID      int         `json:"customer_id"`
+	PlanID          int         `json:"plan_id"`
+	PlanName        string      `json:"plan_name"`
+	DiscountPercent float64     `json:"discount_percent"`
+	StartsAt        time.Time   `json:"starts_at"`
+	EndsAt          time.Time   `json:"ends_at"`
+	Status          string      `json:"status"`
+	AutoRenew       bool        `json:"auto_renew"`
+	Allowances      []Allowance `json:"allowances"`
+}
+
+// MembershipLine is one priced line of a rental or purchase. Computer time lines carry
+// the computer type and the billed hours as quantity.
+type MembershipLine struct {
+	ComputerType string
+	Quantity     float64
+	UnitPrice    float64
+	Amount       float64
+}
+
+// AppliedMembership is what a membership takes off an order
+type AppliedMembership struct {
+	MembershipID  int       `json:"membership_id"`
+	PlanName      string    `json:"plan_name"`
+	ComputerType  string    `json:"computer_type,omitempty"`
+	HoursCovered  int       `json:"hours_covered"` // included hours used by the order
+	Discount      float64   `json:"discount"`      // included hours and member discount
+	LineDiscounts []float64 `json:"-"`
+}
+
+// MembershipError explains why a membership cannot cover an order
+type MembershipError struct {
+	Message string
+}
+
+func (e *MembershipError) Error() string {
+	return e.Message
+}
+
+// PlanTaxes prices a plan with the active tax rules
+func PlanTaxes(ctx context.Context, db config.DBTX, plan MembershipPlan) (tax_handler.TaxBreakdown, error) {
+	return tax_handler.QuoteTaxes(ctx, db, []tax_handler.TaxableLine{{Category: CategoryMembership, Amount: plan.Price}})
+}
+
+// LoadPlan fetches a plan with its included hours
+func LoadPlan(ctx context.Context, db config.DBTX, planID int) (MembershipPlan, error) {
+	var plan MembershipPlan
+	query := `
+		SELECT id, name, price, duration_days, discount_percent, is_active, created_at
+		FROM membership_plan
+		WHERE id = $1`
+	err := db.QueryRow(ctx, query, planID).Scan(&plan.ID, &plan.Name, &plan.Price, &plan.DurationDays, &plan.DiscountPercent, &plan.IsActive, &plan.CreatedAt)
+	if err != nil {
+		return plan, err
+	}
+	plan.Hours, err = loadPlanHours(ctx, db, plan.ID)
+	return plan, err
+}
+
+// loadPlanHours fetches the included hours of a plan
+func loadPlanHours(ctx context.Context, db config.DBTX, planID int) ([]PlanHours, error) {
+	query := `SELECT computer_type, included_hours FROM membership_plan_hours WHERE plan_id = $1 ORDER BY computer_type`
+	rows, err := db.Query(ctx, query, planID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch plan hours: %w", err)
+	}
+	defer rows.Close()
+
+	hours := []PlanHours{}
+	for rows.Next() {
+		var h PlanHours
+		if err := rows.Scan(&h.ComputerType, &h.IncludedHours); err != nil {
+			return nil, fmt.Errorf("failed to parse plan hours: %w", err)
+		}
+		hours = append(hours, h)
+	}
+	return hours, rows.Err()
+}
+
+// ActiveMembership returns the customer's active membership, or nil if there is none
+func ActiveMembership(ctx context.Context, db config.DBTX, customerID int) (*Membership, error) {
+	var m Membership
+	query := `
+		SELECT m.id, m.customer_id, m.plan_id, p.name, p.discount_percent, m.starts_at, m.ends_at, m.status, m.auto_renew
+		FROM membership m
+		JOIN membership_plan p ON p.id = m.plan_id
+		WHERE m.customer_id = $1 AND m.status = $2 AND m.starts_at <= NOW() AND m.ends_at > NOW()`
+	err := db.QueryRow(ctx, query, customerID, StatusActive).Scan(
+		&m.ID, &m.CustomerID, &m.PlanID, &m.PlanName, &m.DiscountPercent, &m.StartsAt, &m.EndsAt, &m.Status, &m.AutoRenew,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to fetch membership: %w", err)
+	}
+
+	allowanceQuery := `SELECT computer_type, remaining_hours FROM membership_allowance WHERE membership_id = $1 ORDER BY computer_type`
+	rows, err := db.Query(ctx, allowanceQuery, m.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch membership allowances: %w", err)
+	}
+	defer rows.Close()
+
+	m.Allowances = []Allowance{}
+	for rows.Next() {
+		var a Allowance
+		if err := rows.Scan(&a.ComputerType, &a.RemainingHours); err != nil {
+			return nil, fmt.Errorf("failed to parse membership allowance: %w", err)
+		}
+		m.Allowances = append(m.Allowances, a)
+	}
+	return &m, rows.Err()
+}
+
+// ComputeMembershipDiscount covers the computer time with the included hours left for
+// its computer type, then takes the member discount off whatever is still payable.
+func ComputeMembershipDiscount(m Membership, lines []MembershipLine) AppliedMembership {
+	applied := AppliedMembership{MembershipID: m.ID, PlanName: m.PlanName, LineDiscounts: make([]float64, len(lines))}
+
+	for i, line := range lines {
+		payable := line.Amount
+
+		if line.ComputerType != "" {
+			for _, allowance := range m.Allowances {
+				if !strings.EqualFold(allowance.ComputerType, line.ComputerType) {
+					continue
+				}
+				covered := int(line.Quantity)
+				if allowance.RemainingHours != nil && *allowance.RemainingHours < covered {
+					covered = *allowance.RemainingHours
+				}
+				if covered > 0 {
+					applied.ComputerType = allowance.ComputerType
+					applied.HoursCovered = covered
+					payable -= math.Min(float64(covered)*line.UnitPrice, payable)
+				}
+				break
+			}
+		}
+
+		discount := line.Amount - payable + math.Round(payable*m.DiscountPercent/100)
+		applied.LineDiscounts[i] = discount
+		applied.Discount += discount
+	}
+	return applied
+}
+
+// ApplyMembership computes what the customer's active membership takes off the lines.
+// It returns nil if the customer has no membership or it does not cover the order.
+func ApplyMembership(ctx context.Context, db config.DBTX, customerID int, lines []MembershipLine) (*AppliedMembership, error) {
+	m, err := ActiveMembership(ctx, db, customerID)
+	if err != nil || m == nil {
+		return nil, err
+	}
+	applied := ComputeMembershipDiscount(*m, lines)
+	if applied.Discount == 0 {
+		return nil, nil
+	}
+	return &applied, nil
+}
+
+// ConsumeHours takes the included hours of an order before the customer pays and
+// returns the usage to link to the transaction. It fails with a *MembershipError if the
+// hours were used up since the quote.
+func ConsumeHours(ctx context.Context, db config.DBTX, applied AppliedMembership) (int, error) {
+	if applied.HoursCovered > 0 {
+		query := `
+			UPDATE membership_allowance
+			SET remaining_hours = remaining_hours - $1
+			WHERE membership_id = $2 AND computer_type = $3 AND (remaining_hours IS NULL OR remaining_hours >= $1)`
+		tag, err := db.Exec(ctx, query, applied.HoursCovered, applied.MembershipID, applied.ComputerType)
+		if err != nil {
+			return 0, fmt.Errorf("failed to consume membership hours: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return 0, &MembershipError{Message: "Membership hours were used up since the quote"}
+		}
+	}
+
+	var usageID int
+	insertQuery := `
+		INSERT INTO membership_usage (membership_id, computer_type, hours, discount_amount)
+		VALUES ($1, NULLIF($2, ''), $3, $4)
+		RETURNING id`
+	err := db.QueryRow(ctx, insertQuery, applied.MembershipID, applied.ComputerType, applied.HoursCovered, applied.Discount).Scan(&usageID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record membership usage: %w", err)
+	}
+	return usageID, nil
+}
+
+// LinkUsage attaches a membership usage to the transaction it discounted
+func LinkUsage(ctx context.Context, db config.DBTX, usageID int, applied AppliedMembership, transactionID int) error {
+	query := `UPDATE membership_usage SET transaction_id = $1 WHERE id = $2`
+	if _, err := db.Exec(ctx, query, transactionID, usageID); err != nil {
+		return fmt.Errorf("failed to link membership usage: %w", err)
+	}
+
+	updateQuery := `UPDATE transaction SET membership_discount = $1 WHERE id = $2`
+	if _, err := db.Exec(ctx, updateQuery, applied.Discount, transactionID); err != nil {
+		return fmt.Errorf("failed to save membership discount for transaction %d: %w", transactionID, err)
+	}
+	return nil
+}
+
+// CancelUsage gives back the hours taken by ConsumeHours and removes the usage
+func CancelUsage(ctx context.Context, db config.DBTX, usageID int) error {
+	query := `
+		UPDATE membership_allowance a
+		SET remaining_hours = a.remaining_hours + u.hours
+		FROM membership_usage u
+		WHERE u.id = $1 AND a.membership_id = u.membership_id AND a.computer_type = u.computer_type
+		  AND a.remaining_hours IS NOT NULL`
+	if _, err := db.Exec(ctx, query, usageID); err != nil {
+		return fmt.Errorf("failed to restore membership hours: %w", err)
+	}
+
+	deleteQuery := `DELETE FROM membership_usage WHERE id = $1`
+	if _, err := db.Exec(ctx, deleteQuery, usageID); err != nil {
+		return fmt.Errorf("failed to remove membership usage: %w", err)
+	}
+	return nil
+}
+
+// ReleaseTransactionMembership gives back the hours used by a transaction that will never settle
+func ReleaseTransactionMembership(ctx context.Context, db config.DBTX, transactionID int) error {
+	var usageID int
+	query := `SELECT id FROM membership_usage WHERE transaction_id = $1`
+	err := db.QueryRow(ctx, query, transactionID).Scan(&usageID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to fetch membership usage of transaction %d: %w", transactionID, err)
+	}
+	return CancelUsage(ctx, db, usageID)
+}
+
+// ActivateMembership starts a paid plan for a customer at startsAt, replacing any
+// active membership, and returns the new membership ID
+func ActivateMembership(ctx context.Context, db config.DBTX, customerID int, planID int, autoRenew bool, transactionID int, startsAt time.Time) (int, error) {
+	plan, err := LoadPlan(ctx, db, planID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch membership plan %d: %w", planID, err)
+	}
+
+	replaceQuery := `UPDATE membership SET status = $1 WHERE customer_id = $2 AND status = $3`
+	if _, err := db.Exec(ctx, replaceQuery, StatusReplaced, customerID, StatusActive); err != nil {
+		return 0, fmt.Errorf("failed to replace current membership: %w", err)
+	}
+
+	var membershipID int
+	insertQuery := `
+		INSERT INTO membership (customer_id, plan_id, starts_at, ends_at, status, auto_renew, transaction_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`
+	endsAt := startsAt.AddDate(0, 0, plan.DurationDays)
+	err = db.QueryRow(ctx, insertQuery, customerID, planID, startsAt, endsAt, StatusActive, autoRenew, transactionID).Scan(&membershipID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create membership: %w", err)
+	}
+
+	for _, hours := range plan.Hours {
+		allowanceQuery := `INSERT INTO membership_allowance (membership_id, computer_type, remaining_hours) VALUES ($1, $2, $3)`
+		if _, err := db.Exec(ctx, allowanceQuery, membershipID, hours.ComputerType, hours.IncludedHours); err != nil {
+			return 0, fmt.Errorf("failed to create membership allowance: %w", err)
+		}
+	}
+
+	event := audit_handler.New(audit_handler.ActorSystem, nil, "membership.activate", "membership", membershipID).
+		Change(nil, map[string]interface{}{"customer_id": customerID, "plan_id": planID, "starts_at": startsAt, "ends_at": endsAt,
+			"auto_renew": autoRenew, "transaction_id": transactionID}).
+		Describe("Customer %d started membership %s until %s", customerID, plan.Name, endsAt.Format("2006-01-02"))
+	if err := audit_handler.Record(ctx, db, event); err != nil {
+		return 0, err
+	}
+	return membershipID, nil
+}
@@ -0,0 +1,538 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+	audit_handler "w4/p2/milestones/internal/auditHandler"
+	tax_handler "w4/p2/milestones/internal/taxHandler"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/midtrans/midtrans-go"
+	"github.com/midtrans/midtrans-go/coreapi"
+)
+
+// Initialize Midtrans Core API client
+var coreAPI coreapi.Client
+
+func Init() {
+	// retrieve server key from .env
+	ServerKey := os.Getenv("ServerKey")
+
+	coreAPI = coreapi.Client{}
+	coreAPI.New(ServerKey, midtrans.Sandbox)
+}
+
+// PurchaseMembershipRequest defines the payload to buy a plan
+type PurchaseMembershipRequest struct {
+	PlanID        int    `json:"plan_id" validate:"required"`
+	PaymentMethod string `json:"payment_method" validate:"required"` // "wallet" or "gopay"
+	AutoRenew     bool   `json:"auto_renew"`
+}
+
+// AutoRenewRequest defines the payload to turn auto-renewal on or off
+type AutoRenewRequest struct {
+	AutoRenew bool `json:"auto_renew"`
+}
+
+// MembershipPlanRequest defines the payload to create or update a plan
+type MembershipPlanRequest struct {
+	Name            string      `json:"name" validate:"required"`
+	Price           float64     `json:"price" validate:"required"`
+	DurationDays    int         `json:"duration_days" validate:"required"`
+	DiscountPercent float64     `json:"discount_percent"`
+	Hours           []PlanHours `json:"hours"`
+	IsActive        *bool       `json:"is_active"`
+}
+
+// validate checks the fields shared by create and update
+func (req MembershipPlanRequest) validate() string {
+	switch {
+	case req.Name == "":
+		return "Plan name is required"
+	case req.Price <= 0:
+		return "Price must be greater than zero"
+	case req.DurationDays <= 0:
+		return "Duration must be at least one day"
+	case req.DiscountPercent < 0 || req.DiscountPercent > 100:
+		return "Discount must be between 0 and 100 percent"
+	}
+	for _, hours := range req.Hours {
+		if hours.ComputerType == "" {
+			return "Computer type is required for included hours"
+		}
+		if hours.IncludedHours != nil && *hours.IncludedHours <= 0 {
+			return "Included hours must be greater than zero, or null for unlimited"
+		}
+	}
+	return ""
+}
+
+// loadPlans fetches plans with their included hours
+func loadPlans(ctx context.Context, activeOnly bool) ([]MembershipPlan, error) {
+	query := `
+		SELECT id, name, price, duration_days, discount_percent, is_active, created_at
+		FROM membership_plan
+		WHERE is_active = TRUE OR $1 = FALSE
+		ORDER BY price`
+	rows, err := config.Pool.Query(ctx, query, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	plans := []MembershipPlan{}
+	for rows.Next() {
+		var plan MembershipPlan
+		if err := rows.Scan(&plan.ID, &plan.Name, &plan.Price, &plan.DurationDays, &plan.DiscountPercent, &plan.IsActive, &plan.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		plans = append(plans, plan)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range plans {
+		if plans[i].Hours, err = loadPlanHours(ctx, config.Pool, plans[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return plans, nil
+}
+
+// GetMembershipPlans godoc
+// @Summary List membership plans
+// @Description Retrieve the membership plans on sale with their price, duration, included hours per computer type and member discount
+// @Tags Memberships
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Membership plans retrieved successfully"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /customer/membership/plans [get]
+func GetMembershipPlans(c echo.Context) error {
+	plans, err := loadPlans(context.Background(), true)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch membership plans"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Membership plans retrieved successfully",
+		"data":    plans,
+	})
+}
+
+// GetMyMembership godoc
+// @Summary Get current membership
+// @Description Retrieve the customer's active membership with the included hours left, or null if there is none
+// @Tags Memberships
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Membership retrieved successfully"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /customer/membership [get]
+func GetMyMembership(c echo.Context) error {
+	// Extract customer ID from JWT claims
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	customerID := int(claims["customer_id"].(float64))
+
+	membership, err := ActiveMembership(context.Background(), config.Pool, customerID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch membership"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Membership retrieved successfully",
+		"data":    membership,
+	})
+}
+
+// PurchaseMembership godoc
+// @Summary Buy a membership plan
+// @Description Buy a membership plan with the wallet or GoPay. The plan starts when paid and replaces any active membership. The price includes the active tax rules.
+// @Tags Memberships
+// @Accept json
+// @Produce json
+// @Param request body PurchaseMembershipRequest true "Plan and payment method"
+// @Success 200 {object} map[string]interface{} "Membership purchased, or payment initiated for GoPay"
+// @Failure 400 {object} map[string]string "Invalid request or insufficient wallet balance"
+// @Failure 404 {object} map[string]string "Plan not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /customer/membership/purchase [post]
+func PurchaseMembership(c echo.Context) error {
+	// Initialize Midtrans
+	Init()
+
+	// Extract customer ID from JWT claims
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	customerID := int(claims["customer_id"].(float64))
+
+	var req PurchaseMembershipRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	ctx := context.Background()
+	plan, err := LoadPlan(ctx, config.Pool, req.PlanID)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !plan.IsActive) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Membership plan not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch membership plan"})
+	}
+
+	tax, err := PlanTaxes(ctx, config.Pool, plan)
+	if err != nil {
+		fmt.Println("Tax error:", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to compute tax"})
+	}
+	totalCost := tax.Total
+
+	switch req.PaymentMethod {
+	case "wallet":
+		tx, err := config.Pool.Begin(ctx)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+		}
+		defer tx.Rollback(ctx)
+
+		var walletBalance float64
+		walletQuery := "SELECT wallet FROM customer WHERE id = $1 FOR UPDATE"
+		if err := tx.QueryRow(ctx, walletQuery, customerID).Scan(&walletBalance); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to retrieve wallet balance"})
+		}
+		if walletBalance < totalCost {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Insufficient wallet balance"})
+		}
+
+		deductWalletQuery := "UPDATE customer SET wallet = wallet - $1 WHERE id = $2"
+		if _, err := tx.Exec(ctx, deductWalletQuery, totalCost, customerID); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to deduct wallet balance"})
+		}
+
+		var transactionID int
+		transactionQuery := `
+			INSERT INTO transaction (customer_id, transaction_type, amount, transaction_method, status, transaction_date)
+			VALUES ($1, 'Membership Payment', $2, 'Wallet', 'settlement', NOW()) RETURNING id`
+		if err := tx.QueryRow(ctx, transactionQuery, customerID, totalCost).Scan(&transactionID); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log transaction"})
+		}
+		if err := tax_handler.SaveTransactionTaxes(ctx, tx, transactionID, tax); err != nil {
+			fmt.Println("Tax error:", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log transaction tax"})
+		}
+
+		membershipID, err := ActivateMembership(ctx, tx, customerID, plan.ID, req.AutoRenew, transactionID, time.Now())
+		if err != nil {
+			fmt.Println("Membership error:", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to activate membership"})
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to save membership"})
+		}
+
+		membership, err := ActiveMembership(ctx, config.Pool, customerID)
+		if err != nil || membership == nil || membership.ID != membershipID {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch membership"})
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message":    "Membership purchased successfully",
+			"total_cost": totalCost,
+			"tax_total":  tax.TaxTotal,
+			"membership": membership,
+		})
+
+	case "gopay":
+		orderID := fmt.Sprintf("membership-%d-%d", customerID, time.Now().Unix())
+		paymentRequest := &coreapi.ChargeReq{
+			PaymentType: coreapi.PaymentTypeGopay,
+			TransactionDetails: midtrans.TransactionDetails{
+				OrderID:  orderID,
+				GrossAmt: int64(math.Round(totalCost)),
+			},
+			Gopay: &coreapi.GopayDetails{
+				EnableCallback: true,
+				CallbackUrl:    "https://24d5-66-96-225-168.ngrok-free.app/webhook/payment",
+			},
+		}
+
+		resp, chargeErr := coreAPI.ChargeTransaction(paymentRequest)
+		if chargeErr != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create GoPay payment"})
+		}
+
+		// The membership starts when the payment settles
+		var transactionID int
+		transactionQuery := `
+			INSERT INTO transaction (customer_id, transaction_type, amount, transaction_method, status, payment_url, order_id, metadata)
+			VALUES ($1, 'Membership Payment', $2, 'GoPay', 'Pending', $3, $4, $5) RETURNING id`
+		metadata := map[string]interface{}{
+			"plan_id":    plan.ID,
+			"auto_renew": req.AutoRenew,
+		}
+		err := config.Pool.QueryRow(ctx, transactionQuery, customerID, totalCost, resp.Actions[0].URL, orderID, metadata).Scan(&transactionID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log transaction"})
+		}
+		if err := tax_handler.SaveTransactionTaxes(ctx, config.Pool, transactionID, tax); err != nil {
+			fmt.Println("Tax error:", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log transaction tax"})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message":     "Payment initiated",
+			"order_id":    orderID,
+			"payment_url": resp.Actions[0].URL,
+			"total_cost":  totalCost,
+		})
+
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid payment method"})
+	}
+}
+
+// UpdateAutoRenew godoc
+// @Summary Turn membership auto-renewal on or off
+// @Description When on, the membership is renewed from the wallet for the same plan when it ends
+// @Tags Memberships
+// @Accept json
+// @Produce json
+// @Param request body AutoRenewRequest true "Auto-renewal"
+// @Success 200 {object} map[string]interface{} "Auto-renewal updated"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 404 {object} map[string]string "No active membership"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /customer/membership/auto-renew [put]
+func UpdateAutoRenew(c echo.Context) error {
+	// Extract customer ID from JWT claims
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	customerID := int(claims["customer_id"].(float64))
+
+	var req AutoRenewRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	query := `UPDATE membership SET auto_renew = $1 WHERE customer_id = $2 AND status = $3`
+	tag, err := config.Pool.Exec(context.Background(), query, req.AutoRenew, customerID, StatusActive)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update auto-renewal"})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "No active membership"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":    "Auto-renewal updated",
+		"auto_renew": req.AutoRenew,
+	})
+}
+
+// requireSuperAdmin returns the admin ID if the JWT belongs to a super-admin
+func requireSuperAdmin(c echo.Context) (int, bool) {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	adminRole, _ := claims["role"].(string)
+	adminID, _ := claims["admin_id"].(float64)
+	return int(adminID), adminRole == "super-admin"
+}
+
+// savePlanHours replaces the included hours of a plan
+func savePlanHours(ctx context.Context, db config.DBTX, planID int, hours []PlanHours) error {
+	if _, err := db.Exec(ctx, `DELETE FROM membership_plan_hours WHERE plan_id = $1`, planID); err != nil {
+		return err
+	}
+	for _, h := range hours {
+		query := `INSERT INTO membership_plan_hours (plan_id, computer_type, included_hours) VALUES ($1, $2, $3)`
+		if _, err := db.Exec(ctx, query, planID, h.ComputerType, h.IncludedHours); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetAdminMembershipPlans godoc
+// @Summary List all membership plans
+// @Description Retrieve every membership plan, including those no longer on sale
+// @Tags Memberships
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Membership plans retrieved successfully"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/membership-plans [get]
+func GetAdminMembershipPlans(c echo.Context) error {
+	if _, ok := requireSuperAdmin(c); !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can manage membership plans."})
+	}
+
+	plans, err := loadPlans(context.Background(), false)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch membership plans"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Membership plans retrieved successfully",
+		"data":    plans,
+	})
+}
+
+// CreateMembershipPlan godoc
+// @Summary Create a membership plan
+// @Description Create a plan with a price, duration, included hours per computer type (null hours for unlimited) and a member discount
+// @Tags Memberships
+// @Accept json
+// @Produce json
+// @Param request body MembershipPlanRequest true "Membership plan"
+// @Success 200 {object} MembershipPlan "Created plan"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/membership-plans [post]
+func CreateMembershipPlan(c echo.Context) error {
+	adminID, ok := requireSuperAdmin(c)
+	if !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can manage membership plans."})
+	}
+
+	var req MembershipPlanRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+	if msg := req.validate(); msg != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": msg})
+	}
+
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	var planID int
+	query := `
+		INSERT INTO membership_plan (name, price, duration_days, discount_percent, is_active)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	if err := tx.QueryRow(ctx, query, req.Name, req.Price, req.DurationDays, req.DiscountPercent, isActive).Scan(&planID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create membership plan"})
+	}
+	if err := savePlanHours(ctx, tx, planID, req.Hours); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to save included hours"})
+	}
+
+	plan, err := LoadPlan(ctx, tx, planID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch membership plan"})
+	}
+
+	event := audit_handler.FromRequest(c, "membership_plan.create", "membership_plan", planID).Change(nil, plan).
+		Describe("Super-admin (ID: %d) created membership plan %s", adminID, req.Name)
+	if err := audit_handler.Record(ctx, tx, event); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log admin action"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to save membership plan"})
+	}
+
+	return c.JSON(http.StatusOK, plan)
+}
+
+// UpdateMembershipPlan godoc
+// @Summary Update a membership plan
+// @Description Change a plan's price, duration, included hours, discount or active flag. Current memberships keep the hours they started with.
+// @Tags Memberships
+// @Accept json
+// @Produce json
+// @Param id path int true "Plan ID"
+// @Param request body MembershipPlanRequest true "Membership plan"
+// @Success 200 {object} MembershipPlan "Updated plan"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Plan not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/membership-plans/{id} [put]
+func UpdateMembershipPlan(c echo.Context) error {
+	adminID, ok := requireSuperAdmin(c)
+	if !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can manage membership plans."})
+	}
+
+	planID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid plan ID"})
+	}
+
+	var req MembershipPlanRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+	if msg := req.validate(); msg != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": msg})
+	}
+
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT id FROM membership_plan WHERE id = $1 FOR UPDATE`, planID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch membership plan"})
+	}
+	before, err := LoadPlan(ctx, tx, planID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Membership plan not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch membership plan"})
+	}
+
+	query := `
+		UPDATE membership_plan
+		SET name = $1, price = $2, duration_days = $3, discount_percent = $4, is_active = COALESCE($5, is_active)
+		WHERE id = $6`
+	if _, err := tx.Exec(ctx, query, req.Name, req.Price, req.DurationDays, req.DiscountPercent, req.IsActive, planID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update membership plan"})
+	}
+	if err := savePlanHours(ctx, tx, planID, req.Hours); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to save included hours"})
+	}
+
+	plan, err := LoadPlan(ctx, tx, planID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch membership plan"})
+	}
+
+	event := audit_handler.FromRequest(c, "membership_plan.update", "membership_plan", planID).Change(before, plan).
+		Describe("Super-admin (ID: %d) updated membership plan %d (%s)", adminID, planID, req.Name)
+	if err := audit_handler.Record(ctx, tx, event); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log admin action"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to save membership plan"})
+	}
+
+	return c.JSON(http.StatusOK, plan)
+}
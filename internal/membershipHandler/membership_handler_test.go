@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetMembershipPlans(t *testing.T) {
+	// Setup Echo
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/customer/membership/plans", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	// Manually set the JWT claims
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"customer_id": float64(1), // Customer ID from the ddl.sql setup
+	})
+	c.Set("user", token)
+
+	err := GetMembershipPlans(c)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.Equal(t, "Membership plans retrieved successfully", response["message"])
+		assert.NotEmpty(t, response["data"])
+	}
+}
+
+func TestComputeMembershipDiscount(t *testing.T) {
+	remaining := 2
+	membership := Membership{
+		ID:              1,
+		PlanName:        "Gaming 20 Hours",
+		DiscountPercent: 10,
+		Allowances:      []Allowance{{ComputerType: "Gaming", RemainingHours: &remaining}},
+	}
+	lines := []MembershipLine{
+		{ComputerType: "Gaming", Quantity: 3, UnitPrice: 20000, Amount: 60000},
+		{Quantity: 2, UnitPrice: 3000, Amount: 6000},
+	}
+
+	// Included hours cover what is left, the member discount applies to the rest
+	applied := ComputeMembershipDiscount(membership, lines)
+	assert.Equal(t, 2, applied.HoursCovered)
+	assert.Equal(t, []float64{42000, 600}, applied.LineDiscounts)
+	assert.Equal(t, float64(42600), applied.Discount)
+
+	// Unlimited hours cover the whole rental
+	membership.Allowances[0].RemainingHours = nil
+	applied = ComputeMembershipDiscount(membership, lines[:1])
+	assert.Equal(t, 3, applied.HoursCovered)
+	assert.Equal(t, float64(60000), applied.Discount)
+}
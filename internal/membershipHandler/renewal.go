@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+	audit_handler "w4/p2/milestones/internal/auditHandler"
+	tax_handler "w4/p2/milestones/internal/taxHandler"
+)
+
+// RenewalResult is the outcome of one ended membership
+type RenewalResult struct {
+	MembershipID int    `json:"membership_id"`
+	CustomerID   int    `json:"customer_id"`
+	Action       string `json:"action"` // renewed, expired or renewal_failed
+}
+
+// StartMembershipRenewal renews or expires ended memberships every interval
+func StartMembershipRenewal(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			results, err := RenewMemberships(context.Background())
+			if err != nil {
+				fmt.Printf("Membership renewal error: %v\n", err)
+				continue
+			}
+			if len(results) > 0 {
+				fmt.Printf("Membership renewal processed %d ended memberships\n", len(results))
+			}
+		}
+	}()
+}
+
+// RenewMemberships processes every active membership that has ended. Memberships set
+// to auto-renew are charged from the wallet for the same plan, starting when the old
+// one ended; the rest, and those the wallet cannot cover, are expired.
+func RenewMemberships(ctx context.Context) ([]RenewalResult, error) {
+	query := `SELECT id FROM membership WHERE status = $1 AND ends_at <= NOW() ORDER BY ends_at`
+	rows, err := config.Pool.Query(ctx, query, StatusActive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch ended memberships: %w", err)
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to parse membership: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var results []RenewalResult
+	for _, id := range ids {
+		result, err := renewMembership(ctx, id)
+		if err != nil {
+			fmt.Printf("Failed to renew membership %d: %v\n", id, err)
+			continue
+		}
+		if result != nil {
+			results = append(results, *result)
+		}
+	}
+	return results, nil
+}
+
+// renewMembership renews or expires one ended membership in its own transaction
+func renewMembership(ctx context.Context, membershipID int) (*RenewalResult, error) {
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the membership and skip it if another run already handled it
+	var result RenewalResult
+	var planID int
+	var autoRenew bool
+	var endsAt time.Time
+	var status string
+	query := `SELECT customer_id, plan_id, auto_renew, ends_at, status FROM membership WHERE id = $1 FOR UPDATE`
+	err = tx.QueryRow(ctx, query, membershipID).Scan(&result.CustomerID, &planID, &autoRenew, &endsAt, &status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock membership: %w", err)
+	}
+	if status != StatusActive || endsAt.After(time.Now()) {
+		return nil, tx.Commit(ctx)
+	}
+	result.MembershipID = membershipID
+
+	expireQuery := `UPDATE membership SET status = $1 WHERE id = $2`
+	if _, err := tx.Exec(ctx, expireQuery, StatusExpired, membershipID); err != nil {
+		return nil, fmt.Errorf("failed to expire membership: %w", err)
+	}
+
+	result.Action = "expired"
+	if autoRenew {
+		renewed, err := chargeRenewal(ctx, tx, result.CustomerID, planID, endsAt)
+		if err != nil {
+			return nil, err
+		}
+		result.Action = "renewal_failed"
+		if renewed {
+			result.Action = "renewed"
+		}
+	}
+
+	if result.Action != "renewed" {
+		event := audit_handler.New(audit_handler.ActorSystem, nil, "membership."+result.Action, "membership", membershipID).
+			Change(map[string]string{"status": status}, map[string]string{"status": StatusExpired}).
+			Describe("Membership %d of Customer %d %s", membershipID, result.CustomerID, result.Action)
+		if err := audit_handler.Record(ctx, tx, event); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit renewal: %w", err)
+	}
+	return &result, nil
+}
+
+// chargeRenewal charges the plan price from the wallet and starts the next period. It
+// reports false if the plan is no longer sold or the wallet cannot cover it.
+func chargeRenewal(ctx context.Context, tx config.DBTX, customerID int, planID int, startsAt time.Time) (bool, error) {
+	plan, err := LoadPlan(ctx, tx, planID)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch membership plan %d: %w", planID, err)
+	}
+	if !plan.IsActive {
+		return false, nil
+	}
+
+	tax, err := PlanTaxes(ctx, tx, plan)
+	if err != nil {
+		return false, err
+	}
+
+	var walletBalance float64
+	walletQuery := `SELECT wallet FROM customer WHERE id = $1 FOR UPDATE`
+	if err := tx.QueryRow(ctx, walletQuery, customerID).Scan(&walletBalance); err != nil {
+		return false, fmt.Errorf("failed to retrieve wallet balance: %w", err)
+	}
+	if walletBalance < tax.Total {
+		return false, nil
+	}
+
+	deductWalletQuery := `UPDATE customer SET wallet = wallet - $1 WHERE id = $2`
+	if _, err := tx.Exec(ctx, deductWalletQuery, tax.Total, customerID); err != nil {
+		return false, fmt.Errorf("failed to deduct wallet balance: %w", err)
+	}
+
+	var transactionID int
+	transactionQuery := `
+		INSERT INTO transaction (customer_id, transaction_type, amount, transaction_method, status, transaction_date)
+		VALUES ($1, 'Membership Payment', $2, 'Wallet', 'settlement', NOW()) RETURNING id`
+	if err := tx.QueryRow(ctx, transactionQuery, customerID, tax.Total).Scan(&transactionID); err != nil {
+		return false, fmt.Errorf("failed to log transaction: %w", err)
+	}
+	if err := tax_handler.SaveTransactionTaxes(ctx, tx, transactionID, tax); err != nil {
+		return false, err
+	}
+
+	if _, err := ActivateMembership(ctx, tx, customerID, planID, true, transactionID, startsAt); err != nil {
+		return false, err
+	}
+	return true, nil
+}
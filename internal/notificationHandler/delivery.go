@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Notifier delivers messages outside the app, such as scheduled reports
+type Notifier interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// Message is a delivery to a list of recipients
+type Message struct {
+	Recipients  []string
+	Subject     string
+	Body        string
+	Attachments []Attachment
+}
+
+// Attachment is a file sent with a message
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Content     []byte
+}
+
+// NotifierFromEnv picks the notifier configured by REPORT_NOTIFIER: smtp sends mail
+// through SMTP_HOST, and file writes deliveries to REPORT_OUTBOX_DIR. Without it, mail
+// is sent when SMTP_HOST is set and written to files otherwise.
+func NotifierFromEnv() Notifier {
+	kind := strings.ToLower(os.Getenv("REPORT_NOTIFIER"))
+	if kind == "" && os.Getenv("SMTP_HOST") != "" {
+		kind = "smtp"
+	}
+	if kind == "smtp" {
+		return SMTPNotifier{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+		}
+	}
+
+	dir := os.Getenv("REPORT_OUTBOX_DIR")
+	if dir == "" {
+		dir = "outbox"
+	}
+	return FileNotifier{Dir: dir}
+}
+
+// SMTPNotifier sends messages as mail with the attachments
+type SMTPNotifier struct {
+	Host     string
+	Port     string // 587 if empty
+	Username string // no authentication if empty
+	Password string
+	From     string
+}
+
+func (n SMTPNotifier) Send(ctx context.Context, message Message) error {
+	if len(message.Recipients) == 0 {
+		return fmt.Errorf("no recipients for %q", message.Subject)
+	}
+	port := n.Port
+	if port == "" {
+		port = "587"
+	}
+	var auth smtp.Auth
+	if n.Username != "" {
+		auth = smtp.PlainAuth("", n.Username, n.Password, n.Host)
+	}
+
+	mail, err := BuildMail(n.From, message)
+	if err != nil {
+		return err
+	}
+	if err := smtp.SendMail(n.Host+":"+port, auth, n.From, message.Recipients, mail); err != nil {
+		return fmt.Errorf("failed to send %q: %w", message.Subject, err)
+	}
+	return nil
+}
+
+// BuildMail writes a message as a MIME mail with the body as text and the attachments in base64
+func BuildMail(from string, message Message) ([]byte, error) {
+	var buf bytes.Buffer
+	parts := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(message.Recipients, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", parts.Boundary())
+
+	body, err := parts.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+	if err != nil {
+		return nil, err
+	}
+	body.Write([]byte(strings.ReplaceAll(message.Body, "\n", "\r\n")))
+
+	for _, attachment := range message.Attachments {
+		part, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {attachment.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoded := base64.StdEncoding.EncodeToString(attachment.Content)
+		for len(encoded) > 76 {
+			part.Write([]byte(encoded[:76] + "\r\n"))
+			encoded = encoded[76:]
+		}
+		part.Write([]byte(encoded + "\r\n"))
+	}
+
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// FileNotifier writes each message to its own folder under Dir, with the attachments
+// beside it, for local runs and tests
+type FileNotifier struct {
+	Dir string
+}
+
+var unsafeFilename = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func (n FileNotifier) Send(ctx context.Context, message Message) error {
+	name := time.Now().Format("20060102-150405.000000") + "-" + strings.Trim(unsafeFilename.ReplaceAllString(message.Subject, "-"), "-")
+	dir := filepath.Join(n.Dir, name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create outbox folder: %w", err)
+	}
+
+	text := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", strings.Join(message.Recipients, ", "), message.Subject, message.Body)
+	if err := os.WriteFile(filepath.Join(dir, "message.txt"), []byte(text), 0o644); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	for _, attachment := range message.Attachments {
+		path := filepath.Join(dir, unsafeFilename.ReplaceAllString(filepath.Base(attachment.Filename), "-"))
+		if err := os.WriteFile(path, attachment.Content, 0o644); err != nil {
+			return fmt.Errorf("failed to write attachment: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+)
+
+// Notification types
+const (
+	TypeLowStock            = "low_stock"
+	TypeOversold            = "oversold"
+	TypeUnreturnedEquipment = "unreturned_equipment"
+	TypePaymentConflict     = "payment_conflict"
+)
+
+// Notification is a message for the admins
+type Notification struct {
+	ID               int        `json:"id"`
+	NotificationType string     `json:"notification_type"`
+	Message          string     `json:"message"`
+	ServiceID        *int       `json:"service_id"`
+	ReadBy           *int       `json:"read_by"` // admin who acknowledged it
+	ReadAt           *time.Time `json:"read_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// NotifyAdmins posts a notification for the admins. It runs on db so it is only
+// posted if the caller's transaction commits.
+func NotifyAdmins(ctx context.Context, db config.DBTX, notificationType string, serviceID *int, message string) error {
+	query := `INSERT INTO admin_notification (notification_type, service_id, message) VALUES ($1, $2, $3)`
+	if _, err := db.Exec(ctx, query, notificationType, serviceID, message); err != nil {
+		return fmt.Errorf("failed to notify admins: %w", err)
+	}
+	return nil
+}
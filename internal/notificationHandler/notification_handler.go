@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	config "w4/p2/milestones/config/database"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// requireAdmin returns the admin ID if the JWT belongs to an admin or super-admin
+func requireAdmin(c echo.Context) (int, bool) {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	adminRole, _ := claims["role"].(string)
+	adminID, _ := claims["admin_id"].(float64)
+	return int(adminID), adminRole == "admin" || adminRole == "super-admin"
+}
+
+// GetNotifications godoc
+// @Summary List admin notifications
+// @Description Retrieve notifications for the admins, such as low stock, newest first
+// @Tags Notifications
+// @Produce json
+// @Param unread query bool false "Only notifications nobody has read"
+// @Param type query string false "Notification type (low_stock, oversold)"
+// @Success 200 {object} map[string]interface{} "Notifications retrieved successfully"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/notifications [get]
+func GetNotifications(c echo.Context) error {
+	if _, ok := requireAdmin(c); !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only selected admins can perform this action."})
+	}
+
+	unreadOnly := c.QueryParam("unread") == "true"
+	query := `
+		SELECT id, notification_type, message, service_id, read_by, read_at, created_at
+		FROM admin_notification
+		WHERE (NOT $1 OR read_at IS NULL) AND ($2 = '' OR notification_type = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT 200`
+	rows, err := config.Pool.Query(context.Background(), query, unreadOnly, c.QueryParam("type"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch notifications"})
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		var n Notification
+		if err := rows.Scan(&n.ID, &n.NotificationType, &n.Message, &n.ServiceID, &n.ReadBy, &n.ReadAt, &n.CreatedAt); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to parse notifications"})
+		}
+		notifications = append(notifications, n)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Notifications retrieved successfully",
+		"data":    notifications,
+	})
+}
+
+// MarkNotificationRead godoc
+// @Summary Mark a notification as read
+// @Description Acknowledge an admin notification
+// @Tags Notifications
+// @Produce json
+// @Param id path int true "Notification ID"
+// @Success 200 {object} map[string]string "Notification marked as read"
+// @Failure 400 {object} map[string]string "Invalid notification ID"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Notification not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/notifications/{id}/read [put]
+func MarkNotificationRead(c echo.Context) error {
+	adminID, ok := requireAdmin(c)
+	if !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only selected admins can perform this action."})
+	}
+
+	notificationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid notification ID"})
+	}
+
+	query := `UPDATE admin_notification SET read_by = $1, read_at = NOW() WHERE id = $2 AND read_at IS NULL`
+	tag, err := config.Pool.Exec(context.Background(), query, adminID, notificationID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update notification"})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Unread notification not found"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Notification marked as read"})
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetNotifications(t *testing.T) {
+	// Setup Echo
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/admin/notifications?unread=true", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	// Manually set the JWT claims
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"admin_id": float64(1),
+		"role":     "admin",
+	})
+	c.Set("user", token)
+
+	err := GetNotifications(c)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.Equal(t, "Notifications retrieved successfully", response["message"])
+	}
+}
+
+func TestMarkNotificationReadRequiresAdmin(t *testing.T) {
+	// Setup Echo
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPut, "/admin/notifications/1/read", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	// Customers cannot acknowledge admin notifications
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"customer_id": float64(1),
+	})
+	c.Set("user", token)
+
+	err := MarkNotificationRead(c)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	}
+}
+
+func TestFileNotifier(t *testing.T) {
+	dir := t.TempDir()
+	notifier := FileNotifier{Dir: dir}
+
+	message := Message{
+		Recipients:  []string{"owner@example.com"},
+		Subject:     "Revenue Report 2024-12-18",
+		Body:        "Total revenue: Rp 340,000",
+		Attachments: []Attachment{{Filename: "revenue-report-2024-12-18.csv", ContentType: "text/csv", Content: []byte("Section,Item\n")}},
+	}
+	if !assert.NoError(t, notifier.Send(context.Background(), message)) {
+		return
+	}
+
+	folders, _ := os.ReadDir(dir)
+	if assert.Len(t, folders, 1) {
+		assert.True(t, strings.HasSuffix(folders[0].Name(), "-Revenue-Report-2024-12-18"))
+		text, _ := os.ReadFile(filepath.Join(dir, folders[0].Name(), "message.txt"))
+		assert.Contains(t, string(text), "To: owner@example.com")
+		attachment, _ := os.ReadFile(filepath.Join(dir, folders[0].Name(), "revenue-report-2024-12-18.csv"))
+		assert.Equal(t, "Section,Item\n", string(attachment))
+	}
+}
+
+func TestBuildMail(t *testing.T) {
+	mail, err := BuildMail("reports@example.com", Message{
+		Recipients:  []string{"owner@example.com", "accountant@example.com"},
+		Subject:     "Revenue Report",
+		Body:        "Attached",
+		Attachments: []Attachment{{Filename: "report.xlsx", ContentType: "application/octet-stream", Content: make([]byte, 100)}},
+	})
+	if assert.NoError(t, err) {
+		text := string(mail)
+		assert.Contains(t, text, "To: owner@example.com, accountant@example.com\r\n")
+		assert.Contains(t, text, "Content-Type: multipart/mixed; boundary=")
+		assert.Contains(t, text, `Content-Disposition: attachment; filename=report.xlsx`)
+		for _, line := range strings.Split(text, "\r\n") {
+			assert.LessOrEqual(t, len(line), 998, "Mail lines must stay within the SMTP limit")
+		}
+	}
+}
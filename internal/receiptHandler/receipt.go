@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+	tax_handler "w4/p2/milestones/internal/taxHandler"
+)
+
+// Receipt structure
+type Receipt struct {
+	ID              int       `json:"id"`
+	InvoiceNumber   string    `json:"invoice_number"`
+	CustomerID      int       `json:"customer_id"`
+	TransactionID   int       `json:"transaction_id"`
+	RentalHistoryID *int      `json:"rental_history_id"`
+	OrderID         *string   `json:"order_id"`
+	PaymentMethod   string    `json:"payment_method"`
+	Total           float64   `json:"total"`
+	ReprintCount    int       `json:"reprint_count"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// ReceiptRental describes the rented computer and rental window
+type ReceiptRental struct {
+	ComputerName string    `json:"computer_name"`
+	ComputerType string    `json:"computer_type"`
+	HourlyRate   float64   `json:"hourly_rate"`
+	RentalStart  time.Time `json:"rental_start"`
+	RentalEnd    time.Time `json:"rental_end"`
+	TotalCost    float64   `json:"total_cost"`
+}
+
+// ReceiptLine is one service line item from rental_services at the price charged,
+// bundled services at their share of the bundle price
+type ReceiptLine struct {
+	ServiceID   int     `json:"service_id"`
+	ServiceName string  `json:"service_name"`
+	Quantity    int     `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+	Amount      float64 `json:"amount"`
+}
+
+// ReceiptDetail is everything printed on a receipt
+type ReceiptDetail struct {
+	Receipt
+	CustomerName       string                   `json:"customer_name"`
+	Rental             *ReceiptRental           `json:"rental"`
+	Services           []ReceiptLine            `json:"services"`
+	Discount           float64                  `json:"discount"`
+	VoucherCode        *string                  `json:"voucher_code"`
+	MembershipDiscount float64                  `json:"membership_discount"`
+	PrepaidTimeCredit  float64                  `json:"prepaid_time_credit"`
+	LoyaltyDiscount    float64                  `json:"loyalty_discount"`
+	Subtotal           float64                  `json:"subtotal"`
+	Taxes              []tax_handler.AppliedTax `json:"taxes"`
+}
+
+// CreateReceipt issues a receipt with the next running invoice number for a settled
+// transaction. It runs on db so it can join the caller's transaction.
+func CreateReceipt(ctx context.Context, db config.DBTX, transactionID int, rentalHistoryID *int) (Receipt, error) {
+	var receipt Receipt
+	query := `
+		INSERT INTO receipt (invoice_number, customer_id, transaction_id, rental_history_id, order_id, payment_method, total)
+		SELECT 'INV-' || LPAD(nextval('invoice_number_seq')::TEXT, 6, '0'), t.customer_id, t.id, $2, t.order_id,
+		       COALESCE(t.transaction_method, ''), t.amount
+		FROM transaction t
+		WHERE t.id = $1
+		RETURNING id, invoice_number, customer_id, transaction_id, rental_history_id, order_id, payment_method, total, reprint_count, created_at`
+	err := db.QueryRow(ctx, query, transactionID, rentalHistoryID).Scan(
+		&receipt.ID, &receipt.InvoiceNumber, &receipt.CustomerID, &receipt.TransactionID, &receipt.RentalHistoryID,
+		&receipt.OrderID, &receipt.PaymentMethod, &receipt.Total, &receipt.ReprintCount, &receipt.CreatedAt,
+	)
+	if err != nil {
+		return receipt, fmt.Errorf("failed to create receipt for transaction %d: %w", transactionID, err)
+	}
+	return receipt, nil
+}
+
+// loadReceiptDetail fetches a receipt with its rental and service line items
+func loadReceiptDetail(ctx context.Context, receiptID int) (ReceiptDetail, error) {
+	var detail ReceiptDetail
+	query := `
+		SELECT r.id, r.invoice_number, r.customer_id, r.transaction_id, r.rental_history_id, r.order_id,
+		       r.payment_method, r.total, r.reprint_count, r.created_at, cu.name, COALESCE(t.discount_amount, 0), v.code,
+		       COALESCE(t.membership_discount, 0), COALESCE(t.time_credit, 0), COALESCE(t.loyalty_discount, 0)
+		FROM receipt r
+		JOIN customer cu ON cu.id = r.customer_id
+		JOIN transaction t ON t.id = r.transaction_id
+		LEFT JOIN voucher v ON v.id = t.voucher_id
+		WHERE r.id = $1`
+	err := config.Pool.QueryRow(ctx, query, receiptID).Scan(
+		&detail.ID, &detail.InvoiceNumber, &detail.CustomerID, &detail.TransactionID, &detail.RentalHistoryID,
+		&detail.OrderID, &detail.PaymentMethod, &detail.Total, &detail.ReprintCount, &detail.CreatedAt, &detail.CustomerName,
+		&detail.Discount, &detail.VoucherCode, &detail.MembershipDiscount, &detail.PrepaidTimeCredit, &detail.LoyaltyDiscount,
+	)
+	if err != nil {
+		return detail, err
+	}
+
+	if detail.RentalHistoryID != nil {
+		var rental ReceiptRental
+		rentalQuery := `
+			SELECT c.name, c.type, rh.unit_price, rh.rental_start_time, rh.rental_end_time, rh.total_cost
+			FROM rental_history rh
+			JOIN computer c ON c.id = rh.computer_id
+			WHERE rh.id = $1`
+		err = config.Pool.QueryRow(ctx, rentalQuery, *detail.RentalHistoryID).Scan(
+			&rental.ComputerName, &rental.ComputerType, &rental.HourlyRate, &rental.RentalStart, &rental.RentalEnd, &rental.TotalCost,
+		)
+		if err != nil {
+			return detail, fmt.Errorf("failed to fetch rental: %w", err)
+		}
+		detail.Rental = &rental
+	}
+
+	servicesQuery := `
+		SELECT s.id, s.name, rs.quantity, rs.unit_price
+		FROM rental_services rs
+		JOIN service s ON s.id = rs.service_id
+		WHERE rs.transaction_id = $1
+		ORDER BY rs.id`
+	rows, err := config.Pool.Query(ctx, servicesQuery, detail.TransactionID)
+	if err != nil {
+		return detail, fmt.Errorf("failed to fetch service lines: %w", err)
+	}
+	defer rows.Close()
+
+	detail.Services = []ReceiptLine{}
+	for rows.Next() {
+		var line ReceiptLine
+		if err := rows.Scan(&line.ServiceID, &line.ServiceName, &line.Quantity, &line.UnitPrice); err != nil {
+			return detail, fmt.Errorf("failed to parse service line: %w", err)
+		}
+		line.Amount = line.UnitPrice * float64(line.Quantity)
+		detail.Services = append(detail.Services, line)
+	}
+	if err := rows.Err(); err != nil {
+		return detail, err
+	}
+
+	// The subtotal is after the discount and excludes the taxes added on top of the prices
+	detail.Taxes, err = tax_handler.LoadTransactionTaxes(ctx, config.Pool, detail.TransactionID)
+	if err != nil {
+		return detail, err
+	}
+	detail.Subtotal = detail.Total
+	for _, tax := range detail.Taxes {
+		if !tax.Inclusive {
+			detail.Subtotal -= tax.TaxAmount
+		}
+	}
+	return detail, nil
+}
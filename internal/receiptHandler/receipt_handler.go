@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	config "w4/p2/milestones/config/database"
+	audit_handler "w4/p2/milestones/internal/auditHandler"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// respondWithReceipt writes the receipt as JSON, PDF or thermal printer text
+func respondWithReceipt(c echo.Context, detail ReceiptDetail, reprint bool) error {
+	switch c.QueryParam("format") {
+	case "pdf":
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%s.pdf", detail.InvoiceNumber))
+		return c.Blob(http.StatusOK, "application/pdf", RenderPDF(detail, reprint))
+	case "text":
+		return c.String(http.StatusOK, RenderThermal(detail, reprint))
+	case "", "json":
+		return c.JSON(http.StatusOK, detail)
+	}
+	return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid format. Use json, pdf or text"})
+}
+
+// GetCustomerReceipts godoc
+// @Summary List receipts
+// @Description Retrieve the authenticated customer's receipts, most recent first
+// @Tags Receipts
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Receipts retrieved successfully"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /customer/receipts [get]
+func GetCustomerReceipts(c echo.Context) error {
+	// Extract customer ID from the JWT claims
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	customerID := int(claims["customer_id"].(float64))
+
+	query := `
+		SELECT id, invoice_number, customer_id, transaction_id, rental_history_id, order_id, payment_method, total, reprint_count, created_at
+		FROM receipt
+		WHERE customer_id = $1
+		ORDER BY created_at DESC`
+	rows, err := config.Pool.Query(context.Background(), query, customerID)
+	if err != nil {
+		fmt.Printf("Query error: %v\n", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch receipts"})
+	}
+	defer rows.Close()
+
+	receipts := []Receipt{}
+	for rows.Next() {
+		var receipt Receipt
+		if err := rows.Scan(&receipt.ID, &receipt.InvoiceNumber, &receipt.CustomerID, &receipt.TransactionID, &receipt.RentalHistoryID,
+			&receipt.OrderID, &receipt.PaymentMethod, &receipt.Total, &receipt.ReprintCount, &receipt.CreatedAt); err != nil {
+			fmt.Printf("Scan error: %v\n", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to process receipts"})
+		}
+		receipts = append(receipts, receipt)
+	}
+
+	if err := rows.Err(); err != nil {
+		fmt.Printf("Row iteration error: %v\n", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to process receipts"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Receipts retrieved successfully",
+		"data":    receipts,
+	})
+}
+
+// GetCustomerReceipt godoc
+// @Summary Download a receipt
+// @Description Retrieve one of the authenticated customer's receipts as JSON, PDF or plain-text thermal printer format
+// @Tags Receipts
+// @Produce json,application/pdf,plain
+// @Param id path int true "Receipt ID"
+// @Param format query string false "json (default), pdf or text"
+// @Success 200 {object} ReceiptDetail "Receipt"
+// @Failure 400 {object} map[string]string "Invalid receipt ID or format"
+// @Failure 404 {object} map[string]string "Receipt not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /customer/receipts/{id} [get]
+func GetCustomerReceipt(c echo.Context) error {
+	// Extract customer ID from the JWT claims
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	customerID := int(claims["customer_id"].(float64))
+
+	receiptID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid receipt ID"})
+	}
+
+	detail, err := loadReceiptDetail(context.Background(), receiptID)
+	// Receipts of other customers are reported as missing rather than forbidden
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && detail.CustomerID != customerID) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Receipt not found"})
+	} else if err != nil {
+		fmt.Printf("Receipt error: %v\n", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch receipt"})
+	}
+
+	return respondWithReceipt(c, detail, false)
+}
+
+// GetReceipt godoc
+// @Summary View a receipt
+// @Description Allows admins to view any receipt without counting a reprint
+// @Tags Receipts
+// @Produce json,application/pdf,plain
+// @Param id path int true "Receipt ID"
+// @Param format query string false "json (default), pdf or text"
+// @Success 200 {object} ReceiptDetail "Receipt"
+// @Failure 400 {object} map[string]string "Invalid receipt ID or format"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Receipt not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/receipts/{id} [get]
+func GetReceipt(c echo.Context) error {
+	// Extract admin role from JWT claims
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	adminRole := claims["role"].(string)
+
+	// Validate admin role
+	if adminRole != "admin" && adminRole != "super-admin" {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only selected admins can perform this action."})
+	}
+
+	receiptID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid receipt ID"})
+	}
+
+	detail, err := loadReceiptDetail(context.Background(), receiptID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Receipt not found"})
+	} else if err != nil {
+		fmt.Printf("Receipt error: %v\n", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch receipt"})
+	}
+
+	return respondWithReceipt(c, detail, false)
+}
+
+// ReprintReceipt godoc
+// @Summary Reprint a receipt
+// @Description Allows admins to reprint any receipt. Each reprint is counted and marked on the printed copy.
+// @Tags Receipts
+// @Produce plain,application/pdf,json
+// @Param id path int true "Receipt ID"
+// @Param format query string false "json (default), pdf or text"
+// @Success 200 {object} ReceiptDetail "Receipt"
+// @Failure 400 {object} map[string]string "Invalid receipt ID or format"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Receipt not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/receipts/{id}/reprint [post]
+func ReprintReceipt(c echo.Context) error {
+	// Extract admin ID and role from JWT claims
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	adminID := int(claims["admin_id"].(float64))
+	adminRole := claims["role"].(string)
+
+	// Validate admin role
+	if adminRole != "admin" && adminRole != "super-admin" {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only selected admins can perform this action."})
+	}
+
+	receiptID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid receipt ID"})
+	}
+
+	// Count the reprint before rendering so the copy shows its number
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	var invoiceNumber string
+	var reprintCount int
+	reprintQuery := `UPDATE receipt SET reprint_count = reprint_count + 1 WHERE id = $1 RETURNING invoice_number, reprint_count`
+	err = tx.QueryRow(ctx, reprintQuery, receiptID).Scan(&invoiceNumber, &reprintCount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Receipt not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to reprint receipt"})
+	}
+
+	// Log the reprint
+	event := audit_handler.FromRequest(c, "receipt.reprint", "receipt", receiptID).
+		Change(map[string]int{"reprint_count": reprintCount - 1}, map[string]int{"reprint_count": reprintCount}).
+		Describe("Admin %d reprinted receipt %s (reprint #%d)", adminID, invoiceNumber, reprintCount)
+	if err := audit_handler.Record(ctx, tx, event); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log reprint"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to reprint receipt"})
+	}
+
+	detail, err := loadReceiptDetail(ctx, receiptID)
+	if err != nil {
+		fmt.Printf("Receipt error: %v\n", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch receipt"})
+	}
+
+	return respondWithReceipt(c, detail, true)
+}
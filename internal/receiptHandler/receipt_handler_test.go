@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCustomerReceipts(t *testing.T) {
+	// Setup Echo
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/customer/receipts", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	// Manually set the JWT claims for a customer
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"customer_id": float64(1), // Customer ID from the database
+	})
+	c.Set("user", token)
+
+	// Call the handler function
+	err := GetCustomerReceipts(c)
+
+	// Assertions
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.Equal(t, "Receipts retrieved successfully", response["message"])
+		assert.NotNil(t, response["data"], "Receipts should not be nil")
+	}
+}
+
+func TestRenderReceipt(t *testing.T) {
+	orderID := "rental-1-1734500000"
+	start := time.Date(2024, 12, 18, 10, 0, 0, 0, time.UTC)
+	detail := ReceiptDetail{
+		Receipt: Receipt{
+			ID:            1,
+			InvoiceNumber: "INV-000001",
+			OrderID:       &orderID,
+			PaymentMethod: "GoPay",
+			Total:         47500,
+			CreatedAt:     start,
+		},
+		CustomerName: "John Doe",
+		Rental: &ReceiptRental{
+			ComputerName: "PC-001",
+			ComputerType: "Gaming",
+			HourlyRate:   20000,
+			RentalStart:  start,
+			RentalEnd:    start.Add(2 * time.Hour),
+			TotalCost:    40000,
+		},
+		Services: []ReceiptLine{
+			{ServiceID: 1, ServiceName: "Printing", Quantity: 3, UnitPrice: 2500, Amount: 7500},
+		},
+	}
+
+	// Thermal receipts fit the printer width and show every line item
+	text := RenderThermal(detail, false)
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		assert.LessOrEqual(t, len(line), thermalWidth, "Line should fit the thermal printer: %q", line)
+	}
+	assert.Contains(t, text, "INV-000001")
+	assert.Contains(t, text, "PC-001 (Gaming)")
+	assert.Contains(t, text, "40.000")
+	assert.Contains(t, text, "Rp 47.500")
+	assert.NotContains(t, text, "REPRINT")
+
+	// Reprints are marked on the copy
+	detail.ReprintCount = 2
+	assert.Contains(t, RenderThermal(detail, true), "REPRINT #2")
+
+	// PDF receipts are complete documents
+	pdf := RenderPDF(detail, false)
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4")))
+	assert.True(t, bytes.HasSuffix(pdf, []byte("%%EOF\n")))
+	assert.Contains(t, string(pdf), "(Invoice : INV-000001) Tj")
+}
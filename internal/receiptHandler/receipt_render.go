@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Thermal printers with 80mm paper print 42 characters per line in font A
+const thermalWidth = 42
+
+// formatRupiah formats an amount with Indonesian thousands separators, e.g. 40000 -> "40.000"
+func formatRupiah(amount float64) string {
+	negative := amount < 0
+	digits := fmt.Sprintf("%d", int64(math.Round(math.Abs(amount))))
+
+	var out strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			out.WriteByte('.')
+		}
+		out.WriteRune(d)
+	}
+	if negative {
+		return "-" + out.String()
+	}
+	return out.String()
+}
+
+// center pads text so it is centered on a thermal line
+func center(text string) string {
+	if len(text) >= thermalWidth {
+		return text
+	}
+	return strings.Repeat(" ", (thermalWidth-len(text))/2) + text
+}
+
+// columns left-aligns label and right-aligns amount on one thermal line
+func columns(label string, amount string) string {
+	gap := thermalWidth - len(label) - len(amount)
+	if gap < 1 {
+		return label + "\n" + strings.Repeat(" ", thermalWidth-len(amount)) + amount
+	}
+	return label + strings.Repeat(" ", gap) + amount
+}
+
+// RenderThermal renders a receipt as plain text for a thermal printer
+func RenderThermal(detail ReceiptDetail, reprint bool) string {
+	var lines []string
+	double := strings.Repeat("=", thermalWidth)
+	single := strings.Repeat("-", thermalWidth)
+
+	lines = append(lines, double, center("RECEIPT"))
+	if reprint {
+		lines = append(lines, center(fmt.Sprintf("** REPRINT #%d **", detail.ReprintCount)))
+	}
+	lines = append(lines, double)
+
+	orderID := "-"
+	if detail.OrderID != nil {
+		orderID = *detail.OrderID
+	}
+	lines = append(lines,
+		"Invoice : "+detail.InvoiceNumber,
+		"Date    : "+detail.CreatedAt.Format("2006-01-02 15:04"),
+		"Order ID: "+orderID,
+		"Customer: "+detail.CustomerName,
+		"Payment : "+detail.PaymentMethod,
+		single,
+	)
+
+	if detail.Rental != nil {
+		rental := detail.Rental
+		hours := rental.RentalEnd.Sub(rental.RentalStart).Hours()
+		lines = append(lines,
+			fmt.Sprintf("%s (%s)", rental.ComputerName, rental.ComputerType),
+			fmt.Sprintf("  %s - %s", rental.RentalStart.Format("2006-01-02 15:04"), rental.RentalEnd.Format("15:04")),
+			columns(fmt.Sprintf("  %.1f h x %s", hours, formatRupiah(rental.HourlyRate)), formatRupiah(rental.TotalCost)),
+		)
+	}
+
+	for _, line := range detail.Services {
+		lines = append(lines,
+			line.ServiceName,
+			columns(fmt.Sprintf("  %d x %s", line.Quantity, formatRupiah(line.UnitPrice)), formatRupiah(line.Amount)),
+		)
+	}
+
+	lines = append(lines, single)
+	if detail.MembershipDiscount > 0 {
+		lines = append(lines, columns("Membership", formatRupiah(-detail.MembershipDiscount)))
+	}
+	if detail.PrepaidTimeCredit > 0 {
+		lines = append(lines, columns("Prepaid time", formatRupiah(-detail.PrepaidTimeCredit)))
+	}
+	if detail.Discount > 0 {
+		label := "Discount"
+		if detail.VoucherCode != nil {
+			label += " " + *detail.VoucherCode
+		}
+		lines = append(lines, columns(label, formatRupiah(-detail.Discount)))
+	}
+	if detail.LoyaltyDiscount > 0 {
+		lines = append(lines, columns("Loyalty reward", formatRupiah(-detail.LoyaltyDiscount)))
+	}
+	if len(detail.Taxes) > 0 || detail.MembershipDiscount > 0 || detail.PrepaidTimeCredit > 0 || detail.Discount > 0 || detail.LoyaltyDiscount > 0 {
+		lines = append(lines, columns("Subtotal", formatRupiah(detail.Subtotal)))
+		for _, tax := range detail.Taxes {
+			label := fmt.Sprintf("%s %g%%", tax.Name, tax.Rate)
+			if tax.Inclusive {
+				label += " (incl.)"
+			}
+			lines = append(lines, columns(label, formatRupiah(tax.TaxAmount)))
+		}
+	}
+	lines = append(lines,
+		columns("TOTAL", "Rp "+formatRupiah(detail.Total)),
+		double,
+		center("Thank you for visiting!"),
+	)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// pdfEscape escapes a line for a PDF string literal, replacing characters
+// outside the standard Courier encoding
+func pdfEscape(text string) string {
+	var out strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			out.WriteByte('\\')
+			out.WriteRune(r)
+		case r < 32 || r > 126:
+			out.WriteByte('?')
+		default:
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
+
+// RenderPDF renders a receipt as an A4 PDF using the thermal layout in Courier,
+// adding pages when the receipt does not fit on one
+func RenderPDF(detail ReceiptDetail, reprint bool) []byte {
+	const (
+		pageWidth    = 595
+		pageHeight   = 842
+		margin       = 56
+		fontSize     = 11
+		leading      = 14
+		linesPerPage = (pageHeight - 2*margin) / leading
+	)
+
+	text := strings.TrimRight(RenderThermal(detail, reprint), "\n")
+	lines := strings.Split(text, "\n")
+
+	// Split the lines into page content streams
+	var pages []string
+	for start := 0; start < len(lines); start += linesPerPage {
+		end := start + linesPerPage
+		if end > len(lines) {
+			end = len(lines)
+		}
+		var content strings.Builder
+		fmt.Fprintf(&content, "BT\n/F1 %d Tf\n%d TL\n%d %d Td\n", fontSize, leading, margin, pageHeight-margin)
+		for _, line := range lines[start:end] {
+			fmt.Fprintf(&content, "(%s) Tj T*\n", pdfEscape(line))
+		}
+		content.WriteString("ET")
+		pages = append(pages, content.String())
+	}
+
+	// Objects: 1 catalog, 2 page tree, 3 font, then a page and content stream per page
+	var objects []string
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>",
+	)
+	for i, content := range pages {
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+				pageWidth, pageHeight, 5+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
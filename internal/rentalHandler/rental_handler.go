@@ -2,13 +2,24 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/golang-jwt/jwt/v4"
 	config "w4/p2/milestones/config/database"
+	audit_handler "w4/p2/milestones/internal/auditHandler"
+	receipt_handler "w4/p2/milestones/internal/receiptHandler"
+	shift_handler "w4/p2/milestones/internal/shiftHandler"
+	loyalty_handler "w4/p2/milestones/internal/loyaltyHandler"
+	inventory_handler "w4/p2/milestones/internal/inventoryHandler"
+	loan_handler "w4/p2/milestones/internal/loanHandler"
+	membership_handler "w4/p2/milestones/internal/membershipHandler"
+	time_package_handler "w4/p2/milestones/internal/timePackageHandler"
+	voucher_handler "w4/p2/milestones/internal/voucherHandler"
 
 	"github.com/midtrans/midtrans-go"
 	"github.com/midtrans/midtrans-go/coreapi"
@@ -25,6 +36,9 @@ type RentalRequest struct {
 	RentalEnd     time.Time      `json:"rental_end" validate:"required"`
 	Services      []ServiceEntry `json:"services"`
 	ActivityDesc  string         `json:"activity_description"`
+	VoucherCode   string         `json:"voucher_code"`
+	LoyaltyRewardID int          `json:"loyalty_reward_id"` // reward to redeem points for
+	BundleID      int            `json:"bundle_id"` // combo of computer time and services sold with the rental
 }
 
 // ServiceEntry structure for additional services
@@ -46,11 +60,12 @@ func Init() {
 
 // RentComputer handles the rental process
 // @Summary Rent a computer with optional services
-// @Description Rent a computer and optionally purchase additional services
+// @Description Rent a computer and optionally purchase additional services or a bundle. A bundle covers its hours and services at the bundle price. The total includes the services, membership hours, prepaid time, the membership, voucher and loyalty reward discounts and the active tax rules. Settled rentals earn loyalty points.
 // @Tags Rentals
 // @Accept json
 // @Produce json
 // @Param rentalRequest body RentalRequest true "Rental Details"
+// @Param payment_method query string true "Payment method: wallet, gopay or cash (requires an open shift)"
 // @Success 200 {object} map[string]interface{} "Rental successful response"
 // @Failure 400 {object} map[string]string "Invalid request"
 // @Failure 500 {object} map[string]string "Internal server error"
@@ -77,49 +92,185 @@ func RentComputer(c echo.Context) error {
         return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only selected admins can perform this action."})
     }
 
-    // Calculate rental duration and cost
-    var hourlyRate int
-    query := "SELECT hourly_rate FROM computer WHERE id = $1 AND isAvailable = TRUE"
-    err := config.Pool.QueryRow(context.Background(), query, req.ComputerID).Scan(&hourlyRate)
+    // Calculate rental duration and cost, including services and tax
+    quote, err := buildRentalQuote(context.Background(), req)
     if err != nil {
-        return c.JSON(http.StatusBadRequest, map[string]string{"message": "Computer not available"})
+        return quoteErrorResponse(c, err)
     }
 
-    rentalDuration := req.RentalEnd.Sub(req.RentalStart).Hours()
-    totalCost := int(rentalDuration) * hourlyRate
+    rentalDuration := quote.RentalDuration
+    totalCost := quote.TotalCost
+    hourlyRate, timeDiscount, timeTotal := quote.timeCharge()
+
+    // Take the membership hours, given back if the rental is not recorded
+    var holds chargeHolds
+    chargesSaved := false
+    if quote.Membership != nil {
+        usageID, consumeErr := membership_handler.ConsumeHours(context.Background(), config.Pool, *quote.Membership)
+        var membershipErr *membership_handler.MembershipError
+        if errors.As(consumeErr, &membershipErr) {
+            return c.JSON(http.StatusConflict, map[string]string{"message": membershipErr.Message})
+        } else if consumeErr != nil {
+            fmt.Println("Membership error:", consumeErr)
+            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to use membership hours"})
+        }
+        holds.usageID = usageID
+        defer func() {
+            if !chargesSaved {
+                membership_handler.CancelUsage(context.Background(), config.Pool, holds.usageID)
+            }
+        }()
+    }
+
+    // Draw the prepaid minutes, given back if the rental is not recorded
+    if quote.PrepaidTime != nil {
+        usageIDs, consumeErr := time_package_handler.ConsumeTime(context.Background(), *quote.PrepaidTime, req.CustomerID)
+        var timeErr *time_package_handler.TimeError
+        if errors.As(consumeErr, &timeErr) {
+            return c.JSON(http.StatusConflict, map[string]string{"message": timeErr.Message})
+        } else if consumeErr != nil {
+            fmt.Println("Prepaid time error:", consumeErr)
+            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to use prepaid time"})
+        }
+        holds.timeUsageIDs = usageIDs
+        defer func() {
+            if !chargesSaved {
+                time_package_handler.CancelTimeUsage(context.Background(), config.Pool, holds.timeUsageIDs)
+            }
+        }()
+    }
+
+    // Take one use of the voucher, given back if the rental is not recorded
+    if quote.Voucher != nil {
+        voucherRedemptionID, reserveErr := voucher_handler.ReserveVoucher(context.Background(), *quote.Voucher, req.CustomerID)
+        var voucherErr *voucher_handler.VoucherError
+        if errors.As(reserveErr, &voucherErr) {
+            return c.JSON(http.StatusConflict, map[string]string{"message": voucherErr.Message})
+        } else if reserveErr != nil {
+            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to reserve voucher"})
+        }
+        holds.voucherRedemptionID = voucherRedemptionID
+        defer func() {
+            if !chargesSaved {
+                voucher_handler.CancelReservation(context.Background(), config.Pool, holds.voucherRedemptionID)
+            }
+        }()
+    }
+
+    // Spend the loyalty points, refunded if the rental is not recorded
+    if quote.Reward != nil {
+        entryID, redeemErr := loyalty_handler.RedeemPoints(context.Background(), *quote.Reward, req.CustomerID)
+        var loyaltyErr *loyalty_handler.LoyaltyError
+        if errors.As(redeemErr, &loyaltyErr) {
+            return c.JSON(http.StatusConflict, map[string]string{"message": loyaltyErr.Message})
+        } else if redeemErr != nil {
+            fmt.Println("Loyalty error:", redeemErr)
+            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to redeem loyalty points"})
+        }
+        holds.redemptionID = entryID
+        defer func() {
+            if !chargesSaved {
+                loyalty_handler.CancelRedemption(context.Background(), config.Pool, holds.redemptionID)
+            }
+        }()
+    }
 
     // Check if the user chooses to pay with wallet or GoPay
-    paymentMethod := c.QueryParam("payment_method") // "wallet" or "gopay"
+    paymentMethod := c.QueryParam("payment_method") // "wallet", "gopay" or "cash"
+    var transactionID int
+
+    // Take the stock of services paid now, bundled or not, returned if the rental is not recorded
+    stockLines := quote.stockLines()
+    if (paymentMethod == "wallet" || paymentMethod == "cash") && len(stockLines) > 0 {
+        movementIDs, stockErr := inventory_handler.TakeStock(context.Background(), stockLines, fmt.Sprintf("Rental by Customer %d", req.CustomerID))
+        var outOfStock *inventory_handler.StockError
+        if errors.As(stockErr, &outOfStock) {
+            return c.JSON(http.StatusConflict, map[string]string{"message": outOfStock.Message})
+        } else if stockErr != nil {
+            fmt.Println("Stock error:", stockErr)
+            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update service quantity"})
+        }
+        holds.stockMovementIDs = movementIDs
+        defer func() {
+            if !chargesSaved {
+                inventory_handler.ReturnStock(context.Background(), config.Pool, holds.stockMovementIDs, "Rental not recorded")
+            }
+        }()
+    } else if paymentMethod == "gopay" && len(stockLines) > 0 {
+        // Reserve the stock until the GoPay payment settles, released if the order is not created
+        reservationIDs, stockErr := inventory_handler.ReserveStock(context.Background(), stockLines, inventory_handler.ReservationTTL)
+        var outOfStock *inventory_handler.StockError
+        if errors.As(stockErr, &outOfStock) {
+            return c.JSON(http.StatusConflict, map[string]string{"message": outOfStock.Message})
+        } else if stockErr != nil {
+            fmt.Println("Stock error:", stockErr)
+            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to reserve service stock"})
+        }
+        holds.reservationIDs = reservationIDs
+        defer func() {
+            if !chargesSaved {
+                inventory_handler.ReleaseReservations(context.Background(), config.Pool, holds.reservationIDs, "Order not created")
+            }
+        }()
+    }
+
+    // Everything from the payment to the receipt is one transaction; the holds above are
+    // given back if it does not commit
+    ctx := context.Background()
+    tx, err := config.Pool.Begin(ctx)
+    if err != nil {
+        return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+    }
+    defer tx.Rollback(ctx)
 
     if paymentMethod == "wallet" {
         // Deduct wallet balance
         var walletBalance float64
-        walletQuery := "SELECT wallet FROM customer WHERE id = $1"
-        err = config.Pool.QueryRow(context.Background(), walletQuery, req.CustomerID).Scan(&walletBalance)
+        walletQuery := "SELECT wallet FROM customer WHERE id = $1 FOR UPDATE"
+        err = tx.QueryRow(ctx, walletQuery, req.CustomerID).Scan(&walletBalance)
         if err != nil {
             return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to retrieve wallet balance"})
         }
 
-        if walletBalance < float64(totalCost) {
+        if walletBalance < totalCost {
             return c.JSON(http.StatusBadRequest, map[string]string{"message": "Insufficient wallet balance"})
         }
 
         deductWalletQuery := "UPDATE customer SET wallet = wallet - $1 WHERE id = $2"
-        _, err = config.Pool.Exec(context.Background(), deductWalletQuery, totalCost, req.CustomerID)
+        _, err = tx.Exec(ctx, deductWalletQuery, totalCost, req.CustomerID)
         if err != nil {
             return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to deduct wallet balance"})
         }
 
         // Log wallet payment in transaction table
         transactionQuery := `
-            INSERT INTO transaction (customer_id, transaction_type, amount, transaction_method, status, transaction_date)
-            VALUES ($1, 'Rental Payment', $2, 'Wallet', 'settlement', NOW())`
+            INSERT INTO transaction (customer_id, transaction_type, amount, transaction_method, status, transaction_date, admin_id)
+            VALUES ($1, 'Rental Payment', $2, 'Wallet', 'settlement', NOW(), $3) RETURNING id`
         
-        _, txnErr := config.Pool.Exec(context.Background(), transactionQuery, req.CustomerID, totalCost)
+        txnErr := tx.QueryRow(ctx, transactionQuery, req.CustomerID, totalCost, adminID).Scan(&transactionID)
         if txnErr != nil {
             return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log transaction"})
         }
         
+    } else if paymentMethod == "cash" {
+        // Cash is collected at the counter and recorded against the admin's open shift
+        shiftID, err := shift_handler.GetOpenShiftID(ctx, tx, adminID)
+        if errors.Is(err, shift_handler.ErrNoOpenShift) {
+            return c.JSON(http.StatusBadRequest, map[string]string{"message": "Open a shift before accepting cash payments"})
+        } else if err != nil {
+            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to check open shift"})
+        }
+
+        // Log cash payment in transaction table
+        transactionQuery := `
+            INSERT INTO transaction (customer_id, transaction_type, amount, transaction_method, status, transaction_date, shift_id, admin_id)
+            VALUES ($1, 'Rental Payment', $2, 'Cash', 'settlement', NOW(), $3, $4) RETURNING id`
+
+        txnErr := tx.QueryRow(ctx, transactionQuery, req.CustomerID, totalCost, shiftID, adminID).Scan(&transactionID)
+        if txnErr != nil {
+            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log transaction"})
+        }
+
     } else if paymentMethod == "gopay" {
         // Create payment request via GoPay
         orderID := fmt.Sprintf("rental-%d-%d", req.CustomerID, time.Now().Unix())
@@ -127,7 +278,7 @@ func RentComputer(c echo.Context) error {
             PaymentType: coreapi.PaymentTypeGopay,
             TransactionDetails: midtrans.TransactionDetails{
                 OrderID:  orderID,
-                GrossAmt: int64(totalCost),
+                GrossAmt: int64(math.Round(totalCost)),
             },
             Gopay: &coreapi.GopayDetails{
                 EnableCallback: true,
@@ -142,112 +293,161 @@ func RentComputer(c echo.Context) error {
 
         // Save transaction in the database
 		transactionQuery := `
-			INSERT INTO transaction (customer_id, transaction_type, amount, transaction_method, status, payment_url, order_id, metadata)
-			VALUES ($1, 'Rental Payment', $2, 'GoPay', 'Pending', $3, $4, $5)`
+			INSERT INTO transaction (customer_id, transaction_type, amount, transaction_method, status, payment_url, order_id, metadata, admin_id)
+			VALUES ($1, 'Rental Payment', $2, 'GoPay', 'Pending', $3, $4, $5, $6) RETURNING id`
 		metadata := map[string]interface{}{
             "admin_id": adminID,
 			"computer_id":   req.ComputerID,
 			"rental_start":  req.RentalStart,
 			"rental_end":    req.RentalEnd,
 			"activity_desc": req.ActivityDesc,
-            "total_cost": totalCost,
+            "total_cost": quote.RentalCost,
+            "hourly_rate": hourlyRate,
+            "time_discount": timeDiscount,
+            "time_total": timeTotal,
+            "bundle_id": req.BundleID,
+            "services": quote.serviceMetadata(),
 		}
-		_, txnErr := config.Pool.Exec(context.Background(), transactionQuery, req.CustomerID, totalCost, resp.Actions[0].URL, orderID, metadata)
+		txnErr := tx.QueryRow(ctx, transactionQuery, req.CustomerID, totalCost, resp.Actions[0].URL, orderID, metadata, adminID).Scan(&transactionID)
 		if txnErr != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log transaction"})
 		}
 
+        // Store the tax and discount quoted for the pending order
+        chargesErr := saveQuoteCharges(ctx, tx, transactionID, quote, holds)
+        if chargesErr != nil {
+            fmt.Println("Charges error:", chargesErr)
+            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log transaction tax and discounts"})
+        }
+        if err := tx.Commit(ctx); err != nil {
+            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log transaction"})
+        }
+        chargesSaved = true
+
         // Return payment URL to the user
         return c.JSON(http.StatusOK, map[string]interface{}{
             "message":    "Payment initiated",
             "payment_url": resp.Actions[0].URL,
+            "quote":       quote,
         })
     } else {
         return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid payment method"})
     }
 
+    // Store the tax and discount charged on the transaction
+    err = saveQuoteCharges(ctx, tx, transactionID, quote, holds)
+    if err != nil {
+        fmt.Println("Charges error:", err)
+        return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log transaction tax and discounts"})
+    }
+
+    // Credit loyalty points for the settled payment
+    pointsEarned, err := loyalty_handler.EarnPoints(ctx, tx, transactionID)
+    if err != nil {
+        fmt.Println("Loyalty error:", err)
+        return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to credit loyalty points"})
+    }
+
     // Record rental history
     var rentalHistoryID int
     rentalHistoryQuery := `
-        INSERT INTO rental_history (customer_id, computer_id, admin_id, rental_start_time, rental_end_time, total_cost, booking_status)
-        VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`        
-    err = config.Pool.QueryRow(context.Background(), rentalHistoryQuery, req.CustomerID, req.ComputerID, adminID, req.RentalStart, req.RentalEnd, totalCost, "settlement").Scan(&rentalHistoryID)
+        INSERT INTO rental_history (customer_id, computer_id, admin_id, rental_start_time, rental_end_time, total_cost, booking_status, bundle_id,
+                                    unit_price, discount, line_total)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, 0), $9, $10, $11) RETURNING id`        
+    err = tx.QueryRow(ctx, rentalHistoryQuery, req.CustomerID, req.ComputerID, adminID, req.RentalStart, req.RentalEnd, quote.RentalCost, "settlement", req.BundleID,
+        hourlyRate, timeDiscount, timeTotal).Scan(&rentalHistoryID)
     if err != nil {
         return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to record rental history"})
     }
 
     // Log rental activity
-    desc := fmt.Sprintf("Rental payment completed for Customer %d, with Computer %d from %s to %s. Activity: %s",
-    req.CustomerID, req.ComputerID, req.RentalStart.Format("2006-01-02 15:04:05"), req.RentalEnd.Format("2006-01-02 15:04:05"), req.ActivityDesc)
-    logQuery := `INSERT INTO log (description) VALUES ($1)`
-    _, err = config.Pool.Exec(context.Background(), logQuery, desc)
+    rentalEvent := audit_handler.FromRequest(c, "rental.create", "rental_history", rentalHistoryID).
+        Change(nil, map[string]interface{}{"customer_id": req.CustomerID, "computer_id": req.ComputerID, "rental_start": req.RentalStart,
+            "rental_end": req.RentalEnd, "total_cost": quote.RentalCost, "transaction_id": transactionID}).
+        Describe("Rental payment completed for Customer %d, with Computer %d from %s to %s. Activity: %s",
+            req.CustomerID, req.ComputerID, req.RentalStart.Format("2006-01-02 15:04:05"), req.RentalEnd.Format("2006-01-02 15:04:05"), req.ActivityDesc)
+    err = audit_handler.Record(ctx, tx, rentalEvent)
     if err != nil {
         return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log rental activity"})
     }
 
-    // Log additional services if any
-    if len(req.Services) > 0 {
-        for _, service := range req.Services {
-            // Check available quantity of the service
-            var availableQuantity int
-            checkQuantityQuery := "SELECT quantity FROM service WHERE id = $1"
-            err := config.Pool.QueryRow(context.Background(), checkQuantityQuery, service.ServiceID).Scan(&availableQuantity)
-            if err != nil {
-                return c.JSON(http.StatusInternalServerError, map[string]string{
-                    "message": fmt.Sprintf("Failed to check quantity for Service ID %d", service.ServiceID),
-                })
-            }
+    // Log additional and bundled services if any, their stock was taken before payment
+    for _, line := range quote.Lines {
+        if line.ServiceID == 0 {
+            continue
+        }
 
-            // Ensure the requested quantity does not exceed the available quantity
-            if service.Quantity > availableQuantity {
-                return c.JSON(http.StatusBadRequest, map[string]string{
-                    "message": fmt.Sprintf("Insufficient stock for Service ID %d. Available: %d, Requested: %d",
-                        service.ServiceID, availableQuantity, service.Quantity),
-                })
-            }
+        // Insert into rental_services table with the prices charged, bundled services at their share of the bundle price
+        var bundleAmount *float64
+        if line.BundleID != 0 {
+            bundleAmount = &line.Amount
+        }
+        serviceQuery := `
+            INSERT INTO rental_services (rental_history_id, service_id, quantity, transaction_id, bundle_id, bundle_amount, unit_price, discount, line_total)
+            VALUES ($1, $2, $3, $4, NULLIF($5, 0), $6, $7, $8, $9)`
+        _, err = tx.Exec(ctx, serviceQuery, rentalHistoryID, line.ServiceID, int(line.Quantity), transactionID, line.BundleID, bundleAmount,
+            line.UnitPrice, line.Discount, line.Amount-line.Discount)
+        if err != nil {
+            return c.JSON(http.StatusInternalServerError, map[string]string{
+                "message": fmt.Sprintf("Failed to log service ID %d", line.ServiceID),
+            })
+        }
 
-            // Deduct the requested quantity from the service
-            deductQuantityQuery := "UPDATE service SET quantity = quantity - $1 WHERE id = $2"
-            _, err = config.Pool.Exec(context.Background(), deductQuantityQuery, service.Quantity, service.ServiceID)
-            if err != nil {
-                return c.JSON(http.StatusInternalServerError, map[string]string{
-                    "message": fmt.Sprintf("Failed to update quantity for Service ID %d", service.ServiceID),
-                })
-            }
+        // Log the service details in the audit log
+        serviceEvent := audit_handler.FromRequest(c, "service.sell", "service", line.ServiceID).
+            Change(nil, map[string]interface{}{"customer_id": req.CustomerID, "rental_history_id": rentalHistoryID, "quantity": int(line.Quantity),
+                "unit_price": line.UnitPrice, "discount": line.Discount, "transaction_id": transactionID}).
+            Describe("Customer %d purchased Service ID %d (Quantity: %d)", req.CustomerID, line.ServiceID, int(line.Quantity))
+        err = audit_handler.Record(ctx, tx, serviceEvent)
+        if err != nil {
+            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log service purchase"})
+        }
+    }
 
-            // Insert into rental_services table
-            serviceQuery := `
-                INSERT INTO rental_services (rental_history_id, service_id, quantity)
-                VALUES ($1, $2, $3)`
-            _, err = config.Pool.Exec(context.Background(), serviceQuery, rentalHistoryID, service.ServiceID, service.Quantity)
-            if err != nil {
-                return c.JSON(http.StatusInternalServerError, map[string]string{
-                    "message": fmt.Sprintf("Failed to log service ID %d", service.ServiceID),
-                })
-            }
+    // Credit any time packages bought with the rental
+    if _, err := time_package_handler.CreditPackages(ctx, tx, transactionID); err != nil {
+        fmt.Println("Prepaid time error:", err)
+        return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to credit time packages"})
+    }
 
-            // Log the service details in the Log table
-            logDesc := fmt.Sprintf("Customer %d purchased Service ID %d (Quantity: %d)",
-                req.CustomerID, service.ServiceID, service.Quantity)
-            logQuery := `INSERT INTO log (description) VALUES ($1)`
-            _, err = config.Pool.Exec(context.Background(), logQuery, logDesc)
-            if err != nil {
-                return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log service purchase"})
-            }
-        }
+    // Lend the equipment taken with the rental until it ends
+    if _, err := loan_handler.CheckOutLoans(ctx, tx, rentalHistoryID); err != nil {
+        fmt.Println("Equipment loan error:", err)
+        return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to check out equipment"})
     }
  
     // Update computer availability
     updateComputerQuery := "UPDATE computer SET isAvailable = FALSE WHERE id = $1"
-    _, err = config.Pool.Exec(context.Background(), updateComputerQuery, req.ComputerID)
+    _, err = tx.Exec(ctx, updateComputerQuery, req.ComputerID)
     if err != nil {
         return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update computer availability"})
     }
 
+    // Issue the receipt with the rental window and service line items
+    receipt, err := receipt_handler.CreateReceipt(ctx, tx, transactionID, &rentalHistoryID)
+    if err != nil {
+        fmt.Println("Receipt error:", err)
+        return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create receipt"})
+    }
+
+    // Charge, rental, services and their audit events are kept together or not at all
+    if err := tx.Commit(ctx); err != nil {
+        return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to record rental"})
+    }
+    chargesSaved = true
+
     return c.JSON(http.StatusOK, map[string]interface{}{
         "message":         "Rental recorded successfully",
         "rental_history":  rentalHistoryID,
         "total_cost":      totalCost,
+        "discount":        quote.Discount,
+        "loyalty_discount": quote.LoyaltyDiscount,
+        "membership_discount": quote.MembershipDiscount,
+        "prepaid_time_credit": quote.PrepaidTimeCredit,
+        "points_earned":   pointsEarned,
+        "tax_total":       quote.Tax.TaxTotal,
         "rental_duration": rentalDuration,
+        "receipt_id":      receipt.ID,
+        "invoice_number":  receipt.InvoiceNumber,
     })
 }
\ No newline at end of file
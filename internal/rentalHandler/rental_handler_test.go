@@ -8,6 +8,8 @@ import (
 	"testing"
 	"time"
 
+	voucher_handler "w4/p2/milestones/internal/voucherHandler"
+
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
@@ -58,4 +60,59 @@ func TestRentComputer(t *testing.T) {
         json.Unmarshal(rec.Body.Bytes(), &response)
         assert.Contains(t, response["message"], "Rental recorded successfully")
     }
-}
\ No newline at end of file
+}
+func TestTimeCharge(t *testing.T) {
+	// 2 billed hours plus 3 bundled hours, with a voucher on the billed hours
+	quote := RentalQuote{Lines: []QuoteLine{
+		{Description: "PC-001", Category: "computer_time", Quantity: 2, UnitPrice: 20000, Amount: 40000, Discount: 4000},
+		{Description: "Gaming 3h - PC-001", Category: "computer_time", Quantity: 3, UnitPrice: 16901, Amount: 50704, BundleID: 1},
+		{Description: "Drinks", Category: "food", ServiceID: 3, Quantity: 2, UnitPrice: 3000, Amount: 6000},
+		{Description: "Office 5 Hours", Category: "computer_time", ServiceID: 4, Quantity: 1, UnitPrice: 40000, Amount: 40000},
+	}}
+
+	hourlyRate, discount, lineTotal := quote.timeCharge()
+	assert.Equal(t, 20000.0, hourlyRate)
+	assert.Equal(t, 4000.0, discount)
+	assert.Equal(t, 86704.0, lineTotal)
+}
+
+func TestVoucherAfterMembership(t *testing.T) {
+	// Member discounts already taken on the hours and the drinks, then a 100% voucher
+	quote := RentalQuote{Lines: []QuoteLine{
+		{Description: "PC-001", Category: "computer_time", Quantity: 2, UnitPrice: 20000, Amount: 40000, Discount: 8000},
+		{Description: "Drinks", Category: "food", ServiceID: 3, Quantity: 2, UnitPrice: 3000, Amount: 6000, Discount: 1200},
+	}}
+	voucher := voucher_handler.Voucher{Code: "FREE", DiscountType: voucher_handler.DiscountPercentage, DiscountValue: 100}
+
+	applied, err := voucher_handler.ComputeDiscount(voucher, quote.voucherLines("Gaming"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, 36800.0, applied.Discount)
+		for i, line := range quote.Lines {
+			assert.GreaterOrEqual(t, line.Amount-line.Discount-applied.LineDiscounts[i], 0.0)
+		}
+	}
+}
+
+func TestVoucherAfterPrepaidTime(t *testing.T) {
+	// Hours fully paid from a time package, then a fixed voucher on computer time
+	quote := RentalQuote{Lines: []QuoteLine{
+		{Description: "PC-001", Category: "computer_time", Quantity: 2, UnitPrice: 20000, Amount: 40000, Discount: 40000},
+		{Description: "Drinks", Category: "food", ServiceID: 3, Quantity: 1, UnitPrice: 3000, Amount: 3000},
+	}}
+	voucher := voucher_handler.Voucher{Code: "TIME10", DiscountType: voucher_handler.DiscountFixed, DiscountValue: 10000,
+		ComputerTypes: []string{"Gaming"}}
+
+	// Nothing is left to discount on the hours
+	_, err := voucher_handler.ComputeDiscount(voucher, quote.voucherLines("Gaming"))
+	assert.Error(t, err)
+
+	// An order-wide voucher only takes what is left on the drinks
+	voucher.ComputerTypes = nil
+	applied, err := voucher_handler.ComputeDiscount(voucher, quote.voucherLines("Gaming"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, 3000.0, applied.Discount)
+		for i, line := range quote.Lines {
+			assert.GreaterOrEqual(t, line.Amount-line.Discount-applied.LineDiscounts[i], 0.0)
+		}
+	}
+}
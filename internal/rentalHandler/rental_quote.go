@@ -0,0 +1,453 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	config "w4/p2/milestones/config/database"
+	bundle_handler "w4/p2/milestones/internal/bundleHandler"
+	inventory_handler "w4/p2/milestones/internal/inventoryHandler"
+	loyalty_handler "w4/p2/milestones/internal/loyaltyHandler"
+	membership_handler "w4/p2/milestones/internal/membershipHandler"
+	tax_handler "w4/p2/milestones/internal/taxHandler"
+	time_package_handler "w4/p2/milestones/internal/timePackageHandler"
+	voucher_handler "w4/p2/milestones/internal/voucherHandler"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// QuoteLine is one priced line of a rental quote
+type QuoteLine struct {
+	Description string  `json:"description"`
+	Category    string  `json:"category"`
+	ServiceID   int     `json:"service_id,omitempty"`
+	Quantity    float64 `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+	Amount      float64 `json:"amount"`
+	Discount    float64 `json:"discount"`            // membership, voucher and loyalty reward discount
+	BundleID    int     `json:"bundle_id,omitempty"` // part of a bundle, priced at its share of the bundle price
+}
+
+// RentalQuote is the priced breakdown of a rental before payment
+type RentalQuote struct {
+	Lines              []QuoteLine                           `json:"lines"`
+	RentalDuration     float64                               `json:"rental_duration"`
+	RentalCost         int                                   `json:"rental_cost"` // computer time only, stored on rental_history
+	Bundle             *bundle_handler.Bundle                `json:"bundle,omitempty"`
+	Membership         *membership_handler.AppliedMembership `json:"membership,omitempty"`
+	MembershipDiscount float64                               `json:"membership_discount"` // included hours and member discount
+	PrepaidTime        *time_package_handler.AppliedTime     `json:"prepaid_time,omitempty"`
+	PrepaidTimeCredit  float64                               `json:"prepaid_time_credit"` // computer time paid from time packages
+	Voucher            *voucher_handler.AppliedVoucher       `json:"voucher,omitempty"`
+	Discount           float64                               `json:"discount"` // voucher discount
+	Reward             *loyalty_handler.AppliedReward        `json:"loyalty_reward,omitempty"`
+	LoyaltyDiscount    float64                               `json:"loyalty_discount"`
+	Tax                tax_handler.TaxBreakdown              `json:"tax"`
+	TotalCost          float64                               `json:"total_cost"` // amount charged, including exclusive tax
+}
+
+// buildRentalQuote prices the computer time, services and bundle of a rental, applies the
+// customer's membership and prepaid time, the voucher and loyalty reward if given, and then the active
+// tax rules on the discounted prices. Errors are *echo.HTTPError carrying the response status.
+func buildRentalQuote(ctx context.Context, req RentalRequest) (RentalQuote, error) {
+	var quote RentalQuote
+
+	// Calculate rental duration and cost
+	var computerName, computerType string
+	var hourlyRate int
+	query := "SELECT name, type, hourly_rate FROM computer WHERE id = $1 AND isAvailable = TRUE"
+	err := config.Pool.QueryRow(ctx, query, req.ComputerID).Scan(&computerName, &computerType, &hourlyRate)
+	if err != nil {
+		return quote, echo.NewHTTPError(http.StatusBadRequest, "Computer not available")
+	}
+
+	quote.RentalDuration = req.RentalEnd.Sub(req.RentalStart).Hours()
+	billedHours := int(quote.RentalDuration)
+
+	// The hours included in a bundle are priced with the bundle
+	if req.BundleID != 0 {
+		bundle, err := bundle_handler.LoadBundle(ctx, config.Pool, req.BundleID)
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && !bundle.IsActive) {
+			return quote, echo.NewHTTPError(http.StatusBadRequest, "Invalid bundle ID")
+		} else if err != nil {
+			fmt.Println("Bundle error:", err)
+			return quote, echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch bundle")
+		}
+		if bundle.ComputerType != nil && !strings.EqualFold(*bundle.ComputerType, computerType) {
+			return quote, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("%s is for %s computers", bundle.Name, *bundle.ComputerType))
+		}
+		if bundle.Hours > billedHours {
+			return quote, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("%s includes %d hours, the rental is shorter", bundle.Name, bundle.Hours))
+		}
+		billedHours -= bundle.Hours
+		quote.Bundle = &bundle
+	}
+
+	quote.RentalCost = billedHours * hourlyRate
+	quote.Lines = append(quote.Lines, QuoteLine{
+		Description: computerName,
+		Category:    tax_handler.CategoryComputerTime,
+		Quantity:    float64(billedHours),
+		UnitPrice:   float64(hourlyRate),
+		Amount:      float64(quote.RentalCost),
+	})
+
+	// Price the additional services and check their stock
+	requested := map[int]int{}
+	for _, service := range req.Services {
+		if service.Quantity <= 0 {
+			return quote, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid quantity for Service ID %d", service.ServiceID))
+		}
+
+		var name, category string
+		var price float64
+		var availableQuantity int
+		serviceQuery := "SELECT name, category, price, quantity FROM service WHERE id = $1 AND is_active = TRUE"
+		err := config.Pool.QueryRow(ctx, serviceQuery, service.ServiceID).Scan(&name, &category, &price, &availableQuantity)
+		if err != nil {
+			return quote, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid service ID %d", service.ServiceID))
+		}
+
+		if service.Quantity > availableQuantity {
+			return quote, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Insufficient stock for Service ID %d. Available: %d, Requested: %d",
+				service.ServiceID, availableQuantity, service.Quantity))
+		}
+
+		quote.Lines = append(quote.Lines, QuoteLine{
+			Description: name,
+			Category:    category,
+			ServiceID:   service.ServiceID,
+			Quantity:    float64(service.Quantity),
+			UnitPrice:   price,
+			Amount:      price * float64(service.Quantity),
+		})
+		requested[service.ServiceID] += service.Quantity
+	}
+
+	// Split the bundle into its computer time and services, each allocated its share of
+	// the bundle price by list value so per-service revenue stays accurate
+	if quote.Bundle != nil {
+		bundleLines, err := bundleQuoteLines(*quote.Bundle, computerName, hourlyRate, requested)
+		if err != nil {
+			return quote, err
+		}
+		quote.Lines = append(quote.Lines, bundleLines...)
+		if quote.Bundle.Hours > 0 {
+			quote.RentalCost += int(math.Round(bundleLines[0].Amount))
+		}
+	}
+
+	// Cover included hours and take the member discount first
+	membershipLines := make([]membership_handler.MembershipLine, len(quote.Lines))
+	for i, line := range quote.Lines {
+		if line.BundleID != 0 {
+			continue // bundles are already discounted
+		}
+		membershipLines[i] = membership_handler.MembershipLine{Quantity: float64(line.Quantity), UnitPrice: line.UnitPrice, Amount: line.Amount - line.Discount}
+		if line.Category == tax_handler.CategoryComputerTime {
+			membershipLines[i].ComputerType = computerType
+		}
+	}
+	membership, err := membership_handler.ApplyMembership(ctx, config.Pool, req.CustomerID, membershipLines)
+	if err != nil {
+		fmt.Println("Membership error:", err)
+		return quote, echo.NewHTTPError(http.StatusInternalServerError, "Failed to apply membership")
+	}
+	if membership != nil {
+		for i := range quote.Lines {
+			quote.Lines[i].Discount = membership.LineDiscounts[i]
+		}
+		quote.Membership = membership
+		quote.MembershipDiscount = membership.Discount
+	}
+
+	// Draw the hours membership does not include from prepaid time packages
+	uncoveredHours := billedHours
+	if membership != nil {
+		uncoveredHours -= membership.HoursCovered
+	}
+	prepaid, err := time_package_handler.ApplyTime(ctx, config.Pool, req.CustomerID, computerType, uncoveredHours, quote.Lines[0].Amount-quote.Lines[0].Discount)
+	if err != nil {
+		fmt.Println("Prepaid time error:", err)
+		return quote, echo.NewHTTPError(http.StatusInternalServerError, "Failed to apply prepaid time")
+	}
+	if prepaid != nil {
+		quote.Lines[0].Discount += prepaid.Amount
+		quote.PrepaidTime = prepaid
+		quote.PrepaidTimeCredit = prepaid.Amount
+	}
+
+	// Apply the voucher on what is left to pay
+	if req.VoucherCode != "" {
+		applied, err := voucher_handler.ApplyVoucher(ctx, config.Pool, req.VoucherCode, req.CustomerID, quote.voucherLines(computerType))
+		var voucherErr *voucher_handler.VoucherError
+		if errors.As(err, &voucherErr) {
+			return quote, echo.NewHTTPError(http.StatusBadRequest, voucherErr.Message)
+		} else if err != nil {
+			fmt.Println("Voucher error:", err)
+			return quote, echo.NewHTTPError(http.StatusInternalServerError, "Failed to apply voucher")
+		}
+
+		for i := range quote.Lines {
+			quote.Lines[i].Discount += applied.LineDiscounts[i]
+		}
+		quote.Voucher = &applied
+		quote.Discount = applied.Discount
+	}
+
+	// Apply the loyalty reward on what is left to pay
+	if req.LoyaltyRewardID != 0 {
+		rewardLines := make([]loyalty_handler.RewardLine, len(quote.Lines))
+		for i, line := range quote.Lines {
+			rewardLines[i] = loyalty_handler.RewardLine{
+				ComputerTime: line.Category == tax_handler.CategoryComputerTime,
+				ServiceID:    line.ServiceID,
+				Quantity:     line.Quantity,
+				UnitPrice:    line.UnitPrice,
+				Amount:       line.Amount - line.Discount,
+			}
+			if line.BundleID != 0 {
+				// free hours and free services are not taken from bundles
+				rewardLines[i] = loyalty_handler.RewardLine{Amount: line.Amount - line.Discount}
+			}
+		}
+
+		applied, err := loyalty_handler.ApplyReward(ctx, config.Pool, req.LoyaltyRewardID, req.CustomerID, rewardLines)
+		var loyaltyErr *loyalty_handler.LoyaltyError
+		if errors.As(err, &loyaltyErr) {
+			return quote, echo.NewHTTPError(http.StatusBadRequest, loyaltyErr.Message)
+		} else if err != nil {
+			fmt.Println("Loyalty error:", err)
+			return quote, echo.NewHTTPError(http.StatusInternalServerError, "Failed to apply loyalty reward")
+		}
+
+		for i := range quote.Lines {
+			quote.Lines[i].Discount += applied.LineDiscounts[i]
+		}
+		quote.Reward = &applied
+		quote.LoyaltyDiscount = applied.Discount
+	}
+
+	// Apply tax per category
+	var taxable []tax_handler.TaxableLine
+	for _, line := range quote.Lines {
+		taxable = append(taxable, tax_handler.TaxableLine{Category: line.Category, Amount: line.Amount - line.Discount})
+	}
+	quote.Tax, err = tax_handler.QuoteTaxes(ctx, config.Pool, taxable)
+	if err != nil {
+		fmt.Println("Tax error:", err)
+		return quote, echo.NewHTTPError(http.StatusInternalServerError, "Failed to compute tax")
+	}
+	quote.TotalCost = quote.Tax.Total
+
+	return quote, nil
+}
+
+// bundleQuoteLines splits a bundle into a computer time line, if it includes hours, and
+// a line per service, each priced at its share of the bundle price. The services are
+// checked for stock on top of what the rental already requested.
+func bundleQuoteLines(bundle bundle_handler.Bundle, computerName string, hourlyRate int, requested map[int]int) ([]QuoteLine, error) {
+	var lines []QuoteLine
+	if bundle.Hours > 0 {
+		lines = append(lines, QuoteLine{
+			Description: fmt.Sprintf("%s - %s", bundle.Name, computerName),
+			Category:    tax_handler.CategoryComputerTime,
+			Quantity:    float64(bundle.Hours),
+			Amount:      float64(bundle.Hours * hourlyRate),
+			BundleID:    bundle.ID,
+		})
+	}
+	for _, component := range bundle.Components {
+		if requested[component.ServiceID]+component.Quantity > component.InStock {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Insufficient stock for Service ID %d. Available: %d, Requested: %d",
+				component.ServiceID, component.InStock, requested[component.ServiceID]+component.Quantity))
+		}
+		lines = append(lines, QuoteLine{
+			Description: fmt.Sprintf("%s - %s", bundle.Name, component.ServiceName),
+			Category:    component.Category,
+			ServiceID:   component.ServiceID,
+			Quantity:    float64(component.Quantity),
+			Amount:      component.UnitPrice * float64(component.Quantity),
+			BundleID:    bundle.ID,
+		})
+	}
+
+	// Lines carry their list value until the bundle price is allocated across them
+	listValues := make([]float64, len(lines))
+	for i, line := range lines {
+		listValues[i] = line.Amount
+	}
+	for i, amount := range bundle_handler.AllocatePrice(bundle.Price, listValues) {
+		lines[i].Amount = amount
+		lines[i].UnitPrice = amount / lines[i].Quantity
+	}
+	return lines, nil
+}
+
+// stockLines are the services of the quote to take from stock, bundled or not
+func (q RentalQuote) stockLines() []inventory_handler.StockLine {
+	var lines []inventory_handler.StockLine
+	for _, line := range q.Lines {
+		if line.ServiceID != 0 {
+			lines = append(lines, inventory_handler.StockLine{ServiceID: line.ServiceID, Quantity: int(line.Quantity)})
+		}
+	}
+	return lines
+}
+
+// serviceMetadata are the service lines stored on a pending order, to be recorded in
+// rental_services with the prices charged when it settles. Bundled lines carry the bundle
+// and their allocated amount.
+func (q RentalQuote) serviceMetadata() []map[string]interface{} {
+	services := []map[string]interface{}{}
+	for _, line := range q.Lines {
+		if line.ServiceID == 0 {
+			continue
+		}
+		service := map[string]interface{}{
+			"service_id": line.ServiceID,
+			"quantity":   int(line.Quantity),
+			"unit_price": line.UnitPrice,
+			"discount":   line.Discount,
+			"line_total": line.Amount - line.Discount,
+		}
+		if line.BundleID != 0 {
+			service["bundle_id"] = line.BundleID
+			service["bundle_amount"] = line.Amount
+		}
+		services = append(services, service)
+	}
+	return services
+}
+
+// voucherLines are the lines of the quote as a voucher sees them: what is left to pay on
+// each after the membership discount and prepaid time, so the voucher cannot take a line
+// below zero
+func (q RentalQuote) voucherLines(computerType string) []voucher_handler.DiscountableLine {
+	discountable := make([]voucher_handler.DiscountableLine, len(q.Lines))
+	for i, line := range q.Lines {
+		if line.BundleID != 0 {
+			// only vouchers valid on any purchase apply to bundles
+			discountable[i] = voucher_handler.DiscountableLine{Amount: line.Amount - line.Discount}
+			continue
+		}
+		discountable[i] = voucher_handler.DiscountableLine{ServiceID: line.ServiceID, Amount: line.Amount - line.Discount}
+		if line.Category == tax_handler.CategoryComputerTime {
+			discountable[i].ComputerType = computerType
+		}
+	}
+	return discountable
+}
+
+// timeCharge is the computer time of the quote as stored on rental_history: the hourly
+// rate, the discount on the hours, bundled or not, and what they came to before tax
+func (q RentalQuote) timeCharge() (hourlyRate float64, discount float64, lineTotal float64) {
+	for i, line := range q.Lines {
+		if i == 0 {
+			hourlyRate = line.UnitPrice
+		}
+		if line.Category == tax_handler.CategoryComputerTime && line.ServiceID == 0 {
+			discount += line.Discount
+			lineTotal += line.Amount - line.Discount
+		}
+	}
+	return hourlyRate, discount, lineTotal
+}
+
+// chargeHolds are the voucher use, loyalty points, membership hours, prepaid minutes and stock taken or reserved before payment
+type chargeHolds struct {
+	voucherRedemptionID int
+	redemptionID        int
+	usageID             int
+	timeUsageIDs        []int
+	stockMovementIDs    []int
+	reservationIDs      []int
+}
+
+// saveQuoteCharges stores the tax, discounts, points redemption, membership usage,
+// prepaid minutes and stock taken or reserved for a quote on its transaction
+func saveQuoteCharges(ctx context.Context, db config.DBTX, transactionID int, quote RentalQuote, holds chargeHolds) error {
+	if err := tax_handler.SaveTransactionTaxes(ctx, db, transactionID, quote.Tax); err != nil {
+		return err
+	}
+	if quote.Voucher != nil {
+		if err := voucher_handler.RecordRedemption(ctx, db, holds.voucherRedemptionID, *quote.Voucher, transactionID); err != nil {
+			return err
+		}
+	}
+	if quote.Reward != nil {
+		if err := loyalty_handler.LinkRedemption(ctx, db, holds.redemptionID, *quote.Reward, transactionID); err != nil {
+			return err
+		}
+	}
+	if quote.Membership != nil {
+		if err := membership_handler.LinkUsage(ctx, db, holds.usageID, *quote.Membership, transactionID); err != nil {
+			return err
+		}
+	}
+	if quote.PrepaidTime != nil {
+		if err := time_package_handler.LinkTimeUsage(ctx, db, holds.timeUsageIDs, *quote.PrepaidTime, transactionID); err != nil {
+			return err
+		}
+	}
+	if len(holds.stockMovementIDs) > 0 {
+		if err := inventory_handler.LinkMovements(ctx, db, holds.stockMovementIDs, transactionID); err != nil {
+			return err
+		}
+	}
+	if len(holds.reservationIDs) > 0 {
+		return inventory_handler.LinkReservations(ctx, db, holds.reservationIDs, transactionID)
+	}
+	return nil
+}
+
+// quoteErrorResponse writes an error returned by buildRentalQuote
+func quoteErrorResponse(c echo.Context, err error) error {
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return c.JSON(httpErr.Code, map[string]string{"message": fmt.Sprint(httpErr.Message)})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to quote rental"})
+}
+
+// QuoteRental godoc
+// @Summary Quote a rental
+// @Description Price a rental and its services, including membership hours, prepaid time and the membership, voucher and loyalty reward discounts, tax and service charge, without charging the customer
+// @Tags Rentals
+// @Accept json
+// @Produce json
+// @Param rentalRequest body RentalRequest true "Rental Details"
+// @Success 200 {object} RentalQuote "Rental quote"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/rental/quote [post]
+func QuoteRental(c echo.Context) error {
+	var req RentalRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	// Extract admin role from JWT claims
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	adminRole, _ := claims["role"].(string)
+
+	// Validate admin role
+	if adminRole != "admin" && adminRole != "super-admin" {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only selected admins can perform this action."})
+	}
+
+	quote, err := buildRentalQuote(context.Background(), req)
+	if err != nil {
+		return quoteErrorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, quote)
+}
@@ -0,0 +1,473 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+	export_handler "w4/p2/milestones/internal/exportHandler"
+
+	"github.com/labstack/echo/v4"
+)
+
+// spendTypes are the transactions that are money spent at the cafe. Top-ups and
+// transfers only move money into or between wallets.
+const spendTypes = `('Rental Payment', 'Service Payment', 'Membership Payment', 'Equipment Charge')`
+
+// CustomerInsight is what a customer has spent and how often they come
+type CustomerInsight struct {
+	CustomerID           int          `json:"customer_id"`
+	Name                 string       `json:"name"`
+	Username             string       `json:"username"`
+	RegisteredAt         time.Time    `json:"registered_at"`
+	LifetimeSpend        float64      `json:"lifetime_spend"`
+	Payments             int          `json:"payments"`
+	Visits               int          `json:"visits"` // rentals
+	VisitsPerMonth       float64      `json:"visits_per_month"`
+	AverageDaysBetween   *float64     `json:"average_days_between_visits"` // nil with fewer than two visits
+	FirstVisit           *time.Time   `json:"first_visit"`
+	LastVisit            *time.Time   `json:"last_visit"`
+	LastActive           *time.Time   `json:"last_active"` // last visit or payment
+	DaysInactive         *int         `json:"days_inactive"`
+	FavoriteComputerType *string      `json:"favorite_computer_type"`
+	TopServices          []TopService `json:"top_services"`
+}
+
+// Cohort is how many customers who registered in a month came back in each month after
+type Cohort struct {
+	Month     string            `json:"month"` // registration month, YYYY-MM
+	Customers int               `json:"customers"`
+	Retention []CohortRetention `json:"retention"`
+}
+
+// CohortRetention is the share of a cohort active a number of months after registering
+type CohortRetention struct {
+	MonthOffset int     `json:"month_offset"` // 0 is the registration month
+	Active      int     `json:"active"`
+	Rate        float64 `json:"rate"`
+}
+
+// cohortActivity is the number of customers of a cohort active in a month after registering
+type cohortActivity struct {
+	Cohort      time.Time
+	MonthOffset int
+	Active      int
+}
+
+// customerSorts are the orders the customer list can be sorted in
+var customerSorts = map[string]string{
+	"lifetime_spend": "lifetime_spend DESC, cu.id",
+	"visits":         "visits DESC, cu.id",
+	"last_visit":     "last_visit DESC NULLS LAST, cu.id",
+	"registered":     "cu.created_at DESC, cu.id",
+}
+
+// customerMetricsQuery selects the insight columns of every customer, before filters and order
+const customerMetricsQuery = `
+	WITH spend AS (
+		SELECT customer_id, SUM(amount) AS lifetime_spend, COUNT(*) AS payments, MAX(transaction_date) AS last_payment
+		FROM transaction
+		WHERE status ILIKE 'Settlement' AND transaction_type IN ` + spendTypes + `
+		GROUP BY customer_id
+	), visits AS (
+		SELECT customer_id, COUNT(*) AS visits, MIN(rental_start_time) AS first_visit, MAX(rental_start_time) AS last_visit
+		FROM rental_history
+		GROUP BY customer_id
+	), favorite AS (
+		SELECT DISTINCT ON (rh.customer_id) rh.customer_id, c.type
+		FROM rental_history rh
+		JOIN computer c ON c.id = rh.computer_id
+		GROUP BY rh.customer_id, c.type
+		ORDER BY rh.customer_id, COUNT(*) DESC, c.type
+	), metrics AS (
+		SELECT cu.id, cu.name, cu.username, cu.created_at,
+		       COALESCE(sp.lifetime_spend, 0)::DOUBLE PRECISION AS lifetime_spend, COALESCE(sp.payments, 0)::INT AS payments,
+		       COALESCE(v.visits, 0)::INT AS visits, v.first_visit, v.last_visit,
+		       NULLIF(GREATEST(COALESCE(v.last_visit, '-infinity'), COALESCE(sp.last_payment, '-infinity')), '-infinity') AS last_active,
+		       f.type AS favorite_type
+		FROM customer cu
+		LEFT JOIN spend sp ON sp.customer_id = cu.id
+		LEFT JOIN visits v ON v.customer_id = cu.id
+		LEFT JOIN favorite f ON f.customer_id = cu.id
+	)
+	SELECT cu.id, cu.name, cu.username, cu.created_at, cu.lifetime_spend, cu.payments, cu.visits,
+	       cu.first_visit, cu.last_visit, cu.last_active, cu.favorite_type, COUNT(*) OVER ()
+	FROM metrics cu`
+
+// queryCustomerInsights runs customerMetricsQuery with a filter and order, then adds
+// each customer's visit frequency and top services
+func queryCustomerInsights(ctx context.Context, now time.Time, where, orderBy string, limit, offset int, args ...interface{}) ([]CustomerInsight, int, error) {
+	args = append(args, limit, offset)
+	query := customerMetricsQuery + `
+		WHERE ` + where + `
+		ORDER BY ` + orderBy + fmt.Sprintf(`
+		LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+	rows, err := config.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch customer insights: %w", err)
+	}
+	defer rows.Close()
+
+	insights := []CustomerInsight{}
+	index := map[int]int{}
+	var customerIDs []int
+	total := 0
+	for rows.Next() {
+		var ci CustomerInsight
+		if err := rows.Scan(&ci.CustomerID, &ci.Name, &ci.Username, &ci.RegisteredAt, &ci.LifetimeSpend, &ci.Payments, &ci.Visits,
+			&ci.FirstVisit, &ci.LastVisit, &ci.LastActive, &ci.FavoriteComputerType, &total); err != nil {
+			return nil, 0, fmt.Errorf("failed to parse customer insights: %w", err)
+		}
+		visitFrequency(&ci, now)
+		ci.TopServices = []TopService{}
+		index[ci.CustomerID] = len(insights)
+		customerIDs = append(customerIDs, ci.CustomerID)
+		insights = append(insights, ci)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if len(customerIDs) == 0 {
+		return insights, total, nil
+	}
+
+	// Services bought during rentals, or on their own, three per customer
+	servicesQuery := `
+		SELECT customer_id, name, total_revenue, total_sold
+		FROM (
+			SELECT COALESCE(rh.customer_id, t.customer_id) AS customer_id, s.name,
+			       SUM(rs.line_total)::DOUBLE PRECISION AS total_revenue, SUM(rs.quantity)::INT AS total_sold,
+			       ROW_NUMBER() OVER (PARTITION BY COALESCE(rh.customer_id, t.customer_id) ORDER BY SUM(rs.quantity) DESC, s.name) AS rank
+			FROM rental_services rs
+			JOIN service s ON s.id = rs.service_id
+			LEFT JOIN rental_history rh ON rh.id = rs.rental_history_id
+			LEFT JOIN transaction t ON t.id = rs.transaction_id
+			WHERE COALESCE(rh.customer_id, t.customer_id) = ANY($1)
+			GROUP BY 1, s.name
+		) ranked
+		WHERE rank <= 3
+		ORDER BY customer_id, rank`
+	serviceRows, err := config.Pool.Query(ctx, servicesQuery, customerIDs)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch customer top services: %w", err)
+	}
+	defer serviceRows.Close()
+	for serviceRows.Next() {
+		var customerID int
+		var service TopService
+		if err := serviceRows.Scan(&customerID, &service.ServiceName, &service.TotalRevenue, &service.TotalSold); err != nil {
+			return nil, 0, fmt.Errorf("failed to parse customer top services: %w", err)
+		}
+		ci := &insights[index[customerID]]
+		ci.TopServices = append(ci.TopServices, service)
+	}
+	return insights, total, serviceRows.Err()
+}
+
+// visitFrequency works out how often a customer visits: visits per 30 days since their
+// first visit, counting at least one month, and the average gap between visits
+func visitFrequency(ci *CustomerInsight, now time.Time) {
+	if ci.LastActive != nil {
+		days := int(now.Sub(*ci.LastActive).Hours() / 24)
+		ci.DaysInactive = &days
+	}
+	if ci.FirstVisit == nil || ci.Visits == 0 {
+		return
+	}
+	months := math.Max(now.Sub(*ci.FirstVisit).Hours()/24/30, 1)
+	ci.VisitsPerMonth = round2(float64(ci.Visits) / months)
+	if ci.Visits > 1 && ci.LastVisit != nil {
+		gap := math.Round(ci.LastVisit.Sub(*ci.FirstVisit).Hours()/24/float64(ci.Visits-1)*10) / 10
+		ci.AverageDaysBetween = &gap
+	}
+}
+
+// parsePage reads limit and offset, with a default and maximum limit
+func parsePage(c echo.Context, defaultLimit, maxLimit int) (int, int, error) {
+	limit, offset := defaultLimit, 0
+	if value := c.QueryParam("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > maxLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+		limit = parsed
+	}
+	if value := c.QueryParam("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return 0, 0, fmt.Errorf("Invalid offset")
+		}
+		offset = parsed
+	}
+	return limit, offset, nil
+}
+
+// GetCustomerAnalytics godoc
+// @Summary Customer analytics
+// @Description Per-customer lifetime spend, visit frequency, last visit, favorite computer type and top services. Lifetime spend counts settled rental, service, membership and equipment payments, not top-ups or transfers.
+// @Tags Analytics
+// @Produce json
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param sort query string false "lifetime_spend (default), visits, last_visit or registered"
+// @Param limit query int false "Number of customers (default 50)"
+// @Param offset query int false "Customers to skip"
+// @Param format query string false "json, csv or xlsx (or use the Accept header)"
+// @Param lang query string false "Language of export column headers (en or id)"
+// @Success 200 {object} map[string]interface{} "Customer analytics retrieved successfully"
+// @Failure 400 {object} map[string]string "Invalid parameters"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/analytics/customers [get]
+func GetCustomerAnalytics(c echo.Context) error {
+	if _, ok := requireSuperAdmin(c); !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can view analytics."})
+	}
+
+	format, err := export_handler.RequestedFormat(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+	sort := c.QueryParam("sort")
+	if sort == "" {
+		sort = "lifetime_spend"
+	}
+	orderBy, ok := customerSorts[sort]
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "sort must be lifetime_spend, visits, last_visit or registered"})
+	}
+	limit, offset, err := parsePage(c, 50, 500)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+
+	insights, total, err := queryCustomerInsights(context.Background(), time.Now(), "TRUE", orderBy, limit, offset)
+	if err != nil {
+		fmt.Println("Customer analytics error:", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch customer analytics"})
+	}
+
+	if format != export_handler.FormatJSON {
+		return exportCustomerInsights(c, format, "customer-analytics", insights)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Customer analytics retrieved successfully",
+		"data":    insights,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// GetChurnRisk godoc
+// @Summary Customers at risk of churning
+// @Description Customers who visited or paid before but not in the last N days, the biggest spenders first
+// @Tags Analytics
+// @Produce json
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param days query int false "Days without activity (default 30)"
+// @Param limit query int false "Number of customers (default 50)"
+// @Param offset query int false "Customers to skip"
+// @Param format query string false "json, csv or xlsx (or use the Accept header)"
+// @Param lang query string false "Language of export column headers (en or id)"
+// @Success 200 {object} map[string]interface{} "Churn risk retrieved successfully"
+// @Failure 400 {object} map[string]string "Invalid parameters"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/analytics/churn-risk [get]
+func GetChurnRisk(c echo.Context) error {
+	if _, ok := requireSuperAdmin(c); !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can view analytics."})
+	}
+
+	format, err := export_handler.RequestedFormat(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+	days := 30
+	if value := c.QueryParam("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > 3650 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "days must be between 1 and 3650"})
+		}
+		days = parsed
+	}
+	limit, offset, err := parsePage(c, 50, 500)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+
+	now := time.Now()
+	insights, total, err := queryCustomerInsights(context.Background(), now, "cu.last_active < $1", "lifetime_spend DESC, cu.last_active, cu.id",
+		limit, offset, now.AddDate(0, 0, -days))
+	if err != nil {
+		fmt.Println("Churn risk error:", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch churn risk"})
+	}
+
+	if format != export_handler.FormatJSON {
+		return exportCustomerInsights(c, format, fmt.Sprintf("churn-risk-%d-days", days), insights)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Churn risk retrieved successfully",
+		"days":    days,
+		"data":    insights,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// GetCohortRetention godoc
+// @Summary Cohort retention
+// @Description Customers grouped by registration month, with the share of each group that visited or paid in every month since
+// @Tags Analytics
+// @Produce json
+// @Param months query int false "Registration months to include, up to this month (default 12)"
+// @Success 200 {object} map[string]interface{} "Cohort retention retrieved successfully"
+// @Failure 400 {object} map[string]string "Invalid parameters"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/analytics/cohorts [get]
+func GetCohortRetention(c echo.Context) error {
+	if _, ok := requireSuperAdmin(c); !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can view analytics."})
+	}
+
+	months := 12
+	if value := c.QueryParam("months"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > 60 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "months must be between 1 and 60"})
+		}
+		months = parsed
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	since := thisMonth.AddDate(0, -(months - 1), 0)
+
+	sizes := map[string]int{}
+	rows, err := config.Pool.Query(ctx, `
+		SELECT date_trunc('month', created_at), COUNT(*)::INT
+		FROM customer
+		WHERE created_at >= $1
+		GROUP BY 1`, since)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch cohorts"})
+	}
+	for rows.Next() {
+		var month time.Time
+		var size int
+		if err := rows.Scan(&month, &size); err != nil {
+			rows.Close()
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to parse cohorts"})
+		}
+		sizes[month.Format("2006-01")] = size
+	}
+	rows.Close()
+
+	// Months in which each customer rented a computer or paid for something
+	activityQuery := `
+		WITH cohort AS (
+			SELECT id, date_trunc('month', created_at) AS month
+			FROM customer
+			WHERE created_at >= $1
+		), activity AS (
+			SELECT customer_id, date_trunc('month', rental_start_time) AS month FROM rental_history
+			UNION
+			SELECT customer_id, date_trunc('month', transaction_date) FROM transaction
+			WHERE status ILIKE 'Settlement' AND transaction_type IN ` + spendTypes + `
+		)
+		SELECT co.month, ((EXTRACT(YEAR FROM a.month) - EXTRACT(YEAR FROM co.month)) * 12
+		                 + EXTRACT(MONTH FROM a.month) - EXTRACT(MONTH FROM co.month))::INT AS month_offset,
+		       COUNT(DISTINCT co.id)::INT
+		FROM cohort co
+		JOIN activity a ON a.customer_id = co.id AND a.month >= co.month
+		GROUP BY 1, 2`
+	rows, err = config.Pool.Query(ctx, activityQuery, since)
+	if err != nil {
+		fmt.Println("Cohort retention error:", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch cohort activity"})
+	}
+	activity := []cohortActivity{}
+	for rows.Next() {
+		var a cohortActivity
+		if err := rows.Scan(&a.Cohort, &a.MonthOffset, &a.Active); err != nil {
+			rows.Close()
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to parse cohort activity"})
+		}
+		activity = append(activity, a)
+	}
+	rows.Close()
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Cohort retention retrieved successfully",
+		"data":    BuildCohorts(since, thisMonth, sizes, activity),
+	})
+}
+
+// BuildCohorts lays out a retention table for every registration month from since to
+// thisMonth, with a rate for each month a cohort has been registered
+func BuildCohorts(since, thisMonth time.Time, sizes map[string]int, activity []cohortActivity) []Cohort {
+	active := map[string]map[int]int{}
+	for _, a := range activity {
+		month := a.Cohort.Format("2006-01")
+		if active[month] == nil {
+			active[month] = map[int]int{}
+		}
+		active[month][a.MonthOffset] = a.Active
+	}
+
+	cohorts := []Cohort{}
+	for month := since; !month.After(thisMonth); month = month.AddDate(0, 1, 0) {
+		remaining := (thisMonth.Year()-month.Year())*12 + int(thisMonth.Month()-month.Month())
+		key := month.Format("2006-01")
+		cohort := Cohort{Month: key, Customers: sizes[key], Retention: []CohortRetention{}}
+		for offset := 0; offset <= remaining; offset++ {
+			retention := CohortRetention{MonthOffset: offset, Active: active[key][offset]}
+			retention.Rate = ratio(float64(retention.Active), float64(cohort.Customers))
+			cohort.Retention = append(cohort.Retention, retention)
+		}
+		cohorts = append(cohorts, cohort)
+	}
+	return cohorts
+}
+
+// customerInsightColumns are the columns of a customer analytics export
+var customerInsightColumns = []export_handler.Column{
+	{English: "Customer ID", Indonesian: "ID Pelanggan", Kind: export_handler.KindNumber},
+	{English: "Name", Indonesian: "Nama", Kind: export_handler.KindText},
+	{English: "Username", Indonesian: "Username", Kind: export_handler.KindText},
+	{English: "Registered", Indonesian: "Terdaftar", Kind: export_handler.KindDate},
+	{English: "Lifetime Spend", Indonesian: "Total Belanja", Kind: export_handler.KindCurrency},
+	{English: "Visits", Indonesian: "Kunjungan", Kind: export_handler.KindNumber},
+	{English: "Visits per Month", Indonesian: "Kunjungan per Bulan", Kind: export_handler.KindNumber},
+	{English: "Last Visit", Indonesian: "Kunjungan Terakhir", Kind: export_handler.KindDate},
+	{English: "Days Inactive", Indonesian: "Hari Tidak Aktif", Kind: export_handler.KindNumber},
+	{English: "Favorite Computer Type", Indonesian: "Tipe Komputer Favorit", Kind: export_handler.KindText},
+}
+
+// exportCustomerInsights writes customer insights as a spreadsheet download
+func exportCustomerInsights(c echo.Context, format, filename string, insights []CustomerInsight) error {
+	w, err := export_handler.NewWriter(c, format, filename, customerInsightColumns)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+	for _, ci := range insights {
+		if err := w.WriteRow(ci.CustomerID, ci.Name, ci.Username, ci.RegisteredAt, ci.LifetimeSpend, ci.Visits,
+			ci.VisitsPerMonth, ci.LastVisit, ci.DaysInactive, ci.FavoriteComputerType); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
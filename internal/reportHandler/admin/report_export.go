@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"net/http"
+
+	export_handler "w4/p2/milestones/internal/exportHandler"
+
+	"github.com/labstack/echo/v4"
+)
+
+// revenueReportColumns lay a revenue report out as one line per figure, grouped by section
+var revenueReportColumns = []export_handler.Column{
+	{English: "Section", Indonesian: "Bagian", Kind: export_handler.KindText},
+	{English: "Item", Indonesian: "Keterangan", Kind: export_handler.KindText},
+	{English: "Count", Indonesian: "Jumlah", Kind: export_handler.KindNumber},
+	{English: "Amount", Indonesian: "Nominal", Kind: export_handler.KindCurrency},
+}
+
+// exportRevenueReport writes a revenue report as a spreadsheet download
+func exportRevenueReport(c echo.Context, format, filename string, report RevenueReportResponse) error {
+	w, err := export_handler.NewWriter(c, format, filename, revenueReportColumns)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+	return writeRevenueReport(w, report)
+}
+
+// writeRevenueReport writes the lines of a revenue report and closes the writer
+func writeRevenueReport(w export_handler.Writer, report RevenueReportResponse) error {
+	summary := [][]interface{}{
+		{"Summary", "Total revenue", report.TotalTransactions, report.TotalRevenue},
+		{"Summary", "Total tax", nil, report.TotalTax},
+		{"Summary", "Net revenue", nil, report.NetRevenue},
+		{"Summary", "Voucher discounts", nil, report.TotalDiscount},
+	}
+	for _, line := range summary {
+		if err := w.WriteRow(line...); err != nil {
+			return err
+		}
+	}
+	for _, tax := range report.TaxBreakdown {
+		if err := w.WriteRow("Tax", tax.Name, nil, tax.TaxAmount); err != nil {
+			return err
+		}
+	}
+	for _, voucher := range report.VoucherUsage {
+		if err := w.WriteRow("Voucher", voucher.Code, voucher.Redemptions, voucher.TotalDiscount); err != nil {
+			return err
+		}
+	}
+	for _, service := range report.TopServices {
+		if err := w.WriteRow("Top service", service.ServiceName, service.TotalSold, service.TotalRevenue); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
@@ -12,34 +12,65 @@ import (
 	"github.com/labstack/echo/v4"
 
 	config "w4/p2/milestones/config/database"
+	audit_handler "w4/p2/milestones/internal/auditHandler"
+	export_handler "w4/p2/milestones/internal/exportHandler"
 )
 
 // RevenueReportRequest defines the request payload for the report
 type RevenueReportRequest struct {
-	StartDate string `json:"start_date" validate:"required"`
-	EndDate   string `json:"end_date" validate:"required"`
+	StartDate  string `json:"start_date" validate:"required"`
+	EndDate    string `json:"end_date" validate:"required"`
+	Regenerate bool   `json:"regenerate"` // recompute even if a report for the period was already saved
 }
 
 // RevenueReportResponse defines the structure of the response
 type RevenueReportResponse struct {
+	ReportID          int       `json:"report_id"`
+	GeneratedAt       time.Time `json:"generated_at"`
+	Reused            bool      `json:"reused"` // an earlier report for the same period was returned
 	TotalRevenue      float64 `json:"total_revenue"`
+	TotalTax          float64 `json:"total_tax"`
+	TotalDiscount     float64 `json:"total_discount"` // voucher discounts given, already excluded from revenue
+	NetRevenue        float64 `json:"net_revenue"` // revenue excluding tax
 	TotalTransactions int     `json:"total_transactions"`
-	TopServices       []struct {
-		ServiceName  string `json:"service_name"`
-		TotalRevenue float64 `json:"total_revenue"`
-		TotalSold    int     `json:"total_sold"`
-	} `json:"top_services"`
+	TaxBreakdown      []TaxSummary `json:"tax_breakdown"`
+	VoucherUsage      []VoucherSummary `json:"voucher_usage"`
+	TopServices       []TopService `json:"top_services"`
+}
+
+// TopService is a best-selling service in a report period
+type TopService struct {
+	ServiceName  string  `json:"service_name"`
+	TotalRevenue float64 `json:"total_revenue"`
+	TotalSold    int     `json:"total_sold"`
+}
+
+// TaxSummary is the tax collected under one tax rule name
+type TaxSummary struct {
+	Name      string  `json:"name"`
+	TaxAmount float64 `json:"tax_amount"`
+}
+
+// VoucherSummary is the discount given through one voucher code
+type VoucherSummary struct {
+	Code          string  `json:"code"`
+	Redemptions   int     `json:"redemptions"`
+	TotalDiscount float64 `json:"total_discount"`
 }
 
 // GenerateRevenueReport godoc
 // @Summary Generate revenue report
-// @Description Allows super-admins to generate a revenue report for a specified date range, including total revenue, transactions, and top services.
+// @Description Allows super-admins to generate a revenue report for a specified date range, both days included as days in Asia/Jakarta, including total revenue, tax collected, voucher discounts, transactions, and top services. A report saved for the same period after it closed and its payments settled (a day later) is returned unless regenerate is set.
 // @Tags Reports
 // @Accept json
 // @Produce json
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
 // @Param body body RevenueReportRequest true "Request body with start_date and end_date"
+// @Param format query string false "json, csv or xlsx (or use the Accept header)"
+// @Param lang query string false "Language of export column headers (en or id)"
 // @Success 200 {object} RevenueReportResponse
-// @Failure 400 {object} map[string]string "Invalid request payload or date format"
+// @Failure 400 {object} map[string]string "Invalid request payload, date or format"
 // @Failure 403 {object} map[string]string "Unauthorized action. Only super-admins can generate reports."
 // @Failure 500 {object} map[string]string "Failed to generate report or perform internal operation"
 // @Security BearerAuth
@@ -51,12 +82,23 @@ func GenerateRevenueReport(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
 	}
 
-	startDate, err := time.Parse("2006-01-02", req.StartDate)
+	format, err := export_handler.RequestedFormat(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+	filename := fmt.Sprintf("revenue-report-%s-%s", req.StartDate, req.EndDate)
+
+	// Report days are days in Jakarta, as for scheduled reports
+	loc, err := time.LoadLocation(DefaultTimezone)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to load report timezone"})
+	}
+	startDate, err := time.ParseInLocation("2006-01-02", req.StartDate, loc)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid start date format"})
 	}
 
-	endDate, err := time.Parse("2006-01-02", req.EndDate)
+	endDate, err := time.ParseInLocation("2006-01-02", req.EndDate, loc)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid end date format"})
 	}
@@ -74,93 +116,176 @@ func GenerateRevenueReport(c echo.Context) error {
 		})
 	}
 
-	// Query total revenue and total transactions
-	var totalRevenue float64
-	var totalTransactions int
-	// Query total revenue and total transactions
+	// Return the report already saved for the period unless asked to recompute it
+	if !req.Regenerate {
+		saved, err := findReport(context.Background(), RevenueReportType, startDate, endDate)
+		if err != nil {
+			fmt.Println("Report error:", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch saved report"})
+		}
+		if saved != nil {
+			response := saved.revenueResponse()
+			response.Reused = true
+			if format != export_handler.FormatJSON {
+				return exportRevenueReport(c, format, filename, response)
+			}
+			return c.JSON(http.StatusOK, response)
+		}
+	}
+
+	// Compute the report and save it with the breakdowns, so it can be read back in full
+	response, err := BuildRevenueReport(context.Background(), startDate, endDate)
+	if err != nil {
+		fmt.Println("Report error:", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to generate report"})
+	}
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	if err := SaveRevenueReport(ctx, tx, adminID, startDate, endDate, &response); err != nil {
+		fmt.Println("Report error:", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to save report"})
+	}
+
+	// Log the admin action in the audit log
+	event := audit_handler.FromRequest(c, "report.generate", "report", response.ReportID).
+		Change(nil, map[string]interface{}{"report_type": RevenueReportType, "start_date": req.StartDate, "end_date": req.EndDate}).
+		Describe("Super-admin (ID: %d) generated a revenue report for %s to %s", adminID, req.StartDate, req.EndDate)
+	if err := audit_handler.Record(ctx, tx, event); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log admin action"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to save report"})
+	}
+
+	if format != export_handler.FormatJSON {
+		return exportRevenueReport(c, format, filename, response)
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// BuildRevenueReport computes the revenue report of the days from startDate to endDate, both
+// included. The dates are the midnights starting the days in the report's timezone, and
+// are compared as instants with the database clock.
+func BuildRevenueReport(ctx context.Context, startDate, endDate time.Time) (RevenueReportResponse, error) {
+	report := RevenueReportResponse{
+		TaxBreakdown: []TaxSummary{},
+		VoucherUsage: []VoucherSummary{},
+		TopServices:  []TopService{},
+	}
+	periodEnd := endDate.AddDate(0, 0, 1)
+
+	// Query total revenue, tax, discounts and total transactions
 	query := `
-		SELECT COALESCE(SUM(amount), 0) AS total_revenue, COALESCE(COUNT(*), 0) AS total_transactions
+		SELECT COALESCE(SUM(amount), 0) AS total_revenue, COALESCE(SUM(tax_amount), 0) AS total_tax,
+		       COALESCE(SUM(discount_amount), 0) AS total_discount, COALESCE(COUNT(*), 0) AS total_transactions
 		FROM transaction
-		WHERE transaction_date BETWEEN $1 AND $2 AND status ILIKE 'Settlement'
+		WHERE transaction_date >= $1::TIMESTAMPTZ AND transaction_date < $2::TIMESTAMPTZ AND status ILIKE 'Settlement'
+		  AND transaction_type NOT IN ('Transfer Out', 'Transfer In') -- wallet transfers move existing balance
 	`
-	err = config.Pool.QueryRow(context.Background(), query, startDate, endDate).Scan(&totalRevenue, &totalTransactions)
+	err := config.Pool.QueryRow(ctx, query, startDate, periodEnd).Scan(&report.TotalRevenue, &report.TotalTax, &report.TotalDiscount, &report.TotalTransactions)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to generate report"})
+		return report, fmt.Errorf("failed to fetch revenue totals: %w", err)
+	}
+	report.NetRevenue = report.TotalRevenue - report.TotalTax
+
+	// Query tax collected per rule
+	taxQuery := `
+		SELECT tt.name, SUM(tt.tax_amount) AS tax_amount
+		FROM transaction_tax tt
+		JOIN transaction t ON t.id = tt.transaction_id
+		WHERE t.transaction_date >= $1::TIMESTAMPTZ AND t.transaction_date < $2::TIMESTAMPTZ AND t.status ILIKE 'Settlement'
+		GROUP BY tt.name
+		ORDER BY tax_amount DESC`
+	taxRows, err := config.Pool.Query(ctx, taxQuery, startDate, periodEnd)
+	if err != nil {
+		return report, fmt.Errorf("failed to fetch tax breakdown: %w", err)
+	}
+	defer taxRows.Close()
+
+	for taxRows.Next() {
+		var tax TaxSummary
+		if err := taxRows.Scan(&tax.Name, &tax.TaxAmount); err != nil {
+			return report, fmt.Errorf("failed to parse tax breakdown: %w", err)
+		}
+		report.TaxBreakdown = append(report.TaxBreakdown, tax)
+	}
+	if err := taxRows.Err(); err != nil {
+		return report, fmt.Errorf("failed to read tax breakdown: %w", err)
 	}
 
-	// Query top services
+	// Query discounts given per voucher
+	voucherQuery := `
+		SELECT v.code, COUNT(*) AS redemptions, SUM(t.discount_amount) AS total_discount
+		FROM transaction t
+		JOIN voucher v ON v.id = t.voucher_id
+		WHERE t.transaction_date >= $1::TIMESTAMPTZ AND t.transaction_date < $2::TIMESTAMPTZ AND t.status ILIKE 'Settlement'
+		GROUP BY v.code
+		ORDER BY total_discount DESC`
+	voucherRows, err := config.Pool.Query(ctx, voucherQuery, startDate, periodEnd)
+	if err != nil {
+		return report, fmt.Errorf("failed to fetch voucher usage: %w", err)
+	}
+	defer voucherRows.Close()
+
+	for voucherRows.Next() {
+		var voucher VoucherSummary
+		if err := voucherRows.Scan(&voucher.Code, &voucher.Redemptions, &voucher.TotalDiscount); err != nil {
+			return report, fmt.Errorf("failed to parse voucher usage: %w", err)
+		}
+		report.VoucherUsage = append(report.VoucherUsage, voucher)
+	}
+	if err := voucherRows.Err(); err != nil {
+		return report, fmt.Errorf("failed to read voucher usage: %w", err)
+	}
+
+	// Query top services from the prices charged at the time of sale, after discounts
 	topServicesQuery := `
-		SELECT s.name, SUM(rs.quantity * s.price) AS total_revenue, SUM(rs.quantity) AS total_sold
+		SELECT s.name, SUM(rs.line_total) AS total_revenue, SUM(rs.quantity) AS total_sold
 		FROM rental_services rs
 		JOIN service s ON rs.service_id = s.id
-		WHERE rs.created_at BETWEEN $1 AND $2
+		WHERE rs.created_at >= $1::TIMESTAMPTZ AND rs.created_at < $2::TIMESTAMPTZ
 		GROUP BY s.id
 		ORDER BY total_revenue DESC
 		LIMIT 5`
-	rows, err := config.Pool.Query(context.Background(), topServicesQuery, startDate, endDate)
+	rows, err := config.Pool.Query(ctx, topServicesQuery, startDate, periodEnd)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch top services"})
+		return report, fmt.Errorf("failed to fetch top services: %w", err)
 	}
 	defer rows.Close()
 
-	var topServices []struct {
-		ServiceName  string  `json:"service_name"`
-		TotalRevenue float64 `json:"total_revenue"`
-		TotalSold    int     `json:"total_sold"`
-	}
-
 	for rows.Next() {
-		var service struct {
-			ServiceName  string  `json:"service_name"`
-			TotalRevenue float64 `json:"total_revenue"`
-			TotalSold    int     `json:"total_sold"`
-		}
-		err = rows.Scan(&service.ServiceName, &service.TotalRevenue, &service.TotalSold)
-		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to parse top services"})
+		var service TopService
+		if err := rows.Scan(&service.ServiceName, &service.TotalRevenue, &service.TotalSold); err != nil {
+			return report, fmt.Errorf("failed to parse top services: %w", err)
 		}
-		topServices = append(topServices, service)
+		report.TopServices = append(report.TopServices, service)
 	}
-
-	// Ensure `topServices` is not nil even if no rows are returned
-	if topServices == nil {
-		topServices = []struct {
-			ServiceName  string  `json:"service_name"`
-			TotalRevenue float64 `json:"total_revenue"`
-			TotalSold    int     `json:"total_sold"`
-		}{}
+	if err := rows.Err(); err != nil {
+		return report, fmt.Errorf("failed to read top services: %w", err)
 	}
+	return report, nil
+}
 
-	// Insert report into the report table
-	reportQuery := `
-		INSERT INTO report (admin_id, report_type, start_date, end_date, total_transactions, total_revenue, top_services, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())`
-	topServicesJSON, err := json.Marshal(topServices)
+// SaveRevenueReport stores a computed revenue report for an admin and sets its ID and generation time
+func SaveRevenueReport(ctx context.Context, db config.DBTX, adminID int, startDate, endDate time.Time, report *RevenueReportResponse) error {
+	topServicesJSON, err := json.Marshal(report.TopServices)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to serialize top services"})
+		return fmt.Errorf("failed to serialize top services: %w", err)
 	}
+	details := reportDetails{TaxBreakdown: report.TaxBreakdown, VoucherUsage: report.VoucherUsage}
 
-	_, err = config.Pool.Exec(context.Background(), reportQuery, adminID, "Revenue Report", startDate, endDate, totalTransactions, totalRevenue, string(topServicesJSON))
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to save report"})
-	}
-
-	// Log the admin action in the log table
-	logQuery := `
-		INSERT INTO log (description)
-		VALUES ($1)`
-	logDescription := fmt.Sprintf("Super-admin (ID: %d) generated a revenue report for %s to %s", adminID, req.StartDate, req.EndDate)
-	_, err = config.Pool.Exec(context.Background(), logQuery, logDescription)
+	reportQuery := `
+		INSERT INTO report (admin_id, report_type, start_date, end_date, total_transactions, total_revenue, top_services, total_tax, total_discount, details, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW()) RETURNING id, created_at`
+	err = db.QueryRow(ctx, reportQuery, adminID, RevenueReportType, startDate, endDate, report.TotalTransactions, report.TotalRevenue,
+		string(topServicesJSON), report.TotalTax, report.TotalDiscount, details).Scan(&report.ReportID, &report.GeneratedAt)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log admin action"})
-	}
-
-	// Construct the response
-	response := RevenueReportResponse{
-		TotalRevenue:      totalRevenue,
-		TotalTransactions: totalTransactions,
-		TopServices:       topServices,
+		return fmt.Errorf("failed to save report: %w", err)
 	}
-
-	return c.JSON(http.StatusOK, response)
-}
\ No newline at end of file
+	return nil
+}
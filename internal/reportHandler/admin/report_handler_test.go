@@ -2,10 +2,17 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"os"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+	notification_handler "w4/p2/milestones/internal/notificationHandler"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/labstack/echo/v4"
@@ -56,3 +63,417 @@ func TestGenerateRevenueReport(t *testing.T) {
 		assert.NotNil(t, response.TopServices, "Top services should not be nil")
 	}
 }
+
+func TestGenerateRevenueReportReusesSavedReport(t *testing.T) {
+	e := echo.New()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"admin_id": float64(1),
+		"role":     "super-admin",
+	})
+
+	generate := func(startDate, endDate string, regenerate bool) RevenueReportResponse {
+		requestBody, _ := json.Marshal(RevenueReportRequest{StartDate: startDate, EndDate: endDate, Regenerate: regenerate})
+		req := httptest.NewRequest(http.MethodPost, "/admin/report/revenue", bytes.NewReader(requestBody))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("user", token)
+
+		var response RevenueReportResponse
+		if assert.NoError(t, GenerateRevenueReport(c)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+			json.Unmarshal(rec.Body.Bytes(), &response)
+		}
+		return response
+	}
+
+	first := generate("2024-11-01", "2024-11-30", true)
+	assert.False(t, first.Reused)
+
+	second := generate("2024-11-01", "2024-11-30", false)
+	assert.True(t, second.Reused, "An existing report for the period should be returned")
+	assert.Equal(t, first.ReportID, second.ReportID)
+	assert.Equal(t, first.TopServices, second.TopServices)
+
+	// A report of today was saved before the day ended and is computed again
+	today := time.Now().Format("2006-01-02")
+	first = generate(today, today, true)
+	second = generate(today, today, false)
+	assert.False(t, second.Reused, "A report saved before its period closed should not be reused")
+	assert.NotEqual(t, first.ReportID, second.ReportID)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/reports?type=Revenue+Report&limit=5", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user", token)
+	if assert.NoError(t, GetReports(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+}
+
+func TestParseTopServices(t *testing.T) {
+	stored := `[{"service_name":"Printing","total_revenue":15000,"total_sold":3}]`
+	assert.Equal(t, []TopService{{ServiceName: "Printing", TotalRevenue: 15000, TotalSold: 3}}, ParseTopServices(stored))
+
+	// Reports saved before top services were stored as JSON only kept the names
+	assert.Equal(t, []TopService{{ServiceName: "Printing"}, {ServiceName: "Snacks"}}, ParseTopServices("Printing, Snacks"))
+	assert.Equal(t, []TopService{}, ParseTopServices(""))
+}
+
+func TestGetUtilizationReport(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/admin/reports/utilization?start_date=2024-12-18&end_date=2024-12-18&open_hour=9&close_hour=21", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user", jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"admin_id": float64(1),
+		"role":     "super-admin",
+	}))
+
+	if assert.NoError(t, GetUtilizationReport(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var report UtilizationReport
+		json.Unmarshal(rec.Body.Bytes(), &report)
+		assert.Equal(t, float64(12), report.OpenHoursPerComputer)
+		assert.Len(t, report.Heatmap, 12)
+		assert.NotEmpty(t, report.Computers)
+	}
+}
+
+func TestBuildUtilization(t *testing.T) {
+	computers := []ComputerUtilization{
+		{ComputerID: 1, Name: "PC 1", Type: "Gaming", Sessions: 2, AverageSessionHours: 3, Revenue: 60000},
+		{ComputerID: 2, Name: "PC 2", Type: "Gaming", Sessions: 1, AverageSessionHours: 0.5, Revenue: 5000},
+		{ComputerID: 3, Name: "PC 3", Type: "Office"},
+	}
+	usage := []hourUsage{
+		{ComputerID: 1, Hour: 10, Booked: 2},
+		{ComputerID: 1, Hour: 11, Booked: 2},
+		{ComputerID: 1, Hour: 12, Booked: 2},
+		{ComputerID: 2, Hour: 10, Booked: 0.5},
+	}
+
+	// Two days open from 10:00 to 14:00
+	report := BuildUtilization(computers, usage, 2, 10, 14, 0.2)
+
+	assert.Equal(t, float64(8), report.OpenHoursPerComputer)
+	assert.Equal(t, float64(6), report.Computers[0].BookedHours)
+	assert.Equal(t, 0.75, report.Computers[0].Utilization)
+	assert.Equal(t, "", report.Computers[0].Flag)
+	assert.Equal(t, FlagUnderUse, report.Computers[1].Flag)
+	assert.Equal(t, FlagIdle, report.Computers[2].Flag)
+
+	gaming := report.Types[0]
+	assert.Equal(t, "Gaming", gaming.Type)
+	assert.Equal(t, 2, gaming.Computers)
+	assert.Equal(t, 6.5, gaming.BookedHours)
+	assert.Equal(t, 0.4063, gaming.Utilization)
+	assert.Equal(t, 2.17, gaming.AverageSessionHours)
+	assert.Equal(t, float64(32500), gaming.RevenuePerComputer)
+	assert.Equal(t, HourOccupancy{Hour: 10, BookedHours: 2.5, Occupancy: 0.625}, gaming.Heatmap[0])
+
+	// 2.5 hours booked at 10:00 out of 3 computers over 2 days
+	assert.Len(t, report.Heatmap, 4)
+	assert.Equal(t, 0.4167, report.Heatmap[0].Occupancy)
+	assert.Equal(t, HourOccupancy{Hour: 13}, report.Heatmap[3])
+}
+
+func TestGetRevenueSeries(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/admin/reports/revenue-series?start_date=2024-12-01&end_date=2024-12-31&interval=week&group_by=transaction_type&compare=true", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user", jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"admin_id": float64(1),
+		"role":     "super-admin",
+	}))
+
+	if assert.NoError(t, GetRevenueSeries(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var series RevenueSeries
+		json.Unmarshal(rec.Body.Bytes(), &series)
+		assert.Equal(t, "2024-10-31", series.PreviousStartDate, "31 days are compared with the 31 days before")
+		for _, group := range series.Series {
+			assert.Len(t, group.Points, 6, "Every week touching December should be listed")
+		}
+	}
+}
+
+func TestBuildSeries(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 12, d, 0, 0, 0, 0, time.UTC) }
+
+	starts := BucketStarts(day(2), day(15), IntervalWeek)
+	assert.Equal(t, []time.Time{day(2), day(9)}, starts)
+	previousStart, previousEnd := PreviousPeriod(day(2), day(15), IntervalWeek)
+	assert.Equal(t, time.Date(2024, 11, 18, 0, 0, 0, 0, time.UTC), previousStart)
+	assert.Equal(t, time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC), previousEnd)
+
+	current := []seriesBucket{
+		{Group: "Payment", PeriodStart: day(2), Revenue: 50000, Count: 2},
+		{Group: "Payment", PeriodStart: day(9), Revenue: 30000, Count: 1},
+		{Group: "Top-up", PeriodStart: day(9), Revenue: 100000, Count: 1},
+	}
+	previous := []seriesBucket{
+		{Group: "Payment", PeriodStart: time.Date(2024, 11, 25, 0, 0, 0, 0, time.UTC), Revenue: 40000, Count: 2},
+		{Group: "Rental", PeriodStart: time.Date(2024, 11, 18, 0, 0, 0, 0, time.UTC), Revenue: 20000, Count: 1},
+	}
+	series, total := BuildSeries(starts, current, BucketStarts(previousStart, previousEnd, IntervalWeek), previous, true)
+
+	assert.Equal(t, []string{"Top-up", "Payment", "Rental"}, []string{series[0].Group, series[1].Group, series[2].Group})
+	payment := series[1]
+	assert.Equal(t, float64(80000), payment.Revenue)
+	assert.Equal(t, 3, payment.Count)
+	assert.Equal(t, float64(0), *payment.Points[0].PreviousRevenue)
+	assert.Equal(t, float64(40000), *payment.Points[1].PreviousRevenue)
+	assert.Equal(t, float64(100), *payment.ChangePercent)
+	assert.Nil(t, series[0].ChangePercent, "No change percent without previous revenue")
+	assert.Equal(t, float64(0), series[2].Points[0].Revenue, "Empty buckets are listed as zero")
+
+	assert.Equal(t, float64(180000), total.Revenue)
+	assert.Equal(t, float64(60000), *total.PreviousRevenue)
+	assert.Equal(t, float64(200), *total.ChangePercent)
+
+	// Whole months are compared with whole months
+	previousStart, previousEnd = PreviousPeriod(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC), IntervalMonth)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), previousStart)
+	assert.Equal(t, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), previousEnd)
+}
+
+func TestRunDueSchedules(t *testing.T) {
+	ctx := context.Background()
+	var scheduleID int
+	query := `
+		INSERT INTO report_schedule (report_type, frequency, recipients, next_run_at, created_by)
+		VALUES ($1, 'daily', '{owner@example.com}', NOW() - INTERVAL '1 minute', 1) RETURNING id`
+	if !assert.NoError(t, config.Pool.QueryRow(ctx, query, RevenueReportType).Scan(&scheduleID)) {
+		return
+	}
+	defer config.Pool.Exec(ctx, `DELETE FROM report_schedule WHERE id = $1`, scheduleID)
+
+	dir := t.TempDir()
+	ran, err := RunDueSchedules(ctx, time.Now(), notification_handler.FileNotifier{Dir: dir})
+	if assert.NoError(t, err) {
+		assert.GreaterOrEqual(t, ran, 1)
+	}
+
+	var nextRun time.Time
+	var lastReportID *int
+	var lastError *string
+	config.Pool.QueryRow(ctx, `SELECT next_run_at, last_report_id, last_error FROM report_schedule WHERE id = $1`, scheduleID).Scan(&nextRun, &lastReportID, &lastError)
+	assert.True(t, nextRun.After(time.Now()), "The schedule should move on to its next run")
+	assert.NotNil(t, lastReportID, "The report should be saved")
+	assert.Nil(t, lastError)
+
+	deliveries, _ := os.ReadDir(dir)
+	assert.NotEmpty(t, deliveries, "The report should be delivered")
+}
+
+func TestScheduleTimes(t *testing.T) {
+	jakarta, err := time.LoadLocation(DefaultTimezone)
+	if !assert.NoError(t, err) {
+		return
+	}
+	// Wednesday 18 December 2024, 08:30 in Jakarta
+	now := time.Date(2024, 12, 18, 8, 30, 0, 0, jakarta)
+
+	assert.Equal(t, time.Date(2024, 12, 19, 7, 0, 0, 0, jakarta), NextRun(FrequencyDaily, 7, jakarta, now))
+	assert.Equal(t, time.Date(2024, 12, 18, 9, 0, 0, 0, jakarta), NextRun(FrequencyDaily, 9, jakarta, now))
+	assert.Equal(t, time.Date(2024, 12, 23, 7, 0, 0, 0, jakarta), NextRun(FrequencyWeekly, 7, jakarta, now))
+	assert.Equal(t, time.Date(2025, 1, 1, 7, 0, 0, 0, jakarta), NextRun(FrequencyMonthly, 7, jakarta, now))
+
+	// 07:00 in Jakarta is still the evening before in UTC
+	runAt := time.Date(2024, 12, 23, 0, 0, 0, 0, time.UTC)
+	date := func(month time.Month, day int) time.Time { return time.Date(2024, month, day, 0, 0, 0, 0, jakarta) }
+	start, end := SchedulePeriod(FrequencyDaily, jakarta, runAt)
+	assert.Equal(t, []time.Time{date(12, 22), date(12, 22)}, []time.Time{start, end})
+	start, end = SchedulePeriod(FrequencyWeekly, jakarta, runAt)
+	assert.Equal(t, []time.Time{date(12, 16), date(12, 22)}, []time.Time{start, end})
+	start, end = SchedulePeriod(FrequencyMonthly, jakarta, runAt)
+	assert.Equal(t, []time.Time{date(11, 1), date(11, 30)}, []time.Time{start, end})
+
+	// A daily run at 01:00 in Jakarta covers the Jakarta day that has just ended, which
+	// began at 17:00 UTC two days before
+	runAt = time.Date(2024, 12, 23, 1, 0, 0, 0, jakarta)
+	start, end = SchedulePeriod(FrequencyDaily, jakarta, runAt)
+	assert.True(t, start.Equal(time.Date(2024, 12, 21, 17, 0, 0, 0, time.UTC)))
+	assert.True(t, end.Equal(start))
+	assert.False(t, end.AddDate(0, 0, 1).After(runAt), "the period has ended when the report runs")
+	assert.Equal(t, 24*time.Hour, end.AddDate(0, 0, 1).Sub(start))
+}
+
+func TestGetCustomerAnalytics(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/admin/analytics/customers?sort=visits&limit=5", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user", jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"admin_id": float64(1),
+		"role":     "super-admin",
+	}))
+
+	if assert.NoError(t, GetCustomerAnalytics(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response struct {
+			Data []CustomerInsight `json:"data"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.LessOrEqual(t, len(response.Data), 5)
+		for i := 1; i < len(response.Data); i++ {
+			assert.GreaterOrEqual(t, response.Data[i-1].Visits, response.Data[i].Visits)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/analytics/churn-risk?days=0", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.Set("user", jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"admin_id": float64(1),
+		"role":     "super-admin",
+	}))
+	if assert.NoError(t, GetChurnRisk(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestCustomerInsightMetrics(t *testing.T) {
+	now := time.Date(2025, 1, 31, 12, 0, 0, 0, time.UTC)
+	first := now.AddDate(0, 0, -90)
+	last := now.AddDate(0, 0, -10)
+
+	ci := CustomerInsight{Visits: 5, FirstVisit: &first, LastVisit: &last, LastActive: &last}
+	visitFrequency(&ci, now)
+	assert.Equal(t, 1.67, ci.VisitsPerMonth, "5 visits over 3 months")
+	assert.Equal(t, 20.0, *ci.AverageDaysBetween)
+	assert.Equal(t, 10, *ci.DaysInactive)
+
+	// A first visit this week still counts as a month
+	recent := now.AddDate(0, 0, -3)
+	ci = CustomerInsight{Visits: 1, FirstVisit: &recent, LastVisit: &recent}
+	visitFrequency(&ci, now)
+	assert.Equal(t, float64(1), ci.VisitsPerMonth)
+	assert.Nil(t, ci.AverageDaysBetween)
+	assert.Nil(t, ci.DaysInactive)
+}
+
+func TestBuildCohorts(t *testing.T) {
+	month := func(m int) time.Time { return time.Date(2024, time.Month(m), 1, 0, 0, 0, 0, time.UTC) }
+
+	cohorts := BuildCohorts(month(10), month(12), map[string]int{"2024-10": 4, "2024-12": 2}, []cohortActivity{
+		{Cohort: month(10), MonthOffset: 0, Active: 4},
+		{Cohort: month(10), MonthOffset: 2, Active: 1},
+		{Cohort: month(12), MonthOffset: 0, Active: 1},
+	})
+
+	assert.Len(t, cohorts, 3)
+	assert.Equal(t, "2024-10", cohorts[0].Month)
+	assert.Equal(t, []CohortRetention{
+		{MonthOffset: 0, Active: 4, Rate: 1},
+		{MonthOffset: 1, Active: 0, Rate: 0},
+		{MonthOffset: 2, Active: 1, Rate: 0.25},
+	}, cohorts[0].Retention)
+	assert.Equal(t, Cohort{Month: "2024-11", Retention: []CohortRetention{{MonthOffset: 0}, {MonthOffset: 1}}}, cohorts[1])
+	assert.Equal(t, []CohortRetention{{MonthOffset: 0, Active: 1, Rate: 0.5}}, cohorts[2].Retention)
+}
+
+func TestGetStaffPerformance(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/admin/reports/staff?start_date=2024-12-01&end_date=2024-12-31", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user", jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"admin_id": float64(1),
+		"role":     "super-admin",
+	}))
+
+	if assert.NoError(t, GetStaffPerformance(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var report StaffPerformanceReport
+		json.Unmarshal(rec.Body.Bytes(), &report)
+		assert.Equal(t, "2024-12-31", report.EndDate)
+		assert.NotEmpty(t, report.Staff)
+	}
+}
+
+func TestStaffPerformanceAttribution(t *testing.T) {
+	ctx := context.Background()
+	jakarta, err := time.LoadLocation(DefaultTimezone)
+	if !assert.NoError(t, err) {
+		return
+	}
+	day := time.Date(2031, 5, 14, 0, 0, 0, 0, jakarta)
+	adminID := 1
+
+	// A rental booked by the admin and paid from the wallet before payments recorded their admin
+	var rentalID, rentalPaymentID, servicePaymentID int
+	err = config.Pool.QueryRow(ctx, `
+		INSERT INTO rental_history (customer_id, computer_id, admin_id, rental_start_time, rental_end_time, total_cost, booking_status, unit_price, line_total)
+		VALUES (1, 1, $1, '2031-05-14 10:00', '2031-05-14 12:00', 40000, 'settlement', 20000, 40000) RETURNING id`, adminID).Scan(&rentalID)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer config.Pool.Exec(ctx, `DELETE FROM rental_history WHERE id = $1`, rentalID)
+	err = config.Pool.QueryRow(ctx, `
+		INSERT INTO transaction (customer_id, transaction_type, amount, transaction_method, status, transaction_date)
+		VALUES (1, 'Rental Payment', 44000, 'Wallet', 'settlement', '2031-05-14 12:00') RETURNING id`).Scan(&rentalPaymentID)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer config.Pool.Exec(ctx, `DELETE FROM transaction WHERE id = $1`, rentalPaymentID)
+	_, err = config.Pool.Exec(ctx, `
+		INSERT INTO receipt (invoice_number, customer_id, transaction_id, rental_history_id, payment_method, total)
+		VALUES ($1, 1, $2, $3, 'Wallet', 44000)`, fmt.Sprintf("TEST-%d", rentalPaymentID), rentalPaymentID, rentalID)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer config.Pool.Exec(ctx, `DELETE FROM receipt WHERE transaction_id = $1`, rentalPaymentID)
+
+	// A wallet service purchase taken by the admin outside any shift
+	err = config.Pool.QueryRow(ctx, `
+		INSERT INTO transaction (customer_id, transaction_type, amount, transaction_method, status, transaction_date, admin_id)
+		VALUES (1, 'Service Payment', 11000, 'Wallet', 'settlement', '2031-05-14 15:00', $1) RETURNING id`, adminID).Scan(&servicePaymentID)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer config.Pool.Exec(ctx, `DELETE FROM transaction WHERE id = $1`, servicePaymentID)
+
+	report, err := BuildStaffPerformance(ctx, day, day, &adminID)
+	if assert.NoError(t, err) && assert.Len(t, report.Staff, 1) {
+		s := report.Staff[0]
+		assert.Equal(t, 1, s.RentalsProcessed)
+		assert.Equal(t, float64(40000), s.RentalRevenue)
+		assert.Equal(t, 2, s.TransactionsHandled)
+		assert.Equal(t, float64(55000), s.RevenueHandled)
+	}
+}
+
+func TestStaffScope(t *testing.T) {
+	other := 7
+	self := 3
+
+	scope, err := staffScope("super-admin", self, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, scope, "Super-admins see everyone")
+
+	scope, err = staffScope("super-admin", self, &other)
+	assert.NoError(t, err)
+	assert.Equal(t, other, *scope)
+
+	scope, err = staffScope("admin", self, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, self, *scope, "Admins only see themselves")
+
+	_, err = staffScope("admin", self, &other)
+	assert.ErrorIs(t, err, errOtherAdmin)
+
+	_, err = staffScope("cashier", self, nil)
+	assert.Error(t, err)
+
+	s := StaffPerformance{RevenueHandled: 150000, RentalsProcessed: 5, ShiftHours: 7.5}
+	s.perShiftHour()
+	assert.Equal(t, float64(20000), s.RevenuePerShiftHour)
+	assert.Equal(t, 0.67, s.RentalsPerShiftHour)
+}
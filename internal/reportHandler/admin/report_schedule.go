@@ -0,0 +1,570 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+	audit_handler "w4/p2/milestones/internal/auditHandler"
+	export_handler "w4/p2/milestones/internal/exportHandler"
+	notification_handler "w4/p2/milestones/internal/notificationHandler"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// Schedule frequencies. Daily schedules report the day before, weekly ones the week
+// before from Monday to Sunday, and monthly ones the month before.
+const (
+	FrequencyDaily   = "daily"
+	FrequencyWeekly  = "weekly"
+	FrequencyMonthly = "monthly"
+)
+
+// DefaultTimezone is the timezone schedules run in unless given one
+const DefaultTimezone = "Asia/Jakarta"
+
+// ReportSchedule generates a report automatically and delivers it to its recipients
+type ReportSchedule struct {
+	ID           int        `json:"id"`
+	ReportType   string     `json:"report_type"`
+	Frequency    string     `json:"frequency"`
+	Timezone     string     `json:"timezone"`
+	RunHour      int        `json:"run_hour"` // local hour of the day it runs at
+	Format       string     `json:"format"`   // attachment format, csv or xlsx
+	Recipients   []string   `json:"recipients"`
+	IsActive     bool       `json:"is_active"`
+	NextRunAt    time.Time  `json:"next_run_at"`
+	LastRunAt    *time.Time `json:"last_run_at"`
+	LastReportID *int       `json:"last_report_id"`
+	LastError    *string    `json:"last_error"`
+	CreatedBy    int        `json:"created_by"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// ScheduleRequest creates or changes a report schedule
+type ScheduleRequest struct {
+	ReportType string   `json:"report_type"` // Revenue Report by default
+	Frequency  string   `json:"frequency" validate:"required"`
+	Timezone   string   `json:"timezone"`
+	RunHour    *int     `json:"run_hour"` // 7 by default
+	Format     string   `json:"format"`   // xlsx by default
+	Recipients []string `json:"recipients"`
+	IsActive   *bool    `json:"is_active"`
+}
+
+// scheduledReport generates a report for a period on behalf of an admin and returns it
+// saved, ready to attach
+type scheduledReport func(ctx context.Context, adminID int, startDate, endDate time.Time, format string) (reportID int, summary string, attachment []byte, err error)
+
+// scheduledReports are the report types that can be scheduled
+var scheduledReports = map[string]scheduledReport{
+	RevenueReportType: runRevenueReport,
+}
+
+// normalize fills in defaults and checks the request, returning the schedule's location
+func (req *ScheduleRequest) normalize() (*time.Location, error) {
+	req.ReportType = strings.TrimSpace(req.ReportType)
+	if req.ReportType == "" {
+		req.ReportType = RevenueReportType
+	}
+	if _, ok := scheduledReports[req.ReportType]; !ok {
+		return nil, fmt.Errorf("Reports of type %q cannot be scheduled", req.ReportType)
+	}
+
+	req.Frequency = strings.ToLower(strings.TrimSpace(req.Frequency))
+	if req.Frequency != FrequencyDaily && req.Frequency != FrequencyWeekly && req.Frequency != FrequencyMonthly {
+		return nil, errors.New("frequency must be daily, weekly or monthly")
+	}
+
+	req.Timezone = strings.TrimSpace(req.Timezone)
+	if req.Timezone == "" {
+		req.Timezone = DefaultTimezone
+	}
+	loc, err := time.LoadLocation(req.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("Unknown timezone %q", req.Timezone)
+	}
+
+	if req.RunHour == nil {
+		runHour := 7
+		req.RunHour = &runHour
+	}
+	if *req.RunHour < 0 || *req.RunHour > 23 {
+		return nil, errors.New("run_hour must be between 0 and 23")
+	}
+
+	req.Format = strings.ToLower(strings.TrimSpace(req.Format))
+	if req.Format == "" {
+		req.Format = export_handler.FormatXLSX
+	}
+	if req.Format != export_handler.FormatCSV && req.Format != export_handler.FormatXLSX {
+		return nil, errors.New("format must be csv or xlsx")
+	}
+
+	if len(req.Recipients) == 0 {
+		return nil, errors.New("At least one recipient is required")
+	}
+	for i, recipient := range req.Recipients {
+		address, err := mail.ParseAddress(strings.TrimSpace(recipient))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid recipient %q", recipient)
+		}
+		req.Recipients[i] = address.Address
+	}
+	return loc, nil
+}
+
+// NextRun is the first time after after that a schedule runs, at runHour local time:
+// every day, on Mondays, or on the first of the month
+func NextRun(frequency string, runHour int, loc *time.Location, after time.Time) time.Time {
+	local := after.In(loc)
+	run := time.Date(local.Year(), local.Month(), local.Day(), runHour, 0, 0, 0, loc)
+	switch frequency {
+	case FrequencyWeekly:
+		run = run.AddDate(0, 0, -((int(run.Weekday()) + 6) % 7))
+		for !run.After(after) {
+			run = run.AddDate(0, 0, 7)
+		}
+	case FrequencyMonthly:
+		run = time.Date(local.Year(), local.Month(), 1, runHour, 0, 0, 0, loc)
+		for !run.After(after) {
+			run = run.AddDate(0, 1, 0)
+		}
+	default:
+		for !run.After(after) {
+			run = run.AddDate(0, 0, 1)
+		}
+	}
+	return run
+}
+
+// SchedulePeriod is the period covered by a run at runAt: the local day, Monday to Sunday
+// week or month before it, as the local midnights starting its first and last days
+func SchedulePeriod(frequency string, loc *time.Location, runAt time.Time) (time.Time, time.Time) {
+	local := runAt.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	switch frequency {
+	case FrequencyWeekly:
+		monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		return monday.AddDate(0, 0, -7), monday.AddDate(0, 0, -1)
+	case FrequencyMonthly:
+		first := today.AddDate(0, 0, 1-today.Day())
+		return first.AddDate(0, -1, 0), first.AddDate(0, 0, -1)
+	}
+	return today.AddDate(0, 0, -1), today.AddDate(0, 0, -1)
+}
+
+// runRevenueReport reuses the revenue report saved for the period, or generates and saves one
+func runRevenueReport(ctx context.Context, adminID int, startDate, endDate time.Time, format string) (int, string, []byte, error) {
+	var report RevenueReportResponse
+	saved, err := findReport(ctx, RevenueReportType, startDate, endDate)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	if saved != nil {
+		report = saved.revenueResponse()
+	} else {
+		if report, err = BuildRevenueReport(ctx, startDate, endDate); err != nil {
+			return 0, "", nil, err
+		}
+		tx, err := config.Pool.Begin(ctx)
+		if err != nil {
+			return 0, "", nil, fmt.Errorf("failed to start transaction: %w", err)
+		}
+		defer tx.Rollback(ctx)
+		if err := SaveRevenueReport(ctx, tx, adminID, startDate, endDate, &report); err != nil {
+			return 0, "", nil, err
+		}
+		event := audit_handler.New(audit_handler.ActorSystem, nil, "report.generate", "report", report.ReportID).
+			Change(nil, map[string]interface{}{"report_type": RevenueReportType, "start_date": startDate.Format("2006-01-02"),
+				"end_date": endDate.Format("2006-01-02"), "scheduled_by": adminID}).
+			Describe("Scheduled revenue report for %s to %s", startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
+		if err := audit_handler.Record(ctx, tx, event); err != nil {
+			return 0, "", nil, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return 0, "", nil, fmt.Errorf("failed to save report: %w", err)
+		}
+	}
+
+	var attachment bytes.Buffer
+	w, err := export_handler.NewFileWriter(&attachment, format, revenueReportColumns, export_handler.LangEnglish)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	if err := writeRevenueReport(w, report); err != nil {
+		return 0, "", nil, fmt.Errorf("failed to write report attachment: %w", err)
+	}
+
+	summary := fmt.Sprintf("Total revenue: %s from %d transactions\nTax: %s\nNet revenue: %s\nVoucher discounts: %s",
+		export_handler.FormatCurrency(report.TotalRevenue, export_handler.LangEnglish), report.TotalTransactions,
+		export_handler.FormatCurrency(report.TotalTax, export_handler.LangEnglish),
+		export_handler.FormatCurrency(report.NetRevenue, export_handler.LangEnglish),
+		export_handler.FormatCurrency(report.TotalDiscount, export_handler.LangEnglish))
+	return report.ReportID, summary, attachment.Bytes(), nil
+}
+
+const scheduleColumns = `id, report_type, frequency, timezone, run_hour, format, recipients, is_active,
+	next_run_at, last_run_at, last_report_id, last_error, created_by, created_at`
+
+func scanSchedule(row pgx.Row, s *ReportSchedule) error {
+	return row.Scan(&s.ID, &s.ReportType, &s.Frequency, &s.Timezone, &s.RunHour, &s.Format, &s.Recipients, &s.IsActive,
+		&s.NextRunAt, &s.LastRunAt, &s.LastReportID, &s.LastError, &s.CreatedBy, &s.CreatedAt)
+}
+
+// claimDueSchedule takes the next schedule due to run and moves its next run on, so no
+// other worker runs it too. It returns nil when none is due.
+func claimDueSchedule(ctx context.Context, now time.Time) (*ReportSchedule, error) {
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		SELECT ` + scheduleColumns + `
+		FROM report_schedule
+		WHERE is_active AND next_run_at <= $1
+		ORDER BY next_run_at
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`
+	var s ReportSchedule
+	err = scanSchedule(tx.QueryRow(ctx, query, now), &s)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to fetch due schedule: %w", err)
+	}
+
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("schedule %d has an unknown timezone: %w", s.ID, err)
+	}
+	// Runs missed while the server was down are not repeated
+	next := NextRun(s.Frequency, s.RunHour, loc, now)
+	if _, err := tx.Exec(ctx, `UPDATE report_schedule SET next_run_at = $1 WHERE id = $2`, next, s.ID); err != nil {
+		return nil, fmt.Errorf("failed to move schedule %d on: %w", s.ID, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to claim schedule %d: %w", s.ID, err)
+	}
+	return &s, nil
+}
+
+// runSchedule generates the report of a schedule for the period before its due run and delivers it
+func runSchedule(ctx context.Context, s ReportSchedule, notifier notification_handler.Notifier) (int, error) {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return 0, err
+	}
+	startDate, endDate := SchedulePeriod(s.Frequency, loc, s.NextRunAt)
+	reportID, summary, attachment, err := scheduledReports[s.ReportType](ctx, s.CreatedBy, startDate, endDate, s.Format)
+	if err != nil {
+		return 0, err
+	}
+
+	period := startDate.Format("2006-01-02")
+	if !endDate.Equal(startDate) {
+		period += " to " + endDate.Format("2006-01-02")
+	}
+	message := notification_handler.Message{
+		Recipients: s.Recipients,
+		Subject:    fmt.Sprintf("%s %s", s.ReportType, period),
+		Body:       fmt.Sprintf("The %s %s for %s is attached.\n\n%s\n", s.Frequency, strings.ToLower(s.ReportType), period, summary),
+		Attachments: []notification_handler.Attachment{{
+			Filename:    fmt.Sprintf("%s-%s.%s", strings.ToLower(strings.ReplaceAll(s.ReportType, " ", "-")), strings.ReplaceAll(period, " to ", "-"), s.Format),
+			ContentType: export_handler.ContentType(s.Format),
+			Content:     attachment,
+		}},
+	}
+	if err := notifier.Send(ctx, message); err != nil {
+		return reportID, err
+	}
+	return reportID, nil
+}
+
+// RunDueSchedules runs every schedule due at now and records how each run went
+func RunDueSchedules(ctx context.Context, now time.Time, notifier notification_handler.Notifier) (int, error) {
+	ran := 0
+	for {
+		s, err := claimDueSchedule(ctx, now)
+		if err != nil {
+			return ran, err
+		}
+		if s == nil {
+			return ran, nil
+		}
+
+		reportID, runErr := runSchedule(ctx, *s, notifier)
+		var lastError *string
+		if runErr != nil {
+			message := runErr.Error()
+			if len(message) > 500 {
+				message = message[:500]
+			}
+			lastError = &message
+			fmt.Printf("Report schedule %d error: %v\n", s.ID, runErr)
+		}
+		var lastReportID *int
+		if reportID != 0 {
+			lastReportID = &reportID
+		}
+		updateQuery := `
+			UPDATE report_schedule
+			SET last_run_at = NOW(), last_report_id = COALESCE($1, last_report_id), last_error = $2
+			WHERE id = $3`
+		if _, err := config.Pool.Exec(ctx, updateQuery, lastReportID, lastError, s.ID); err != nil {
+			return ran, fmt.Errorf("failed to record run of schedule %d: %w", s.ID, err)
+		}
+		ran++
+	}
+}
+
+// StartReportScheduler periodically runs the report schedules that are due and delivers
+// their reports through the notifier configured in the environment
+func StartReportScheduler(interval time.Duration) {
+	notifier := notification_handler.NotifierFromEnv()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			ran, err := RunDueSchedules(context.Background(), time.Now(), notifier)
+			if err != nil {
+				fmt.Printf("Report scheduler error: %v\n", err)
+				continue
+			}
+			if ran > 0 {
+				fmt.Printf("Ran %d scheduled reports\n", ran)
+			}
+		}
+	}()
+}
+
+// GetReportSchedules godoc
+// @Summary List report schedules
+// @Description Retrieve the report schedules with when they run next and how their last run went
+// @Tags Reports
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Report schedules retrieved successfully"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/report/schedules [get]
+func GetReportSchedules(c echo.Context) error {
+	if _, ok := requireSuperAdmin(c); !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can schedule reports."})
+	}
+
+	rows, err := config.Pool.Query(context.Background(), `SELECT `+scheduleColumns+` FROM report_schedule ORDER BY is_active DESC, next_run_at, id`)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch report schedules"})
+	}
+	defer rows.Close()
+
+	schedules := []ReportSchedule{}
+	for rows.Next() {
+		var s ReportSchedule
+		if err := scanSchedule(rows, &s); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to parse report schedules"})
+		}
+		schedules = append(schedules, s)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Report schedules retrieved successfully",
+		"data":    schedules,
+	})
+}
+
+// CreateReportSchedule godoc
+// @Summary Schedule a report
+// @Description Generate a report automatically every day, week or month at an hour of the schedule's timezone (Asia/Jakarta by default), save it and send it to the recipients as a CSV or XLSX attachment
+// @Tags Reports
+// @Accept json
+// @Produce json
+// @Param request body ScheduleRequest true "Schedule"
+// @Success 201 {object} ReportSchedule
+// @Failure 400 {object} map[string]string "Invalid schedule"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/report/schedules [post]
+func CreateReportSchedule(c echo.Context) error {
+	adminID, ok := requireSuperAdmin(c)
+	if !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can schedule reports."})
+	}
+
+	var req ScheduleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+	loc, err := req.normalize()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+	isActive := req.IsActive == nil || *req.IsActive
+
+	query := `
+		INSERT INTO report_schedule (report_type, frequency, timezone, run_hour, format, recipients, is_active, next_run_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING ` + scheduleColumns
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	var s ReportSchedule
+	err = scanSchedule(tx.QueryRow(ctx, query, req.ReportType, req.Frequency, req.Timezone, *req.RunHour, req.Format,
+		req.Recipients, isActive, NextRun(req.Frequency, *req.RunHour, loc, time.Now()), adminID), &s)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create report schedule"})
+	}
+
+	event := audit_handler.FromRequest(c, "report_schedule.create", "report_schedule", s.ID).Change(nil, s).
+		Describe("Super-admin (ID: %d) scheduled a %s %s (schedule %d)", adminID, req.Frequency, req.ReportType, s.ID)
+	if err := audit_handler.Record(ctx, tx, event); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log admin action"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create report schedule"})
+	}
+
+	return c.JSON(http.StatusCreated, s)
+}
+
+// UpdateReportSchedule godoc
+// @Summary Change a report schedule
+// @Description Change when and how a scheduled report runs, or pause it with is_active false. The next run is worked out again.
+// @Tags Reports
+// @Accept json
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Param request body ScheduleRequest true "Schedule"
+// @Success 200 {object} ReportSchedule
+// @Failure 400 {object} map[string]string "Invalid schedule"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Schedule not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/report/schedules/{id} [put]
+func UpdateReportSchedule(c echo.Context) error {
+	adminID, ok := requireSuperAdmin(c)
+	if !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can schedule reports."})
+	}
+
+	scheduleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid schedule ID"})
+	}
+	var req ScheduleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+	loc, err := req.normalize()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+	isActive := req.IsActive == nil || *req.IsActive
+
+	query := `
+		UPDATE report_schedule
+		SET report_type = $1, frequency = $2, timezone = $3, run_hour = $4, format = $5, recipients = $6, is_active = $7, next_run_at = $8
+		WHERE id = $9
+		RETURNING ` + scheduleColumns
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	var before ReportSchedule
+	err = scanSchedule(tx.QueryRow(ctx, `SELECT `+scheduleColumns+` FROM report_schedule WHERE id = $1 FOR UPDATE`, scheduleID), &before)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Schedule not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch report schedule"})
+	}
+
+	var s ReportSchedule
+	err = scanSchedule(tx.QueryRow(ctx, query, req.ReportType, req.Frequency, req.Timezone, *req.RunHour, req.Format,
+		req.Recipients, isActive, NextRun(req.Frequency, *req.RunHour, loc, time.Now()), scheduleID), &s)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update report schedule"})
+	}
+
+	event := audit_handler.FromRequest(c, "report_schedule.update", "report_schedule", scheduleID).Change(before, s).
+		Describe("Super-admin (ID: %d) updated report schedule %d", adminID, scheduleID)
+	if err := audit_handler.Record(ctx, tx, event); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log admin action"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update report schedule"})
+	}
+
+	return c.JSON(http.StatusOK, s)
+}
+
+// DeleteReportSchedule godoc
+// @Summary Delete a report schedule
+// @Description Stop a scheduled report for good. Reports it already generated are kept.
+// @Tags Reports
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Success 200 {object} map[string]string "Report schedule deleted successfully"
+// @Failure 400 {object} map[string]string "Invalid schedule ID"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Schedule not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/report/schedules/{id} [delete]
+func DeleteReportSchedule(c echo.Context) error {
+	adminID, ok := requireSuperAdmin(c)
+	if !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can schedule reports."})
+	}
+
+	scheduleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid schedule ID"})
+	}
+
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	var before ReportSchedule
+	err = scanSchedule(tx.QueryRow(ctx, `DELETE FROM report_schedule WHERE id = $1 RETURNING `+scheduleColumns, scheduleID), &before)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Schedule not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to delete report schedule"})
+	}
+
+	event := audit_handler.FromRequest(c, "report_schedule.delete", "report_schedule", scheduleID).Change(before, nil).
+		Describe("Super-admin (ID: %d) deleted report schedule %d", adminID, scheduleID)
+	if err := audit_handler.Record(ctx, tx, event); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log admin action"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to delete report schedule"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Report schedule deleted successfully"})
+}
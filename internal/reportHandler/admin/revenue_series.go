@@ -0,0 +1,388 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Series intervals
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week" // starting on Monday
+	IntervalMonth = "month"
+)
+
+// maxSeriesBuckets caps how many buckets one series request can ask for
+const maxSeriesBuckets = 400
+
+// settleGrace is how long after a period ends its revenue can still change, as pending
+// payments created in it settle. Periods are only cached once it has passed.
+const settleGrace = 24 * time.Hour
+
+// seriesSources are the revenue each grouping dimension is measured from, as rows of
+// (at, grp, amount, count). Transaction dimensions use settled transaction amounts like
+// the revenue report; computer type and service use the time and service line totals,
+// after discounts and before tax.
+var seriesSources = map[string]string{
+	"": `
+		SELECT t.transaction_date AS at, 'Total' AS grp, t.amount AS amount, 1 AS count
+		FROM transaction t
+		WHERE t.status ILIKE 'Settlement' AND t.transaction_type NOT IN ('Transfer Out', 'Transfer In')`,
+	"transaction_type": `
+		SELECT t.transaction_date AS at, t.transaction_type AS grp, t.amount AS amount, 1 AS count
+		FROM transaction t
+		WHERE t.status ILIKE 'Settlement' AND t.transaction_type NOT IN ('Transfer Out', 'Transfer In')`,
+	"transaction_method": `
+		SELECT t.transaction_date AS at, COALESCE(t.transaction_method, 'Unknown') AS grp, t.amount AS amount, 1 AS count
+		FROM transaction t
+		WHERE t.status ILIKE 'Settlement' AND t.transaction_type NOT IN ('Transfer Out', 'Transfer In')`,
+	"admin": `
+		SELECT t.transaction_date AS at, COALESCE(a.username, 'No shift') AS grp, t.amount AS amount, 1 AS count
+		FROM transaction t
+		LEFT JOIN shift sh ON sh.id = t.shift_id
+		LEFT JOIN admin a ON a.id = sh.admin_id
+		WHERE t.status ILIKE 'Settlement' AND t.transaction_type NOT IN ('Transfer Out', 'Transfer In')`,
+	"computer_type": `
+		SELECT rh.rental_start_time AS at, c.type AS grp, rh.line_total AS amount, 1 AS count
+		FROM rental_history rh
+		JOIN computer c ON c.id = rh.computer_id`,
+	"service": `
+		SELECT rs.created_at AS at, s.name AS grp, rs.line_total AS amount, rs.quantity AS count
+		FROM rental_services rs
+		JOIN service s ON s.id = rs.service_id`,
+}
+
+// RevenueSeries is revenue over a date range in buckets of a day, week or month
+type RevenueSeries struct {
+	StartDate         string        `json:"start_date"`
+	EndDate           string        `json:"end_date"`
+	Interval          string        `json:"interval"`
+	GroupBy           string        `json:"group_by"`
+	PreviousStartDate string        `json:"previous_start_date,omitempty"`
+	PreviousEndDate   string        `json:"previous_end_date,omitempty"`
+	Series            []SeriesGroup `json:"series"`
+	Total             SeriesTotal   `json:"total"`
+	Cached            bool          `json:"cached"`
+}
+
+// SeriesGroup is the revenue of one group, such as a transaction type, per bucket
+type SeriesGroup struct {
+	Group string `json:"group"`
+	SeriesTotal
+	Points []SeriesPoint `json:"points"`
+}
+
+// SeriesTotal is revenue over the whole range, compared with the previous period when asked
+type SeriesTotal struct {
+	Revenue         float64  `json:"revenue"`
+	Count           int      `json:"count"` // transactions, rentals or units sold
+	PreviousRevenue *float64 `json:"previous_revenue,omitempty"`
+	Change          *float64 `json:"change,omitempty"`
+	ChangePercent   *float64 `json:"change_percent,omitempty"` // nil when there was no previous revenue
+}
+
+// SeriesPoint is the revenue of one bucket
+type SeriesPoint struct {
+	PeriodStart     time.Time `json:"period_start"`
+	Revenue         float64   `json:"revenue"`
+	Count           int       `json:"count"`
+	PreviousRevenue *float64  `json:"previous_revenue,omitempty"` // same bucket of the previous period
+}
+
+// seriesBucket is the revenue of a group in one bucket, as read from the database
+type seriesBucket struct {
+	Group       string
+	PeriodStart time.Time
+	Revenue     float64
+	Count       int
+}
+
+// seriesCache keeps series of periods that can no longer change
+var seriesCache = struct {
+	sync.Mutex
+	entries map[string]RevenueSeries
+}{entries: map[string]RevenueSeries{}}
+
+// maxCachedSeries bounds the cache; it is emptied when full
+const maxCachedSeries = 500
+
+// GetRevenueSeries godoc
+// @Summary Revenue time series
+// @Description Revenue over a date range bucketed by day, week or month, optionally grouped by transaction_type, transaction_method, computer_type, service or admin (the admin whose shift took the payment), and compared with the previous period of the same length. Days and buckets start at midnight in Asia/Jakarta. Periods that closed over a day ago are cached.
+// @Tags Reports
+// @Produce json
+// @Param start_date query string true "First day (YYYY-MM-DD)"
+// @Param end_date query string true "Last day, inclusive (YYYY-MM-DD)"
+// @Param interval query string false "day, week or month (default day)"
+// @Param group_by query string false "transaction_type, transaction_method, computer_type, service or admin"
+// @Param compare query bool false "Compare with the previous period"
+// @Success 200 {object} RevenueSeries
+// @Failure 400 {object} map[string]string "Invalid parameters"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/reports/revenue-series [get]
+func GetRevenueSeries(c echo.Context) error {
+	if _, ok := requireSuperAdmin(c); !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can view reports."})
+	}
+
+	// Series days are days in Jakarta, as for the revenue report
+	loc, err := time.LoadLocation(DefaultTimezone)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to load report timezone"})
+	}
+	startDate, err := time.ParseInLocation("2006-01-02", c.QueryParam("start_date"), loc)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid start_date, use YYYY-MM-DD"})
+	}
+	endDate, err := time.ParseInLocation("2006-01-02", c.QueryParam("end_date"), loc)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid end_date, use YYYY-MM-DD"})
+	}
+	if endDate.Before(startDate) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "end_date cannot be before start_date"})
+	}
+
+	interval := c.QueryParam("interval")
+	if interval == "" {
+		interval = IntervalDay
+	}
+	if interval != IntervalDay && interval != IntervalWeek && interval != IntervalMonth {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "interval must be day, week or month"})
+	}
+	groupBy := c.QueryParam("group_by")
+	if _, ok := seriesSources[groupBy]; !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "group_by must be transaction_type, transaction_method, computer_type, service or admin"})
+	}
+	compare := c.QueryParam("compare") == "true"
+
+	buckets := BucketStarts(startDate, endDate, interval)
+	if len(buckets) > maxSeriesBuckets {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": fmt.Sprintf("The range spans more than %d %ss, use a longer interval", maxSeriesBuckets, interval)})
+	}
+
+	key := fmt.Sprintf("%s|%s|%s|%s|%t", startDate.Format("2006-01-02"), endDate.Format("2006-01-02"), interval, groupBy, compare)
+	seriesCache.Lock()
+	cached, ok := seriesCache.entries[key]
+	seriesCache.Unlock()
+	if ok {
+		cached.Cached = true
+		return c.JSON(http.StatusOK, cached)
+	}
+
+	ctx := context.Background()
+	current, err := fetchSeriesBuckets(ctx, groupBy, interval, startDate, endDate)
+	if err != nil {
+		fmt.Println("Revenue series error:", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch revenue series"})
+	}
+
+	series := RevenueSeries{
+		StartDate: startDate.Format("2006-01-02"),
+		EndDate:   endDate.Format("2006-01-02"),
+		Interval:  interval,
+		GroupBy:   groupBy,
+	}
+	var previous []seriesBucket
+	var previousBuckets []time.Time
+	if compare {
+		previousStart, previousEnd := PreviousPeriod(startDate, endDate, interval)
+		previous, err = fetchSeriesBuckets(ctx, groupBy, interval, previousStart, previousEnd)
+		if err != nil {
+			fmt.Println("Revenue series error:", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch previous revenue series"})
+		}
+		previousBuckets = BucketStarts(previousStart, previousEnd, interval)
+		series.PreviousStartDate = previousStart.Format("2006-01-02")
+		series.PreviousEndDate = previousEnd.Format("2006-01-02")
+	}
+	series.Series, series.Total = BuildSeries(buckets, current, previousBuckets, previous, compare)
+
+	// Only periods that can no longer change are kept
+	if time.Now().After(endDate.AddDate(0, 0, 1).Add(settleGrace)) {
+		seriesCache.Lock()
+		if len(seriesCache.entries) >= maxCachedSeries {
+			seriesCache.entries = map[string]RevenueSeries{}
+		}
+		seriesCache.entries[key] = series
+		seriesCache.Unlock()
+	}
+
+	return c.JSON(http.StatusOK, series)
+}
+
+// fetchSeriesBuckets sums the revenue of each group per bucket over the days from startDate
+// to endDate. Days and buckets start at midnight in the location of the dates.
+func fetchSeriesBuckets(ctx context.Context, groupBy, interval string, startDate, endDate time.Time) ([]seriesBucket, error) {
+	query := `
+		SELECT src.grp, date_trunc('` + interval + `', src.at::TIMESTAMPTZ AT TIME ZONE $3) AT TIME ZONE $3 AS period_start,
+		       COALESCE(SUM(src.amount), 0)::DOUBLE PRECISION, COALESCE(SUM(src.count), 0)::INT
+		FROM (` + seriesSources[groupBy] + `) src
+		WHERE src.at >= $1::TIMESTAMPTZ AND src.at < $2::TIMESTAMPTZ
+		GROUP BY 1, 2`
+	rows, err := config.Pool.Query(ctx, query, startDate, endDate.AddDate(0, 0, 1), startDate.Location().String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := []seriesBucket{}
+	for rows.Next() {
+		var b seriesBucket
+		if err := rows.Scan(&b.Group, &b.PeriodStart, &b.Revenue, &b.Count); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
+}
+
+// BucketStarts lists the start of every bucket touching the days from startDate to endDate
+func BucketStarts(startDate, endDate time.Time, interval string) []time.Time {
+	var starts []time.Time
+	for at := truncate(startDate, interval); !at.After(endDate); at = next(at, interval) {
+		starts = append(starts, at)
+	}
+	return starts
+}
+
+// PreviousPeriod is the period of the same length just before the given one. A range of
+// whole months is compared with the same number of whole months before it.
+func PreviousPeriod(startDate, endDate time.Time, interval string) (time.Time, time.Time) {
+	if interval == IntervalMonth && startDate.Day() == 1 && endDate.AddDate(0, 0, 1).Day() == 1 {
+		months := (endDate.Year()-startDate.Year())*12 + int(endDate.Month()-startDate.Month()) + 1
+		return startDate.AddDate(0, -months, 0), startDate.AddDate(0, 0, -1)
+	}
+	days := int(endDate.Sub(startDate).Hours()/24) + 1
+	return startDate.AddDate(0, 0, -days), startDate.AddDate(0, 0, -1)
+}
+
+// BuildSeries lays the buckets read for each group over every bucket of the range, so
+// empty buckets show as zero, and lines the previous period up bucket by bucket
+func BuildSeries(starts []time.Time, current []seriesBucket, previousStarts []time.Time, previous []seriesBucket, compare bool) ([]SeriesGroup, SeriesTotal) {
+	// Buckets are matched by instant, whichever location they were read in
+	index := map[int64]int{}
+	for i, at := range starts {
+		index[at.Unix()] = i
+	}
+	previousIndex := map[int64]int{}
+	for i, at := range previousStarts {
+		previousIndex[at.Unix()] = i
+	}
+
+	groups := map[string]*SeriesGroup{}
+	group := func(name string) *SeriesGroup {
+		g, ok := groups[name]
+		if !ok {
+			g = &SeriesGroup{Group: name, Points: make([]SeriesPoint, len(starts))}
+			for i, at := range starts {
+				g.Points[i].PeriodStart = at
+				if compare {
+					zero := 0.0
+					g.Points[i].PreviousRevenue = &zero
+				}
+			}
+			if compare {
+				zero := 0.0
+				g.PreviousRevenue = &zero
+			}
+			groups[name] = g
+		}
+		return g
+	}
+
+	for _, b := range current {
+		i, ok := index[b.PeriodStart.Unix()]
+		if !ok {
+			continue
+		}
+		g := group(b.Group)
+		g.Points[i].Revenue += b.Revenue
+		g.Points[i].Count += b.Count
+		g.Revenue += b.Revenue
+		g.Count += b.Count
+	}
+	if compare {
+		for _, b := range previous {
+			i, ok := previousIndex[b.PeriodStart.Unix()]
+			if !ok {
+				continue
+			}
+			g := group(b.Group)
+			*g.PreviousRevenue += b.Revenue
+			if i < len(g.Points) {
+				*g.Points[i].PreviousRevenue += b.Revenue
+			}
+		}
+	}
+
+	var total SeriesTotal
+	if compare {
+		zero := 0.0
+		total.PreviousRevenue = &zero
+	}
+	series := []SeriesGroup{}
+	for _, g := range groups {
+		total.Revenue += g.Revenue
+		total.Count += g.Count
+		if compare {
+			*total.PreviousRevenue += *g.PreviousRevenue
+			g.SeriesTotal.compare()
+		}
+		series = append(series, *g)
+	}
+	if compare {
+		total.compare()
+	}
+
+	// Biggest groups first
+	sort.Slice(series, func(i, j int) bool {
+		if series[i].Revenue != series[j].Revenue {
+			return series[i].Revenue > series[j].Revenue
+		}
+		return series[i].Group < series[j].Group
+	})
+	return series, total
+}
+
+// compare works out the change from the previous revenue
+func (t *SeriesTotal) compare() {
+	change := t.Revenue - *t.PreviousRevenue
+	t.Change = &change
+	if *t.PreviousRevenue != 0 {
+		percent := math.Round(change / *t.PreviousRevenue * 10000) / 100
+		t.ChangePercent = &percent
+	}
+}
+
+// truncate is the start of the bucket a day falls in, as date_trunc works it out
+func truncate(day time.Time, interval string) time.Time {
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	switch interval {
+	case IntervalWeek:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case IntervalMonth:
+		return day.AddDate(0, 0, 1-day.Day())
+	}
+	return day
+}
+
+func next(at time.Time, interval string) time.Time {
+	switch interval {
+	case IntervalWeek:
+		return at.AddDate(0, 0, 7)
+	case IntervalMonth:
+		return at.AddDate(0, 1, 0)
+	}
+	return at.AddDate(0, 0, 1)
+}
@@ -0,0 +1,289 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+	export_handler "w4/p2/milestones/internal/exportHandler"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// RevenueReportType is the report_type revenue reports are saved under
+const RevenueReportType = "Revenue Report"
+
+// SavedReport is a report as stored when it was generated
+type SavedReport struct {
+	ID                int              `json:"id"`
+	AdminID           int              `json:"admin_id"`
+	AdminUsername     string           `json:"admin_username"`
+	ReportType        string           `json:"report_type"`
+	StartDate         time.Time        `json:"start_date"`
+	EndDate           time.Time        `json:"end_date"`
+	TotalTransactions int              `json:"total_transactions"`
+	TotalRevenue      float64          `json:"total_revenue"`
+	TotalRentals      int              `json:"total_rentals"`
+	TotalTax          float64          `json:"total_tax"`
+	TotalDiscount     float64          `json:"total_discount"`
+	NetRevenue        float64          `json:"net_revenue"`
+	TopServices       []TopService     `json:"top_services"`
+	TaxBreakdown      []TaxSummary     `json:"tax_breakdown"`
+	VoucherUsage      []VoucherSummary `json:"voucher_usage"`
+	CreatedAt         time.Time        `json:"created_at"`
+}
+
+// reportDetails holds the breakdowns of a report that have no column of their own
+type reportDetails struct {
+	TaxBreakdown []TaxSummary     `json:"tax_breakdown"`
+	VoucherUsage []VoucherSummary `json:"voucher_usage"`
+}
+
+func (r SavedReport) revenueResponse() RevenueReportResponse {
+	return RevenueReportResponse{
+		ReportID:          r.ID,
+		GeneratedAt:       r.CreatedAt,
+		TotalRevenue:      r.TotalRevenue,
+		TotalTax:          r.TotalTax,
+		TotalDiscount:     r.TotalDiscount,
+		NetRevenue:        r.NetRevenue,
+		TotalTransactions: r.TotalTransactions,
+		TaxBreakdown:      r.TaxBreakdown,
+		VoucherUsage:      r.VoucherUsage,
+		TopServices:       r.TopServices,
+	}
+}
+
+// requireSuperAdmin returns the admin ID from the JWT and whether it belongs to a super-admin
+func requireSuperAdmin(c echo.Context) (int, bool) {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	adminRole, _ := claims["role"].(string)
+	adminID, _ := claims["admin_id"].(float64)
+	return int(adminID), adminRole == "super-admin"
+}
+
+const reportColumns = `r.id, r.admin_id, COALESCE(a.username, ''), r.report_type, r.start_date, r.end_date,
+	COALESCE(r.total_transactions, 0), COALESCE(r.total_revenue, 0), COALESCE(r.total_rentals, 0),
+	COALESCE(r.total_tax, 0), COALESCE(r.total_discount, 0), COALESCE(r.top_services, ''), r.details, r.created_at`
+
+// scanReport reads a row of reportColumns, followed by any extra columns into extra
+func scanReport(row pgx.Row, r *SavedReport, extra ...interface{}) error {
+	var topServices string
+	var details *reportDetails
+	dest := []interface{}{&r.ID, &r.AdminID, &r.AdminUsername, &r.ReportType, &r.StartDate, &r.EndDate,
+		&r.TotalTransactions, &r.TotalRevenue, &r.TotalRentals, &r.TotalTax, &r.TotalDiscount, &topServices, &details, &r.CreatedAt}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return err
+	}
+	r.NetRevenue = r.TotalRevenue - r.TotalTax
+	r.TopServices = ParseTopServices(topServices)
+	r.TaxBreakdown, r.VoucherUsage = []TaxSummary{}, []VoucherSummary{}
+	if details != nil {
+		if details.TaxBreakdown != nil {
+			r.TaxBreakdown = details.TaxBreakdown
+		}
+		if details.VoucherUsage != nil {
+			r.VoucherUsage = details.VoucherUsage
+		}
+	}
+	return nil
+}
+
+// ParseTopServices reads the top services saved with a report. Reports saved before
+// they were stored as JSON only list the service names, separated by commas.
+func ParseTopServices(stored string) []TopService {
+	services := []TopService{}
+	stored = strings.TrimSpace(stored)
+	if stored == "" {
+		return services
+	}
+	if err := json.Unmarshal([]byte(stored), &services); err == nil {
+		return services
+	}
+	services = []TopService{}
+	for _, name := range strings.Split(stored, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			services = append(services, TopService{ServiceName: name})
+		}
+	}
+	return services
+}
+
+// findReport returns the latest report of a type saved for exactly the given period, or nil.
+// Only reports generated once the period had closed and its payments had settled are
+// returned, the same rule the revenue series cache uses; earlier ones may miss sales.
+func findReport(ctx context.Context, reportType string, startDate, endDate time.Time) (*SavedReport, error) {
+	query := `
+		SELECT ` + reportColumns + `
+		FROM report r
+		LEFT JOIN admin a ON a.id = r.admin_id
+		WHERE r.report_type = $1 AND r.start_date = $2 AND r.end_date = $3 AND r.created_at >= $4::TIMESTAMPTZ
+		ORDER BY r.created_at DESC, r.id DESC
+		LIMIT 1`
+	settled := endDate.AddDate(0, 0, 1).Add(settleGrace)
+	var r SavedReport
+	err := scanReport(config.Pool.QueryRow(ctx, query, reportType, startDate, endDate, settled), &r)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to fetch saved report: %w", err)
+	}
+	return &r, nil
+}
+
+// GetReports godoc
+// @Summary List saved reports
+// @Description Retrieve the reports generated so far, newest first, without recomputing them. The date range matches reports whose whole period falls inside it.
+// @Tags Reports
+// @Produce json
+// @Param type query string false "Report type, e.g. Revenue Report"
+// @Param admin_id query int false "Only reports generated by this admin"
+// @Param start_date query string false "Earliest report period start (YYYY-MM-DD)"
+// @Param end_date query string false "Latest report period end (YYYY-MM-DD)"
+// @Param limit query int false "Number of reports (default 20)"
+// @Param offset query int false "Reports to skip"
+// @Success 200 {object} map[string]interface{} "Reports retrieved successfully"
+// @Failure 400 {object} map[string]string "Invalid filter"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/reports [get]
+func GetReports(c echo.Context) error {
+	if _, ok := requireSuperAdmin(c); !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can view reports."})
+	}
+
+	var adminID int
+	if value := c.QueryParam("admin_id"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid admin_id"})
+		}
+		adminID = parsed
+	}
+
+	var startDate, endDate *time.Time
+	for param, target := range map[string]**time.Time{"start_date": &startDate, "end_date": &endDate} {
+		if value := c.QueryParam(param); value != "" {
+			parsed, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid " + param + ", use YYYY-MM-DD"})
+			}
+			*target = &parsed
+		}
+	}
+
+	limit, offset := 20, 0
+	if value := c.QueryParam("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > 100 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "limit must be between 1 and 100"})
+		}
+		limit = parsed
+	}
+	if value := c.QueryParam("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid offset"})
+		}
+		offset = parsed
+	}
+
+	query := `
+		SELECT ` + reportColumns + `, COUNT(*) OVER ()
+		FROM report r
+		LEFT JOIN admin a ON a.id = r.admin_id
+		WHERE ($1 = '' OR r.report_type = $1)
+		  AND ($2 = 0 OR r.admin_id = $2)
+		  AND ($3::DATE IS NULL OR r.start_date >= $3::DATE)
+		  AND ($4::DATE IS NULL OR r.end_date < $4::DATE + 1)
+		ORDER BY r.created_at DESC, r.id DESC
+		LIMIT $5 OFFSET $6`
+	rows, err := config.Pool.Query(context.Background(), query, c.QueryParam("type"), adminID, startDate, endDate, limit, offset)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch reports"})
+	}
+	defer rows.Close()
+
+	reports := []SavedReport{}
+	var total int
+	for rows.Next() {
+		var r SavedReport
+		if err := scanReport(rows, &r, &total); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to parse reports"})
+		}
+		reports = append(reports, r)
+	}
+	if err := rows.Err(); err != nil {
+		fmt.Printf("Row iteration error: %v\n", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to parse reports"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Reports retrieved successfully",
+		"data":    reports,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// GetReport godoc
+// @Summary Get a saved report
+// @Description Retrieve a report as it was stored when generated, with its top services, tax breakdown and voucher usage
+// @Tags Reports
+// @Produce json
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param id path int true "Report ID"
+// @Param format query string false "json, csv or xlsx (or use the Accept header)"
+// @Param lang query string false "Language of export column headers (en or id)"
+// @Success 200 {object} SavedReport
+// @Failure 400 {object} map[string]string "Invalid report ID or format"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Report not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/reports/{id} [get]
+func GetReport(c echo.Context) error {
+	if _, ok := requireSuperAdmin(c); !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can view reports."})
+	}
+
+	reportID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid report ID"})
+	}
+	format, err := export_handler.RequestedFormat(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+
+	query := `
+		SELECT ` + reportColumns + `
+		FROM report r
+		LEFT JOIN admin a ON a.id = r.admin_id
+		WHERE r.id = $1`
+	var report SavedReport
+	err = scanReport(config.Pool.QueryRow(context.Background(), query, reportID), &report)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Report not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch report"})
+	}
+
+	if format != export_handler.FormatJSON {
+		return exportRevenueReport(c, format, fmt.Sprintf("report-%d", report.ID), report.revenueResponse())
+	}
+	return c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,261 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+	export_handler "w4/p2/milestones/internal/exportHandler"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// StaffPerformanceReport is what every admin handled over a date range
+type StaffPerformanceReport struct {
+	StartDate string             `json:"start_date"`
+	EndDate   string             `json:"end_date"`
+	Staff     []StaffPerformance `json:"staff"`
+}
+
+// StaffPerformance is the work of one admin. Rentals are credited to the admin recorded on
+// them; payments to the admin who took the order, or else whose shift took it or who
+// booked its rental; and services to the admin of the rental they were sold with, or else
+// of the payment.
+type StaffPerformance struct {
+	AdminID             int     `json:"admin_id"`
+	Username            string  `json:"username"`
+	Role                string  `json:"role"`
+	RentalsProcessed    int     `json:"rentals_processed"`
+	RentalRevenue       float64 `json:"rental_revenue"` // computer time after discount, before tax
+	TransactionsHandled int     `json:"transactions_handled"`
+	RevenueHandled      float64 `json:"revenue_handled"` // settled payments, transfers excluded
+	ServicesSold        int     `json:"services_sold"`
+	ServiceRevenue      float64 `json:"service_revenue"`
+	CancelledRentals    int     `json:"cancelled_rentals"`
+	Shifts              int     `json:"shifts"`
+	ShiftHours          float64 `json:"shift_hours"` // within the date range, open shifts up to now
+	RevenuePerShiftHour float64 `json:"revenue_per_shift_hour"`
+	RentalsPerShiftHour float64 `json:"rentals_per_shift_hour"`
+}
+
+// errOtherAdmin is returned when an admin asks for the performance of someone else
+var errOtherAdmin = errors.New("admins can only view their own performance")
+
+// staffScope picks whose performance a role may see. Super-admins see everyone, or the
+// admin they ask for; admins only themselves. A nil result means every admin.
+func staffScope(role string, adminID int, requested *int) (*int, error) {
+	switch role {
+	case "super-admin":
+		return requested, nil
+	case "admin":
+		if requested != nil && *requested != adminID {
+			return nil, errOtherAdmin
+		}
+		return &adminID, nil
+	}
+	return nil, fmt.Errorf("role %q cannot view staff performance", role)
+}
+
+// perShiftHour works out revenue and rentals per hour on shift
+func (s *StaffPerformance) perShiftHour() {
+	s.ShiftHours = round2(s.ShiftHours)
+	if s.ShiftHours <= 0 {
+		return
+	}
+	s.RevenuePerShiftHour = round2(s.RevenueHandled / s.ShiftHours)
+	s.RentalsPerShiftHour = round2(float64(s.RentalsProcessed) / s.ShiftHours)
+}
+
+// staffPerformanceQuery totals the work of each admin between the instants $1 and $2, for admin $3 or everyone
+const staffPerformanceQuery = `
+	WITH rentals AS (
+		SELECT admin_id,
+		       COUNT(*) FILTER (WHERE booking_status IS NULL OR booking_status NOT ILIKE 'Cancel%') AS rentals,
+		       SUM(line_total) FILTER (WHERE booking_status IS NULL OR booking_status NOT ILIKE 'Cancel%') AS revenue,
+		       COUNT(*) FILTER (WHERE booking_status ILIKE 'Cancel%') AS cancelled
+		FROM rental_history
+		WHERE admin_id IS NOT NULL AND rental_start_time >= $1::TIMESTAMPTZ AND rental_start_time < $2::TIMESTAMPTZ
+		GROUP BY admin_id
+	), payments AS (
+		SELECT COALESCE(t.admin_id, sh.admin_id, rh.admin_id) AS admin_id,
+		       COUNT(*) AS transactions, SUM(t.amount) AS revenue
+		FROM transaction t
+		LEFT JOIN shift sh ON sh.id = t.shift_id
+		LEFT JOIN receipt rc ON rc.transaction_id = t.id
+		LEFT JOIN rental_history rh ON rh.id = rc.rental_history_id
+		WHERE t.status ILIKE 'Settlement' AND t.transaction_type NOT IN ('Transfer Out', 'Transfer In')
+		  AND t.transaction_date >= $1::TIMESTAMPTZ AND t.transaction_date < $2::TIMESTAMPTZ
+		GROUP BY 1
+	), services AS (
+		SELECT COALESCE(rh.admin_id, t.admin_id, sh.admin_id) AS admin_id, SUM(rs.quantity) AS sold, SUM(rs.line_total) AS revenue
+		FROM rental_services rs
+		LEFT JOIN rental_history rh ON rh.id = rs.rental_history_id
+		LEFT JOIN transaction t ON t.id = rs.transaction_id
+		LEFT JOIN shift sh ON sh.id = t.shift_id
+		WHERE rs.created_at >= $1::TIMESTAMPTZ AND rs.created_at < $2::TIMESTAMPTZ
+		GROUP BY 1
+	), shifts AS (
+		SELECT admin_id, COUNT(*) AS shifts,
+		       SUM(EXTRACT(EPOCH FROM LEAST(COALESCE(closed_at::TIMESTAMPTZ, NOW()), $2::TIMESTAMPTZ)
+		           - GREATEST(opened_at::TIMESTAMPTZ, $1::TIMESTAMPTZ)) / 3600) AS hours
+		FROM shift
+		WHERE opened_at < $2::TIMESTAMPTZ AND COALESCE(closed_at::TIMESTAMPTZ, NOW()) > $1::TIMESTAMPTZ
+		GROUP BY admin_id
+	)
+	SELECT a.id, a.username, a.role,
+	       COALESCE(r.rentals, 0)::INT, COALESCE(r.revenue, 0)::DOUBLE PRECISION,
+	       COALESCE(p.transactions, 0)::INT, COALESCE(p.revenue, 0)::DOUBLE PRECISION,
+	       COALESCE(sv.sold, 0)::INT, COALESCE(sv.revenue, 0)::DOUBLE PRECISION,
+	       COALESCE(r.cancelled, 0)::INT,
+	       COALESCE(s.shifts, 0)::INT, COALESCE(s.hours, 0)::DOUBLE PRECISION
+	FROM admin a
+	LEFT JOIN rentals r ON r.admin_id = a.id
+	LEFT JOIN payments p ON p.admin_id = a.id
+	LEFT JOIN services sv ON sv.admin_id = a.id
+	LEFT JOIN shifts s ON s.admin_id = a.id
+	WHERE ($3::INT IS NULL OR a.id = $3)
+	ORDER BY COALESCE(p.revenue, 0) DESC, a.id`
+
+// BuildStaffPerformance reads the performance of admin adminID, or every admin when nil,
+// between startDate and the end of endDate, in the days of the dates' location
+func BuildStaffPerformance(ctx context.Context, startDate, endDate time.Time, adminID *int) (StaffPerformanceReport, error) {
+	report := StaffPerformanceReport{
+		StartDate: startDate.Format("2006-01-02"),
+		EndDate:   endDate.Format("2006-01-02"),
+		Staff:     []StaffPerformance{},
+	}
+	rows, err := config.Pool.Query(ctx, staffPerformanceQuery, startDate, endDate.AddDate(0, 0, 1), adminID)
+	if err != nil {
+		return report, fmt.Errorf("failed to fetch staff performance: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s StaffPerformance
+		if err := rows.Scan(&s.AdminID, &s.Username, &s.Role, &s.RentalsProcessed, &s.RentalRevenue,
+			&s.TransactionsHandled, &s.RevenueHandled, &s.ServicesSold, &s.ServiceRevenue,
+			&s.CancelledRentals, &s.Shifts, &s.ShiftHours); err != nil {
+			return report, fmt.Errorf("failed to parse staff performance: %w", err)
+		}
+		s.perShiftHour()
+		report.Staff = append(report.Staff, s)
+	}
+	return report, rows.Err()
+}
+
+// GetStaffPerformance godoc
+// @Summary Staff performance report
+// @Description Rentals processed, revenue handled, services sold, cancelled rentals and shift hours per admin, over Asia/Jakarta days. Super-admins see every admin, admins only themselves; the role is read from the admin account.
+// @Tags Reports
+// @Produce json
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param start_date query string true "Start date (YYYY-MM-DD)"
+// @Param end_date query string true "End date (YYYY-MM-DD), included"
+// @Param admin_id query int false "Only this admin"
+// @Param format query string false "json, csv or xlsx (or use the Accept header)"
+// @Param lang query string false "Language of export column headers (en or id)"
+// @Success 200 {object} StaffPerformanceReport
+// @Failure 400 {object} map[string]string "Invalid parameters"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/reports/staff [get]
+func GetStaffPerformance(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	claimedID, _ := claims["admin_id"].(float64)
+	adminID := int(claimedID)
+	ctx := context.Background()
+
+	// The role on the account, so a demoted admin loses access before their token expires
+	var role string
+	err := config.Pool.QueryRow(ctx, `SELECT role FROM admin WHERE id = $1`, adminID).Scan(&role)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only admins can view staff performance."})
+	}
+
+	var requested *int
+	if value := c.QueryParam("admin_id"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid admin_id"})
+		}
+		requested = &parsed
+	}
+	scope, err := staffScope(role, adminID, requested)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. " + err.Error()})
+	}
+
+	format, err := export_handler.RequestedFormat(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+	// Report days are days in Jakarta, as for the revenue report
+	loc, err := time.LoadLocation(DefaultTimezone)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to load report timezone"})
+	}
+	startDate, err := time.ParseInLocation("2006-01-02", c.QueryParam("start_date"), loc)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid start_date, use YYYY-MM-DD"})
+	}
+	endDate, err := time.ParseInLocation("2006-01-02", c.QueryParam("end_date"), loc)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid end_date, use YYYY-MM-DD"})
+	}
+	if endDate.Before(startDate) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "end_date must not be before start_date"})
+	}
+
+	report, err := BuildStaffPerformance(ctx, startDate, endDate, scope)
+	if err != nil {
+		fmt.Println("Staff performance error:", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch staff performance"})
+	}
+
+	if format != export_handler.FormatJSON {
+		return exportStaffPerformance(c, format, report)
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+// staffPerformanceColumns are the columns of a staff performance export, one row per admin
+var staffPerformanceColumns = []export_handler.Column{
+	{English: "Admin ID", Indonesian: "ID Admin", Kind: export_handler.KindNumber},
+	{English: "Username", Indonesian: "Username", Kind: export_handler.KindText},
+	{English: "Role", Indonesian: "Peran", Kind: export_handler.KindText},
+	{English: "Rentals Processed", Indonesian: "Sewa Diproses", Kind: export_handler.KindNumber},
+	{English: "Rental Revenue", Indonesian: "Pendapatan Sewa", Kind: export_handler.KindCurrency},
+	{English: "Transactions Handled", Indonesian: "Transaksi Ditangani", Kind: export_handler.KindNumber},
+	{English: "Revenue Handled", Indonesian: "Pendapatan Ditangani", Kind: export_handler.KindCurrency},
+	{English: "Services Sold", Indonesian: "Layanan Terjual", Kind: export_handler.KindNumber},
+	{English: "Service Revenue", Indonesian: "Pendapatan Layanan", Kind: export_handler.KindCurrency},
+	{English: "Cancelled Rentals", Indonesian: "Sewa Dibatalkan", Kind: export_handler.KindNumber},
+	{English: "Shifts", Indonesian: "Shift", Kind: export_handler.KindNumber},
+	{English: "Shift Hours", Indonesian: "Jam Shift", Kind: export_handler.KindNumber},
+	{English: "Revenue per Shift Hour", Indonesian: "Pendapatan per Jam Shift", Kind: export_handler.KindCurrency},
+}
+
+// exportStaffPerformance writes a staff performance report as a spreadsheet download
+func exportStaffPerformance(c echo.Context, format string, report StaffPerformanceReport) error {
+	filename := fmt.Sprintf("staff-performance-%s-%s", report.StartDate, report.EndDate)
+	w, err := export_handler.NewWriter(c, format, filename, staffPerformanceColumns)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+	for _, s := range report.Staff {
+		if err := w.WriteRow(s.AdminID, s.Username, s.Role, s.RentalsProcessed, s.RentalRevenue, s.TransactionsHandled,
+			s.RevenueHandled, s.ServicesSold, s.ServiceRevenue, s.CancelledRentals, s.Shifts, s.ShiftHours,
+			s.RevenuePerShiftHour); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
@@ -0,0 +1,349 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+	export_handler "w4/p2/milestones/internal/exportHandler"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Utilization flags
+const (
+	FlagIdle     = "idle"       // not booked at all in the period
+	FlagUnderUse = "under_used" // booked less than the threshold of its open hours
+)
+
+// defaultUnderUseThreshold is the share of open hours below which a computer is under-used
+const defaultUnderUseThreshold = 0.2
+
+// UtilizationReport is how much of their open hours computers were booked over a period
+type UtilizationReport struct {
+	StartDate            string                `json:"start_date"`
+	EndDate              string                `json:"end_date"`
+	OpenHour             int                   `json:"open_hour"`
+	CloseHour            int                   `json:"close_hour"`
+	OpenHoursPerComputer float64               `json:"open_hours_per_computer"`
+	Threshold            float64               `json:"threshold"`
+	Computers            []ComputerUtilization `json:"computers"`
+	Types                []TypeUtilization     `json:"types"`
+	Heatmap              []HourOccupancy       `json:"heatmap"` // all computers
+}
+
+// ComputerUtilization is the use of one computer over the period
+type ComputerUtilization struct {
+	ComputerID          int     `json:"computer_id"`
+	Name                string  `json:"name"`
+	Type                string  `json:"type"`
+	Sessions            int     `json:"sessions"`
+	BookedHours         float64 `json:"booked_hours"` // within open hours
+	OpenHours           float64 `json:"open_hours"`
+	Utilization         float64 `json:"utilization"` // booked share of open hours, 0 to 1
+	AverageSessionHours float64 `json:"average_session_hours"`
+	Revenue             float64 `json:"revenue"` // computer time, after discounts and before tax
+	Flag                string  `json:"flag,omitempty"`
+}
+
+// TypeUtilization is the use of all computers of one type over the period
+type TypeUtilization struct {
+	Type                string          `json:"type"`
+	Computers           int             `json:"computers"`
+	Sessions            int             `json:"sessions"`
+	BookedHours         float64         `json:"booked_hours"`
+	OpenHours           float64         `json:"open_hours"`
+	Utilization         float64         `json:"utilization"`
+	AverageSessionHours float64         `json:"average_session_hours"`
+	Revenue             float64         `json:"revenue"`
+	RevenuePerComputer  float64         `json:"revenue_per_computer"`
+	Heatmap             []HourOccupancy `json:"heatmap"`
+}
+
+// HourOccupancy is the share of computers booked at an hour of the day, on average over the period
+type HourOccupancy struct {
+	Hour        int     `json:"hour"`
+	BookedHours float64 `json:"booked_hours"`
+	Occupancy   float64 `json:"occupancy"`
+}
+
+// hourUsage is the time a computer was booked during one hour of the day over the period
+type hourUsage struct {
+	ComputerID int
+	Hour       int
+	Booked     float64
+}
+
+// GetUtilizationReport godoc
+// @Summary Computer utilization report
+// @Description Report how much of their open hours computers were booked over a date range, per computer and per type, with occupancy by hour of day, average session length and time revenue. Days and hours are Asia/Jakarta time. Idle and under-used computers are flagged.
+// @Tags Reports
+// @Produce json
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param start_date query string true "First day (YYYY-MM-DD)"
+// @Param end_date query string true "Last day, inclusive (YYYY-MM-DD)"
+// @Param open_hour query int false "Hour the cafe opens (default 0)"
+// @Param close_hour query int false "Hour the cafe closes (default 24)"
+// @Param threshold query number false "Utilization below which a computer is under-used (default 0.2)"
+// @Param format query string false "json, csv or xlsx (or use the Accept header)"
+// @Param lang query string false "Language of export column headers (en or id)"
+// @Success 200 {object} UtilizationReport
+// @Failure 400 {object} map[string]string "Invalid parameters"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/reports/utilization [get]
+func GetUtilizationReport(c echo.Context) error {
+	if _, ok := requireSuperAdmin(c); !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can view reports."})
+	}
+
+	format, err := export_handler.RequestedFormat(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+
+	// Report days and hours are Jakarta time, as for the revenue report
+	loc, err := time.LoadLocation(DefaultTimezone)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to load report timezone"})
+	}
+	startDate, err := time.ParseInLocation("2006-01-02", c.QueryParam("start_date"), loc)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid start_date, use YYYY-MM-DD"})
+	}
+	endDate, err := time.ParseInLocation("2006-01-02", c.QueryParam("end_date"), loc)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid end_date, use YYYY-MM-DD"})
+	}
+	days := int(endDate.Sub(startDate).Hours()/24) + 1
+	if days < 1 || days > 366 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "The date range must cover 1 to 366 days"})
+	}
+
+	openHour, closeHour := 0, 24
+	for param, target := range map[string]*int{"open_hour": &openHour, "close_hour": &closeHour} {
+		if value := c.QueryParam(param); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 0 || parsed > 24 {
+				return c.JSON(http.StatusBadRequest, map[string]string{"message": param + " must be between 0 and 24"})
+			}
+			*target = parsed
+		}
+	}
+	if openHour >= closeHour {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "open_hour must be before close_hour"})
+	}
+
+	threshold := defaultUnderUseThreshold
+	if value := c.QueryParam("threshold"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "threshold must be between 0 and 1"})
+		}
+		threshold = parsed
+	}
+
+	ctx := context.Background()
+	periodEnd := endDate.AddDate(0, 0, 1)
+
+	// Sessions, length and revenue of the rentals that started in the period, for every
+	// computer. Cancelled bookings do not count as use, as in staff performance.
+	computerQuery := `
+		SELECT c.id, c.name, c.type, COUNT(rh.id),
+		       COALESCE(AVG(EXTRACT(EPOCH FROM rh.rental_end_time - rh.rental_start_time) / 3600), 0)::DOUBLE PRECISION,
+		       COALESCE(SUM(rh.line_total), 0)::DOUBLE PRECISION
+		FROM computer c
+		LEFT JOIN rental_history rh ON rh.computer_id = c.id AND rh.rental_start_time >= $1::TIMESTAMPTZ AND rh.rental_start_time < $2::TIMESTAMPTZ
+		      AND (rh.booking_status IS NULL OR rh.booking_status NOT ILIKE 'Cancel%')
+		GROUP BY c.id, c.name, c.type
+		ORDER BY c.type, c.id`
+	rows, err := config.Pool.Query(ctx, computerQuery, startDate, periodEnd)
+	if err != nil {
+		fmt.Println("Utilization report error:", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch computer usage"})
+	}
+	computers := []ComputerUtilization{}
+	for rows.Next() {
+		var cu ComputerUtilization
+		if err := rows.Scan(&cu.ComputerID, &cu.Name, &cu.Type, &cu.Sessions, &cu.AverageSessionHours, &cu.Revenue); err != nil {
+			rows.Close()
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to parse computer usage"})
+		}
+		computers = append(computers, cu)
+	}
+	rows.Close()
+
+	// Booked time within each open hour of the period, split across the hours a rental spans.
+	// Slots are instants, and their hour of day is read in Jakarta.
+	hourlyQuery := `
+		WITH slots AS (
+			SELECT slot
+			FROM generate_series($1::TIMESTAMPTZ, $2::TIMESTAMPTZ - INTERVAL '1 hour', INTERVAL '1 hour') AS slot
+			WHERE EXTRACT(HOUR FROM slot AT TIME ZONE $5) >= $3 AND EXTRACT(HOUR FROM slot AT TIME ZONE $5) < $4
+		)
+		SELECT rh.computer_id, EXTRACT(HOUR FROM s.slot AT TIME ZONE $5)::INT,
+		       SUM(EXTRACT(EPOCH FROM LEAST(rh.rental_end_time::TIMESTAMPTZ, s.slot + INTERVAL '1 hour')
+		           - GREATEST(rh.rental_start_time::TIMESTAMPTZ, s.slot)) / 3600)::DOUBLE PRECISION
+		FROM rental_history rh
+		JOIN slots s ON rh.rental_start_time::TIMESTAMPTZ < s.slot + INTERVAL '1 hour' AND rh.rental_end_time::TIMESTAMPTZ > s.slot
+		WHERE rh.booking_status IS NULL OR rh.booking_status NOT ILIKE 'Cancel%'
+		GROUP BY 1, 2`
+	rows, err = config.Pool.Query(ctx, hourlyQuery, startDate, periodEnd, openHour, closeHour, loc.String())
+	if err != nil {
+		fmt.Println("Utilization report error:", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch hourly usage"})
+	}
+	usage := []hourUsage{}
+	for rows.Next() {
+		var u hourUsage
+		if err := rows.Scan(&u.ComputerID, &u.Hour, &u.Booked); err != nil {
+			rows.Close()
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to parse hourly usage"})
+		}
+		usage = append(usage, u)
+	}
+	rows.Close()
+
+	report := BuildUtilization(computers, usage, days, openHour, closeHour, threshold)
+	report.StartDate, report.EndDate = c.QueryParam("start_date"), c.QueryParam("end_date")
+
+	if format != export_handler.FormatJSON {
+		return exportUtilizationReport(c, format, report)
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+// BuildUtilization works out utilization, occupancy and flags from the sessions of each
+// computer and the hours they were booked, over days days open from openHour to closeHour
+func BuildUtilization(computers []ComputerUtilization, usage []hourUsage, days, openHour, closeHour int, threshold float64) UtilizationReport {
+	report := UtilizationReport{
+		OpenHour:             openHour,
+		CloseHour:            closeHour,
+		OpenHoursPerComputer: float64(days * (closeHour - openHour)),
+		Threshold:            threshold,
+	}
+
+	booked := map[int]float64{}
+	bookedByHour := map[int]map[int]float64{} // computer ID, hour of day
+	for _, u := range usage {
+		booked[u.ComputerID] += u.Booked
+		if bookedByHour[u.ComputerID] == nil {
+			bookedByHour[u.ComputerID] = map[int]float64{}
+		}
+		bookedByHour[u.ComputerID][u.Hour] += u.Booked
+	}
+
+	types := map[string]*TypeUtilization{}
+	typeHours := map[string]map[int]float64{}
+	var typeNames []string
+	allHours := map[int]float64{}
+	for i := range computers {
+		cu := &computers[i]
+		cu.OpenHours = report.OpenHoursPerComputer
+		cu.BookedHours = round2(booked[cu.ComputerID])
+		cu.Utilization = ratio(cu.BookedHours, cu.OpenHours)
+		cu.AverageSessionHours = round2(cu.AverageSessionHours)
+		switch {
+		case cu.Sessions == 0 && cu.BookedHours == 0:
+			cu.Flag = FlagIdle
+		case cu.Utilization < threshold:
+			cu.Flag = FlagUnderUse
+		}
+
+		t, ok := types[cu.Type]
+		if !ok {
+			t = &TypeUtilization{Type: cu.Type}
+			types[cu.Type] = t
+			typeHours[cu.Type] = map[int]float64{}
+			typeNames = append(typeNames, cu.Type)
+		}
+		t.Computers++
+		t.Sessions += cu.Sessions
+		t.BookedHours += cu.BookedHours
+		t.OpenHours += cu.OpenHours
+		t.AverageSessionHours += cu.AverageSessionHours * float64(cu.Sessions)
+		t.Revenue += cu.Revenue
+		for hour, hours := range bookedByHour[cu.ComputerID] {
+			typeHours[cu.Type][hour] += hours
+			allHours[hour] += hours
+		}
+	}
+
+	sort.Strings(typeNames)
+	report.Types = []TypeUtilization{}
+	for _, name := range typeNames {
+		t := types[name]
+		t.BookedHours = round2(t.BookedHours)
+		t.Utilization = ratio(t.BookedHours, t.OpenHours)
+		if t.Sessions > 0 {
+			t.AverageSessionHours = round2(t.AverageSessionHours / float64(t.Sessions))
+		}
+		t.RevenuePerComputer = math.Round(t.Revenue / float64(t.Computers))
+		t.Heatmap = heatmap(typeHours[name], t.Computers, days, openHour, closeHour)
+		report.Types = append(report.Types, *t)
+	}
+	report.Computers = computers
+	report.Heatmap = heatmap(allHours, len(computers), days, openHour, closeHour)
+	return report
+}
+
+// heatmap is the average occupancy of computers at each open hour of the day
+func heatmap(booked map[int]float64, computers, days, openHour, closeHour int) []HourOccupancy {
+	hours := []HourOccupancy{}
+	for hour := openHour; hour < closeHour; hour++ {
+		hours = append(hours, HourOccupancy{
+			Hour:        hour,
+			BookedHours: round2(booked[hour]),
+			Occupancy:   ratio(booked[hour], float64(computers*days)),
+		})
+	}
+	return hours
+}
+
+// ratio is part of whole to four decimals, capped at 1 for overlapping bookings
+func ratio(part, whole float64) float64 {
+	if whole <= 0 {
+		return 0
+	}
+	return math.Min(math.Round(part/whole*10000)/10000, 1)
+}
+
+func round2(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// utilizationColumns are the columns of a utilization report export, one row per computer
+var utilizationColumns = []export_handler.Column{
+	{English: "Computer ID", Indonesian: "ID Komputer", Kind: export_handler.KindNumber},
+	{English: "Computer", Indonesian: "Komputer", Kind: export_handler.KindText},
+	{English: "Type", Indonesian: "Tipe", Kind: export_handler.KindText},
+	{English: "Sessions", Indonesian: "Sesi", Kind: export_handler.KindNumber},
+	{English: "Booked Hours", Indonesian: "Jam Terpakai", Kind: export_handler.KindNumber},
+	{English: "Open Hours", Indonesian: "Jam Buka", Kind: export_handler.KindNumber},
+	{English: "Utilization", Indonesian: "Utilisasi", Kind: export_handler.KindNumber},
+	{English: "Average Session Hours", Indonesian: "Rata-rata Jam per Sesi", Kind: export_handler.KindNumber},
+	{English: "Revenue", Indonesian: "Pendapatan", Kind: export_handler.KindCurrency},
+	{English: "Flag", Indonesian: "Catatan", Kind: export_handler.KindText},
+}
+
+// exportUtilizationReport writes the per-computer part of a utilization report as a spreadsheet
+func exportUtilizationReport(c echo.Context, format string, report UtilizationReport) error {
+	w, err := export_handler.NewWriter(c, format, fmt.Sprintf("utilization-report-%s-%s", report.StartDate, report.EndDate), utilizationColumns)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+	for _, cu := range report.Computers {
+		if err := w.WriteRow(cu.ComputerID, cu.Name, cu.Type, cu.Sessions, cu.BookedHours, cu.OpenHours,
+			cu.Utilization, cu.AverageSessionHours, cu.Revenue, cu.Flag); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
@@ -4,34 +4,103 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	config "w4/p2/milestones/config/database"
+	export_handler "w4/p2/milestones/internal/exportHandler"
 
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/jackc/pgx/v5"
 	"github.com/labstack/echo/v4"
 )
 
 // BookingReport structure
 type BookingReport struct {
-	RentalID       int     `json:"rental_id"`
-	ComputerID     int     `json:"computer_id"`
-	ComputerName   string  `json:"computer_name"`
-	AdminID        int     `json:"admin_id"`
-	AdminUsername  string  `json:"admin_username"`
-	RentalStart    time.Time  `json:"rental_start"`
-	RentalEnd      time.Time  `json:"rental_end"`
-	TotalCost      float64 `json:"total_cost"`
+	RentalID      int              `json:"rental_id"`
+	ComputerID    int              `json:"computer_id"`
+	ComputerName  string           `json:"computer_name"`
+	AdminID       *int             `json:"admin_id"` // nil when no admin was recorded
+	AdminUsername *string          `json:"admin_username"`
+	RentalStart   time.Time        `json:"rental_start"`
+	RentalEnd     time.Time        `json:"rental_end"`
+	TotalCost     float64          `json:"total_cost"`
+	BookingStatus *string          `json:"booking_status"`
+	TimeTotal     float64          `json:"time_total"` // computer time after discount, before tax
+	ServicesTotal float64          `json:"services_total"`
+	PaymentMethod *string          `json:"payment_method"` // nil when no receipt was issued
+	PaymentStatus *string          `json:"payment_status"`
+	AmountPaid    *float64         `json:"amount_paid"`
+	Services      []BookingService `json:"services"`
+}
+
+// BookingService is a service line bought during a rental
+type BookingService struct {
+	ServiceID   int     `json:"service_id"`
+	ServiceName string  `json:"service_name"`
+	Quantity    int     `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+	Discount    float64 `json:"discount"`
+	LineTotal   float64 `json:"line_total"`
+}
+
+// bookingReportQuery selects a customer's rentals with their payment and services total.
+// The payment is found through the receipt issued for the rental; rentals recorded
+// without one, and rentals booked online, leave the payment and admin columns NULL.
+const bookingReportQuery = `
+		SELECT rh.id AS rental_id, rh.computer_id, COALESCE(c.name, '') AS computer_name, rh.admin_id,
+		       a.username AS admin_username, rh.rental_start_time, rh.rental_end_time, rh.total_cost,
+		       rh.booking_status, rh.line_total, COALESCE(sv.total, 0)::DOUBLE PRECISION, COALESCE(sv.summary, ''),
+		       t.transaction_method, t.status, t.amount, COUNT(*) OVER ()
+		FROM rental_history rh
+		LEFT JOIN computer c ON rh.computer_id = c.id
+		LEFT JOIN admin a ON rh.admin_id = a.id
+		LEFT JOIN receipt r ON r.rental_history_id = rh.id
+		LEFT JOIN transaction t ON t.id = r.transaction_id
+		LEFT JOIN LATERAL (
+			SELECT SUM(rs.line_total) AS total, string_agg(s.name || ' x' || rs.quantity, ', ' ORDER BY rs.id) AS summary
+			FROM rental_services rs
+			JOIN service s ON s.id = rs.service_id
+			WHERE rs.rental_history_id = rh.id
+		) sv ON TRUE
+		WHERE rh.customer_id = $1
+		  AND ($2::DATE IS NULL OR rh.rental_start_time >= $2::DATE)
+		  AND ($3::DATE IS NULL OR rh.rental_start_time < $3::DATE + 1)
+		  AND ($4 = '' OR rh.booking_status ILIKE $4)
+		  AND ($5 = '' OR t.status ILIKE $5)
+		ORDER BY rh.rental_start_time DESC, rh.id DESC
+		LIMIT $6 OFFSET $7
+	`
+
+// scanBookingReport reads a row of bookingReportQuery, with the services summary and total row count
+func scanBookingReport(rows pgx.Rows, report *BookingReport, servicesSummary *string, total *int) error {
+	return rows.Scan(
+		&report.RentalID, &report.ComputerID, &report.ComputerName, &report.AdminID,
+		&report.AdminUsername, &report.RentalStart, &report.RentalEnd, &report.TotalCost,
+		&report.BookingStatus, &report.TimeTotal, &report.ServicesTotal, servicesSummary,
+		&report.PaymentMethod, &report.PaymentStatus, &report.AmountPaid, total,
+	)
 }
 
 // GetBookingReport godoc
 // @Summary Get booking report
-// @Description Retrieve a customer's booking history, including details of rentals and costs. Optionally fetch only the most recent booking.
+// @Description Retrieve a customer's booking history, including rentals, costs, payment and the services bought during each rental. Optionally fetch only the most recent booking.
 // @Tags Reports
 // @Accept json
 // @Produce json
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
 // @Param recent query string false "If set to 'true', fetches only the most recent booking"
+// @Param start_date query string false "Rentals starting on or after this date (YYYY-MM-DD)"
+// @Param end_date query string false "Rentals starting on or before this date (YYYY-MM-DD)"
+// @Param status query string false "Booking status, such as Completed or settlement"
+// @Param payment_status query string false "Payment status, such as settlement or pending"
+// @Param limit query int false "Number of rentals (default 20, up to 100; exports are not limited unless set)"
+// @Param offset query int false "Rentals to skip"
+// @Param format query string false "json, csv or xlsx (or use the Accept header)"
+// @Param lang query string false "Language of export column headers (en or id)"
 // @Success 200 {object} map[string]interface{} "Booking report retrieved successfully"
+// @Failure 400 {object} map[string]string "Invalid parameters"
 // @Failure 500 {object} map[string]string "Failed to fetch or process booking report data"
 // @Security BearerAuth
 // @Router /booking-report [get]
@@ -41,49 +110,77 @@ func GetBookingReport(c echo.Context) error {
 	claims := user.Claims.(jwt.MapClaims)
 	customerID := int(claims["customer_id"].(float64))
 
-	// check for the optional "recent" query parameter
-	recent := c.QueryParam("recent")
+	format, err := export_handler.RequestedFormat(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
 
-	// Query rental history for the customer
-	query := `
-		SELECT rh.id AS rental_id, rh.computer_id, c.name AS computer_name, rh.admin_id,
-		       a.username AS admin_username, rh.rental_start_time, rh.rental_end_time, rh.total_cost
-		FROM rental_history rh
-		LEFT JOIN computer c ON rh.computer_id = c.id
-		LEFT JOIN admin a ON rh.admin_id = a.id
-		WHERE rh.customer_id = $1
-	`
+	var startDate, endDate *time.Time
+	for param, target := range map[string]**time.Time{"start_date": &startDate, "end_date": &endDate} {
+		if value := c.QueryParam(param); value != "" {
+			parsed, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid " + param + ", use YYYY-MM-DD"})
+			}
+			*target = &parsed
+		}
+	}
 
-	// Modify the query if the "recent" parameter is provided
-	if recent == "true" {
-		query += `
-			ORDER BY rh.rental_start_time DESC
-			LIMIT 1
-		`
-	} else {
-		query += `
-			ORDER BY rh.rental_start_time DESC
-		`
+	// Exports stream the whole history unless limited; NULL is no limit
+	var limit *int
+	if format == export_handler.FormatJSON {
+		defaultLimit := 20
+		limit = &defaultLimit
+	}
+	offset := 0
+	if value := c.QueryParam("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || (format == export_handler.FormatJSON && parsed > 100) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "limit must be between 1 and 100"})
+		}
+		limit = &parsed
+	}
+	if value := c.QueryParam("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid offset"})
+		}
+		offset = parsed
 	}
 
-	rows, err := config.Pool.Query(context.Background(), query, customerID)
+	// The "recent" parameter only fetches the most recent booking
+	if c.QueryParam("recent") == "true" {
+		one := 1
+		limit, offset = &one, 0
+	}
+
+	rows, err := config.Pool.Query(context.Background(), bookingReportQuery, customerID, startDate, endDate,
+		c.QueryParam("status"), c.QueryParam("payment_status"), limit, offset)
 	if err != nil {
 		fmt.Printf("Query error: %v\n", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch booking report"})
 	}
 	defer rows.Close()
 
+	if format != export_handler.FormatJSON {
+		return exportBookingReport(c, format, rows)
+	}
+
 	// Populate the booking report
-	var reports []BookingReport
+	reports := []BookingReport{}
+	index := map[int]int{}
+	var rentalIDs []int
+	total := 0
 	for rows.Next() {
 		var report BookingReport
-		if err := rows.Scan(
-			&report.RentalID, &report.ComputerID, &report.ComputerName, &report.AdminID,
-			&report.AdminUsername, &report.RentalStart, &report.RentalEnd, &report.TotalCost,
-		); err != nil {
+		var servicesSummary string
+		if err := scanBookingReport(rows, &report, &servicesSummary, &total); err != nil {
 			fmt.Printf("Scan error: %v\n", err)
 			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to process booking report data"})
 		}
+		report.Services = []BookingService{}
+		index[report.RentalID] = len(reports)
+		rentalIDs = append(rentalIDs, report.RentalID)
 		reports = append(reports, report)
 	}
 
@@ -92,10 +189,86 @@ func GetBookingReport(c echo.Context) error {
 		fmt.Printf("Row iteration error: %v\n", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to process booking report data"})
 	}
+	rows.Close()
+
+	// Attach the service lines of the rentals on this page
+	if len(rentalIDs) > 0 {
+		serviceQuery := `
+			SELECT rs.rental_history_id, rs.service_id, s.name, rs.quantity, rs.unit_price, rs.discount, rs.line_total
+			FROM rental_services rs
+			JOIN service s ON s.id = rs.service_id
+			WHERE rs.rental_history_id = ANY($1)
+			ORDER BY rs.rental_history_id, rs.id
+		`
+		serviceRows, err := config.Pool.Query(context.Background(), serviceQuery, rentalIDs)
+		if err != nil {
+			fmt.Printf("Query error: %v\n", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch booking services"})
+		}
+		defer serviceRows.Close()
+		for serviceRows.Next() {
+			var rentalID int
+			var service BookingService
+			if err := serviceRows.Scan(&rentalID, &service.ServiceID, &service.ServiceName, &service.Quantity,
+				&service.UnitPrice, &service.Discount, &service.LineTotal); err != nil {
+				fmt.Printf("Scan error: %v\n", err)
+				return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to process booking services"})
+			}
+			report := &reports[index[rentalID]]
+			report.Services = append(report.Services, service)
+		}
+		if err := serviceRows.Err(); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to process booking services"})
+		}
+	}
 
 	// Return the booking report
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"message": "Booking report retrieved successfully",
 		"data":    reports,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
 	})
-}
\ No newline at end of file
+}
+
+// bookingReportColumns are the columns of a booking report export
+var bookingReportColumns = []export_handler.Column{
+	{English: "Rental ID", Indonesian: "ID Sewa", Kind: export_handler.KindNumber},
+	{English: "Computer ID", Indonesian: "ID Komputer", Kind: export_handler.KindNumber},
+	{English: "Computer", Indonesian: "Komputer", Kind: export_handler.KindText},
+	{English: "Admin", Indonesian: "Admin", Kind: export_handler.KindText},
+	{English: "Start", Indonesian: "Mulai", Kind: export_handler.KindDate},
+	{English: "End", Indonesian: "Selesai", Kind: export_handler.KindDate},
+	{English: "Total Cost", Indonesian: "Total Biaya", Kind: export_handler.KindCurrency},
+	{English: "Status", Indonesian: "Status", Kind: export_handler.KindText},
+	{English: "Services", Indonesian: "Layanan", Kind: export_handler.KindText},
+	{English: "Services Total", Indonesian: "Total Layanan", Kind: export_handler.KindCurrency},
+	{English: "Payment Method", Indonesian: "Metode Pembayaran", Kind: export_handler.KindText},
+	{English: "Payment Status", Indonesian: "Status Pembayaran", Kind: export_handler.KindText},
+}
+
+// exportBookingReport streams booking report rows into a spreadsheet as they are read
+func exportBookingReport(c echo.Context, format string, rows pgx.Rows) error {
+	w, err := export_handler.NewWriter(c, format, "booking-report", bookingReportColumns)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+	for rows.Next() {
+		var report BookingReport
+		var servicesSummary string
+		var total int
+		if err := scanBookingReport(rows, &report, &servicesSummary, &total); err != nil {
+			return fmt.Errorf("failed to process booking report data: %w", err)
+		}
+		if err := w.WriteRow(report.RentalID, report.ComputerID, report.ComputerName, report.AdminUsername,
+			report.RentalStart, report.RentalEnd, report.TotalCost, report.BookingStatus, servicesSummary,
+			report.ServicesTotal, report.PaymentMethod, report.PaymentStatus); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to process booking report data: %w", err)
+	}
+	return w.Close()
+}
@@ -77,6 +77,65 @@ func TestGetBookingReport(t *testing.T) {
 	}
 }
 
+func TestGetBookingReportFilters(t *testing.T) {
+	setupTestData()
+	e := echo.New()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"customer_id": float64(1),
+	})
+
+	// Rentals without an admin are listed instead of failing the scan
+	req := httptest.NewRequest(http.MethodGet, "/booking-report?start_date=2024-12-10&end_date=2024-12-10", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user", token)
+
+	if assert.NoError(t, GetBookingReport(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response struct {
+			Data  []BookingReport `json:"data"`
+			Total int             `json:"total"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		if assert.Len(t, response.Data, 1) {
+			assert.Equal(t, 3, response.Data[0].RentalID)
+			assert.Nil(t, response.Data[0].AdminID)
+			assert.Nil(t, response.Data[0].AdminUsername)
+			assert.Equal(t, "Completed", *response.Data[0].BookingStatus)
+		}
+		assert.Equal(t, 1, response.Total)
+	}
+
+	// Service lines are embedded with their totals
+	req = httptest.NewRequest(http.MethodGet, "/booking-report?start_date=2024-12-18&end_date=2024-12-18&limit=5", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.Set("user", token)
+
+	if assert.NoError(t, GetBookingReport(c)) {
+		var response struct {
+			Data []BookingReport `json:"data"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		for _, report := range response.Data {
+			total := 0.0
+			for _, service := range report.Services {
+				total += service.LineTotal
+			}
+			assert.Equal(t, report.ServicesTotal, total)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/booking-report?start_date=18-12-2024", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.Set("user", token)
+	if assert.NoError(t, GetBookingReport(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+}
+
 // setupTestData sets up the database for testing
 func setupTestData() {
 	// Create mock data in the database
@@ -99,9 +158,16 @@ func setupTestData() {
 	`)
 
 	_, _ = config.Pool.Exec(context.Background(), `
-		INSERT INTO rental_history (id, customer_id, computer_id, admin_id, rental_start_time, rental_end_time, total_cost)
-		VALUES (1, 1, 1, 1, '2024-12-18 10:00:00', '2024-12-18 12:00:00', 40000),
-		       (2, 1, 1, 1, '2024-12-17 09:00:00', '2024-12-17 11:00:00', 30000)
+		INSERT INTO rental_history (id, customer_id, computer_id, admin_id, rental_start_time, rental_end_time, total_cost, unit_price, line_total)
+		VALUES (1, 1, 1, 1, '2024-12-18 10:00:00', '2024-12-18 12:00:00', 40000, 20000, 40000),
+		       (2, 1, 1, 1, '2024-12-17 09:00:00', '2024-12-17 11:00:00', 30000, 15000, 30000)
+		ON CONFLICT DO NOTHING
+	`)
+
+	// A rental recorded without an admin
+	_, _ = config.Pool.Exec(context.Background(), `
+		INSERT INTO rental_history (id, customer_id, computer_id, admin_id, rental_start_time, rental_end_time, total_cost, booking_status, unit_price, line_total)
+		VALUES (3, 1, 1, NULL, '2024-12-10 09:00:00', '2024-12-10 10:00:00', 20000, 'Completed', 20000, 20000)
 		ON CONFLICT DO NOTHING
 	`)
 }
@@ -0,0 +1,509 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+	audit_handler "w4/p2/milestones/internal/auditHandler"
+	inventory_handler "w4/p2/milestones/internal/inventoryHandler"
+	time_package_handler "w4/p2/milestones/internal/timePackageHandler"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// Service product types
+const (
+	ProductItem = "item"
+)
+
+// Service is a catalog entry with its stock
+type Service struct {
+	ID                  int       `json:"id"`
+	Name                string    `json:"name"`
+	Price               float64   `json:"price"`
+	Description         string    `json:"description"`
+	Quantity            int       `json:"quantity"`
+	Category            string    `json:"category"`
+	ProductType         string    `json:"product_type"`
+	PackageMinutes      *int      `json:"package_minutes,omitempty"`
+	PackageComputerType *string   `json:"package_computer_type,omitempty"`
+	PackageValidityDays *int      `json:"package_validity_days,omitempty"`
+	ReorderThreshold    *int      `json:"reorder_threshold"`
+	LossCharge          *float64  `json:"loss_charge,omitempty"`
+	IsActive            bool      `json:"is_active"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+// MenuItem is a service as customers see it
+type MenuItem struct {
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	Price       float64 `json:"price"`
+	Description string  `json:"description"`
+	Category    string  `json:"category"`
+	ProductType string  `json:"product_type"`
+	InStock     bool    `json:"in_stock"`
+}
+
+// ServiceCatalogRequest defines the payload to create or edit a service
+type ServiceCatalogRequest struct {
+	Name                string   `json:"name" validate:"required"`
+	Price               float64  `json:"price" validate:"required"`
+	Description         string   `json:"description"`
+	Category            string   `json:"category"`     // defaults to service
+	ProductType         string   `json:"product_type"` // item (default), time_package or loan
+	PackageMinutes      *int     `json:"package_minutes"`
+	PackageComputerType *string  `json:"package_computer_type"`
+	PackageValidityDays *int     `json:"package_validity_days"`
+	ReorderThreshold    *int     `json:"reorder_threshold"` // stock level that notifies admins, none if omitted
+	LossCharge          *float64 `json:"loss_charge"`       // loan only, charged per item lost
+	InitialStock        int      `json:"initial_stock"`     // create only, recorded as a restock
+	IsActive            *bool    `json:"is_active"`
+}
+
+// StockRequest defines the payload to restock or write off a service
+type StockRequest struct {
+	Quantity int    `json:"quantity" validate:"required"`
+	Reason   string `json:"reason" validate:"required"`
+}
+
+// normalize fills in defaults and checks the fields shared by create and edit
+func (req *ServiceCatalogRequest) normalize() string {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Category = strings.TrimSpace(req.Category)
+	if req.Category == "" {
+		req.Category = "service"
+	}
+	if req.ProductType == "" {
+		req.ProductType = ProductItem
+	}
+
+	switch {
+	case req.Name == "":
+		return "Service name is required"
+	case req.Price <= 0:
+		return "Price must be greater than zero"
+	case len(req.Description) > 250:
+		return "Description cannot exceed 250 characters"
+	case req.ProductType != ProductItem && req.ProductType != time_package_handler.ProductTimePackage && req.ProductType != inventory_handler.ProductLoan:
+		return "Product type must be item, time_package or loan"
+	case req.ProductType == time_package_handler.ProductTimePackage && (req.PackageMinutes == nil || *req.PackageMinutes <= 0):
+		return "Time packages need package_minutes greater than zero"
+	case req.PackageValidityDays != nil && *req.PackageValidityDays <= 0:
+		return "Package validity must be greater than zero days"
+	case req.LossCharge != nil && *req.LossCharge < 0:
+		return "Loss charge cannot be negative"
+	case req.ReorderThreshold != nil && *req.ReorderThreshold < 0:
+		return "Reorder threshold cannot be negative"
+	case req.InitialStock < 0:
+		return "Initial stock cannot be negative"
+	}
+
+	// Only time packages carry package fields and only loans a loss charge
+	if req.ProductType != time_package_handler.ProductTimePackage {
+		req.PackageMinutes, req.PackageComputerType, req.PackageValidityDays = nil, nil, nil
+	}
+	if req.ProductType != inventory_handler.ProductLoan {
+		req.LossCharge = nil
+	}
+	return ""
+}
+
+// requireAdmin returns the admin ID and role if the JWT belongs to an admin or super-admin
+func requireAdmin(c echo.Context) (int, string, bool) {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	adminRole, _ := claims["role"].(string)
+	adminID, _ := claims["admin_id"].(float64)
+	return int(adminID), adminRole, adminRole == "admin" || adminRole == "super-admin"
+}
+
+const serviceColumns = `id, name, price, COALESCE(description, ''), quantity, category, product_type,
+	package_minutes, package_computer_type, package_validity_days, reorder_threshold, loss_charge, is_active, created_at`
+
+// loadService fetches one catalog entry
+func loadService(ctx context.Context, db config.DBTX, serviceID int) (Service, error) {
+	var s Service
+	err := db.QueryRow(ctx, `SELECT `+serviceColumns+` FROM service WHERE id = $1`, serviceID).Scan(
+		&s.ID, &s.Name, &s.Price, &s.Description, &s.Quantity, &s.Category, &s.ProductType,
+		&s.PackageMinutes, &s.PackageComputerType, &s.PackageValidityDays, &s.ReorderThreshold, &s.LossCharge, &s.IsActive, &s.CreatedAt,
+	)
+	return s, err
+}
+
+// GetServiceMenu godoc
+// @Summary Get the service menu
+// @Description List the services on sale with price, description and whether they are in stock
+// @Tags Services
+// @Produce json
+// @Param category query string false "Only services in this category"
+// @Success 200 {object} map[string]interface{} "Services retrieved successfully"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /customer/services [get]
+func GetServiceMenu(c echo.Context) error {
+	query := `
+		SELECT id, name, price, COALESCE(description, ''), category, product_type, quantity > 0
+		FROM service
+		WHERE is_active = TRUE AND ($1 = '' OR LOWER(category) = LOWER($1))
+		ORDER BY category, name`
+	rows, err := config.Pool.Query(context.Background(), query, c.QueryParam("category"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch services"})
+	}
+	defer rows.Close()
+
+	menu := []MenuItem{}
+	for rows.Next() {
+		var item MenuItem
+		if err := rows.Scan(&item.ID, &item.Name, &item.Price, &item.Description, &item.Category, &item.ProductType, &item.InStock); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to parse services"})
+		}
+		menu = append(menu, item)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Services retrieved successfully",
+		"data":    menu,
+	})
+}
+
+// GetServices godoc
+// @Summary List the service catalog
+// @Description Retrieve every service with its stock, including deactivated ones
+// @Tags Services
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Services retrieved successfully"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/services [get]
+func GetServices(c echo.Context) error {
+	if _, _, ok := requireAdmin(c); !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only selected admins can perform this action."})
+	}
+
+	rows, err := config.Pool.Query(context.Background(), `SELECT `+serviceColumns+` FROM service ORDER BY id`)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch services"})
+	}
+	defer rows.Close()
+
+	services := []Service{}
+	for rows.Next() {
+		var s Service
+		if err := rows.Scan(&s.ID, &s.Name, &s.Price, &s.Description, &s.Quantity, &s.Category, &s.ProductType,
+			&s.PackageMinutes, &s.PackageComputerType, &s.PackageValidityDays, &s.ReorderThreshold, &s.LossCharge, &s.IsActive, &s.CreatedAt); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to parse services"})
+		}
+		services = append(services, s)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Services retrieved successfully",
+		"data":    services,
+	})
+}
+
+// CreateService godoc
+// @Summary Create a service
+// @Description Add an item or time package to the catalog. Initial stock is recorded as a restock.
+// @Tags Services
+// @Accept json
+// @Produce json
+// @Param request body ServiceCatalogRequest true "Service"
+// @Success 200 {object} Service "Created service"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/services [post]
+func CreateService(c echo.Context) error {
+	adminID, role, ok := requireAdmin(c)
+	if !ok || role != "super-admin" {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can manage the service catalog."})
+	}
+
+	var req ServiceCatalogRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+	if msg := req.normalize(); msg != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": msg})
+	}
+
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	var serviceID int
+	query := `
+		INSERT INTO service (name, price, description, quantity, category, product_type, package_minutes, package_computer_type, package_validity_days, reorder_threshold, loss_charge, is_active)
+		VALUES ($1, $2, $3, 0, $4, $5, $6, $7, $8, $9, $10, COALESCE($11, TRUE)) RETURNING id`
+	err = tx.QueryRow(ctx, query, req.Name, req.Price, req.Description, req.Category, req.ProductType,
+		req.PackageMinutes, req.PackageComputerType, req.PackageValidityDays, req.ReorderThreshold, req.LossCharge, req.IsActive).Scan(&serviceID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create service"})
+	}
+
+	if req.InitialStock > 0 {
+		if _, err := inventory_handler.MoveStock(ctx, tx, serviceID, inventory_handler.MovementRestock, req.InitialStock, "Initial stock", nil, &adminID); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to record initial stock"})
+		}
+	}
+
+	service, err := loadService(ctx, tx, serviceID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch service"})
+	}
+
+	event := audit_handler.FromRequest(c, "service.create", "service", serviceID).Change(nil, service).
+		Describe("Super-admin (ID: %d) created service %d (%s) at %.0f", adminID, serviceID, req.Name, req.Price)
+	if err := audit_handler.Record(ctx, tx, event); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log admin action"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to save service"})
+	}
+
+	return c.JSON(http.StatusOK, service)
+}
+
+// UpdateService godoc
+// @Summary Edit a service
+// @Description Change a service's name, price, description, category, package details, reorder threshold or active state. Stock is changed through restock and write-off.
+// @Tags Services
+// @Accept json
+// @Produce json
+// @Param id path int true "Service ID"
+// @Param request body ServiceCatalogRequest true "Service"
+// @Success 200 {object} Service "Updated service"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Service not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/services/{id} [put]
+func UpdateService(c echo.Context) error {
+	adminID, role, ok := requireAdmin(c)
+	if !ok || role != "super-admin" {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can manage the service catalog."})
+	}
+
+	serviceID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid service ID"})
+	}
+
+	var req ServiceCatalogRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+	if msg := req.normalize(); msg != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": msg})
+	}
+
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := loadService(ctx, tx, serviceID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Service not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch service"})
+	}
+
+	query := `
+		UPDATE service
+		SET name = $1, price = $2, description = $3, category = $4, product_type = $5, package_minutes = $6,
+		    package_computer_type = $7, package_validity_days = $8, reorder_threshold = $9, loss_charge = $10, is_active = COALESCE($11, is_active)
+		WHERE id = $12`
+	_, err = tx.Exec(ctx, query, req.Name, req.Price, req.Description, req.Category, req.ProductType,
+		req.PackageMinutes, req.PackageComputerType, req.PackageValidityDays, req.ReorderThreshold, req.LossCharge, req.IsActive, serviceID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update service"})
+	}
+
+	service, err := loadService(ctx, tx, serviceID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch service"})
+	}
+
+	event := audit_handler.FromRequest(c, "service.update", "service", serviceID).Change(before, service).
+		Describe("Super-admin (ID: %d) updated service %d (%s) at %.0f", adminID, serviceID, req.Name, req.Price)
+	if err := audit_handler.Record(ctx, tx, event); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log admin action"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update service"})
+	}
+	return c.JSON(http.StatusOK, service)
+}
+
+// DeactivateService godoc
+// @Summary Deactivate a service
+// @Description Take a service off sale. It stays in the catalog and in past sales, and can be reactivated by editing it.
+// @Tags Services
+// @Produce json
+// @Param id path int true "Service ID"
+// @Success 200 {object} map[string]string "Service deactivated"
+// @Failure 400 {object} map[string]string "Invalid service ID"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Service not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/services/{id} [delete]
+func DeactivateService(c echo.Context) error {
+	adminID, role, ok := requireAdmin(c)
+	if !ok || role != "super-admin" {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can manage the service catalog."})
+	}
+
+	serviceID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid service ID"})
+	}
+
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	var wasActive bool
+	err = tx.QueryRow(ctx, `SELECT is_active FROM service WHERE id = $1 FOR UPDATE`, serviceID).Scan(&wasActive)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Service not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to deactivate service"})
+	}
+	if _, err := tx.Exec(ctx, `UPDATE service SET is_active = FALSE WHERE id = $1`, serviceID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to deactivate service"})
+	}
+
+	event := audit_handler.FromRequest(c, "service.deactivate", "service", serviceID).
+		Change(map[string]bool{"is_active": wasActive}, map[string]bool{"is_active": false}).
+		Describe("Super-admin (ID: %d) deactivated service %d", adminID, serviceID)
+	if err := audit_handler.Record(ctx, tx, event); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log admin action"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to deactivate service"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Service deactivated successfully"})
+}
+
+// RestockService godoc
+// @Summary Restock a service
+// @Description Add stock to a service with a reason, recorded as a restock in the inventory ledger
+// @Tags Services
+// @Accept json
+// @Produce json
+// @Param id path int true "Service ID"
+// @Param request body StockRequest true "Quantity and reason"
+// @Success 200 {object} inventory_handler.Movement "Inventory movement"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Service not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/services/{id}/restock [post]
+func RestockService(c echo.Context) error {
+	return adjustStockRequest(c, inventory_handler.MovementRestock)
+}
+
+// WriteOffService godoc
+// @Summary Write off service stock
+// @Description Remove damaged, expired or lost stock from a service with a reason, recorded as an adjustment in the inventory ledger. Stock cannot go below zero.
+// @Tags Services
+// @Accept json
+// @Produce json
+// @Param id path int true "Service ID"
+// @Param request body StockRequest true "Quantity and reason"
+// @Success 200 {object} inventory_handler.Movement "Inventory movement"
+// @Failure 400 {object} map[string]string "Invalid request or not enough stock"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Service not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/services/{id}/write-off [post]
+func WriteOffService(c echo.Context) error {
+	return adjustStockRequest(c, inventory_handler.MovementAdjustment)
+}
+
+// adjustStockRequest handles restock and write-off requests
+func adjustStockRequest(c echo.Context, movementType string) error {
+	adminID, _, ok := requireAdmin(c)
+	if !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only selected admins can perform this action."})
+	}
+
+	serviceID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid service ID"})
+	}
+
+	var req StockRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Quantity <= 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Quantity must be greater than zero"})
+	}
+	if req.Reason == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "A reason is required"})
+	}
+
+	quantity := req.Quantity
+	if movementType == inventory_handler.MovementAdjustment {
+		quantity = -quantity
+	}
+
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	movement, err := inventory_handler.MoveStock(ctx, tx, serviceID, movementType, quantity, req.Reason, nil, &adminID)
+	var stockErr *inventory_handler.StockError
+	if errors.As(err, &stockErr) {
+		// Either the service does not exist or a write-off exceeds the stock
+		if _, loadErr := loadService(ctx, tx, serviceID); errors.Is(loadErr, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"message": "Service not found"})
+		}
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Cannot write off more than the stock"})
+	} else if err != nil {
+		fmt.Println("Stock error:", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to adjust stock"})
+	}
+
+	event := audit_handler.FromRequest(c, "service."+movementType, "service", serviceID).Change(nil, movement).
+		Describe("Admin (ID: %d) recorded %s of %d for service %d: %s", adminID, movementType, quantity, serviceID, req.Reason)
+	if err := audit_handler.Record(ctx, tx, event); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log admin action"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to save stock movement"})
+	}
+
+	return c.JSON(http.StatusOK, movement)
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetServiceMenu(t *testing.T) {
+	// Setup Echo, the menu needs no login
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/customer/services?category=food", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := GetServiceMenu(c)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response struct {
+			Message string     `json:"message"`
+			Data    []MenuItem `json:"data"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.Equal(t, "Services retrieved successfully", response.Message)
+		for _, item := range response.Data {
+			assert.Equal(t, "food", item.Category)
+		}
+	}
+}
+
+func TestServiceCatalogRequestNormalize(t *testing.T) {
+	// Defaults fill in the category and product type
+	req := ServiceCatalogRequest{Name: " Stapling ", Price: 1000}
+	assert.Empty(t, req.normalize())
+	assert.Equal(t, "Stapling", req.Name)
+	assert.Equal(t, "service", req.Category)
+	assert.Equal(t, ProductItem, req.ProductType)
+
+	// Time packages need their minutes
+	req = ServiceCatalogRequest{Name: "Night Pass", Price: 50000, ProductType: "time_package"}
+	assert.Equal(t, "Time packages need package_minutes greater than zero", req.normalize())
+
+	// Items drop package fields
+	minutes := 60
+	req = ServiceCatalogRequest{Name: "Sticker", Price: 2000, PackageMinutes: &minutes}
+	assert.Empty(t, req.normalize())
+	assert.Nil(t, req.PackageMinutes)
+}
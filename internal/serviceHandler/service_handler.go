@@ -2,13 +2,23 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"time"
 
 	"os"
 
 	config "w4/p2/milestones/config/database"
+	audit_handler "w4/p2/milestones/internal/auditHandler"
+	receipt_handler "w4/p2/milestones/internal/receiptHandler"
+	shift_handler "w4/p2/milestones/internal/shiftHandler"
+	loyalty_handler "w4/p2/milestones/internal/loyaltyHandler"
+	inventory_handler "w4/p2/milestones/internal/inventoryHandler"
+	membership_handler "w4/p2/milestones/internal/membershipHandler"
+	time_package_handler "w4/p2/milestones/internal/timePackageHandler"
+	voucher_handler "w4/p2/milestones/internal/voucherHandler"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/labstack/echo/v4"
@@ -33,12 +43,14 @@ type ServiceRequest struct {
         ServiceID int `json:"service_id"`
         Quantity  int `json:"quantity"`
     } `json:"services"`
-    PaymentMethod string `json:"payment_method"`
+    PaymentMethod string `json:"payment_method"` // "wallet", "gopay" or "cash"
+    VoucherCode   string `json:"voucher_code"`
+    LoyaltyRewardID int  `json:"loyalty_reward_id"` // reward to redeem points for
 }
 
 // PurchaseService godoc
 // @Summary Purchase services
-// @Description Allows customers to purchase services using either wallet or GoPay as the payment method. The endpoint validates admin roles.
+// @Description Allows customers to purchase services using wallet, GoPay or cash (requires an open shift) as the payment method. The total includes membership hours, the membership, voucher and loyalty reward discounts and the active tax rules. Stock is taken before wallet and cash payments, and reserved for GoPay orders until they settle or the reservation expires. Settled purchases earn loyalty points. The endpoint validates admin roles.
 // @Tags Services
 // @Accept json
 // @Produce json
@@ -62,97 +74,231 @@ func PurchaseService(c echo.Context) error {
     user := c.Get("user").(*jwt.Token)
     claims := user.Claims.(jwt.MapClaims)
     adminRole := claims["role"].(string)
+    adminID, _ := claims["admin_id"].(float64)
 
     // Validate admin role
     if adminRole != "admin" && adminRole != "super-admin" {
         return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only selected admins can perform this action."})
     }
 
-    // Calculate total service cost
-    var totalCost float64
-    var metadata []map[string]interface{} // To store service details for deferred deduction
+    // Calculate total service cost, including tax
+    quote, metadata, err := buildServiceQuote(context.Background(), req)
+    if err != nil {
+        return quoteErrorResponse(c, err)
+    }
+    totalCost := quote.TotalCost
 
-    for _, service := range req.Services {
-        var servicePrice float64
-        var availableQuantity int
+    // Take the membership hours, given back if the purchase is not recorded
+    var holds chargeHolds
+    chargesSaved := false
+    if quote.Membership != nil {
+        usageID, consumeErr := membership_handler.ConsumeHours(context.Background(), config.Pool, *quote.Membership)
+        var membershipErr *membership_handler.MembershipError
+        if errors.As(consumeErr, &membershipErr) {
+            return c.JSON(http.StatusConflict, map[string]string{"message": membershipErr.Message})
+        } else if consumeErr != nil {
+            fmt.Println("Membership error:", consumeErr)
+            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to use membership hours"})
+        }
+        holds.usageID = usageID
+        defer func() {
+            if !chargesSaved {
+                membership_handler.CancelUsage(context.Background(), config.Pool, holds.usageID)
+            }
+        }()
+    }
 
-        query := "SELECT price, quantity FROM service WHERE id = $1"
-        err := config.Pool.QueryRow(context.Background(), query, service.ServiceID).Scan(&servicePrice, &availableQuantity)
-        if err != nil {
-            return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid service ID"})
+    // Take one use of the voucher, given back if the purchase is not recorded
+    if quote.Voucher != nil {
+        voucherRedemptionID, reserveErr := voucher_handler.ReserveVoucher(context.Background(), *quote.Voucher, req.CustomerID)
+        var voucherErr *voucher_handler.VoucherError
+        if errors.As(reserveErr, &voucherErr) {
+            return c.JSON(http.StatusConflict, map[string]string{"message": voucherErr.Message})
+        } else if reserveErr != nil {
+            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to reserve voucher"})
         }
+        holds.voucherRedemptionID = voucherRedemptionID
+        defer func() {
+            if !chargesSaved {
+                voucher_handler.CancelReservation(context.Background(), config.Pool, holds.voucherRedemptionID)
+            }
+        }()
+    }
 
-        if service.Quantity > availableQuantity {
-            return c.JSON(http.StatusBadRequest, map[string]string{
-                "message": fmt.Sprintf("Insufficient stock for service ID %d. Available: %d", service.ServiceID, availableQuantity),
-            })
+    // Spend the loyalty points, refunded if the purchase is not recorded
+    if quote.Reward != nil {
+        entryID, redeemErr := loyalty_handler.RedeemPoints(context.Background(), *quote.Reward, req.CustomerID)
+        var loyaltyErr *loyalty_handler.LoyaltyError
+        if errors.As(redeemErr, &loyaltyErr) {
+            return c.JSON(http.StatusConflict, map[string]string{"message": loyaltyErr.Message})
+        } else if redeemErr != nil {
+            fmt.Println("Loyalty error:", redeemErr)
+            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to redeem loyalty points"})
         }
+        holds.redemptionID = entryID
+        defer func() {
+            if !chargesSaved {
+                loyalty_handler.CancelRedemption(context.Background(), config.Pool, holds.redemptionID)
+            }
+        }()
+    }
 
-        totalCost += servicePrice * float64(service.Quantity)
+    // Take the stock of purchases paid now, returned if the purchase is not recorded
+    if req.PaymentMethod == "wallet" || req.PaymentMethod == "cash" {
+        var stockLines []inventory_handler.StockLine
+        for _, service := range req.Services {
+            stockLines = append(stockLines, inventory_handler.StockLine{ServiceID: service.ServiceID, Quantity: service.Quantity})
+        }
+        movementIDs, stockErr := inventory_handler.TakeStock(context.Background(), stockLines, fmt.Sprintf("Service purchase by Customer %d", req.CustomerID))
+        var outOfStock *inventory_handler.StockError
+        if errors.As(stockErr, &outOfStock) {
+            return c.JSON(http.StatusConflict, map[string]string{"message": outOfStock.Message})
+        } else if stockErr != nil {
+            fmt.Println("Stock error:", stockErr)
+            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update service quantity"})
+        }
+        holds.stockMovementIDs = movementIDs
+        defer func() {
+            if !chargesSaved {
+                inventory_handler.ReturnStock(context.Background(), config.Pool, holds.stockMovementIDs, "Purchase not recorded")
+            }
+        }()
+    } else if req.PaymentMethod == "gopay" {
+        // Reserve the stock until the GoPay payment settles, released if the order is not created
+        var stockLines []inventory_handler.StockLine
+        for _, service := range req.Services {
+            stockLines = append(stockLines, inventory_handler.StockLine{ServiceID: service.ServiceID, Quantity: service.Quantity})
+        }
+        reservationIDs, stockErr := inventory_handler.ReserveStock(context.Background(), stockLines, inventory_handler.ReservationTTL)
+        var outOfStock *inventory_handler.StockError
+        if errors.As(stockErr, &outOfStock) {
+            return c.JSON(http.StatusConflict, map[string]string{"message": outOfStock.Message})
+        } else if stockErr != nil {
+            fmt.Println("Stock error:", stockErr)
+            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to reserve service stock"})
+        }
+        holds.reservationIDs = reservationIDs
+        defer func() {
+            if !chargesSaved {
+                inventory_handler.ReleaseReservations(context.Background(), config.Pool, holds.reservationIDs, "Order not created")
+            }
+        }()
+    }
 
-        // Add service details to metadata for deferred deduction
-        metadata = append(metadata, map[string]interface{}{
-            "service_id": service.ServiceID,
-            "quantity":   service.Quantity,
-        })
+    // Everything from the payment to the receipt is one transaction; the holds above are
+    // given back if it does not commit
+    ctx := context.Background()
+    tx, err := config.Pool.Begin(ctx)
+    if err != nil {
+        return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
     }
+    defer tx.Rollback(ctx)
 
-    if req.PaymentMethod == "wallet" {
-        // Deduct wallet balance and update quantities immediately
-        var walletBalance float64
-        walletQuery := "SELECT wallet FROM customer WHERE id = $1"
-        err := config.Pool.QueryRow(context.Background(), walletQuery, req.CustomerID).Scan(&walletBalance)
-        if err != nil {
-            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to retrieve wallet balance"})
+    var receipt receipt_handler.Receipt
+    var pointsEarned int
+    if req.PaymentMethod == "wallet" || req.PaymentMethod == "cash" {
+        var shiftID *int
+        transactionMethod := "Wallet"
+
+        if req.PaymentMethod == "cash" {
+            // Cash is collected at the counter and recorded against the admin's open shift
+            openShiftID, err := shift_handler.GetOpenShiftID(ctx, tx, int(adminID))
+            if errors.Is(err, shift_handler.ErrNoOpenShift) {
+                return c.JSON(http.StatusBadRequest, map[string]string{"message": "Open a shift before accepting cash payments"})
+            } else if err != nil {
+                return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to check open shift"})
+            }
+            shiftID = &openShiftID
+            transactionMethod = "Cash"
+        } else {
+            // Deduct wallet balance and update quantities immediately
+            var walletBalance float64
+            walletQuery := "SELECT wallet FROM customer WHERE id = $1 FOR UPDATE"
+            err = tx.QueryRow(ctx, walletQuery, req.CustomerID).Scan(&walletBalance)
+            if err != nil {
+                return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to retrieve wallet balance"})
+            }
+
+            if walletBalance < totalCost {
+                return c.JSON(http.StatusBadRequest, map[string]string{"message": "Insufficient wallet balance"})
+            }
+
+            deductWalletQuery := "UPDATE customer SET wallet = wallet - $1 WHERE id = $2"
+            _, err = tx.Exec(ctx, deductWalletQuery, totalCost, req.CustomerID)
+            if err != nil {
+                return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to deduct wallet balance"})
+            }
         }
 
-        if walletBalance < totalCost {
-            return c.JSON(http.StatusBadRequest, map[string]string{"message": "Insufficient wallet balance"})
+        // Log transaction first so the service lines can reference it
+        var transactionID int
+        transactionQuery := `
+            INSERT INTO transaction (customer_id, transaction_type, amount, transaction_method, status, transaction_date, shift_id, admin_id)
+            VALUES ($1, 'Service Payment', $2, $3, 'settlement', NOW(), $4, $5) RETURNING id`
+        txnErr := tx.QueryRow(ctx, transactionQuery, req.CustomerID, totalCost, transactionMethod, shiftID, int(adminID)).Scan(&transactionID)
+        if txnErr != nil {
+            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log transaction"})
         }
 
-        deductWalletQuery := "UPDATE customer SET wallet = wallet - $1 WHERE id = $2"
-        _, err = config.Pool.Exec(context.Background(), deductWalletQuery, totalCost, req.CustomerID)
+        // Store the tax and discount charged on the transaction
+        err = saveQuoteCharges(ctx, tx, transactionID, quote, holds)
         if err != nil {
-            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to deduct wallet balance"})
+            fmt.Println("Charges error:", err)
+            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log transaction tax and discounts"})
         }
 
-        // update the quantity of the services and insert into log table
-        for _, service := range req.Services {
-            updateQuantityQuery := "UPDATE service SET quantity = quantity - $1 WHERE id = $2"
-            _, err = config.Pool.Exec(context.Background(), updateQuantityQuery, service.Quantity, service.ServiceID)
-            if err != nil {
-                return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update service quantity"})
-            }
+        // Credit loyalty points for the settled payment
+        pointsEarned, err = loyalty_handler.EarnPoints(ctx, tx, transactionID)
+        if err != nil {
+            fmt.Println("Loyalty error:", err)
+            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to credit loyalty points"})
+        }
 
+        // log the services, their stock was taken before payment
+        for _, line := range quote.Lines {
             // Log the service purchase
-            logDesc := fmt.Sprintf("Customer %d purchased Service ID %d (Quantity: %d)",
-                req.CustomerID, service.ServiceID, service.Quantity)
-            logQuery := `INSERT INTO log (description) VALUES ($1)`
-            _, err = config.Pool.Exec(context.Background(), logQuery, logDesc)
+            event := audit_handler.FromRequest(c, "service.sell", "service", line.ServiceID).
+                Change(nil, map[string]interface{}{
+                    "customer_id": req.CustomerID, "transaction_id": transactionID, "quantity": line.Quantity,
+                    "unit_price": line.UnitPrice, "discount": line.Discount, "line_total": line.Amount - line.Discount,
+                }).
+                Describe("Customer %d purchased Service ID %d (Quantity: %d)", req.CustomerID, line.ServiceID, line.Quantity)
+            err = audit_handler.Record(ctx, tx, event)
             if err != nil {
                 return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log service purchase"})
             }
 
-            // Insert into the rental_services table
+            // Insert into the rental_services table with the prices charged
             rentalServiceQuery := `
-                INSERT INTO rental_services (rental_history_id, service_id, quantity, created_at)
-                VALUES (NULL, $1, $2, NOW())`
-            _, err = config.Pool.Exec(context.Background(), rentalServiceQuery, service.ServiceID, service.Quantity)
+                INSERT INTO rental_services (rental_history_id, service_id, quantity, created_at, transaction_id, unit_price, discount, line_total)
+                VALUES (NULL, $1, $2, NOW(), $3, $4, $5, $6)`
+            _, err = tx.Exec(ctx, rentalServiceQuery, line.ServiceID, line.Quantity, transactionID,
+                line.UnitPrice, line.Discount, line.Amount-line.Discount)
             if err != nil {
                 return c.JSON(http.StatusInternalServerError, map[string]string{
-                    "message": fmt.Sprintf("Failed to log service into rental_services for Service ID %d", service.ServiceID),
+                    "message": fmt.Sprintf("Failed to log service into rental_services for Service ID %d", line.ServiceID),
                 })
             }
         }
 
-        // Log transaction
-        transactionQuery := `
-            INSERT INTO transaction (customer_id, transaction_type, amount, transaction_method, status, transaction_date)
-            VALUES ($1, 'Service Payment', $2, 'Wallet', 'settlement', NOW())`
-        _, txnErr := config.Pool.Exec(context.Background(), transactionQuery, req.CustomerID, totalCost)
-        if txnErr != nil {
-            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log transaction"})
+        // Credit any time packages among the services
+        if _, err := time_package_handler.CreditPackages(ctx, tx, transactionID); err != nil {
+            fmt.Println("Prepaid time error:", err)
+            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to credit time packages"})
+        }
+
+        // Issue the receipt with the service line items
+        receipt, err = receipt_handler.CreateReceipt(ctx, tx, transactionID, nil)
+        if err != nil {
+            fmt.Println("Receipt error:", err)
+            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create receipt"})
         }
+
+        // Charge, service lines and their audit events are kept together or not at all
+        if err := tx.Commit(ctx); err != nil {
+            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to record purchase"})
+        }
+        chargesSaved = true
     } else if req.PaymentMethod == "gopay" {
         // Create GoPay payment
         orderID := fmt.Sprintf("service-%d-%d", req.CustomerID, time.Now().Unix())
@@ -160,7 +306,7 @@ func PurchaseService(c echo.Context) error {
             PaymentType: coreapi.PaymentTypeGopay,
             TransactionDetails: midtrans.TransactionDetails{
                 OrderID:  orderID,
-                GrossAmt: int64(totalCost),
+                GrossAmt: int64(math.Round(totalCost)),
             },
             Gopay: &coreapi.GopayDetails{
                 EnableCallback: true,
@@ -175,24 +321,44 @@ func PurchaseService(c echo.Context) error {
 
         // Log transaction with metadata
         transactionQuery := `
-            INSERT INTO transaction (customer_id, transaction_type, amount, transaction_method, status, payment_url, order_id, metadata)
-            VALUES ($1, 'Service Payment', $2, 'GoPay', 'Pending', $3, $4, $5)`
-        _, txnErr := config.Pool.Exec(context.Background(), transactionQuery, req.CustomerID, totalCost, resp.Actions[0].URL, orderID, metadata)
+            INSERT INTO transaction (customer_id, transaction_type, amount, transaction_method, status, payment_url, order_id, metadata, admin_id)
+            VALUES ($1, 'Service Payment', $2, 'GoPay', 'Pending', $3, $4, $5, $6) RETURNING id`
+        var transactionID int
+        txnErr := tx.QueryRow(ctx, transactionQuery, req.CustomerID, totalCost, resp.Actions[0].URL, orderID, metadata, int(adminID)).Scan(&transactionID)
         if txnErr != nil {
             return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log transaction"})
         }
 
+        // Store the tax and discount quoted for the pending order
+        chargesErr := saveQuoteCharges(ctx, tx, transactionID, quote, holds)
+        if chargesErr != nil {
+            fmt.Println("Charges error:", chargesErr)
+            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log transaction tax and discounts"})
+        }
+        if err := tx.Commit(ctx); err != nil {
+            return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log transaction"})
+        }
+        chargesSaved = true
+
         // Return payment URL to the user
         return c.JSON(http.StatusOK, map[string]interface{}{
             "message":    "Payment initiated",
             "payment_url": resp.Actions[0].URL,
+            "quote":       quote,
         })
     } else {
         return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid payment method"})
     }
 
     return c.JSON(http.StatusOK, map[string]interface{}{
-        "message":    "Services purchased successfully",
-        "total_cost": totalCost,
+        "message":        "Services purchased successfully",
+        "total_cost":     totalCost,
+        "discount":       quote.Discount,
+        "loyalty_discount": quote.LoyaltyDiscount,
+        "membership_discount": quote.MembershipDiscount,
+        "points_earned":  pointsEarned,
+        "tax_total":      quote.Tax.TaxTotal,
+        "receipt_id":     receipt.ID,
+        "invoice_number": receipt.InvoiceNumber,
     })
 }
\ No newline at end of file
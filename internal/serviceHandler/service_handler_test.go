@@ -2,11 +2,14 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	voucher_handler "w4/p2/milestones/internal/voucherHandler"
+
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
@@ -60,4 +63,43 @@ func TestPurchaseService(t *testing.T) {
 		assert.Equal(t, "Services purchased successfully", response["message"])
 		assert.Greater(t, response["total_cost"].(float64), float64(0), "Total cost should be greater than 0")
 	}
-}
\ No newline at end of file
+}
+
+func TestVoucherAfterMembership(t *testing.T) {
+	// A 30% member discount already taken, then a voucher larger than what is left
+	quote := ServiceQuote{Lines: []QuoteLine{
+		{ServiceID: 1, Quantity: 2, UnitPrice: 5000, Amount: 10000, Discount: 3000},
+		{ServiceID: 2, Quantity: 1, UnitPrice: 20000, Amount: 20000, Discount: 6000},
+	}}
+	voucher := voucher_handler.Voucher{Code: "BIG", DiscountType: voucher_handler.DiscountFixed, DiscountValue: 25000}
+
+	applied, err := voucher_handler.ComputeDiscount(voucher, quote.voucherLines())
+	if assert.NoError(t, err) {
+		assert.Equal(t, 21000.0, applied.Discount)
+		for i, line := range quote.Lines {
+			assert.GreaterOrEqual(t, line.Amount-line.Discount-applied.LineDiscounts[i], 0.0)
+		}
+	}
+}
+
+func TestServiceQuoteInvalidQuantity(t *testing.T) {
+	// A zero or negative quantity would refund stock and credit the customer
+	for _, quantity := range []int{0, -3} {
+		req := ServiceRequest{
+			CustomerID: 1,
+			Services: []struct {
+				ServiceID int `json:"service_id"`
+				Quantity  int `json:"quantity"`
+			}{
+				{ServiceID: 1, Quantity: quantity},
+			},
+			PaymentMethod: "wallet",
+		}
+
+		_, _, err := buildServiceQuote(context.Background(), req)
+		var httpErr *echo.HTTPError
+		if assert.ErrorAs(t, err, &httpErr) {
+			assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	config "w4/p2/milestones/config/database"
+	inventory_handler "w4/p2/milestones/internal/inventoryHandler"
+	loyalty_handler "w4/p2/milestones/internal/loyaltyHandler"
+	membership_handler "w4/p2/milestones/internal/membershipHandler"
+	tax_handler "w4/p2/milestones/internal/taxHandler"
+	voucher_handler "w4/p2/milestones/internal/voucherHandler"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// QuoteLine is one priced service line of a quote
+type QuoteLine struct {
+	ServiceID   int     `json:"service_id"`
+	Description string  `json:"description"`
+	Category    string  `json:"category"`
+	Quantity    int     `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+	Amount      float64 `json:"amount"`
+	Discount    float64 `json:"discount"` // membership, voucher and loyalty reward discount
+}
+
+// ServiceQuote is the priced breakdown of a service purchase before payment
+type ServiceQuote struct {
+	Lines              []QuoteLine                           `json:"lines"`
+	Membership         *membership_handler.AppliedMembership `json:"membership,omitempty"`
+	MembershipDiscount float64                               `json:"membership_discount"` // member discount
+	Voucher            *voucher_handler.AppliedVoucher       `json:"voucher,omitempty"`
+	Discount           float64                               `json:"discount"` // voucher discount
+	Reward             *loyalty_handler.AppliedReward        `json:"loyalty_reward,omitempty"`
+	LoyaltyDiscount    float64                               `json:"loyalty_discount"`
+	Tax                tax_handler.TaxBreakdown              `json:"tax"`
+	TotalCost          float64                               `json:"total_cost"` // amount charged, including exclusive tax
+}
+
+// buildServiceQuote prices the requested services, checks their stock, applies the
+// customer's membership, the voucher and loyalty reward if given, and then the active
+// tax rules on the discounted prices. It also returns the service details stored as order metadata for
+// deferred deduction. Errors are *echo.HTTPError carrying the response status.
+func buildServiceQuote(ctx context.Context, req ServiceRequest) (ServiceQuote, []map[string]interface{}, error) {
+	var quote ServiceQuote
+	var metadata []map[string]interface{}
+
+	for _, service := range req.Services {
+		if service.Quantity <= 0 {
+			return quote, nil, echo.NewHTTPError(http.StatusBadRequest,
+				fmt.Sprintf("Invalid quantity for service ID %d", service.ServiceID))
+		}
+
+		var name, category, productType string
+		var servicePrice float64
+		var availableQuantity int
+
+		query := "SELECT name, category, price, quantity, product_type FROM service WHERE id = $1 AND is_active = TRUE"
+		err := config.Pool.QueryRow(ctx, query, service.ServiceID).Scan(&name, &category, &servicePrice, &availableQuantity, &productType)
+		if err != nil {
+			return quote, nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid service ID")
+		}
+
+		// Equipment is lent for a rental session and has to come back when it ends
+		if productType == inventory_handler.ProductLoan {
+			return quote, nil, echo.NewHTTPError(http.StatusBadRequest,
+				fmt.Sprintf("%s is loaned equipment and must be added to a rental", name))
+		}
+
+		if service.Quantity > availableQuantity {
+			return quote, nil, echo.NewHTTPError(http.StatusBadRequest,
+				fmt.Sprintf("Insufficient stock for service ID %d. Available: %d", service.ServiceID, availableQuantity))
+		}
+
+		quote.Lines = append(quote.Lines, QuoteLine{
+			ServiceID:   service.ServiceID,
+			Description: name,
+			Category:    category,
+			Quantity:    service.Quantity,
+			UnitPrice:   servicePrice,
+			Amount:      servicePrice * float64(service.Quantity),
+		})
+
+		// Add service details to metadata for deferred deduction
+		metadata = append(metadata, map[string]interface{}{
+			"service_id": service.ServiceID,
+			"quantity":   service.Quantity,
+		})
+	}
+
+	// Cover included hours and take the member discount first
+	membershipLines := make([]membership_handler.MembershipLine, len(quote.Lines))
+	for i, line := range quote.Lines {
+		membershipLines[i] = membership_handler.MembershipLine{Quantity: float64(line.Quantity), UnitPrice: line.UnitPrice, Amount: line.Amount - line.Discount}
+	}
+	membership, err := membership_handler.ApplyMembership(ctx, config.Pool, req.CustomerID, membershipLines)
+	if err != nil {
+		fmt.Println("Membership error:", err)
+		return quote, nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to apply membership")
+	}
+	if membership != nil {
+		for i := range quote.Lines {
+			quote.Lines[i].Discount = membership.LineDiscounts[i]
+		}
+		quote.Membership = membership
+		quote.MembershipDiscount = membership.Discount
+	}
+
+	// Apply the voucher on what is left to pay
+	if req.VoucherCode != "" {
+		applied, err := voucher_handler.ApplyVoucher(ctx, config.Pool, req.VoucherCode, req.CustomerID, quote.voucherLines())
+		var voucherErr *voucher_handler.VoucherError
+		if errors.As(err, &voucherErr) {
+			return quote, nil, echo.NewHTTPError(http.StatusBadRequest, voucherErr.Message)
+		} else if err != nil {
+			fmt.Println("Voucher error:", err)
+			return quote, nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to apply voucher")
+		}
+
+		for i := range quote.Lines {
+			quote.Lines[i].Discount += applied.LineDiscounts[i]
+		}
+		quote.Voucher = &applied
+		quote.Discount = applied.Discount
+	}
+
+	// Apply the loyalty reward on what is left to pay
+	if req.LoyaltyRewardID != 0 {
+		rewardLines := make([]loyalty_handler.RewardLine, len(quote.Lines))
+		for i, line := range quote.Lines {
+			rewardLines[i] = loyalty_handler.RewardLine{
+				ComputerTime: line.Category == tax_handler.CategoryComputerTime,
+				ServiceID:    line.ServiceID,
+				Quantity:     float64(line.Quantity),
+				UnitPrice:    line.UnitPrice,
+				Amount:       line.Amount - line.Discount,
+			}
+		}
+
+		applied, err := loyalty_handler.ApplyReward(ctx, config.Pool, req.LoyaltyRewardID, req.CustomerID, rewardLines)
+		var loyaltyErr *loyalty_handler.LoyaltyError
+		if errors.As(err, &loyaltyErr) {
+			return quote, nil, echo.NewHTTPError(http.StatusBadRequest, loyaltyErr.Message)
+		} else if err != nil {
+			fmt.Println("Loyalty error:", err)
+			return quote, nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to apply loyalty reward")
+		}
+
+		for i := range quote.Lines {
+			quote.Lines[i].Discount += applied.LineDiscounts[i]
+		}
+		quote.Reward = &applied
+		quote.LoyaltyDiscount = applied.Discount
+	}
+
+	// Apply tax per category
+	var taxable []tax_handler.TaxableLine
+	for _, line := range quote.Lines {
+		taxable = append(taxable, tax_handler.TaxableLine{Category: line.Category, Amount: line.Amount - line.Discount})
+	}
+	tax, err := tax_handler.QuoteTaxes(ctx, config.Pool, taxable)
+	if err != nil {
+		fmt.Println("Tax error:", err)
+		return quote, nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to compute tax")
+	}
+	quote.Tax = tax
+	quote.TotalCost = tax.Total
+
+	// Store the prices charged, recorded in rental_services when the order settles
+	for i, line := range quote.Lines {
+		metadata[i]["unit_price"] = line.UnitPrice
+		metadata[i]["discount"] = line.Discount
+		metadata[i]["line_total"] = line.Amount - line.Discount
+	}
+
+	return quote, metadata, nil
+}
+
+// voucherLines are the lines of the quote as a voucher sees them: what is left to pay on
+// each after the membership discount, so the voucher cannot take a line below zero
+func (q ServiceQuote) voucherLines() []voucher_handler.DiscountableLine {
+	discountable := make([]voucher_handler.DiscountableLine, len(q.Lines))
+	for i, line := range q.Lines {
+		discountable[i] = voucher_handler.DiscountableLine{ServiceID: line.ServiceID, Amount: line.Amount - line.Discount}
+	}
+	return discountable
+}
+
+// chargeHolds are the voucher use, loyalty points, membership hours and stock taken or reserved before payment
+type chargeHolds struct {
+	voucherRedemptionID int
+	redemptionID        int
+	usageID             int
+	stockMovementIDs    []int
+	reservationIDs      []int
+}
+
+// saveQuoteCharges stores the tax, discounts, points redemption, membership usage and
+// stock taken or reserved for a quote on its transaction
+func saveQuoteCharges(ctx context.Context, db config.DBTX, transactionID int, quote ServiceQuote, holds chargeHolds) error {
+	if err := tax_handler.SaveTransactionTaxes(ctx, db, transactionID, quote.Tax); err != nil {
+		return err
+	}
+	if quote.Voucher != nil {
+		if err := voucher_handler.RecordRedemption(ctx, db, holds.voucherRedemptionID, *quote.Voucher, transactionID); err != nil {
+			return err
+		}
+	}
+	if quote.Reward != nil {
+		if err := loyalty_handler.LinkRedemption(ctx, db, holds.redemptionID, *quote.Reward, transactionID); err != nil {
+			return err
+		}
+	}
+	if quote.Membership != nil {
+		if err := membership_handler.LinkUsage(ctx, db, holds.usageID, *quote.Membership, transactionID); err != nil {
+			return err
+		}
+	}
+	if len(holds.stockMovementIDs) > 0 {
+		if err := inventory_handler.LinkMovements(ctx, db, holds.stockMovementIDs, transactionID); err != nil {
+			return err
+		}
+	}
+	if len(holds.reservationIDs) > 0 {
+		return inventory_handler.LinkReservations(ctx, db, holds.reservationIDs, transactionID)
+	}
+	return nil
+}
+
+// quoteErrorResponse writes an error returned by buildServiceQuote
+func quoteErrorResponse(c echo.Context, err error) error {
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return c.JSON(httpErr.Code, map[string]string{"message": fmt.Sprint(httpErr.Message)})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to quote services"})
+}
+
+// QuoteService godoc
+// @Summary Quote a service purchase
+// @Description Price services, including membership hours and the membership, voucher and loyalty reward discounts, tax and service charge, without charging the customer
+// @Tags Services
+// @Accept json
+// @Produce json
+// @Param request body ServiceRequest true "Request Body"
+// @Success 200 {object} ServiceQuote "Service quote"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/service/quote [post]
+func QuoteService(c echo.Context) error {
+	var req ServiceRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	// Extract admin role from JWT claims
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	adminRole, _ := claims["role"].(string)
+
+	// Validate admin role
+	if adminRole != "admin" && adminRole != "super-admin" {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only selected admins can perform this action."})
+	}
+
+	quote, _, err := buildServiceQuote(context.Background(), req)
+	if err != nil {
+		return quoteErrorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, quote)
+}
@@ -0,0 +1,338 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+	audit_handler "w4/p2/milestones/internal/auditHandler"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// ErrNoOpenShift is returned when a cash payment is taken without an open shift
+var ErrNoOpenShift = errors.New("no open shift for admin")
+
+// OpenShiftRequest defines the payload to open a counter shift
+type OpenShiftRequest struct {
+	OpeningFloat float64 `json:"opening_float"`
+}
+
+// CloseShiftRequest defines the payload to close a counter shift
+type CloseShiftRequest struct {
+	CountedCash float64 `json:"counted_cash" validate:"required"`
+	Notes       string  `json:"notes"`
+}
+
+// Shift structure
+type Shift struct {
+	ID           int        `json:"id"`
+	AdminID      int        `json:"admin_id"`
+	OpenedAt     time.Time  `json:"opened_at"`
+	ClosedAt     *time.Time `json:"closed_at"`
+	OpeningFloat float64    `json:"opening_float"`
+	CashSales    float64    `json:"cash_sales"`
+	ExpectedCash float64    `json:"expected_cash"`
+	CountedCash  *float64   `json:"counted_cash"`
+	Variance     *float64   `json:"variance"`
+	Status       string     `json:"status"`
+}
+
+// ShiftReconciliation summarizes expected vs counted cash for one admin
+type ShiftReconciliation struct {
+	AdminID       int     `json:"admin_id"`
+	AdminUsername string  `json:"admin_username"`
+	TotalShifts   int     `json:"total_shifts"`
+	ExpectedCash  float64 `json:"expected_cash"`
+	CountedCash   float64 `json:"counted_cash"`
+	Variance      float64 `json:"variance"`
+	Shifts        []Shift `json:"shifts"`
+}
+
+// GetOpenShiftID returns the open shift of an admin, or ErrNoOpenShift. Inside a
+// transaction the shift stays open until it commits, so a cash sale cannot be
+// recorded against a shift being closed.
+func GetOpenShiftID(ctx context.Context, db config.DBTX, adminID int) (int, error) {
+	var shiftID int
+	query := "SELECT id FROM shift WHERE admin_id = $1 AND status = 'Open' FOR SHARE"
+	err := db.QueryRow(ctx, query, adminID).Scan(&shiftID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrNoOpenShift
+	}
+	return shiftID, err
+}
+
+// cashSales sums the settled cash transactions taken during a shift
+func cashSales(ctx context.Context, db config.DBTX, shiftID int) (float64, error) {
+	var total float64
+	query := `
+		SELECT COALESCE(SUM(amount), 0)
+		FROM transaction
+		WHERE shift_id = $1 AND transaction_method = 'Cash' AND status ILIKE 'settlement'`
+	err := db.QueryRow(ctx, query, shiftID).Scan(&total)
+	return total, err
+}
+
+// OpenShift godoc
+// @Summary Open a counter shift
+// @Description Opens a cash shift for the authenticated admin with a starting float. Cash payments are recorded against the open shift.
+// @Tags Shifts
+// @Accept json
+// @Produce json
+// @Param request body OpenShiftRequest true "Starting float"
+// @Success 200 {object} map[string]interface{} "Shift opened successfully"
+// @Failure 400 {object} map[string]string "Invalid request or shift already open"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/shift/open [post]
+func OpenShift(c echo.Context) error {
+	var req OpenShiftRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	// Extract admin ID and role from JWT claims
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	adminID := int(claims["admin_id"].(float64))
+	adminRole := claims["role"].(string)
+
+	// Validate admin role
+	if adminRole != "admin" && adminRole != "super-admin" {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only selected admins can perform this action."})
+	}
+
+	if req.OpeningFloat < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Opening float cannot be negative"})
+	}
+
+	// Only one shift may be open per admin
+	if _, err := GetOpenShiftID(context.Background(), config.Pool, adminID); err == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "A shift is already open for this admin"})
+	} else if !errors.Is(err, ErrNoOpenShift) {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to check open shift"})
+	}
+
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	var shiftID int
+	query := `INSERT INTO shift (admin_id, opening_float, status) VALUES ($1, $2, 'Open') RETURNING id`
+	err = tx.QueryRow(ctx, query, adminID, req.OpeningFloat).Scan(&shiftID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to open shift"})
+	}
+
+	// Log the shift opening
+	event := audit_handler.FromRequest(c, "shift.open", "shift", shiftID).
+		Change(nil, map[string]interface{}{"admin_id": adminID, "opening_float": req.OpeningFloat, "status": "Open"}).
+		Describe("Admin %d opened shift %d with float %.2f", adminID, shiftID, req.OpeningFloat)
+	if err := audit_handler.Record(ctx, tx, event); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log shift"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to open shift"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":       "Shift opened successfully",
+		"shift_id":      shiftID,
+		"opening_float": req.OpeningFloat,
+	})
+}
+
+// CloseShift godoc
+// @Summary Close a counter shift
+// @Description Closes the authenticated admin's open shift with the counted cash and returns the expected cash and variance
+// @Tags Shifts
+// @Accept json
+// @Produce json
+// @Param request body CloseShiftRequest true "Counted cash at close"
+// @Success 200 {object} Shift "Closed shift"
+// @Failure 400 {object} map[string]string "Invalid request or no open shift"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/shift/close [post]
+func CloseShift(c echo.Context) error {
+	var req CloseShiftRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	// Extract admin ID and role from JWT claims
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	adminID := int(claims["admin_id"].(float64))
+	adminRole := claims["role"].(string)
+
+	// Validate admin role
+	if adminRole != "admin" && adminRole != "super-admin" {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only selected admins can perform this action."})
+	}
+
+	if req.CountedCash < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Counted cash cannot be negative"})
+	}
+
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the open shift, waiting for cash sales still being recorded against it
+	var shiftID int
+	lockQuery := "SELECT id FROM shift WHERE admin_id = $1 AND status = 'Open' FOR UPDATE"
+	err = tx.QueryRow(ctx, lockQuery, adminID).Scan(&shiftID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "No open shift for this admin"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to check open shift"})
+	}
+
+	// Expected cash is the starting float plus every cash payment taken during the shift
+	sales, err := cashSales(ctx, tx, shiftID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to compute cash sales"})
+	}
+
+	var shift Shift
+	closeQuery := `
+		UPDATE shift
+		SET status = 'Closed', closed_at = NOW(), expected_cash = opening_float + $1, counted_cash = $2, notes = $3
+		WHERE id = $4 AND status = 'Open'
+		RETURNING id, admin_id, opened_at, closed_at, opening_float, expected_cash, counted_cash, status`
+	err = tx.QueryRow(ctx, closeQuery, sales, req.CountedCash, req.Notes, shiftID).Scan(
+		&shift.ID, &shift.AdminID, &shift.OpenedAt, &shift.ClosedAt, &shift.OpeningFloat,
+		&shift.ExpectedCash, &shift.CountedCash, &shift.Status,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "No open shift for this admin"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to close shift"})
+	}
+	shift.CashSales = sales
+	variance := req.CountedCash - shift.ExpectedCash
+	shift.Variance = &variance
+
+	// Log the shift closing
+	event := audit_handler.FromRequest(c, "shift.close", "shift", shiftID).
+		Change(map[string]string{"status": "Open"}, shift).
+		Describe("Admin %d closed shift %d, expected %.2f, counted %.2f", adminID, shiftID, shift.ExpectedCash, req.CountedCash)
+	if err := audit_handler.Record(ctx, tx, event); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log shift"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to close shift"})
+	}
+
+	return c.JSON(http.StatusOK, shift)
+}
+
+// GetShiftReport godoc
+// @Summary Shift reconciliation report
+// @Description Allows super-admins to compare expected and counted cash per admin for shifts opened in a date range
+// @Tags Shifts
+// @Produce json
+// @Param start_date query string true "Start date (YYYY-MM-DD)"
+// @Param end_date query string true "End date (YYYY-MM-DD)"
+// @Success 200 {object} map[string]interface{} "Shift reconciliation per admin"
+// @Failure 400 {object} map[string]string "Invalid date format"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/shift/report [get]
+func GetShiftReport(c echo.Context) error {
+	// Extract admin role from JWT claims
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	adminRole := claims["role"].(string)
+
+	if adminRole != "super-admin" {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can view shift reports."})
+	}
+
+	startDate, err := time.Parse("2006-01-02", c.QueryParam("start_date"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid start date format"})
+	}
+	endDate, err := time.Parse("2006-01-02", c.QueryParam("end_date"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid end date format"})
+	}
+	endDate = endDate.Add(24*time.Hour - time.Second)
+
+	// Open shifts have no expected cash yet, so it is computed from their sales so far
+	query := `
+		SELECT s.id, s.admin_id, a.username, s.opened_at, s.closed_at, s.opening_float,
+		       COALESCE(cash.total, 0) AS cash_sales, s.counted_cash, s.status
+		FROM shift s
+		JOIN admin a ON a.id = s.admin_id
+		LEFT JOIN (
+			SELECT shift_id, SUM(amount) AS total
+			FROM transaction
+			WHERE transaction_method = 'Cash' AND status ILIKE 'settlement'
+			GROUP BY shift_id
+		) cash ON cash.shift_id = s.id
+		WHERE s.opened_at BETWEEN $1 AND $2
+		ORDER BY s.admin_id, s.opened_at`
+	rows, err := config.Pool.Query(context.Background(), query, startDate, endDate)
+	if err != nil {
+		fmt.Printf("Query error: %v\n", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch shift report"})
+	}
+	defer rows.Close()
+
+	report := []*ShiftReconciliation{}
+	byAdmin := map[int]*ShiftReconciliation{}
+	for rows.Next() {
+		var shift Shift
+		var username string
+		if err := rows.Scan(&shift.ID, &shift.AdminID, &username, &shift.OpenedAt, &shift.ClosedAt,
+			&shift.OpeningFloat, &shift.CashSales, &shift.CountedCash, &shift.Status); err != nil {
+			fmt.Printf("Scan error: %v\n", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to process shift report"})
+		}
+		shift.ExpectedCash = shift.OpeningFloat + shift.CashSales
+
+		summary, ok := byAdmin[shift.AdminID]
+		if !ok {
+			summary = &ShiftReconciliation{AdminID: shift.AdminID, AdminUsername: username, Shifts: []Shift{}}
+			byAdmin[shift.AdminID] = summary
+			report = append(report, summary)
+		}
+
+		// Only closed shifts have a count to reconcile against
+		if shift.CountedCash != nil {
+			variance := *shift.CountedCash - shift.ExpectedCash
+			shift.Variance = &variance
+			summary.ExpectedCash += shift.ExpectedCash
+			summary.CountedCash += *shift.CountedCash
+			summary.Variance += variance
+		}
+		summary.TotalShifts++
+		summary.Shifts = append(summary.Shifts, shift)
+	}
+
+	if err := rows.Err(); err != nil {
+		fmt.Printf("Row iteration error: %v\n", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to process shift report"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Shift report retrieved successfully",
+		"data":    report,
+	})
+}
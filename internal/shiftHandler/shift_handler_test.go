@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAndCloseShift(t *testing.T) {
+	// Setup Echo
+	e := echo.New()
+
+	// Manually set the JWT claims for an admin
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"admin_id": float64(2), // Admin ID from the ddl.sql setup
+		"role":     "admin",
+	})
+
+	// Open a shift with a starting float
+	requestBody, _ := json.Marshal(OpenShiftRequest{OpeningFloat: 200000})
+	req := httptest.NewRequest(http.MethodPost, "/admin/shift/open", bytes.NewReader(requestBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user", token)
+
+	err := OpenShift(c)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.Equal(t, "Shift opened successfully", response["message"])
+	}
+
+	// Close the shift with the counted cash
+	requestBody, _ = json.Marshal(CloseShiftRequest{CountedCash: 200000})
+	req = httptest.NewRequest(http.MethodPost, "/admin/shift/close", bytes.NewReader(requestBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.Set("user", token)
+
+	err = CloseShift(c)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var shift Shift
+		json.Unmarshal(rec.Body.Bytes(), &shift)
+		assert.Equal(t, "Closed", shift.Status)
+		assert.Equal(t, float64(200000), shift.ExpectedCash)
+		if assert.NotNil(t, shift.Variance) {
+			assert.Equal(t, float64(0), *shift.Variance)
+		}
+	}
+}
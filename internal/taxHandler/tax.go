@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+)
+
+// CategoryComputerTime is the tax category of rented computer hours. Services use
+// the category stored on the service table (e.g. 'service', 'food', 'goods').
+const CategoryComputerTime = "computer_time"
+
+// TaxRule structure
+type TaxRule struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Rate      float64   `json:"rate"`      // percentage, e.g. 11 for PPN 11%
+	Inclusive bool      `json:"inclusive"` // true if prices already include the tax
+	Category  *string   `json:"category"`  // nil applies the rule to every category
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TaxableLine is one priced line of a rental or purchase
+type TaxableLine struct {
+	Category string  `json:"category"`
+	Amount   float64 `json:"amount"`
+}
+
+// AppliedTax is the amount one rule adds to a transaction
+type AppliedTax struct {
+	TaxRuleID     int     `json:"tax_rule_id"`
+	Name          string  `json:"name"`
+	Rate          float64 `json:"rate"`
+	Inclusive     bool    `json:"inclusive"`
+	TaxableAmount float64 `json:"taxable_amount"`
+	TaxAmount     float64 `json:"tax_amount"`
+}
+
+// TaxBreakdown is the tax computed for a set of lines
+type TaxBreakdown struct {
+	Subtotal float64      `json:"subtotal"`  // sum of line prices as listed
+	Taxes    []AppliedTax `json:"taxes"`     // per rule, inclusive and exclusive
+	TaxTotal float64      `json:"tax_total"` // all tax contained in Total
+	Total    float64      `json:"total"`     // amount to charge
+}
+
+// appliesTo reports whether a rule taxes the given category
+func (r TaxRule) appliesTo(category string) bool {
+	return r.IsActive && (r.Category == nil || *r.Category == category)
+}
+
+// ComputeTaxes applies the rules to every line. Inclusive rules are extracted from the
+// line price, exclusive rules are added on top of the price net of inclusive tax.
+// Amounts are rounded to whole rupiah per rule.
+func ComputeTaxes(rules []TaxRule, lines []TaxableLine) TaxBreakdown {
+	breakdown := TaxBreakdown{Taxes: []AppliedTax{}}
+	applied := map[int]*AppliedTax{}
+
+	for _, line := range lines {
+		breakdown.Subtotal += line.Amount
+
+		// Strip every inclusive tax from the price to get the net amount
+		inclusiveRate := 0.0
+		for _, rule := range rules {
+			if rule.Inclusive && rule.appliesTo(line.Category) {
+				inclusiveRate += rule.Rate
+			}
+		}
+		net := line.Amount / (1 + inclusiveRate/100)
+
+		for _, rule := range rules {
+			if !rule.appliesTo(line.Category) {
+				continue
+			}
+			tax, ok := applied[rule.ID]
+			if !ok {
+				tax = &AppliedTax{TaxRuleID: rule.ID, Name: rule.Name, Rate: rule.Rate, Inclusive: rule.Inclusive}
+				applied[rule.ID] = tax
+			}
+			tax.TaxableAmount += net
+			tax.TaxAmount += net * rule.Rate / 100
+		}
+	}
+
+	// Keep the rule order so receipts list taxes consistently
+	breakdown.Total = breakdown.Subtotal
+	for _, rule := range rules {
+		tax, ok := applied[rule.ID]
+		if !ok {
+			continue
+		}
+		tax.TaxableAmount = math.Round(tax.TaxableAmount)
+		tax.TaxAmount = math.Round(tax.TaxAmount)
+		breakdown.TaxTotal += tax.TaxAmount
+		if !tax.Inclusive {
+			breakdown.Total += tax.TaxAmount
+		}
+		breakdown.Taxes = append(breakdown.Taxes, *tax)
+	}
+	return breakdown
+}
+
+// LoadActiveTaxRules returns the rules currently in force
+func LoadActiveTaxRules(ctx context.Context, db config.DBTX) ([]TaxRule, error) {
+	query := `
+		SELECT id, name, rate, inclusive, category, is_active, created_at
+		FROM tax_rule
+		WHERE is_active = TRUE
+		ORDER BY id`
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tax rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []TaxRule
+	for rows.Next() {
+		var rule TaxRule
+		if err := rows.Scan(&rule.ID, &rule.Name, &rule.Rate, &rule.Inclusive, &rule.Category, &rule.IsActive, &rule.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to parse tax rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// QuoteTaxes computes the tax breakdown of lines with the active rules
+func QuoteTaxes(ctx context.Context, db config.DBTX, lines []TaxableLine) (TaxBreakdown, error) {
+	rules, err := LoadActiveTaxRules(ctx, db)
+	if err != nil {
+		return TaxBreakdown{}, err
+	}
+	return ComputeTaxes(rules, lines), nil
+}
+
+// SaveTransactionTaxes stores the tax charged on a transaction, per rule and in total
+func SaveTransactionTaxes(ctx context.Context, db config.DBTX, transactionID int, breakdown TaxBreakdown) error {
+	for _, tax := range breakdown.Taxes {
+		query := `
+			INSERT INTO transaction_tax (transaction_id, tax_rule_id, name, rate, inclusive, taxable_amount, tax_amount)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`
+		_, err := db.Exec(ctx, query, transactionID, tax.TaxRuleID, tax.Name, tax.Rate, tax.Inclusive, tax.TaxableAmount, tax.TaxAmount)
+		if err != nil {
+			return fmt.Errorf("failed to save %s for transaction %d: %w", tax.Name, transactionID, err)
+		}
+	}
+
+	updateQuery := `UPDATE transaction SET tax_amount = $1 WHERE id = $2`
+	_, err := db.Exec(ctx, updateQuery, breakdown.TaxTotal, transactionID)
+	if err != nil {
+		return fmt.Errorf("failed to save tax total for transaction %d: %w", transactionID, err)
+	}
+	return nil
+}
+
+// LoadTransactionTaxes returns the tax stored for a transaction
+func LoadTransactionTaxes(ctx context.Context, db config.DBTX, transactionID int) ([]AppliedTax, error) {
+	query := `
+		SELECT tax_rule_id, name, rate, inclusive, taxable_amount, tax_amount
+		FROM transaction_tax
+		WHERE transaction_id = $1
+		ORDER BY id`
+	rows, err := db.Query(ctx, query, transactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch transaction taxes: %w", err)
+	}
+	defer rows.Close()
+
+	taxes := []AppliedTax{}
+	for rows.Next() {
+		var tax AppliedTax
+		if err := rows.Scan(&tax.TaxRuleID, &tax.Name, &tax.Rate, &tax.Inclusive, &tax.TaxableAmount, &tax.TaxAmount); err != nil {
+			return nil, fmt.Errorf("failed to parse transaction tax: %w", err)
+		}
+		taxes = append(taxes, tax)
+	}
+	return taxes, rows.Err()
+}
@@ -0,0 +1,228 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	config "w4/p2/milestones/config/database"
+	audit_handler "w4/p2/milestones/internal/auditHandler"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// TaxRuleRequest defines the payload to create or update a tax rule
+type TaxRuleRequest struct {
+	Name      string  `json:"name" validate:"required"`
+	Rate      float64 `json:"rate" validate:"required"`
+	Inclusive bool    `json:"inclusive"`
+	Category  *string `json:"category"` // computer_time, service, food, goods... or null for all
+	IsActive  *bool   `json:"is_active"`
+}
+
+// validate checks the fields shared by create and update
+func (req TaxRuleRequest) validate() string {
+	if req.Name == "" {
+		return "Tax rule name is required"
+	}
+	if req.Rate <= 0 || req.Rate >= 100 {
+		return "Tax rate must be between 0 and 100 percent"
+	}
+	return ""
+}
+
+// requireSuperAdmin returns the admin ID if the JWT belongs to a super-admin
+func requireSuperAdmin(c echo.Context) (int, bool) {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	adminRole, _ := claims["role"].(string)
+	adminID, _ := claims["admin_id"].(float64)
+	return int(adminID), adminRole == "super-admin"
+}
+
+// GetTaxRules godoc
+// @Summary List tax rules
+// @Description Retrieve all tax and service charge rules, active and inactive
+// @Tags Taxes
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Tax rules retrieved successfully"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/tax-rules [get]
+func GetTaxRules(c echo.Context) error {
+	if _, ok := requireSuperAdmin(c); !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can manage tax rules."})
+	}
+
+	query := `SELECT id, name, rate, inclusive, category, is_active, created_at FROM tax_rule ORDER BY id`
+	rows, err := config.Pool.Query(context.Background(), query)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch tax rules"})
+	}
+	defer rows.Close()
+
+	rules := []TaxRule{}
+	for rows.Next() {
+		var rule TaxRule
+		if err := rows.Scan(&rule.ID, &rule.Name, &rule.Rate, &rule.Inclusive, &rule.Category, &rule.IsActive, &rule.CreatedAt); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to process tax rules"})
+		}
+		rules = append(rules, rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to process tax rules"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Tax rules retrieved successfully",
+		"data":    rules,
+	})
+}
+
+// CreateTaxRule godoc
+// @Summary Create a tax rule
+// @Description Create a tax or service charge rule with a rate, inclusive/exclusive pricing and an optional category
+// @Tags Taxes
+// @Accept json
+// @Produce json
+// @Param request body TaxRuleRequest true "Tax rule"
+// @Success 200 {object} TaxRule "Created tax rule"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/tax-rules [post]
+func CreateTaxRule(c echo.Context) error {
+	adminID, ok := requireSuperAdmin(c)
+	if !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can manage tax rules."})
+	}
+
+	var req TaxRuleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+	if msg := req.validate(); msg != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": msg})
+	}
+
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	var rule TaxRule
+	query := `
+		INSERT INTO tax_rule (name, rate, inclusive, category, is_active)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, name, rate, inclusive, category, is_active, created_at`
+	err = tx.QueryRow(ctx, query, req.Name, req.Rate, req.Inclusive, req.Category, isActive).Scan(
+		&rule.ID, &rule.Name, &rule.Rate, &rule.Inclusive, &rule.Category, &rule.IsActive, &rule.CreatedAt,
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create tax rule"})
+	}
+
+	// Log the admin action
+	event := audit_handler.FromRequest(c, "tax_rule.create", "tax_rule", rule.ID).Change(nil, rule).
+		Describe("Super-admin (ID: %d) created tax rule %s (%.2f%%)", adminID, rule.Name, rule.Rate)
+	if err := audit_handler.Record(ctx, tx, event); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log admin action"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create tax rule"})
+	}
+
+	return c.JSON(http.StatusOK, rule)
+}
+
+// UpdateTaxRule godoc
+// @Summary Update a tax rule
+// @Description Change the rate, pricing mode, category or active flag of a tax rule. Past transactions keep the tax they were charged.
+// @Tags Taxes
+// @Accept json
+// @Produce json
+// @Param id path int true "Tax rule ID"
+// @Param request body TaxRuleRequest true "Tax rule"
+// @Success 200 {object} TaxRule "Updated tax rule"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Tax rule not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/tax-rules/{id} [put]
+func UpdateTaxRule(c echo.Context) error {
+	adminID, ok := requireSuperAdmin(c)
+	if !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can manage tax rules."})
+	}
+
+	ruleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid tax rule ID"})
+	}
+
+	var req TaxRuleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+	if msg := req.validate(); msg != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": msg})
+	}
+
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	var before TaxRule
+	err = tx.QueryRow(ctx, `SELECT id, name, rate, inclusive, category, is_active, created_at FROM tax_rule WHERE id = $1 FOR UPDATE`, ruleID).Scan(
+		&before.ID, &before.Name, &before.Rate, &before.Inclusive, &before.Category, &before.IsActive, &before.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Tax rule not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch tax rule"})
+	}
+
+	var rule TaxRule
+	query := `
+		UPDATE tax_rule
+		SET name = $1, rate = $2, inclusive = $3, category = $4, is_active = COALESCE($5, is_active)
+		WHERE id = $6
+		RETURNING id, name, rate, inclusive, category, is_active, created_at`
+	err = tx.QueryRow(ctx, query, req.Name, req.Rate, req.Inclusive, req.Category, req.IsActive, ruleID).Scan(
+		&rule.ID, &rule.Name, &rule.Rate, &rule.Inclusive, &rule.Category, &rule.IsActive, &rule.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Tax rule not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update tax rule"})
+	}
+
+	// Log the admin action
+	event := audit_handler.FromRequest(c, "tax_rule.update", "tax_rule", rule.ID).Change(before, rule).
+		Describe("Super-admin (ID: %d) updated tax rule %d (%s %.2f%%, active: %t)", adminID, rule.ID, rule.Name, rule.Rate, rule.IsActive)
+	if err := audit_handler.Record(ctx, tx, event); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log admin action"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update tax rule"})
+	}
+
+	return c.JSON(http.StatusOK, rule)
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTaxRules(t *testing.T) {
+	// Setup Echo
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/admin/tax-rules", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	// Manually set the JWT claims for a super-admin
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"admin_id": float64(1),
+		"role":     "super-admin",
+	})
+	c.Set("user", token)
+
+	err := GetTaxRules(c)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.Equal(t, "Tax rules retrieved successfully", response["message"])
+		assert.NotEmpty(t, response["data"])
+	}
+}
+
+func TestComputeTaxes(t *testing.T) {
+	food := "food"
+	rules := []TaxRule{
+		{ID: 1, Name: "PPN", Rate: 11, IsActive: true},
+		{ID: 2, Name: "Service Charge", Rate: 5, Category: &food, IsActive: true},
+	}
+	lines := []TaxableLine{
+		{Category: CategoryComputerTime, Amount: 40000},
+		{Category: "food", Amount: 10000},
+	}
+
+	// Exclusive rules are added on top of the listed prices
+	breakdown := ComputeTaxes(rules, lines)
+	assert.Equal(t, float64(50000), breakdown.Subtotal)
+	if assert.Len(t, breakdown.Taxes, 2) {
+		assert.Equal(t, float64(5500), breakdown.Taxes[0].TaxAmount)
+		assert.Equal(t, float64(500), breakdown.Taxes[1].TaxAmount)
+	}
+	assert.Equal(t, float64(6000), breakdown.TaxTotal)
+	assert.Equal(t, float64(56000), breakdown.Total)
+
+	// Inclusive rules are extracted from the price and do not change the total
+	rules[0].Inclusive = true
+	breakdown = ComputeTaxes(rules[:1], lines[:1])
+	assert.Equal(t, float64(3964), breakdown.TaxTotal)
+	assert.Equal(t, float64(40000), breakdown.Total)
+}
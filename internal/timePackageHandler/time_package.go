@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+)
+
+// ProductTimePackage is the service product type of prepaid time packages
+const ProductTimePackage = "time_package"
+
+// TimeLot is the prepaid time from one package purchase
+type TimeLot struct {
+	ID               int        `json:"id"`
+	PackageName      string     `json:"package_name"`
+	ComputerType     *string    `json:"computer_type"` // nil for any computer
+	MinutesTotal     int        `json:"minutes_total"`
+	MinutesRemaining int        `json:"minutes_remaining"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// AppliedTime is the prepaid time an order draws down
+type AppliedTime struct {
+	ComputerType string  `json:"computer_type"`
+	Minutes      int     `json:"minutes"`
+	Amount       float64 `json:"amount"` // value of the minutes at the hourly rate
+}
+
+// TimeError explains why prepaid time cannot cover an order
+type TimeError struct {
+	Message string
+}
+
+func (e *TimeError) Error() string {
+	return e.Message
+}
+
+// lotsQuery selects the usable lots for a computer type, the soonest to expire first
+const lotsQuery = `
+	SELECT tb.id, s.name, tb.computer_type, tb.minutes_total, tb.minutes_remaining, tb.expires_at, tb.created_at
+	FROM time_balance tb
+	JOIN service s ON s.id = tb.service_id
+	WHERE tb.customer_id = $1 AND tb.minutes_remaining > 0
+	  AND (tb.expires_at IS NULL OR tb.expires_at > NOW())
+	  AND ($2 = '' OR tb.computer_type IS NULL OR LOWER(tb.computer_type) = LOWER($2))
+	ORDER BY tb.expires_at NULLS LAST, tb.id`
+
+// LoadLots returns a customer's usable prepaid time, for one computer type or for all
+// when computerType is empty
+func LoadLots(ctx context.Context, db config.DBTX, customerID int, computerType string) ([]TimeLot, error) {
+	rows, err := db.Query(ctx, lotsQuery, customerID, computerType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch time balance: %w", err)
+	}
+	defer rows.Close()
+
+	lots := []TimeLot{}
+	for rows.Next() {
+		var lot TimeLot
+		if err := rows.Scan(&lot.ID, &lot.PackageName, &lot.ComputerType, &lot.MinutesTotal, &lot.MinutesRemaining, &lot.ExpiresAt, &lot.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to parse time balance: %w", err)
+		}
+		lots = append(lots, lot)
+	}
+	return lots, rows.Err()
+}
+
+// ComputeTimeCredit draws the billed hours of a rental from the lots, in whole
+// minutes, and values them at their share of the amount left to pay
+func ComputeTimeCredit(lots []TimeLot, computerType string, billedHours int, amount float64) *AppliedTime {
+	available := 0
+	for _, lot := range lots {
+		if lot.ComputerType == nil || strings.EqualFold(*lot.ComputerType, computerType) {
+			available += lot.MinutesRemaining
+		}
+	}
+
+	minutes := min(available, billedHours*60)
+	if minutes == 0 || amount <= 0 {
+		return nil
+	}
+	return &AppliedTime{
+		ComputerType: computerType,
+		Minutes:      minutes,
+		Amount:       math.Round(amount * float64(minutes) / float64(billedHours*60)),
+	}
+}
+
+// ApplyTime computes how much of a rental the customer's prepaid time covers, or nil
+// if the customer has none for the computer type
+func ApplyTime(ctx context.Context, db config.DBTX, customerID int, computerType string, billedHours int, amount float64) (*AppliedTime, error) {
+	lots, err := LoadLots(ctx, db, customerID, computerType)
+	if err != nil {
+		return nil, err
+	}
+	return ComputeTimeCredit(lots, computerType, billedHours, amount), nil
+}
+
+// ConsumeTime draws the minutes of an order from the customer's lots before payment,
+// soonest to expire first, and returns the usage rows to link to the transaction. It
+// fails with a *TimeError if the minutes were used since the quote.
+func ConsumeTime(ctx context.Context, applied AppliedTime, customerID int) ([]int, error) {
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	lots, err := LoadLots(ctx, tx, customerID, applied.ComputerType)
+	if err != nil {
+		return nil, err
+	}
+
+	var usageIDs []int
+	needed := applied.Minutes
+	for _, lot := range lots {
+		if needed == 0 {
+			break
+		}
+		take := min(needed, lot.MinutesRemaining)
+
+		// Only take minutes that are still there
+		updateQuery := `UPDATE time_balance SET minutes_remaining = minutes_remaining - $1 WHERE id = $2 AND minutes_remaining >= $1`
+		tag, err := tx.Exec(ctx, updateQuery, take, lot.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to draw prepaid time: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			continue
+		}
+
+		var usageID int
+		insertQuery := `INSERT INTO time_balance_usage (time_balance_id, minutes) VALUES ($1, $2) RETURNING id`
+		if err := tx.QueryRow(ctx, insertQuery, lot.ID, take).Scan(&usageID); err != nil {
+			return nil, fmt.Errorf("failed to record prepaid time usage: %w", err)
+		}
+		usageIDs = append(usageIDs, usageID)
+		needed -= take
+	}
+	if needed > 0 {
+		return nil, &TimeError{Message: "Prepaid time was used since the quote"}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit prepaid time usage: %w", err)
+	}
+	return usageIDs, nil
+}
+
+// LinkTimeUsage attaches drawn minutes to the transaction they paid for
+func LinkTimeUsage(ctx context.Context, db config.DBTX, usageIDs []int, applied AppliedTime, transactionID int) error {
+	query := `UPDATE time_balance_usage SET transaction_id = $1 WHERE id = ANY($2)`
+	if _, err := db.Exec(ctx, query, transactionID, usageIDs); err != nil {
+		return fmt.Errorf("failed to link prepaid time usage: %w", err)
+	}
+
+	updateQuery := `UPDATE transaction SET time_credit = $1 WHERE id = $2`
+	if _, err := db.Exec(ctx, updateQuery, applied.Amount, transactionID); err != nil {
+		return fmt.Errorf("failed to save prepaid time for transaction %d: %w", transactionID, err)
+	}
+	return nil
+}
+
+// CancelTimeUsage gives back minutes drawn by ConsumeTime and removes the usage rows
+func CancelTimeUsage(ctx context.Context, db config.DBTX, usageIDs []int) error {
+	query := `
+		WITH removed AS (
+			DELETE FROM time_balance_usage WHERE id = ANY($1) RETURNING time_balance_id, minutes
+		)
+		UPDATE time_balance tb
+		SET minutes_remaining = tb.minutes_remaining + r.minutes
+		FROM (SELECT time_balance_id, SUM(minutes) AS minutes FROM removed GROUP BY time_balance_id) r
+		WHERE tb.id = r.time_balance_id`
+	if _, err := db.Exec(ctx, query, usageIDs); err != nil {
+		return fmt.Errorf("failed to give back prepaid time: %w", err)
+	}
+	return nil
+}
+
+// ReleaseTransactionTime gives back the minutes drawn by a transaction that will never settle
+func ReleaseTransactionTime(ctx context.Context, db config.DBTX, transactionID int) error {
+	rows, err := db.Query(ctx, `SELECT id FROM time_balance_usage WHERE transaction_id = $1`, transactionID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch prepaid time usage of transaction %d: %w", transactionID, err)
+	}
+	var usageIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to parse prepaid time usage: %w", err)
+		}
+		usageIDs = append(usageIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(usageIDs) == 0 {
+		return nil
+	}
+	return CancelTimeUsage(ctx, db, usageIDs)
+}
+
+// CreditPackages credits the time packages bought on a settled transaction to the
+// customer's balance, one lot per package with every line of it added up. Crediting a
+// transaction twice has no effect.
+func CreditPackages(ctx context.Context, db config.DBTX, transactionID int) (int, error) {
+	query := `
+		INSERT INTO time_balance (customer_id, service_id, transaction_id, computer_type, minutes_total, minutes_remaining, expires_at)
+		SELECT t.customer_id, s.id, t.id, s.package_computer_type, s.package_minutes * SUM(rs.quantity), s.package_minutes * SUM(rs.quantity),
+		       CASE WHEN s.package_validity_days IS NULL THEN NULL ELSE NOW() + s.package_validity_days * INTERVAL '1 day' END
+		FROM rental_services rs
+		JOIN service s ON s.id = rs.service_id
+		JOIN transaction t ON t.id = rs.transaction_id
+		WHERE rs.transaction_id = $1 AND s.product_type = $2
+		GROUP BY t.customer_id, s.id, t.id
+		ON CONFLICT (transaction_id, service_id) DO NOTHING`
+	tag, err := db.Exec(ctx, query, transactionID, ProductTimePackage)
+	if err != nil {
+		return 0, fmt.Errorf("failed to credit time packages of transaction %d: %w", transactionID, err)
+	}
+	return int(tag.RowsAffected()), nil
+}
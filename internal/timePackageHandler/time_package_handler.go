@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	config "w4/p2/milestones/config/database"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// TimePackage is a time package on sale
+type TimePackage struct {
+	ServiceID    int     `json:"service_id"`
+	Name         string  `json:"name"`
+	Description  string  `json:"description"`
+	Price        float64 `json:"price"`
+	Minutes      int     `json:"minutes"`
+	ComputerType *string `json:"computer_type"` // nil for any computer
+	ValidityDays *int    `json:"validity_days"` // nil if the minutes never expire
+}
+
+// TimeBalanceSummary is a customer's prepaid time and the packages on sale
+type TimeBalanceSummary struct {
+	TotalMinutes int           `json:"total_minutes"`
+	Lots         []TimeLot     `json:"lots"`
+	Packages     []TimePackage `json:"packages"`
+}
+
+// GetTimeBalance godoc
+// @Summary Get prepaid time balance
+// @Description Retrieve the customer's remaining prepaid minutes per time package purchase, soonest to expire first, and the time packages on sale. Packages are bought like services and rentals draw down the minutes before charging the customer.
+// @Tags Time Packages
+// @Produce json
+// @Success 200 {object} TimeBalanceSummary "Prepaid time balance"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /customer/time-balance [get]
+func GetTimeBalance(c echo.Context) error {
+	// Extract customer ID from JWT claims
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	customerID := int(claims["customer_id"].(float64))
+
+	ctx := context.Background()
+	lots, err := LoadLots(ctx, config.Pool, customerID, "")
+	if err != nil {
+		fmt.Println("Prepaid time error:", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch time balance"})
+	}
+
+	summary := TimeBalanceSummary{Lots: lots, Packages: []TimePackage{}}
+	for _, lot := range lots {
+		summary.TotalMinutes += lot.MinutesRemaining
+	}
+
+	query := `
+		SELECT id, name, COALESCE(description, ''), price, package_minutes, package_computer_type, package_validity_days
+		FROM service
+		WHERE product_type = $1 AND is_active = TRUE AND quantity > 0
+		ORDER BY price`
+	rows, err := config.Pool.Query(ctx, query, ProductTimePackage)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch time packages"})
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p TimePackage
+		if err := rows.Scan(&p.ServiceID, &p.Name, &p.Description, &p.Price, &p.Minutes, &p.ComputerType, &p.ValidityDays); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to parse time packages"})
+		}
+		summary.Packages = append(summary.Packages, p)
+	}
+
+	return c.JSON(http.StatusOK, summary)
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTimeBalance(t *testing.T) {
+	// Setup Echo
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/customer/time-balance", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	// Manually set the JWT claims
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"customer_id": float64(1), // Customer ID from the ddl.sql setup
+	})
+	c.Set("user", token)
+
+	err := GetTimeBalance(c)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response TimeBalanceSummary
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.NotEmpty(t, response.Packages)
+	}
+}
+
+func TestComputeTimeCredit(t *testing.T) {
+	gaming, office := "Gaming", "Office"
+	lots := []TimeLot{
+		{ID: 1, MinutesRemaining: 120},
+		{ID: 2, ComputerType: &gaming, MinutesRemaining: 200},
+		{ID: 3, ComputerType: &office, MinutesRemaining: 500},
+	}
+
+	// Enough minutes cover the whole rental
+	applied := ComputeTimeCredit(lots, "Gaming", 5, 100000)
+	assert.Equal(t, 300, applied.Minutes)
+	assert.Equal(t, float64(100000), applied.Amount)
+
+	// The rest of a longer rental is left to pay
+	applied = ComputeTimeCredit(lots, "gaming", 8, 160000)
+	assert.Equal(t, 320, applied.Minutes)
+	assert.Equal(t, float64(106667), applied.Amount)
+
+	// Lots for another computer type do not count
+	assert.Nil(t, ComputeTimeCredit(lots[1:2], "Browsing", 2, 10000))
+}
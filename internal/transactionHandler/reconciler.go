@@ -0,0 +1,273 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/midtrans/midtrans-go"
+)
+
+// Orders the gateway no longer knows about are expired locally after this long
+const gatewayExpiryGrace = 24 * time.Hour
+
+// ActionFinalStatusConflict marks an order the gateway settled after it was closed locally
+const ActionFinalStatusConflict = "final_status_conflict"
+
+// PaymentDiscrepancy is one reconciled order, comparing local and gateway status
+type PaymentDiscrepancy struct {
+	OrderID         string    `json:"order_id"`
+	CustomerID      int       `json:"customer_id"`
+	TransactionType string    `json:"transaction_type"`
+	Amount          float64   `json:"amount"`
+	LocalStatus     string    `json:"local_status"`
+	GatewayStatus   string    `json:"gateway_status"`
+	Action          string    `json:"action"`
+	Error           string    `json:"error,omitempty"` // why the order could not be reconciled, when the action is error
+	CheckedAt       time.Time `json:"checked_at"`
+}
+
+// ReconcileRequest defines the optional payload for a manual reconciliation run
+type ReconcileRequest struct {
+	StaleMinutes int `json:"stale_minutes"`
+}
+
+// StartPaymentReconciler polls Midtrans for stale pending orders every interval
+func StartPaymentReconciler(interval time.Duration, staleAfter time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			results, err := ReconcilePendingPayments(context.Background(), staleAfter)
+			if err != nil {
+				fmt.Printf("Payment reconciliation error: %v\n", err)
+				continue
+			}
+			fmt.Printf("Payment reconciliation checked %d pending orders\n", len(results))
+		}
+	}()
+}
+
+// gatewayNotFound reports whether a Midtrans error says the gateway has no such order,
+// as opposed to a timeout, server error or bad key that says nothing about the order
+func gatewayNotFound(err *midtrans.Error) bool {
+	return err != nil && err.StatusCode == http.StatusNotFound
+}
+
+// ReconcilePendingPayments checks every order that has been pending for longer than
+// staleAfter against Midtrans, applies the gateway status through the normal
+// settlement path and records the outcome in the payment_reconciliation table. An order
+// that fails is reported with the error action and the run moves on to the next.
+func ReconcilePendingPayments(ctx context.Context, staleAfter time.Duration) ([]PaymentDiscrepancy, error) {
+	// initialize midtrans api
+	Init()
+
+	query := `
+		SELECT order_id, customer_id, transaction_type, amount, status, transaction_date
+		FROM transaction
+		WHERE status ILIKE 'pending' AND order_id IS NOT NULL AND transaction_date < $1
+		ORDER BY transaction_date`
+	rows, err := config.Pool.Query(ctx, query, time.Now().Add(-staleAfter))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pending payments: %w", err)
+	}
+
+	type pendingOrder struct {
+		PaymentDiscrepancy
+		CreatedAt time.Time
+	}
+	var pending []pendingOrder
+	for rows.Next() {
+		var order pendingOrder
+		if err := rows.Scan(&order.OrderID, &order.CustomerID, &order.TransactionType, &order.Amount, &order.LocalStatus, &order.CreatedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to parse pending payment: %w", err)
+		}
+		pending = append(pending, order)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read pending payments: %w", err)
+	}
+
+	results := []PaymentDiscrepancy{}
+	for _, order := range pending {
+		result := order.PaymentDiscrepancy
+		result.CheckedAt = time.Now()
+
+		resp, midtransErr := coreAPI.CheckTransaction(order.OrderID)
+		switch {
+		case gatewayNotFound(midtransErr) && time.Since(order.CreatedAt) > gatewayExpiryGrace:
+			// The gateway never saw or already purged the order, so it can never settle
+			result.GatewayStatus = "not_found"
+			result.Action = "expired_locally"
+			if _, err := ApplyPaymentStatus(ctx, order.OrderID, "expire", ""); err != nil {
+				result.Action, result.Error = "error", err.Error()
+			}
+		case gatewayNotFound(midtransErr):
+			result.GatewayStatus = "not_found"
+			result.Action = "unchanged"
+		case midtransErr != nil:
+			// Timeouts, gateway errors and a bad key leave the order pending for the next run
+			result.GatewayStatus = "unknown"
+			result.Action = "gateway_error"
+			result.Error = midtransErr.Error()
+		default:
+			result.GatewayStatus = resp.TransactionStatus
+			result.Action = "unchanged"
+			if isFinalStatus(resp.TransactionStatus) {
+				result.Action = "applied_" + resp.TransactionStatus
+				if _, err := ApplyPaymentStatus(ctx, order.OrderID, resp.TransactionStatus, resp.GrossAmount); err != nil {
+					result.Action, result.Error = "error", err.Error()
+				}
+			}
+		}
+		if result.Action == "error" {
+			fmt.Printf("Failed to reconcile order %s: %s\n", result.OrderID, result.Error)
+		}
+
+		insertQuery := `
+			INSERT INTO payment_reconciliation (order_id, local_status, gateway_status, action, checked_at)
+			VALUES ($1, $2, $3, $4, $5)`
+		_, err := config.Pool.Exec(ctx, insertQuery, result.OrderID, result.LocalStatus, result.GatewayStatus, result.Action, result.CheckedAt)
+		if err != nil {
+			fmt.Printf("Failed to record reconciliation for %s: %v\n", result.OrderID, err)
+			result.Action, result.Error = "error", err.Error()
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// ReconcilePayments godoc
+// @Summary Reconcile pending payments
+// @Description Allows super-admins to immediately reconcile stale pending Midtrans orders instead of waiting for the background job
+// @Tags Transactions
+// @Accept json
+// @Produce json
+// @Param request body ReconcileRequest false "Minutes an order must be pending before it is checked (default 15)"
+// @Success 200 {object} map[string]interface{} "Reconciliation results"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/payments/reconcile [post]
+func ReconcilePayments(c echo.Context) error {
+	// Extract admin role from JWT claims
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	adminRole, _ := claims["role"].(string)
+
+	if adminRole != "super-admin" {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can reconcile payments."})
+	}
+
+	var req ReconcileRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+	if req.StaleMinutes <= 0 {
+		req.StaleMinutes = 15
+	}
+
+	results, err := ReconcilePendingPayments(context.Background(), time.Duration(req.StaleMinutes)*time.Minute)
+	if err != nil {
+		fmt.Printf("Reconciliation error: %v\n", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to reconcile payments"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Payments reconciled successfully",
+		"data":    results,
+	})
+}
+
+// GetReconciliationReport godoc
+// @Summary Get payment reconciliation report
+// @Description Discrepancy report of local vs gateway payment status for finance, optionally limited to orders whose status differed
+// @Tags Transactions
+// @Produce json
+// @Param start_date query string false "Start date (YYYY-MM-DD), defaults to 7 days ago"
+// @Param end_date query string false "End date (YYYY-MM-DD), defaults to today"
+// @Param discrepancies_only query string false "If set to 'true', only returns orders whose local status differed from the gateway"
+// @Success 200 {object} map[string]interface{} "Reconciliation report"
+// @Failure 400 {object} map[string]string "Invalid date format"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/payments/reconciliation [get]
+func GetReconciliationReport(c echo.Context) error {
+	// Extract admin role from JWT claims
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	adminRole, _ := claims["role"].(string)
+
+	if adminRole != "super-admin" {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only super-admins can view reconciliation reports."})
+	}
+
+	endDate := time.Now()
+	startDate := endDate.AddDate(0, 0, -7)
+	var err error
+	if v := c.QueryParam("start_date"); v != "" {
+		startDate, err = time.Parse("2006-01-02", v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid start date format"})
+		}
+	}
+	if v := c.QueryParam("end_date"); v != "" {
+		endDate, err = time.Parse("2006-01-02", v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid end date format"})
+		}
+		endDate = endDate.Add(24*time.Hour - time.Second)
+	}
+
+	query := `
+		SELECT pr.order_id, t.customer_id, t.transaction_type, t.amount, pr.local_status, pr.gateway_status, pr.action, pr.checked_at
+		FROM payment_reconciliation pr
+		JOIN transaction t ON t.order_id = pr.order_id
+		WHERE pr.checked_at BETWEEN $1 AND $2`
+	if c.QueryParam("discrepancies_only") == "true" {
+		query += ` AND LOWER(pr.local_status) <> LOWER(pr.gateway_status)`
+	}
+	query += ` ORDER BY pr.checked_at DESC`
+
+	rows, err := config.Pool.Query(context.Background(), query, startDate, endDate)
+	if err != nil {
+		fmt.Printf("Query error: %v\n", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch reconciliation report"})
+	}
+	defer rows.Close()
+
+	// Summarize the outcome per action so finance can see what was corrected
+	report := []PaymentDiscrepancy{}
+	summary := map[string]int{}
+	for rows.Next() {
+		var item PaymentDiscrepancy
+		if err := rows.Scan(&item.OrderID, &item.CustomerID, &item.TransactionType, &item.Amount,
+			&item.LocalStatus, &item.GatewayStatus, &item.Action, &item.CheckedAt); err != nil {
+			fmt.Printf("Scan error: %v\n", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to process reconciliation report"})
+		}
+		summary[item.Action]++
+		report = append(report, item)
+	}
+	if err := rows.Err(); err != nil {
+		fmt.Printf("Row iteration error: %v\n", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to process reconciliation report"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Reconciliation report retrieved successfully",
+		"summary": summary,
+		"data":    report,
+	})
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/midtrans/midtrans-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetReconciliationReport(t *testing.T) {
+	// Setup Echo
+	e := echo.New()
+
+	// Mock request for the discrepancy report
+	req := httptest.NewRequest(http.MethodGet, "/admin/payments/reconciliation?discrepancies_only=true", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	// Manually set the JWT claims for a super-admin
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"admin_id": float64(1),
+		"role":     "super-admin",
+	})
+	c.Set("user", token)
+
+	// Call the handler function
+	err := GetReconciliationReport(c)
+
+	// Assertions
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.Equal(t, "Reconciliation report retrieved successfully", response["message"])
+		assert.NotNil(t, response["data"], "Report data should not be nil")
+	}
+
+	// Regular admins cannot see the finance report
+	req = httptest.NewRequest(http.MethodGet, "/admin/payments/reconciliation", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.Set("user", jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"admin_id": float64(2),
+		"role":     "admin",
+	}))
+
+	err = GetReconciliationReport(c)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	}
+}
+
+func TestGatewayNotFound(t *testing.T) {
+	assert.False(t, gatewayNotFound(nil))
+	assert.True(t, gatewayNotFound(&midtrans.Error{Message: "Transaction doesn't exist.", StatusCode: http.StatusNotFound}))
+
+	// Outages and configuration errors say nothing about the order
+	assert.False(t, gatewayNotFound(&midtrans.Error{Message: "timeout", StatusCode: 0}))
+	assert.False(t, gatewayNotFound(&midtrans.Error{Message: "Internal Server Error", StatusCode: http.StatusInternalServerError}))
+	assert.False(t, gatewayNotFound(&midtrans.Error{Message: "Unauthorized", StatusCode: http.StatusUnauthorized}))
+}
+
+func TestApplyPaymentStatusAfterExpiry(t *testing.T) {
+	ctx := context.Background()
+
+	// A top-up the reconciler already expired locally
+	orderID := fmt.Sprintf("TEST-EXPIRED-%d", time.Now().UnixNano())
+	_, err := config.Pool.Exec(ctx, `
+		INSERT INTO transaction (customer_id, transaction_type, amount, transaction_method, status, order_id)
+		VALUES (1, 'Top-Up', 10000, 'GoPay', 'expire', $1)`, orderID)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer config.Pool.Exec(ctx, `DELETE FROM transaction WHERE order_id = $1`, orderID)
+
+	var walletBefore float64
+	assert.NoError(t, config.Pool.QueryRow(ctx, `SELECT wallet FROM customer WHERE id = 1`).Scan(&walletBefore))
+
+	// The gateway settles it late, twice
+	for i := 0; i < 2; i++ {
+		previousStatus, err := ApplyPaymentStatus(ctx, orderID, "settlement", "10000.00")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "expire", previousStatus)
+		}
+	}
+
+	// The order stays expired, nothing is credited and the conflict is recorded once
+	var status string
+	var walletAfter float64
+	var conflicts int
+	assert.NoError(t, config.Pool.QueryRow(ctx, `SELECT status FROM transaction WHERE order_id = $1`, orderID).Scan(&status))
+	assert.NoError(t, config.Pool.QueryRow(ctx, `SELECT wallet FROM customer WHERE id = 1`).Scan(&walletAfter))
+	assert.NoError(t, config.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM payment_reconciliation WHERE order_id = $1 AND action = $2`,
+		orderID, ActionFinalStatusConflict).Scan(&conflicts))
+	assert.Equal(t, "expire", status)
+	assert.Equal(t, walletBefore, walletAfter)
+	assert.Equal(t, 1, conflicts)
+}
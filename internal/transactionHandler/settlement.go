@@ -0,0 +1,357 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+	audit_handler "w4/p2/milestones/internal/auditHandler"
+	inventory_handler "w4/p2/milestones/internal/inventoryHandler"
+	loan_handler "w4/p2/milestones/internal/loanHandler"
+	loyalty_handler "w4/p2/milestones/internal/loyaltyHandler"
+	membership_handler "w4/p2/milestones/internal/membershipHandler"
+	notification_handler "w4/p2/milestones/internal/notificationHandler"
+	receipt_handler "w4/p2/milestones/internal/receiptHandler"
+	time_package_handler "w4/p2/milestones/internal/timePackageHandler"
+	voucher_handler "w4/p2/milestones/internal/voucherHandler"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// isFinalStatus reports whether a transaction status can no longer change side effects
+func isFinalStatus(status string) bool {
+	switch strings.ToLower(status) {
+	case "settlement", "completed", "expire", "cancel", "deny", "failure", "refund":
+		return true
+	}
+	return false
+}
+
+// ApplyPaymentStatus stores the gateway status of an order and applies its side effects
+// (wallet credit, rental booking, service deduction, receipt) the first time the order settles.
+// Only final statuses are stored, and an order that is already final keeps its status.
+// It is shared by CheckPaymentStatus and the pending payment reconciler, and returns
+// the local status the order had before the update.
+func ApplyPaymentStatus(ctx context.Context, orderID string, gatewayStatus string, grossAmount string) (string, error) {
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the transaction row so the reconciler and a client check cannot settle it twice
+	var transactionType, previousStatus, metadataJSON string
+	var transactionID, customerID int
+	transactionQuery := `SELECT id, transaction_type, customer_id, status, metadata FROM transaction WHERE order_id = $1 FOR UPDATE`
+	err = tx.QueryRow(ctx, transactionQuery, orderID).Scan(&transactionID, &transactionType, &customerID, &previousStatus, &metadataJSON)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch transaction details: %w", err)
+	}
+
+	// Orders that already reached a final status keep their status and side effects. A
+	// payment arriving after the order was closed is left for the admins to resolve.
+	if isFinalStatus(previousStatus) {
+		if strings.EqualFold(gatewayStatus, "settlement") && !strings.EqualFold(previousStatus, "settlement") {
+			if err := recordFinalStatusConflict(ctx, tx, orderID, previousStatus, gatewayStatus); err != nil {
+				return previousStatus, err
+			}
+		}
+		return previousStatus, tx.Commit(ctx)
+	}
+
+	// Only final statuses are stored, so an order in capture or authorize stays pending
+	// and the reconciler keeps checking it until the gateway settles or releases it
+	if !isFinalStatus(gatewayStatus) {
+		return previousStatus, nil
+	}
+
+	// Update the transaction status in the database
+	updateTransactionQuery := `UPDATE transaction SET status = $1 WHERE order_id = $2`
+	_, err = tx.Exec(ctx, updateTransactionQuery, gatewayStatus, orderID)
+	if err != nil {
+		return previousStatus, fmt.Errorf("failed to update transaction: %w", err)
+	}
+
+	switch gatewayStatus {
+	case "settlement":
+		switch transactionType {
+		case "Top-Up":
+			err = settleTopUp(ctx, tx, customerID, grossAmount)
+		case "Rental Payment":
+			var rentalHistoryID int
+			rentalHistoryID, err = settleRentalPayment(ctx, tx, transactionID, customerID, metadataJSON)
+			if err == nil {
+				_, err = receipt_handler.CreateReceipt(ctx, tx, transactionID, &rentalHistoryID)
+			}
+			if err == nil {
+				_, err = loyalty_handler.EarnPoints(ctx, tx, transactionID)
+			}
+		case "Service Payment":
+			err = settleServicePayment(ctx, tx, transactionID, customerID, metadataJSON)
+			if err == nil {
+				_, err = receipt_handler.CreateReceipt(ctx, tx, transactionID, nil)
+			}
+			if err == nil {
+				_, err = loyalty_handler.EarnPoints(ctx, tx, transactionID)
+			}
+		case "Membership Payment":
+			err = settleMembershipPayment(ctx, tx, transactionID, customerID, metadataJSON)
+		}
+	case "expire", "cancel", "deny", "failure":
+		err = releasePendingPayment(ctx, tx, transactionID, customerID, orderID, transactionType, gatewayStatus)
+	}
+	if err != nil {
+		return previousStatus, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return previousStatus, fmt.Errorf("failed to commit payment status: %w", err)
+	}
+	return previousStatus, nil
+}
+
+// recordFinalStatusConflict records an order the gateway settled after it was closed
+// locally, once per order, and notifies the admins so the payment can be refunded or
+// fulfilled by hand
+func recordFinalStatusConflict(ctx context.Context, tx pgx.Tx, orderID string, localStatus string, gatewayStatus string) error {
+	query := `
+		INSERT INTO payment_reconciliation (order_id, local_status, gateway_status, action, checked_at)
+		SELECT $1, $2, $3, $4, NOW()
+		WHERE NOT EXISTS (SELECT 1 FROM payment_reconciliation WHERE order_id = $1 AND action = $4)`
+	tag, err := tx.Exec(ctx, query, orderID, localStatus, gatewayStatus, ActionFinalStatusConflict)
+	if err != nil {
+		return fmt.Errorf("failed to record status conflict of order %s: %w", orderID, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+
+	message := fmt.Sprintf("Order %s was paid after it was closed as %s; its holds were released and nothing was delivered", orderID, localStatus)
+	return notification_handler.NotifyAdmins(ctx, tx, notification_handler.TypePaymentConflict, nil, message)
+}
+
+// settleTopUp credits the customer's wallet with the settled amount
+func settleTopUp(ctx context.Context, tx pgx.Tx, customerID int, grossAmount string) error {
+	updateWalletQuery := `UPDATE customer SET wallet = wallet + $1 WHERE id = $2`
+	_, err := tx.Exec(ctx, updateWalletQuery, grossAmount, customerID)
+	if err != nil {
+		return fmt.Errorf("failed to update wallet balance: %w", err)
+	}
+	return nil
+}
+
+// settleRentalPayment books the computer and services stored in the order metadata and returns the rental history ID
+func settleRentalPayment(ctx context.Context, tx pgx.Tx, transactionID int, customerID int, metadataJSON string) (int, error) {
+	// Deserialize JSON into a map
+	var metadata map[string]interface{}
+	if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+		return 0, fmt.Errorf("failed to parse rental metadata: %w", err)
+	}
+
+	// Validate and convert metadata fields, JSON numbers are float64 in Go
+	adminID, ok := metadata["admin_id"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("invalid admin_id in metadata")
+	}
+	computerID, ok := metadata["computer_id"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("invalid computer_id in metadata")
+	}
+	rentalStart, ok := metadata["rental_start"].(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid rental_start in metadata")
+	}
+	rentalEnd, ok := metadata["rental_end"].(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid rental_end in metadata")
+	}
+	totalCost, ok := metadata["total_cost"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("invalid total_cost in metadata")
+	}
+
+	bundleID, _ := metadata["bundle_id"].(float64) // absent on orders without a bundle
+
+	// Orders placed before prices were recorded are priced at the current hourly rate
+	hourlyRate, ok := metadata["hourly_rate"].(float64)
+	timeDiscount, _ := metadata["time_discount"].(float64)
+	timeTotal, _ := metadata["time_total"].(float64)
+	if !ok {
+		if err := tx.QueryRow(ctx, `SELECT hourly_rate FROM computer WHERE id = $1`, int(computerID)).Scan(&hourlyRate); err != nil {
+			return 0, fmt.Errorf("failed to fetch hourly rate: %w", err)
+		}
+		timeTotal = totalCost
+	}
+
+	// Update rental history
+	rentalHistoryQuery := `
+		INSERT INTO rental_history (customer_id, computer_id, admin_id, rental_start_time, rental_end_time, total_cost, booking_status, bundle_id,
+		                            unit_price, discount, line_total)
+		VALUES ($1, $2, $3, $4, $5, $6, 'Completed', NULLIF($7, 0), $8, $9, $10) RETURNING id`
+	var rentalHistoryID int
+	err := tx.QueryRow(ctx, rentalHistoryQuery, customerID, int(computerID), int(adminID), rentalStart, rentalEnd, totalCost, int(bundleID),
+		hourlyRate, timeDiscount, timeTotal).Scan(&rentalHistoryID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update rental history: %w", err)
+	}
+
+	// Update PC availability
+	updatePCQuery := `UPDATE computer SET isAvailable = FALSE WHERE id = $1`
+	_, err = tx.Exec(ctx, updatePCQuery, int(computerID))
+	if err != nil {
+		return 0, fmt.Errorf("failed to update computer availability: %w", err)
+	}
+
+	// Deduct the services bought with the rental
+	var services []map[string]interface{}
+	if items, ok := metadata["services"].([]interface{}); ok {
+		for _, item := range items {
+			if service, ok := item.(map[string]interface{}); ok {
+				services = append(services, service)
+			}
+		}
+	}
+	if err := settleServiceLines(ctx, tx, transactionID, &rentalHistoryID, customerID, services); err != nil {
+		return 0, err
+	}
+	if _, err := loan_handler.CheckOutLoans(ctx, tx, rentalHistoryID); err != nil {
+		return 0, err
+	}
+
+	// Log general activity in the audit log
+	event := audit_handler.New(audit_handler.ActorSystem, nil, "rental.create", "rental_history", rentalHistoryID).
+		Change(nil, map[string]interface{}{"customer_id": customerID, "computer_id": int(computerID), "rental_start": rentalStart,
+			"rental_end": rentalEnd, "total_cost": totalCost, "transaction_id": transactionID}).
+		Describe("Rental payment completed for Customer %d, with Computer %d", customerID, int(computerID))
+	if err := audit_handler.Record(ctx, tx, event); err != nil {
+		return 0, err
+	}
+	return rentalHistoryID, nil
+}
+
+// settleServicePayment deducts the services stored in the order metadata
+func settleServicePayment(ctx context.Context, tx pgx.Tx, transactionID int, customerID int, metadataJSON string) error {
+	var metadata []map[string]interface{}
+	if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+		return fmt.Errorf("failed to parse metadata: %w", err)
+	}
+	return settleServiceLines(ctx, tx, transactionID, nil, customerID, metadata)
+}
+
+// settleMembershipPayment starts the plan stored in the order metadata
+func settleMembershipPayment(ctx context.Context, tx pgx.Tx, transactionID int, customerID int, metadataJSON string) error {
+	var metadata struct {
+		PlanID    int  `json:"plan_id"`
+		AutoRenew bool `json:"auto_renew"`
+	}
+	if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+		return fmt.Errorf("failed to parse metadata: %w", err)
+	}
+	_, err := membership_handler.ActivateMembership(ctx, tx, customerID, metadata.PlanID, metadata.AutoRenew, transactionID, time.Now())
+	return err
+}
+
+// settleServiceLines sells the reserved stock and records rental_services for paid service lines,
+// linked to the rental when they were bought with one, and credits time packages
+func settleServiceLines(ctx context.Context, tx pgx.Tx, transactionID int, rentalHistoryID *int, customerID int, services []map[string]interface{}) error {
+	// Turn the stock reserved for the order into sales
+	reserved, err := inventory_handler.ConvertReservations(ctx, tx, transactionID)
+	if err != nil {
+		return err
+	}
+
+	for _, service := range services {
+		serviceID := int(service["service_id"].(float64))
+		quantity := int(service["quantity"].(float64))
+
+		// Bundled lines carry the bundle and their share of the bundle price
+		var bundleID *int
+		var bundleAmount *float64
+		if value, ok := service["bundle_id"].(float64); ok {
+			id, amount := int(value), service["bundle_amount"].(float64)
+			bundleID, bundleAmount = &id, &amount
+		}
+
+		// Orders placed before prices were recorded are priced at the current list price
+		unitPrice, ok := service["unit_price"].(float64)
+		discount, _ := service["discount"].(float64)
+		lineTotal, _ := service["line_total"].(float64)
+		if !ok {
+			if err := tx.QueryRow(ctx, `SELECT price FROM service WHERE id = $1`, serviceID).Scan(&unitPrice); err != nil {
+				return fmt.Errorf("failed to fetch price of Service ID %d: %w", serviceID, err)
+			}
+			lineTotal = unitPrice * float64(quantity)
+		}
+
+		// Take stock the reservation no longer covers; an order paid after the stock
+		// ran out is flagged for the admins
+		covered := min(reserved[serviceID], quantity)
+		reserved[serviceID] -= covered
+		if quantity > covered {
+			reason := fmt.Sprintf("GoPay order of Customer %d, reservation expired", customerID)
+			_, err := inventory_handler.MoveStock(ctx, tx, serviceID, inventory_handler.MovementSale, covered-quantity, reason, &transactionID, nil)
+			var outOfStock *inventory_handler.StockError
+			if errors.As(err, &outOfStock) {
+				message := fmt.Sprintf("Transaction %d was paid but could not be fulfilled: %s", transactionID, outOfStock.Message)
+				if err := notification_handler.NotifyAdmins(ctx, tx, notification_handler.TypeOversold, &serviceID, message); err != nil {
+					return err
+				}
+			} else if err != nil {
+				return fmt.Errorf("failed to update quantity for Service ID %d: %w", serviceID, err)
+			}
+		}
+
+		// Log the service purchase in the audit log
+		event := audit_handler.New(audit_handler.ActorSystem, nil, "service.sell", "service", serviceID).
+			Change(nil, map[string]interface{}{"customer_id": customerID, "rental_history_id": rentalHistoryID, "quantity": quantity,
+				"unit_price": unitPrice, "discount": discount, "transaction_id": transactionID}).
+			Describe("Customer %d purchased Service ID %d (Quantity: %d)", customerID, serviceID, quantity)
+		if err := audit_handler.Record(ctx, tx, event); err != nil {
+			return err
+		}
+
+		// Insert into the rental_services table (optional if related to a rental)
+		rentalServiceQuery := `
+			INSERT INTO rental_services (rental_history_id, service_id, quantity, created_at, transaction_id, bundle_id, bundle_amount,
+			                             unit_price, discount, line_total)
+			VALUES ($1, $2, $3, NOW(), $4, $5, $6, $7, $8, $9)`
+		_, err := tx.Exec(ctx, rentalServiceQuery, rentalHistoryID, serviceID, quantity, transactionID, bundleID, bundleAmount,
+			unitPrice, discount, lineTotal)
+		if err != nil {
+			return fmt.Errorf("failed to log service into rental_services for Service ID %d: %w", serviceID, err)
+		}
+	}
+
+	// Credit the time packages among the lines
+	_, err = time_package_handler.CreditPackages(ctx, tx, transactionID)
+	return err
+}
+
+// releasePendingPayment closes an order that will never settle, giving back the voucher
+// use, loyalty points, membership hours, prepaid minutes and stock it reserved, and logs the outcome.
+func releasePendingPayment(ctx context.Context, tx pgx.Tx, transactionID int, customerID int, orderID string, transactionType string, gatewayStatus string) error {
+	if err := voucher_handler.ReleaseTransactionVoucher(ctx, tx, transactionID); err != nil {
+		return err
+	}
+	if err := time_package_handler.ReleaseTransactionTime(ctx, tx, transactionID); err != nil {
+		return err
+	}
+	if err := inventory_handler.ReleaseTransactionReservations(ctx, tx, transactionID, "Payment "+gatewayStatus); err != nil {
+		return err
+	}
+	if err := loyalty_handler.RefundTransactionPoints(ctx, tx, transactionID); err != nil {
+		return err
+	}
+	if err := membership_handler.ReleaseTransactionMembership(ctx, tx, transactionID); err != nil {
+		return err
+	}
+
+	event := audit_handler.New(audit_handler.ActorSystem, nil, "transaction.release", "transaction", transactionID).
+		Change(map[string]string{"status": "Pending"}, map[string]string{"status": gatewayStatus}).
+		Describe("%s %s for Customer %d closed with status %s", transactionType, orderID, customerID, gatewayStatus)
+	return audit_handler.Record(ctx, tx, event)
+}
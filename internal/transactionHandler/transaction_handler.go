@@ -7,7 +7,6 @@ import (
 	"time"
 
 	config "w4/p2/milestones/config/database"
-	"encoding/json"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/labstack/echo/v4"
@@ -162,171 +161,14 @@ func CheckPaymentStatus(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch payment status"})
 	}
 
-	// Update the transaction status in the database
-	updateTransactionQuery := `UPDATE transaction SET status = $1 WHERE order_id = $2`
-	_, dbErr := config.Pool.Exec(context.Background(), updateTransactionQuery, resp.TransactionStatus, orderID)
-	if dbErr != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update transaction"})
-	}
-
 	// print the transaction status for debugging
 	fmt.Println("resp transaction status: ", resp.TransactionStatus)
 
-	// Handle successful payment
-	if resp.TransactionStatus == "settlement" {
-		// Fetch transaction type and customer ID from the database
-		var transactionType string
-		var customerID int
-		
-		transactionQuery := `SELECT transaction_type, customer_id FROM transaction WHERE order_id = $1`
-		err := config.Pool.QueryRow(context.Background(), transactionQuery, orderID).Scan(&transactionType, &customerID)
-		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch transaction details"})
-		}
-
-		fmt.Println("transaction type: ", transactionType)
-
-		// update wallet for top up
-		if transactionType == "Top-Up" {
-			updateWalletQuery := `UPDATE customer SET wallet = wallet + $1 WHERE id = $2`
-			_, err := config.Pool.Exec(context.Background(), updateWalletQuery, resp.GrossAmount, customerID)
-			if err != nil {
-				return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update wallet balance"})
-			}
-		} else if transactionType == "Rental Payment" {
-			// Fetch metadata and deserialize
-			var metadataJSON string
-			metadataQuery := `SELECT metadata FROM transaction WHERE order_id = $1`
-			err := config.Pool.QueryRow(context.Background(), metadataQuery, orderID).Scan(&metadataJSON)
-			
-			// fmt.Println("first err: ", err)
-			
-			if err != nil {
-				return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch rental metadata"})
-			}
-			
-			// Deserialize JSON into a map
-			var metadata map[string]interface{}
-			err = json.Unmarshal([]byte(metadataJSON), &metadata)
-			if err != nil {
-				return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to parse rental metadata"})
-			}
-			
-			// fmt.Println("second err: ", err)	
-
-			// Validate and convert metadata fields
-			adminID, ok := metadata["admin_id"].(float64) // JSON numbers are float64 in Go
-			
-			// fmt.Println("third err: ", ok)				
-
-			if !ok {
-				return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Invalid admin_id in metadata"})
-			}
-
-			computerID, ok := metadata["computer_id"].(float64) // JSON numbers are float64 in Go
-			
-			// fmt.Println("third err: ", ok)				
-
-			if !ok {
-				return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Invalid computer_id in metadata"})
-			}
-		
-			rentalStart, ok := metadata["rental_start"].(string)
-			
-			// fmt.Println("fourth err: ", ok)
-
-			if !ok {
-				return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Invalid rental_start in metadata"})
-			}
-		
-			rentalEnd, ok := metadata["rental_end"].(string)
-
-			// fmt.Println("fifth err: ", ok)
-
-			if !ok {
-				return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Invalid rental_end in metadata"})
-			}
-			
-			totalCost, ok := metadata["total_cost"].(float64)
-			if !ok {
-				return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Invalid activity_desc in metadata"})
-			}
-		
-			// Update rental history
-			rentalHistoryQuery := `
-			INSERT INTO rental_history (customer_id, computer_id, admin_id, rental_start_time, rental_end_time, total_cost, booking_status)
-			VALUES ($1, $2, $3, $4, $5, $6, 'Completed')`
-			
-			_, err = config.Pool.Exec(context.Background(), rentalHistoryQuery,
-				customerID, int(computerID), adminID, rentalStart, rentalEnd, totalCost)
-			if err != nil {
-				return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update rental history"})
-			}
-	
-			// Update PC availability
-			updatePCQuery := `UPDATE computer SET isAvailable = FALSE WHERE id = $1`
-			_, err = config.Pool.Exec(context.Background(), updatePCQuery, int(computerID))
-			if err != nil {
-				return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update computer availability"})
-			}
-		
-			// Log general activity in the log table
-			desc := fmt.Sprintf("Rental payment completed for Customer %d, with Computer %d", customerID, int(computerID))
-			logQuery := `INSERT INTO log (description) VALUES ($1)`
-			_, err = config.Pool.Exec(context.Background(), logQuery, desc)
-			
-			if err != nil {
-				return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log activity"})
-			}
-		} else if transactionType == "Service Payment" {
-
-			// Fetch metadata for deferred service deduction
-			var metadataJSON string
-			metadataQuery := `SELECT metadata FROM transaction WHERE order_id = $1`
-			err := config.Pool.QueryRow(context.Background(), metadataQuery, orderID).Scan(&metadataJSON)
-			fmt.Println("error invoked from check payment status: ", err)	
-			if err != nil {
-				return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch metadata"})
-			}
-		
-			var metadata []map[string]interface{}
-			err = json.Unmarshal([]byte(metadataJSON), &metadata)
-			if err != nil {
-				return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to parse metadata"})
-			}
-		
-			// Deduct service quantities
-			for _, service := range metadata {
-				serviceID := int(service["service_id"].(float64))
-				quantity := int(service["quantity"].(float64))
-				
-				// update quantity of said product
-				updateQuantityQuery := "UPDATE service SET quantity = quantity - $1 WHERE id = $2"
-				_, err = config.Pool.Exec(context.Background(), updateQuantityQuery, quantity, serviceID)
-				if err != nil {
-					return c.JSON(http.StatusInternalServerError, map[string]string{"message": fmt.Sprintf("Failed to update quantity for Service ID %d", serviceID)})
-				}
-
-				// Log the service purchase in the log table
-				logDesc := fmt.Sprintf("Customer %d purchased Service ID %d (Quantity: %d)", customerID, serviceID, quantity)
-				logQuery := `INSERT INTO log (description) VALUES ($1)`
-				_, err = config.Pool.Exec(context.Background(), logQuery, logDesc)
-				if err != nil {
-					return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log service purchase"})
-				}
-				
-				// Insert into the rental_services table (optional if related to a rental)
-				rentalServiceQuery := `
-					INSERT INTO rental_services (service_id, quantity, created_at)
-					VALUES ($1, $2, NOW())`
-				_, err = config.Pool.Exec(context.Background(), rentalServiceQuery, serviceID, quantity)
-				if err != nil {
-					return c.JSON(http.StatusInternalServerError, map[string]string{
-						"message": fmt.Sprintf("Failed to log service into rental_services for Service ID %d", serviceID),
-					})
-				}
-			}
-		}
+	// Update the local status and apply settlement side effects
+	_, applyErr := ApplyPaymentStatus(context.Background(), orderID, resp.TransactionStatus, resp.GrossAmount)
+	if applyErr != nil {
+		fmt.Println("error invoked from check payment status: ", applyErr)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update transaction"})
 	}
 
 	return c.JSON(http.StatusOK, resp)
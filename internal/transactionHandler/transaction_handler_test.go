@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/labstack/echo/v4"
@@ -83,4 +84,31 @@ func TestCheckPaymentStatus(t *testing.T) {
         json.Unmarshal(rec.Body.Bytes(), &response)
         assert.Equal(t, "pending", response["transaction_status"]) // Update expected value to "pending"
     }
-}
\ No newline at end of file
+}
+func TestCheckTransferLimits(t *testing.T) {
+	// Within the daily amount and count
+	assert.NoError(t, checkTransferLimits(100000, 500000, 3))
+
+	// Sending past the daily amount is refused
+	var transferErr *TransferError
+	err := checkTransferLimits(600000, DailyTransferLimit-500000, 3)
+	if assert.ErrorAs(t, err, &transferErr) {
+		assert.Equal(t, "Transfer exceeds the daily limit. Remaining today: 500000", transferErr.Message)
+	}
+
+	// Too many transfers in a day are refused
+	assert.Error(t, checkTransferLimits(1000, 0, DailyTransferCount))
+}
+
+func TestStartOfDay(t *testing.T) {
+	jakarta, err := time.LoadLocation(transferTimezone)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// 01:00 in Jakarta is still the previous day in UTC, the limits reset at Jakarta midnight
+	now := time.Date(2024, 12, 17, 18, 0, 0, 0, time.UTC)
+	start := startOfDay(now, jakarta)
+	assert.True(t, start.Equal(time.Date(2024, 12, 17, 17, 0, 0, 0, time.UTC)))
+	assert.True(t, startOfDay(now.Add(-2*time.Hour), jakarta).Equal(start.AddDate(0, 0, -1)))
+}
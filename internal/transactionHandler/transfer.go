@@ -0,0 +1,481 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+	audit_handler "w4/p2/milestones/internal/auditHandler"
+	export_handler "w4/p2/milestones/internal/exportHandler"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// TransferConfirmThreshold is the amount above which a transfer must be confirmed
+	TransferConfirmThreshold = 500000
+	// DailyTransferLimit is the most a customer can send per day
+	DailyTransferLimit = 2000000
+	// DailyTransferCount is the number of transfers a customer can send per day
+	DailyTransferCount = 10
+	// transferConfirmWindow is how long a transfer waits for confirmation
+	transferConfirmWindow = 10 * time.Minute
+	// transferTimezone is the timezone whose midnight starts a new day of limits
+	transferTimezone = "Asia/Jakarta"
+)
+
+// Transfer statuses
+const (
+	TransferPending   = "Pending"
+	TransferCompleted = "Completed"
+)
+
+// TransferRequest defines the payload to send wallet balance to another customer
+type TransferRequest struct {
+	Recipient string  `json:"recipient" validate:"required"` // username or email
+	Amount    float64 `json:"amount" validate:"required"`
+	Note      string  `json:"note"`
+}
+
+// Transfer is a wallet transfer between two customers
+type Transfer struct {
+	ID          int        `json:"id"`
+	SenderID    int        `json:"sender_id"`
+	RecipientID int        `json:"recipient_id"`
+	Recipient   string     `json:"recipient"` // recipient username
+	Amount      float64    `json:"amount"`
+	Note        string     `json:"note"`
+	Status      string     `json:"status"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // confirmation deadline of pending transfers
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// WalletTransaction is one entry of a customer's transaction history
+type WalletTransaction struct {
+	ID              int       `json:"id"`
+	TransactionType string    `json:"transaction_type"`
+	Amount          float64   `json:"amount"`
+	Method          *string   `json:"transaction_method"`
+	Status          string    `json:"status"`
+	Counterparty    *string   `json:"counterparty,omitempty"` // other customer of a transfer
+	TransactionDate time.Time `json:"transaction_date"`
+}
+
+// TransferError explains why a transfer cannot go through
+type TransferError struct {
+	Message string
+}
+
+func (e *TransferError) Error() string {
+	return e.Message
+}
+
+// checkTransferLimits enforces the daily amount and count limits on a new transfer
+func checkTransferLimits(amount float64, sentToday float64, transfersToday int) error {
+	if transfersToday >= DailyTransferCount {
+		return &TransferError{Message: fmt.Sprintf("Daily limit of %d transfers reached", DailyTransferCount)}
+	}
+	if sentToday+amount > DailyTransferLimit {
+		return &TransferError{Message: fmt.Sprintf("Transfer exceeds the daily limit. Remaining today: %.0f", max(0, DailyTransferLimit-sentToday))}
+	}
+	return nil
+}
+
+// startOfDay is the midnight in loc that started the day of now
+func startOfDay(now time.Time, loc *time.Location) time.Time {
+	local := now.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+}
+
+// transferLimitsUsed returns how much and how many times a customer sent today, in Jakarta
+func transferLimitsUsed(ctx context.Context, db config.DBTX, senderID int) (float64, int, error) {
+	loc, err := time.LoadLocation(transferTimezone)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to load transfer timezone: %w", err)
+	}
+
+	var sentToday float64
+	var transfersToday int
+	query := `
+		SELECT COALESCE(SUM(amount), 0), COUNT(*)
+		FROM wallet_transfer
+		WHERE sender_id = $1 AND status = $2 AND completed_at >= $3::TIMESTAMPTZ`
+	if err := db.QueryRow(ctx, query, senderID, TransferCompleted, startOfDay(time.Now(), loc)).Scan(&sentToday, &transfersToday); err != nil {
+		return 0, 0, fmt.Errorf("failed to fetch today's transfers: %w", err)
+	}
+	return sentToday, transfersToday, nil
+}
+
+// completeTransfer moves the balance of a pending transfer and records the paired
+// transactions. Both customer rows are locked in ID order so opposite transfers
+// cannot deadlock, and the limits and balance are checked under the lock. event is the
+// audit event of the request completing it.
+func completeTransfer(ctx context.Context, tx pgx.Tx, transfer Transfer, event audit_handler.Event) error {
+	rows, err := tx.Query(ctx, `SELECT id, wallet FROM customer WHERE id IN ($1, $2) ORDER BY id FOR UPDATE`, transfer.SenderID, transfer.RecipientID)
+	if err != nil {
+		return fmt.Errorf("failed to lock customers: %w", err)
+	}
+	wallets := map[int]float64{}
+	for rows.Next() {
+		var id int
+		var wallet float64
+		if err := rows.Scan(&id, &wallet); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to parse customer wallet: %w", err)
+		}
+		wallets[id] = wallet
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(wallets) != 2 {
+		return &TransferError{Message: "Recipient not found"}
+	}
+
+	sentToday, transfersToday, err := transferLimitsUsed(ctx, tx, transfer.SenderID)
+	if err != nil {
+		return err
+	}
+	if err := checkTransferLimits(transfer.Amount, sentToday, transfersToday); err != nil {
+		return err
+	}
+	if wallets[transfer.SenderID] < transfer.Amount {
+		return &TransferError{Message: "Insufficient wallet balance"}
+	}
+
+	// Move the balance
+	walletQuery := `UPDATE customer SET wallet = wallet + $1 WHERE id = $2`
+	if _, err := tx.Exec(ctx, walletQuery, -transfer.Amount, transfer.SenderID); err != nil {
+		return fmt.Errorf("failed to debit sender wallet: %w", err)
+	}
+	if _, err := tx.Exec(ctx, walletQuery, transfer.Amount, transfer.RecipientID); err != nil {
+		return fmt.Errorf("failed to credit recipient wallet: %w", err)
+	}
+
+	// Record the paired transactions in both histories
+	transactionQuery := `
+		INSERT INTO transaction (customer_id, transaction_type, amount, transaction_method, status, transaction_date, metadata)
+		VALUES ($1, $2, $3, 'Wallet', 'settlement', NOW(), $4) RETURNING id`
+	metadata := map[string]interface{}{"transfer_id": transfer.ID}
+	var outID, inID int
+	if err := tx.QueryRow(ctx, transactionQuery, transfer.SenderID, "Transfer Out", transfer.Amount, metadata).Scan(&outID); err != nil {
+		return fmt.Errorf("failed to log sender transaction: %w", err)
+	}
+	if err := tx.QueryRow(ctx, transactionQuery, transfer.RecipientID, "Transfer In", transfer.Amount, metadata).Scan(&inID); err != nil {
+		return fmt.Errorf("failed to log recipient transaction: %w", err)
+	}
+
+	updateQuery := `
+		UPDATE wallet_transfer
+		SET status = $1, out_transaction_id = $2, in_transaction_id = $3, completed_at = NOW()
+		WHERE id = $4`
+	if _, err := tx.Exec(ctx, updateQuery, TransferCompleted, outID, inID, transfer.ID); err != nil {
+		return fmt.Errorf("failed to complete transfer %d: %w", transfer.ID, err)
+	}
+
+	before := transfer
+	transfer.Status = TransferCompleted
+	event = event.Change(before, transfer).
+		Describe("Customer %d transferred %.0f to Customer %d (Transfer %d)", transfer.SenderID, transfer.Amount, transfer.RecipientID, transfer.ID)
+	return audit_handler.Record(ctx, tx, event)
+}
+
+// transferErrorResponse writes an error returned while making a transfer
+func transferErrorResponse(c echo.Context, err error) error {
+	var transferErr *TransferError
+	if errors.As(err, &transferErr) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": transferErr.Message})
+	}
+	fmt.Println("Transfer error:", err)
+	return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to transfer wallet balance"})
+}
+
+// TransferWallet godoc
+// @Summary Transfer wallet balance
+// @Description Send wallet balance to another customer identified by username or email. Transfers above the confirmation threshold are held until confirmed. Each transfer is recorded as a Transfer Out and a Transfer In transaction, and daily amount and count limits apply.
+// @Tags Transactions
+// @Accept json
+// @Produce json
+// @Param request body TransferRequest true "Transfer details"
+// @Success 200 {object} map[string]interface{} "Transfer completed"
+// @Success 202 {object} map[string]interface{} "Transfer awaiting confirmation"
+// @Failure 400 {object} map[string]string "Invalid request, limit reached or insufficient balance"
+// @Failure 404 {object} map[string]string "Recipient not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /customer/wallet/transfer [post]
+func TransferWallet(c echo.Context) error {
+	// Extract customer ID from JWT claims
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	customerID := int(claims["customer_id"].(float64))
+
+	var req TransferRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+	req.Recipient = strings.TrimSpace(req.Recipient)
+	if req.Recipient == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Recipient username or email is required"})
+	}
+	if req.Amount <= 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Transfer amount must be greater than zero"})
+	}
+
+	ctx := context.Background()
+	transfer := Transfer{SenderID: customerID, Amount: req.Amount, Note: req.Note}
+	recipientQuery := `SELECT id, username FROM customer WHERE LOWER(username) = LOWER($1) OR LOWER(email) = LOWER($1)`
+	err := config.Pool.QueryRow(ctx, recipientQuery, req.Recipient).Scan(&transfer.RecipientID, &transfer.Recipient)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Recipient not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to find recipient"})
+	}
+	if transfer.RecipientID == customerID {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Cannot transfer to your own wallet"})
+	}
+
+	// Large transfers wait for the sender to confirm
+	if req.Amount > TransferConfirmThreshold {
+		sentToday, transfersToday, err := transferLimitsUsed(ctx, config.Pool, customerID)
+		if err != nil {
+			return transferErrorResponse(c, err)
+		}
+		if err := checkTransferLimits(req.Amount, sentToday, transfersToday); err != nil {
+			return transferErrorResponse(c, err)
+		}
+
+		expiresAt := time.Now().Add(transferConfirmWindow)
+		transfer.ExpiresAt = &expiresAt
+		insertQuery := `
+			INSERT INTO wallet_transfer (sender_id, recipient_id, amount, note, status, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+		err = config.Pool.QueryRow(ctx, insertQuery, customerID, transfer.RecipientID, req.Amount, req.Note, TransferPending, expiresAt).Scan(&transfer.ID, &transfer.CreatedAt)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create transfer"})
+		}
+		transfer.Status = TransferPending
+
+		return c.JSON(http.StatusAccepted, map[string]interface{}{
+			"message":  fmt.Sprintf("Transfers above %d must be confirmed at /customer/wallet/transfer/%d/confirm", TransferConfirmThreshold, transfer.ID),
+			"transfer": transfer,
+		})
+	}
+
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	insertQuery := `
+		INSERT INTO wallet_transfer (sender_id, recipient_id, amount, note, status)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+	err = tx.QueryRow(ctx, insertQuery, customerID, transfer.RecipientID, req.Amount, req.Note, TransferPending).Scan(&transfer.ID, &transfer.CreatedAt)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create transfer"})
+	}
+	if err := completeTransfer(ctx, tx, transfer, audit_handler.FromRequest(c, "wallet_transfer.complete", "wallet_transfer", transfer.ID)); err != nil {
+		return transferErrorResponse(c, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to commit transfer"})
+	}
+	transfer.Status = TransferCompleted
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":  "Transfer completed successfully",
+		"transfer": transfer,
+	})
+}
+
+// ConfirmTransfer godoc
+// @Summary Confirm a wallet transfer
+// @Description Confirm a pending transfer above the confirmation threshold before it expires, moving the balance to the recipient
+// @Tags Transactions
+// @Produce json
+// @Param id path int true "Transfer ID"
+// @Success 200 {object} map[string]interface{} "Transfer completed"
+// @Failure 400 {object} map[string]string "Expired, limit reached or insufficient balance"
+// @Failure 404 {object} map[string]string "Transfer not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /customer/wallet/transfer/{id}/confirm [post]
+func ConfirmTransfer(c echo.Context) error {
+	// Extract customer ID from JWT claims
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	customerID := int(claims["customer_id"].(float64))
+
+	transferID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid transfer ID"})
+	}
+
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the transfer so it is confirmed once
+	var transfer Transfer
+	query := `
+		SELECT wt.id, wt.sender_id, wt.recipient_id, cu.username, wt.amount, COALESCE(wt.note, ''), wt.status, wt.expires_at, wt.created_at
+		FROM wallet_transfer wt
+		JOIN customer cu ON cu.id = wt.recipient_id
+		WHERE wt.id = $1 AND wt.sender_id = $2
+		FOR UPDATE OF wt`
+	err = tx.QueryRow(ctx, query, transferID, customerID).Scan(&transfer.ID, &transfer.SenderID, &transfer.RecipientID, &transfer.Recipient,
+		&transfer.Amount, &transfer.Note, &transfer.Status, &transfer.ExpiresAt, &transfer.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Transfer not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch transfer"})
+	}
+	if transfer.Status != TransferPending {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": fmt.Sprintf("Transfer is already %s", strings.ToLower(transfer.Status))})
+	}
+	if transfer.ExpiresAt != nil && time.Now().After(*transfer.ExpiresAt) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Transfer confirmation has expired"})
+	}
+
+	if err := completeTransfer(ctx, tx, transfer, audit_handler.FromRequest(c, "wallet_transfer.complete", "wallet_transfer", transfer.ID)); err != nil {
+		return transferErrorResponse(c, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to commit transfer"})
+	}
+	transfer.Status = TransferCompleted
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":  "Transfer completed successfully",
+		"transfer": transfer,
+	})
+}
+
+// GetWalletTransactions godoc
+// @Summary Get transaction history
+// @Description Retrieve the customer's transactions, newest first, including top-ups, payments and wallet transfers with the other customer. CSV and XLSX exports cover the whole history unless a limit is given.
+// @Tags Transactions
+// @Produce json
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param limit query int false "Number of transactions (default 50)"
+// @Param offset query int false "Transactions to skip"
+// @Param format query string false "json, csv or xlsx (or use the Accept header)"
+// @Param lang query string false "Language of export column headers (en or id)"
+// @Success 200 {object} map[string]interface{} "Transaction history"
+// @Failure 400 {object} map[string]string "Invalid pagination or format"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /customer/wallet/transactions [get]
+func GetWalletTransactions(c echo.Context) error {
+	// Extract customer ID from JWT claims
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	customerID := int(claims["customer_id"].(float64))
+
+	format, err := export_handler.RequestedFormat(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+
+	// Exports stream the whole history unless limited; NULL is no limit
+	var limit *int
+	if format == export_handler.FormatJSON {
+		defaultLimit := 50
+		limit = &defaultLimit
+	}
+	offset := 0
+	if value := c.QueryParam("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || (format == export_handler.FormatJSON && parsed > 200) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "limit must be between 1 and 200"})
+		}
+		limit = &parsed
+	}
+	if value := c.QueryParam("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid offset"})
+		}
+		offset = parsed
+	}
+
+	query := `
+		SELECT t.id, t.transaction_type, t.amount, t.transaction_method, t.status, cp.username, t.transaction_date
+		FROM transaction t
+		LEFT JOIN wallet_transfer wt ON wt.out_transaction_id = t.id OR wt.in_transaction_id = t.id
+		LEFT JOIN customer cp ON cp.id = CASE WHEN wt.out_transaction_id = t.id THEN wt.recipient_id ELSE wt.sender_id END
+		WHERE t.customer_id = $1
+		ORDER BY t.transaction_date DESC, t.id DESC
+		LIMIT $2 OFFSET $3`
+	rows, err := config.Pool.Query(context.Background(), query, customerID, limit, offset)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch transactions"})
+	}
+	defer rows.Close()
+
+	if format != export_handler.FormatJSON {
+		return exportWalletTransactions(c, format, rows)
+	}
+
+	transactions := []WalletTransaction{}
+	for rows.Next() {
+		var t WalletTransaction
+		if err := rows.Scan(&t.ID, &t.TransactionType, &t.Amount, &t.Method, &t.Status, &t.Counterparty, &t.TransactionDate); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to parse transactions"})
+		}
+		transactions = append(transactions, t)
+	}
+	if err := rows.Err(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to parse transactions"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Transactions retrieved successfully",
+		"data":    transactions,
+	})
+}
+
+// walletTransactionColumns are the columns of a transaction history export
+var walletTransactionColumns = []export_handler.Column{
+	{English: "Transaction ID", Indonesian: "ID Transaksi", Kind: export_handler.KindNumber},
+	{English: "Date", Indonesian: "Tanggal", Kind: export_handler.KindDate},
+	{English: "Type", Indonesian: "Jenis", Kind: export_handler.KindText},
+	{English: "Amount", Indonesian: "Jumlah", Kind: export_handler.KindCurrency},
+	{English: "Method", Indonesian: "Metode", Kind: export_handler.KindText},
+	{English: "Status", Indonesian: "Status", Kind: export_handler.KindText},
+	{English: "Counterparty", Indonesian: "Pihak Lain", Kind: export_handler.KindText},
+}
+
+// exportWalletTransactions streams transaction history rows into a spreadsheet as they are read
+func exportWalletTransactions(c echo.Context, format string, rows pgx.Rows) error {
+	w, err := export_handler.NewWriter(c, format, "transactions", walletTransactionColumns)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+	for rows.Next() {
+		var t WalletTransaction
+		if err := rows.Scan(&t.ID, &t.TransactionType, &t.Amount, &t.Method, &t.Status, &t.Counterparty, &t.TransactionDate); err != nil {
+			return fmt.Errorf("failed to parse transactions: %w", err)
+		}
+		if err := w.WriteRow(t.ID, t.TransactionDate, t.TransactionType, t.Amount, t.Method, t.Status, t.Counterparty); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read transactions: %w", err)
+	}
+	return w.Close()
+}
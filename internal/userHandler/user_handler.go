@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	config "w4/p2/milestones/config/database"
+	audit_handler "w4/p2/milestones/internal/auditHandler"
 
 	"context"
 	"time"
@@ -97,8 +98,14 @@ func RegisterCustomer(c echo.Context) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Internal Server Error"})
+	}
+	defer tx.Rollback(ctx)
+
 	// query row 1: insert to users 
-	err = config.Pool.QueryRow(ctx, customer_query, req.Name, req.Username, req.Email, string(hashPassword)).Scan(&customerID)
+	err = tx.QueryRow(ctx, customer_query, req.Name, req.Username, req.Email, string(hashPassword)).Scan(&customerID)
 	if err != nil {
 		fmt.Println("Error inserting into Customers table:", err)
 
@@ -111,12 +118,15 @@ func RegisterCustomer(c echo.Context) error {
 	}
 
 	// Log the registration
-	logDesc := fmt.Sprintf("Customer %s (ID: %d) registered successfully", req.Name, customerID)
-	logQuery := `INSERT INTO log (description) VALUES ($1)`
-	_, logErr := config.Pool.Exec(context.Background(), logQuery, logDesc)
-	if logErr != nil {
+	event := audit_handler.FromRequest(c, "customer.register", "customer", customerID).As(audit_handler.ActorCustomer, customerID).
+		Change(nil, map[string]interface{}{"name": req.Name, "username": req.Username, "email": req.Email}).
+		Describe("Customer %s (ID: %d) registered successfully", req.Name, customerID)
+	if logErr := audit_handler.Record(ctx, tx, event); logErr != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log registration"})
 	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Internal Server Error"})
+	}
 
     return c.JSON(http.StatusOK, map[string]interface{}{
         "message": fmt.Sprintf(`User %s registered successfully`,req.Name),
@@ -164,20 +174,29 @@ func LoginCustomer(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Invalid Generate Token"})
 	}
 
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update token"})
+	}
+	defer tx.Rollback(ctx)
+
 	// Update the jwt_token column in the database
 	updateQuery := "UPDATE customer SET jwt_token = $1 WHERE id = $2"
-	_, err = config.Pool.Exec(context.Background(), updateQuery, tokenString, customer.ID)
+	_, err = tx.Exec(ctx, updateQuery, tokenString, customer.ID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update token"})
 	}
 
 	// Log the login
-    logDesc := fmt.Sprintf("Customer %d logged in successfully", customer.ID)
-    logQuery := `INSERT INTO log (description) VALUES ($1)`
-    _, logErr := config.Pool.Exec(context.Background(), logQuery, logDesc)
-    if logErr != nil {
+    event := audit_handler.FromRequest(c, "customer.login", "customer", customer.ID).As(audit_handler.ActorCustomer, customer.ID).
+        Describe("Customer %d logged in successfully", customer.ID)
+    if logErr := audit_handler.Record(ctx, tx, event); logErr != nil {
         return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log login"})
     }
+    if err := tx.Commit(ctx); err != nil {
+        return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update token"})
+    }
 
 	// return ok status and login response
 	return c.JSON(http.StatusOK, LoginResponse{Token: tokenString})
@@ -215,8 +234,14 @@ func RegisterAdmin(c echo.Context) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Internal Server Error"})
+	}
+	defer tx.Rollback(ctx)
+
 	// query row 1: insert to users 
-	err = config.Pool.QueryRow(ctx, admin_query, req.Username, string(hashPassword), req.Role).Scan(&adminID)
+	err = tx.QueryRow(ctx, admin_query, req.Username, string(hashPassword), req.Role).Scan(&adminID)
 	if err != nil {
 		fmt.Println("Error inserting into Admin table:", err)
 
@@ -229,12 +254,15 @@ func RegisterAdmin(c echo.Context) error {
 	}
 
 	// Log the registration
-    logDesc := fmt.Sprintf("Admin %s (ID: %d) registered successfully", req.Username, adminID)
-    logQuery := `INSERT INTO log (description) VALUES ($1)`
-    _, logErr := config.Pool.Exec(context.Background(), logQuery, logDesc)
-    if logErr != nil {
+    event := audit_handler.FromRequest(c, "admin.register", "admin", adminID).As(audit_handler.ActorAdmin, adminID).
+        Change(nil, map[string]interface{}{"username": req.Username, "role": req.Role}).
+        Describe("Admin %s (ID: %d) registered successfully", req.Username, adminID)
+    if logErr := audit_handler.Record(ctx, tx, event); logErr != nil {
         return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log registration"})
     }
+    if err := tx.Commit(ctx); err != nil {
+        return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Internal Server Error"})
+    }
 
     return c.JSON(http.StatusOK, map[string]interface{}{
         "message": fmt.Sprintf(`Admin %s registered successfully`,req.Username),
@@ -284,20 +312,29 @@ func LoginAdmin(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Invalid Generate Token"})
 	}
 
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update token"})
+	}
+	defer tx.Rollback(ctx)
+
 	// Update the jwt_token column in the database
 	updateQuery := "UPDATE admin SET jwt_token = $1 WHERE id = $2"
-	_, err = config.Pool.Exec(context.Background(), updateQuery, tokenString, admin.ID)
+	_, err = tx.Exec(ctx, updateQuery, tokenString, admin.ID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update token"})
 	}
 
 	// Log the login
-    logDesc := fmt.Sprintf("Admin %d logged in successfully", admin.ID)
-    logQuery := `INSERT INTO log (description) VALUES ($1)`
-    _, logErr := config.Pool.Exec(context.Background(), logQuery, logDesc)
-    if logErr != nil {
+    event := audit_handler.FromRequest(c, "admin.login", "admin", admin.ID).As(audit_handler.ActorAdmin, admin.ID).
+        Describe("Admin %d logged in successfully", admin.ID)
+    if logErr := audit_handler.Record(ctx, tx, event); logErr != nil {
         return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log login"})
     }
+    if err := tx.Commit(ctx); err != nil {
+        return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update token"})
+    }
 
 	// return ok status and login response
 	return c.JSON(http.StatusOK, LoginResponse{Token: tokenString})
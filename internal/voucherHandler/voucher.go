@@ -0,0 +1,288 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Discount types
+const (
+	DiscountPercentage = "percentage"
+	DiscountFixed      = "fixed"
+)
+
+// Voucher structure
+type Voucher struct {
+	ID               int       `json:"id"`
+	Code             string    `json:"code"`
+	DiscountType     string    `json:"discount_type"`  // percentage or fixed
+	DiscountValue    float64   `json:"discount_value"` // percent off, or rupiah off
+	MaxDiscount      *float64  `json:"max_discount"`   // cap for percentage discounts
+	MinSpend         float64   `json:"min_spend"`
+	ValidFrom        time.Time `json:"valid_from"`
+	ValidUntil       time.Time `json:"valid_until"`
+	UsageLimit       *int      `json:"usage_limit"`        // redemptions across all customers, nil for unlimited
+	PerCustomerLimit *int      `json:"per_customer_limit"` // redemptions per customer, nil for unlimited
+	UsedCount        int       `json:"used_count"`
+	ComputerTypes    []string  `json:"computer_types"` // e.g. Gaming, Office; empty with no service IDs applies to everything
+	ServiceIDs       []int     `json:"service_ids"`
+	IsActive         bool      `json:"is_active"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// DiscountableLine is one priced line of a rental or purchase. Computer time lines
+// carry the computer type, service lines the service ID.
+type DiscountableLine struct {
+	ComputerType string
+	ServiceID    int
+	Amount       float64
+}
+
+// AppliedVoucher is the discount a voucher gives on an order
+type AppliedVoucher struct {
+	VoucherID     int       `json:"voucher_id"`
+	Code          string    `json:"code"`
+	Discount      float64   `json:"discount"`
+	LineDiscounts []float64 `json:"-"` // discount per line, in the order the lines were given
+}
+
+// VoucherError explains why a voucher cannot be applied to an order
+type VoucherError struct {
+	Message string
+}
+
+func (e *VoucherError) Error() string {
+	return e.Message
+}
+
+// NormalizeCode upper-cases a voucher code so codes are matched case-insensitively
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+const voucherColumns = `id, code, discount_type, discount_value, max_discount, min_spend, valid_from, valid_until,
+	usage_limit, per_customer_limit, used_count, computer_types, service_ids, is_active, created_at`
+
+// scanVoucher scans a row selected with voucherColumns
+func scanVoucher(row pgx.Row) (Voucher, error) {
+	var v Voucher
+	err := row.Scan(&v.ID, &v.Code, &v.DiscountType, &v.DiscountValue, &v.MaxDiscount, &v.MinSpend, &v.ValidFrom, &v.ValidUntil,
+		&v.UsageLimit, &v.PerCustomerLimit, &v.UsedCount, &v.ComputerTypes, &v.ServiceIDs, &v.IsActive, &v.CreatedAt)
+	return v, err
+}
+
+// covers reports whether the voucher scope includes a line
+func (v Voucher) covers(line DiscountableLine) bool {
+	if len(v.ComputerTypes) == 0 && len(v.ServiceIDs) == 0 {
+		return true
+	}
+	if line.ComputerType != "" {
+		for _, computerType := range v.ComputerTypes {
+			if strings.EqualFold(computerType, line.ComputerType) {
+				return true
+			}
+		}
+	}
+	if line.ServiceID != 0 {
+		for _, serviceID := range v.ServiceIDs {
+			if serviceID == line.ServiceID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ComputeDiscount works out the discount of a voucher on the lines it covers and spreads
+// it across them in proportion to their amounts, so tax is computed on discounted prices.
+func ComputeDiscount(v Voucher, lines []DiscountableLine) (AppliedVoucher, error) {
+	applied := AppliedVoucher{VoucherID: v.ID, Code: v.Code, LineDiscounts: make([]float64, len(lines))}
+
+	subtotal, eligible := 0.0, 0.0
+	for _, line := range lines {
+		subtotal += line.Amount
+		if v.covers(line) {
+			eligible += line.Amount
+		}
+	}
+
+	if subtotal < v.MinSpend {
+		return applied, &VoucherError{Message: fmt.Sprintf("Voucher %s requires a minimum spend of %.0f", v.Code, v.MinSpend)}
+	}
+	if eligible == 0 {
+		return applied, &VoucherError{Message: fmt.Sprintf("Voucher %s does not apply to this order", v.Code)}
+	}
+
+	discount := v.DiscountValue
+	if v.DiscountType == DiscountPercentage {
+		discount = eligible * v.DiscountValue / 100
+		if v.MaxDiscount != nil && discount > *v.MaxDiscount {
+			discount = *v.MaxDiscount
+		}
+	}
+	discount = math.Round(math.Min(discount, eligible))
+	applied.Discount = discount
+
+	// Spread the discount, giving the rounding remainder to the last covered line
+	remaining, last := discount, -1
+	for i, line := range lines {
+		if !v.covers(line) {
+			continue
+		}
+		applied.LineDiscounts[i] = math.Round(discount * line.Amount / eligible)
+		remaining -= applied.LineDiscounts[i]
+		last = i
+	}
+	applied.LineDiscounts[last] += remaining
+
+	return applied, nil
+}
+
+// ApplyVoucher checks that a customer may use the voucher with the given code now and
+// computes its discount on the lines. Unusable vouchers return a *VoucherError.
+func ApplyVoucher(ctx context.Context, db config.DBTX, code string, customerID int, lines []DiscountableLine) (AppliedVoucher, error) {
+	query := `SELECT ` + voucherColumns + ` FROM voucher WHERE code = $1`
+	v, err := scanVoucher(db.QueryRow(ctx, query, NormalizeCode(code)))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return AppliedVoucher{}, &VoucherError{Message: "Voucher not found"}
+	} else if err != nil {
+		return AppliedVoucher{}, fmt.Errorf("failed to fetch voucher: %w", err)
+	}
+
+	now := time.Now()
+	switch {
+	case !v.IsActive:
+		return AppliedVoucher{}, &VoucherError{Message: fmt.Sprintf("Voucher %s is no longer active", v.Code)}
+	case now.Before(v.ValidFrom):
+		return AppliedVoucher{}, &VoucherError{Message: fmt.Sprintf("Voucher %s is not valid until %s", v.Code, v.ValidFrom.Format("2006-01-02 15:04"))}
+	case now.After(v.ValidUntil):
+		return AppliedVoucher{}, &VoucherError{Message: fmt.Sprintf("Voucher %s has expired", v.Code)}
+	case v.UsageLimit != nil && v.UsedCount >= *v.UsageLimit:
+		return AppliedVoucher{}, &VoucherError{Message: fmt.Sprintf("Voucher %s has been fully redeemed", v.Code)}
+	}
+
+	if v.PerCustomerLimit != nil {
+		var used int
+		countQuery := `SELECT COUNT(*) FROM voucher_redemption WHERE voucher_id = $1 AND customer_id = $2`
+		if err := db.QueryRow(ctx, countQuery, v.ID, customerID).Scan(&used); err != nil {
+			return AppliedVoucher{}, fmt.Errorf("failed to count voucher redemptions: %w", err)
+		}
+		if used >= *v.PerCustomerLimit {
+			return AppliedVoucher{}, &VoucherError{Message: fmt.Sprintf("Voucher %s has already been used the maximum number of times", v.Code)}
+		}
+	}
+
+	return ComputeDiscount(v, lines)
+}
+
+// ReserveVoucher takes one use of the voucher for the customer before they pay and
+// returns the redemption to link to the transaction. It fails with a *VoucherError if
+// another order took the last use, or the customer's last use, since the quote.
+func ReserveVoucher(ctx context.Context, applied AppliedVoucher, customerID int) (int, error) {
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialize reservations of the voucher so the limits are checked against every use
+	var usageLimit, perCustomerLimit *int
+	var usedCount int
+	lockQuery := `SELECT usage_limit, per_customer_limit, used_count FROM voucher WHERE id = $1 FOR UPDATE`
+	if err := tx.QueryRow(ctx, lockQuery, applied.VoucherID).Scan(&usageLimit, &perCustomerLimit, &usedCount); err != nil {
+		return 0, fmt.Errorf("failed to lock voucher %s: %w", applied.Code, err)
+	}
+	if usageLimit != nil && usedCount >= *usageLimit {
+		return 0, &VoucherError{Message: fmt.Sprintf("Voucher %s has been fully redeemed", applied.Code)}
+	}
+
+	if perCustomerLimit != nil {
+		var used int
+		countQuery := `SELECT COUNT(*) FROM voucher_redemption WHERE voucher_id = $1 AND customer_id = $2`
+		if err := tx.QueryRow(ctx, countQuery, applied.VoucherID, customerID).Scan(&used); err != nil {
+			return 0, fmt.Errorf("failed to count voucher redemptions: %w", err)
+		}
+		if used >= *perCustomerLimit {
+			return 0, &VoucherError{Message: fmt.Sprintf("Voucher %s has already been used the maximum number of times", applied.Code)}
+		}
+	}
+
+	var redemptionID int
+	insertQuery := `
+		INSERT INTO voucher_redemption (voucher_id, customer_id, discount_amount)
+		VALUES ($1, $2, $3)
+		RETURNING id`
+	if err := tx.QueryRow(ctx, insertQuery, applied.VoucherID, customerID, applied.Discount).Scan(&redemptionID); err != nil {
+		return 0, fmt.Errorf("failed to reserve voucher %s: %w", applied.Code, err)
+	}
+
+	updateQuery := `UPDATE voucher SET used_count = used_count + 1 WHERE id = $1`
+	if _, err := tx.Exec(ctx, updateQuery, applied.VoucherID); err != nil {
+		return 0, fmt.Errorf("failed to reserve voucher %s: %w", applied.Code, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit voucher reservation: %w", err)
+	}
+	return redemptionID, nil
+}
+
+// CancelReservation gives back a use taken by ReserveVoucher when the order is not placed
+func CancelReservation(ctx context.Context, db config.DBTX, redemptionID int) error {
+	var voucherID int
+	query := `DELETE FROM voucher_redemption WHERE id = $1 AND transaction_id IS NULL RETURNING voucher_id`
+	err := db.QueryRow(ctx, query, redemptionID).Scan(&voucherID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to cancel voucher reservation %d: %w", redemptionID, err)
+	}
+
+	updateQuery := `UPDATE voucher SET used_count = GREATEST(used_count - 1, 0) WHERE id = $1`
+	if _, err := db.Exec(ctx, updateQuery, voucherID); err != nil {
+		return fmt.Errorf("failed to cancel voucher %d reservation: %w", voucherID, err)
+	}
+	return nil
+}
+
+// RecordRedemption links a voucher use reserved by ReserveVoucher to the transaction it discounted
+func RecordRedemption(ctx context.Context, db config.DBTX, redemptionID int, applied AppliedVoucher, transactionID int) error {
+	query := `UPDATE voucher_redemption SET transaction_id = $1 WHERE id = $2`
+	if _, err := db.Exec(ctx, query, transactionID, redemptionID); err != nil {
+		return fmt.Errorf("failed to record voucher redemption: %w", err)
+	}
+
+	updateQuery := `UPDATE transaction SET discount_amount = $1, voucher_id = $2 WHERE id = $3`
+	_, err := db.Exec(ctx, updateQuery, applied.Discount, applied.VoucherID, transactionID)
+	if err != nil {
+		return fmt.Errorf("failed to save discount for transaction %d: %w", transactionID, err)
+	}
+	return nil
+}
+
+// ReleaseTransactionVoucher gives back the voucher use of a transaction that will never
+// settle, so the customer can use the voucher again
+func ReleaseTransactionVoucher(ctx context.Context, db config.DBTX, transactionID int) error {
+	var voucherID int
+	query := `DELETE FROM voucher_redemption WHERE transaction_id = $1 RETURNING voucher_id`
+	err := db.QueryRow(ctx, query, transactionID).Scan(&voucherID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to release voucher of transaction %d: %w", transactionID, err)
+	}
+
+	updateQuery := `UPDATE voucher SET used_count = GREATEST(used_count - 1, 0) WHERE id = $1`
+	if _, err := db.Exec(ctx, updateQuery, voucherID); err != nil {
+		return fmt.Errorf("failed to release voucher %d: %w", voucherID, err)
+	}
+	return nil
+}
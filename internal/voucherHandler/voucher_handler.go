@@ -0,0 +1,261 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	config "w4/p2/milestones/config/database"
+	audit_handler "w4/p2/milestones/internal/auditHandler"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/echo/v4"
+)
+
+// VoucherRequest defines the payload to create or update a voucher
+type VoucherRequest struct {
+	Code             string    `json:"code" validate:"required"`
+	DiscountType     string    `json:"discount_type" validate:"required"` // percentage or fixed
+	DiscountValue    float64   `json:"discount_value" validate:"required"`
+	MaxDiscount      *float64  `json:"max_discount"`
+	MinSpend         float64   `json:"min_spend"`
+	ValidFrom        time.Time `json:"valid_from" validate:"required"`
+	ValidUntil       time.Time `json:"valid_until" validate:"required"`
+	UsageLimit       *int      `json:"usage_limit"`
+	PerCustomerLimit *int      `json:"per_customer_limit"`
+	ComputerTypes    []string  `json:"computer_types"`
+	ServiceIDs       []int     `json:"service_ids"`
+	IsActive         *bool     `json:"is_active"`
+}
+
+// validate checks the fields shared by create and update
+func (req VoucherRequest) validate() string {
+	switch {
+	case NormalizeCode(req.Code) == "":
+		return "Voucher code is required"
+	case req.DiscountType != DiscountPercentage && req.DiscountType != DiscountFixed:
+		return "Discount type must be percentage or fixed"
+	case req.DiscountValue <= 0:
+		return "Discount value must be greater than zero"
+	case req.DiscountType == DiscountPercentage && req.DiscountValue > 100:
+		return "Percentage discount cannot exceed 100"
+	case req.MaxDiscount != nil && *req.MaxDiscount <= 0:
+		return "Maximum discount must be greater than zero"
+	case req.MinSpend < 0:
+		return "Minimum spend cannot be negative"
+	case !req.ValidUntil.After(req.ValidFrom):
+		return "valid_until must be after valid_from"
+	case req.UsageLimit != nil && *req.UsageLimit <= 0:
+		return "Usage limit must be greater than zero"
+	case req.PerCustomerLimit != nil && *req.PerCustomerLimit <= 0:
+		return "Per-customer limit must be greater than zero"
+	}
+	return ""
+}
+
+// requireAdmin returns the admin ID if the JWT belongs to an admin or super-admin
+func requireAdmin(c echo.Context) (int, bool) {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	adminRole, _ := claims["role"].(string)
+	adminID, _ := claims["admin_id"].(float64)
+	return int(adminID), adminRole == "admin" || adminRole == "super-admin"
+}
+
+// isUniqueViolation reports whether err is a duplicate voucher code
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// GetVouchers godoc
+// @Summary List vouchers
+// @Description Retrieve all promo vouchers with their usage
+// @Tags Vouchers
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Vouchers retrieved successfully"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/vouchers [get]
+func GetVouchers(c echo.Context) error {
+	if _, ok := requireAdmin(c); !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only selected admins can perform this action."})
+	}
+
+	query := `SELECT ` + voucherColumns + ` FROM voucher ORDER BY id`
+	rows, err := config.Pool.Query(context.Background(), query)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch vouchers"})
+	}
+	defer rows.Close()
+
+	vouchers := []Voucher{}
+	for rows.Next() {
+		v, err := scanVoucher(rows)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to process vouchers"})
+		}
+		vouchers = append(vouchers, v)
+	}
+
+	if err := rows.Err(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to process vouchers"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Vouchers retrieved successfully",
+		"data":    vouchers,
+	})
+}
+
+// CreateVoucher godoc
+// @Summary Create a voucher
+// @Description Create a promo code with a percentage or fixed discount, validity window, usage limits, minimum spend and optional scope of computer types and services
+// @Tags Vouchers
+// @Accept json
+// @Produce json
+// @Param request body VoucherRequest true "Voucher"
+// @Success 200 {object} Voucher "Created voucher"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 409 {object} map[string]string "Voucher code already exists"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/vouchers [post]
+func CreateVoucher(c echo.Context) error {
+	adminID, ok := requireAdmin(c)
+	if !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only selected admins can perform this action."})
+	}
+
+	var req VoucherRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+	if msg := req.validate(); msg != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": msg})
+	}
+
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO voucher (code, discount_type, discount_value, max_discount, min_spend, valid_from, valid_until,
+		                     usage_limit, per_customer_limit, computer_types, service_ids, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING ` + voucherColumns
+	v, err := scanVoucher(tx.QueryRow(ctx, query,
+		NormalizeCode(req.Code), req.DiscountType, req.DiscountValue, req.MaxDiscount, req.MinSpend, req.ValidFrom, req.ValidUntil,
+		req.UsageLimit, req.PerCustomerLimit, req.ComputerTypes, req.ServiceIDs, isActive))
+	if isUniqueViolation(err) {
+		return c.JSON(http.StatusConflict, map[string]string{"message": "Voucher code already exists"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create voucher"})
+	}
+
+	// Log the admin action
+	event := audit_handler.FromRequest(c, "voucher.create", "voucher", v.ID).Change(nil, v).
+		Describe("Admin (ID: %d) created voucher %s (%s %.2f)", adminID, v.Code, v.DiscountType, v.DiscountValue)
+	if err := audit_handler.Record(ctx, tx, event); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log admin action"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create voucher"})
+	}
+
+	return c.JSON(http.StatusOK, v)
+}
+
+// UpdateVoucher godoc
+// @Summary Update a voucher
+// @Description Change the discount, validity, limits, scope or active flag of a voucher. Redemptions so far are kept.
+// @Tags Vouchers
+// @Accept json
+// @Produce json
+// @Param id path int true "Voucher ID"
+// @Param request body VoucherRequest true "Voucher"
+// @Success 200 {object} Voucher "Updated voucher"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 403 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Voucher not found"
+// @Failure 409 {object} map[string]string "Voucher code already exists"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Router /admin/vouchers/{id} [put]
+func UpdateVoucher(c echo.Context) error {
+	adminID, ok := requireAdmin(c)
+	if !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Unauthorized. Only selected admins can perform this action."})
+	}
+
+	voucherID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid voucher ID"})
+	}
+
+	var req VoucherRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+	if msg := req.validate(); msg != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": msg})
+	}
+
+	ctx := context.Background()
+	tx, err := config.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := scanVoucher(tx.QueryRow(ctx, `SELECT `+voucherColumns+` FROM voucher WHERE id = $1 FOR UPDATE`, voucherID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Voucher not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch voucher"})
+	}
+
+	query := `
+		UPDATE voucher
+		SET code = $1, discount_type = $2, discount_value = $3, max_discount = $4, min_spend = $5, valid_from = $6,
+		    valid_until = $7, usage_limit = $8, per_customer_limit = $9, computer_types = $10, service_ids = $11,
+		    is_active = COALESCE($12, is_active)
+		WHERE id = $13
+		RETURNING ` + voucherColumns
+	v, err := scanVoucher(tx.QueryRow(ctx, query,
+		NormalizeCode(req.Code), req.DiscountType, req.DiscountValue, req.MaxDiscount, req.MinSpend, req.ValidFrom, req.ValidUntil,
+		req.UsageLimit, req.PerCustomerLimit, req.ComputerTypes, req.ServiceIDs, req.IsActive, voucherID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Voucher not found"})
+	} else if isUniqueViolation(err) {
+		return c.JSON(http.StatusConflict, map[string]string{"message": "Voucher code already exists"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update voucher"})
+	}
+
+	// Log the admin action
+	event := audit_handler.FromRequest(c, "voucher.update", "voucher", v.ID).Change(before, v).
+		Describe("Admin (ID: %d) updated voucher %d (%s, active: %t)", adminID, v.ID, v.Code, v.IsActive)
+	if err := audit_handler.Record(ctx, tx, event); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log admin action"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update voucher"})
+	}
+
+	return c.JSON(http.StatusOK, v)
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetVouchers(t *testing.T) {
+	// Setup Echo
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/admin/vouchers", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	// Manually set the JWT claims for an admin
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"admin_id": float64(2),
+		"role":     "admin",
+	})
+	c.Set("user", token)
+
+	err := GetVouchers(c)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.Equal(t, "Vouchers retrieved successfully", response["message"])
+		assert.NotEmpty(t, response["data"])
+	}
+}
+
+func TestComputeDiscount(t *testing.T) {
+	maxDiscount := 5000.0
+	lines := []DiscountableLine{
+		{ComputerType: "Gaming", Amount: 40000},
+		{ServiceID: 2, Amount: 10000},
+	}
+
+	// Percentage discounts are capped and spread over every line
+	voucher := Voucher{ID: 1, Code: "WELCOME10", DiscountType: DiscountPercentage, DiscountValue: 20, MaxDiscount: &maxDiscount}
+	applied, err := ComputeDiscount(voucher, lines)
+	if assert.NoError(t, err) {
+		assert.Equal(t, float64(5000), applied.Discount)
+		assert.Equal(t, []float64{4000, 1000}, applied.LineDiscounts)
+	}
+
+	// Scoped vouchers only discount the lines they cover
+	voucher = Voucher{ID: 2, Code: "GAMING5K", DiscountType: DiscountFixed, DiscountValue: 5000, ComputerTypes: []string{"Gaming"}}
+	applied, err = ComputeDiscount(voucher, lines)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []float64{5000, 0}, applied.LineDiscounts)
+	}
+
+	// Orders below the minimum spend are rejected
+	voucher.MinSpend = 100000
+	_, err = ComputeDiscount(voucher, lines)
+	var voucherErr *VoucherError
+	assert.ErrorAs(t, err, &voucherErr)
+}
@@ -1,11 +1,26 @@
 package main
 
 import (
+	"time"
+
 	"w4/p2/milestones/config/database"
 	user_handler "w4/p2/milestones/internal/userHandler"
 	cust_middleware "w4/p2/milestones/internal/middleware"
 	transaction_handler "w4/p2/milestones/internal/transactionHandler"
 	rental_handler "w4/p2/milestones/internal/rentalHandler"
+	receipt_handler "w4/p2/milestones/internal/receiptHandler"
+	bundle_handler "w4/p2/milestones/internal/bundleHandler"
+	audit_handler "w4/p2/milestones/internal/auditHandler"
+	dashboard_handler "w4/p2/milestones/internal/dashboardHandler"
+	inventory_handler "w4/p2/milestones/internal/inventoryHandler"
+	loan_handler "w4/p2/milestones/internal/loanHandler"
+	loyalty_handler "w4/p2/milestones/internal/loyaltyHandler"
+	membership_handler "w4/p2/milestones/internal/membershipHandler"
+	notification_handler "w4/p2/milestones/internal/notificationHandler"
+	shift_handler "w4/p2/milestones/internal/shiftHandler"
+	tax_handler "w4/p2/milestones/internal/taxHandler"
+	time_package_handler "w4/p2/milestones/internal/timePackageHandler"
+	voucher_handler "w4/p2/milestones/internal/voucherHandler"
 	report_handler_user "w4/p2/milestones/internal/reportHandler/user"	
 	report_handler_admin "w4/p2/milestones/internal/reportHandler/admin"
 	
@@ -25,10 +40,29 @@ func main(){
 	config.InitDB()
 	defer config.CloseDB()
 
+	// reconcile stale pending Midtrans payments in the background
+	transaction_handler.StartPaymentReconciler(5*time.Minute, 15*time.Minute)
+
+	// expire loyalty points past their validity
+	loyalty_handler.StartPointsExpiry(time.Hour)
+
+	// renew memberships from the wallet, or expire them, when they end
+	membership_handler.StartMembershipRenewal(15 * time.Minute)
+
+	// release stock reserved for GoPay orders that were never paid
+	inventory_handler.StartReservationExpiry(time.Minute)
+
+	// flag equipment not returned by the end of its rental
+	loan_handler.StartLoanMonitor(5 * time.Minute)
+
+	// generate and deliver scheduled reports when they are due
+	report_handler_admin.StartReportScheduler(time.Minute)
+
 	e := echo.New()
 
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
+	e.Use(middleware.RequestID())
 
 	// public routes
 	e.POST("/customer/register", user_handler.RegisterCustomer)
@@ -37,6 +71,9 @@ func main(){
 	e.POST("/admin/register", user_handler.RegisterAdmin)
 	e.POST("/admin/login", user_handler.LoginAdmin)
 
+	e.GET("/customer/services", service_handler.GetServiceMenu)
+	e.GET("/customer/bundles", bundle_handler.GetBundleMenu)
+
 	// protected routes for customer using JWT middleware
 	customerGroup := e.Group("/customer")
 	customerGroup.Use(cust_middleware.JWTMiddleware)
@@ -44,15 +81,76 @@ func main(){
 	customerGroup.GET("/wallet/balance", transaction_handler.GetWalletBalance)
 	customerGroup.POST("/wallet/payment", transaction_handler.CreatePayment)
 	customerGroup.GET("/wallet/payment-status/:orderID", transaction_handler.CheckPaymentStatus)
+	customerGroup.GET("/wallet/transactions", transaction_handler.GetWalletTransactions)
+	customerGroup.POST("/wallet/transfer", transaction_handler.TransferWallet)
+	customerGroup.POST("/wallet/transfer/:id/confirm", transaction_handler.ConfirmTransfer)
 	customerGroup.GET("/booking/report", report_handler_user.GetBookingReport)
+	customerGroup.GET("/receipts", receipt_handler.GetCustomerReceipts)
+	customerGroup.GET("/receipts/:id", receipt_handler.GetCustomerReceipt)
+	customerGroup.GET("/loyalty", loyalty_handler.GetLoyalty)
+	customerGroup.GET("/membership", membership_handler.GetMyMembership)
+	customerGroup.GET("/membership/plans", membership_handler.GetMembershipPlans)
+	customerGroup.POST("/membership/purchase", membership_handler.PurchaseMembership)
+	customerGroup.PUT("/membership/auto-renew", membership_handler.UpdateAutoRenew)
+	customerGroup.GET("/time-balance", time_package_handler.GetTimeBalance)
 
 	// protected routes for admin using JWT middleware
 	adminGroup := e.Group("/admin")
 	adminGroup.Use(cust_middleware.JWTMiddleware)
 
 	adminGroup.POST("/rental", rental_handler.RentComputer)
+	adminGroup.POST("/rental/quote", rental_handler.QuoteRental)
 	adminGroup.POST("/service/purchase", service_handler.PurchaseService)
+	adminGroup.POST("/service/quote", service_handler.QuoteService)
+	adminGroup.GET("/services", service_handler.GetServices)
+	adminGroup.POST("/services", service_handler.CreateService)
+	adminGroup.PUT("/services/:id", service_handler.UpdateService)
+	adminGroup.DELETE("/services/:id", service_handler.DeactivateService)
+	adminGroup.GET("/bundles", bundle_handler.GetBundles)
+	adminGroup.POST("/bundles", bundle_handler.CreateBundle)
+	adminGroup.PUT("/bundles/:id", bundle_handler.UpdateBundle)
+	adminGroup.DELETE("/bundles/:id", bundle_handler.DeactivateBundle)
+	adminGroup.POST("/services/:id/restock", service_handler.RestockService)
+	adminGroup.POST("/services/:id/write-off", service_handler.WriteOffService)
+	adminGroup.GET("/inventory/movements", inventory_handler.GetInventoryMovements)
+	adminGroup.GET("/inventory/low-stock", inventory_handler.GetLowStock)
+	adminGroup.GET("/dashboard", dashboard_handler.GetDashboard)
+	adminGroup.GET("/audit-events", audit_handler.SearchAuditEvents)
+	adminGroup.GET("/notifications", notification_handler.GetNotifications)
+	adminGroup.PUT("/notifications/:id/read", notification_handler.MarkNotificationRead)
+	adminGroup.GET("/loans", loan_handler.GetLoans)
+	adminGroup.POST("/loans/:id/return", loan_handler.ReturnLoan)
 	adminGroup.POST("/report/revenue", report_handler_admin.GenerateRevenueReport)	
+	adminGroup.GET("/reports", report_handler_admin.GetReports)
+	adminGroup.GET("/report/schedules", report_handler_admin.GetReportSchedules)
+	adminGroup.POST("/report/schedules", report_handler_admin.CreateReportSchedule)
+	adminGroup.PUT("/report/schedules/:id", report_handler_admin.UpdateReportSchedule)
+	adminGroup.DELETE("/report/schedules/:id", report_handler_admin.DeleteReportSchedule)
+	adminGroup.GET("/reports/utilization", report_handler_admin.GetUtilizationReport)
+	adminGroup.GET("/reports/revenue-series", report_handler_admin.GetRevenueSeries)
+	adminGroup.GET("/reports/staff", report_handler_admin.GetStaffPerformance)
+	adminGroup.GET("/reports/:id", report_handler_admin.GetReport)
+	adminGroup.GET("/analytics/customers", report_handler_admin.GetCustomerAnalytics)
+	adminGroup.GET("/analytics/cohorts", report_handler_admin.GetCohortRetention)
+	adminGroup.GET("/analytics/churn-risk", report_handler_admin.GetChurnRisk)
+	adminGroup.GET("/receipts/:id", receipt_handler.GetReceipt)
+	adminGroup.POST("/receipts/:id/reprint", receipt_handler.ReprintReceipt)
+	adminGroup.POST("/shift/open", shift_handler.OpenShift)
+	adminGroup.POST("/shift/close", shift_handler.CloseShift)
+	adminGroup.GET("/shift/report", shift_handler.GetShiftReport)
+	adminGroup.POST("/payments/reconcile", transaction_handler.ReconcilePayments)
+	adminGroup.GET("/payments/reconciliation", transaction_handler.GetReconciliationReport)
+	adminGroup.GET("/tax-rules", tax_handler.GetTaxRules)
+	adminGroup.POST("/tax-rules", tax_handler.CreateTaxRule)
+	adminGroup.PUT("/tax-rules/:id", tax_handler.UpdateTaxRule)
+	adminGroup.GET("/vouchers", voucher_handler.GetVouchers)
+	adminGroup.POST("/vouchers", voucher_handler.CreateVoucher)
+	adminGroup.PUT("/vouchers/:id", voucher_handler.UpdateVoucher)
+	adminGroup.GET("/loyalty/earn-rates", loyalty_handler.GetEarnRates)
+	adminGroup.PUT("/loyalty/earn-rates", loyalty_handler.UpdateEarnRate)
+	adminGroup.GET("/membership-plans", membership_handler.GetAdminMembershipPlans)
+	adminGroup.POST("/membership-plans", membership_handler.CreateMembershipPlan)
+	adminGroup.PUT("/membership-plans/:id", membership_handler.UpdateMembershipPlan)
 
 	// swagger documentation
 	e.GET("/swagger/*", echoSwagger.WrapHandler)